@@ -9,6 +9,7 @@ import (
 	"echo-cli/internal/agent"
 	"echo-cli/internal/events"
 	"echo-cli/internal/prompts"
+	"echo-cli/internal/session"
 )
 
 func loadAttachments(paths []string, workdir string) []agent.Message {
@@ -31,20 +32,41 @@ func loadAttachments(paths []string, workdir string) []agent.Message {
 	return msgs
 }
 
-// Images are threaded as references so we avoid emitting binary blobs into the transcript.
-func loadImageAttachments(paths []string, workdir string) []agent.Message {
-	var msgs []agent.Message
+type imageAttachment struct {
+	path string
+	part agent.ContentPart
+}
+
+// loadImageFiles 读取并编码 --image 指定的图片；无法读取或格式不支持的图片记录警告后跳过。
+func loadImageFiles(paths []string, workdir string) []imageAttachment {
+	var images []imageAttachment
 	for _, p := range paths {
 		resolved := p
 		if !filepath.IsAbs(resolved) && workdir != "" {
 			resolved = filepath.Join(workdir, resolved)
 		}
+		part, err := agent.LoadImage(resolved)
+		if err != nil {
+			log.Warnf("image attachment skipped (%s): %v", p, err)
+			continue
+		}
+		images = append(images, imageAttachment{path: p, part: part})
+	}
+	return images
+}
+
+// loadImageAttachments 返回交互模式的转录提示与待发送的图片 data URL；转录中不写入图片数据。
+func loadImageAttachments(paths []string, workdir string) ([]agent.Message, []string) {
+	var msgs []agent.Message
+	var urls []string
+	for _, img := range loadImageFiles(paths, workdir) {
 		msgs = append(msgs, agent.Message{
 			Role:    agent.RoleUser,
-			Content: fmt.Sprintf("Image attachment: %s (resolved: %s)", p, resolved),
+			Content: fmt.Sprintf("Image attachment: %s (%s)", img.path, img.part.MediaType),
 		})
+		urls = append(urls, img.part.DataURL())
 	}
-	return msgs
+	return msgs, urls
 }
 
 // attachmentMessages 加载附件并返回 InputMessage 格式
@@ -68,17 +90,14 @@ func attachmentMessages(paths []string, workdir string) []events.InputMessage {
 	return msgs
 }
 
-// imageAttachmentMessages 加载图片附件引用并返回 InputMessage 格式
+// imageAttachmentMessages 加载图片附件并返回带图片内容的 InputMessage
 func imageAttachmentMessages(paths []string, workdir string) []events.InputMessage {
 	var msgs []events.InputMessage
-	for _, p := range paths {
-		resolved := p
-		if !filepath.IsAbs(resolved) && workdir != "" {
-			resolved = filepath.Join(workdir, resolved)
-		}
+	for _, img := range loadImageFiles(paths, workdir) {
 		msgs = append(msgs, events.InputMessage{
 			Role:    "user",
-			Content: fmt.Sprintf("Image attachment: %s (resolved: %s)", p, resolved),
+			Content: "Image attachment: " + img.path,
+			Images:  []string{img.part.DataURL()},
 		})
 	}
 	return msgs
@@ -86,27 +105,7 @@ func imageAttachmentMessages(paths []string, workdir string) []events.InputMessa
 
 // extractConversationHistory 从历史消息中提取纯对话内容，过滤掉系统注入的内容
 func extractConversationHistory(messages []agent.Message) []agent.Message {
-	var filtered []agent.Message
-	for _, msg := range messages {
-		// 过滤 UI 可见但不应喂给模型的角色（例如 tool block）。
-		// Anthropic/OpenAI 消息角色通常仅支持 system/user/assistant。
-		if msg.Role != agent.RoleSystem && msg.Role != agent.RoleUser && msg.Role != agent.RoleAssistant {
-			continue
-		}
-		// 跳过系统注入的内容
-		if msg.Role == agent.RoleSystem {
-			// 跳过输出格式定义
-			if strings.HasPrefix(msg.Content, prompts.OutputSchemaPrefix) {
-				continue
-			}
-			// 跳过审查模式提示词
-			if msg.Content == prompts.ReviewModeSystemPrompt {
-				continue
-			}
-		}
-		filtered = append(filtered, msg)
-	}
-	return filtered
+	return session.ConversationMessages(messages)
 }
 
 // hasOutputSchema 检查历史中是否已包含输出格式定义（保留原函数以兼容性）
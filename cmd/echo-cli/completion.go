@@ -36,13 +36,13 @@ _echo_cli_completions()
             return 0
             ;;
         exec)
-            COMPREPLY=( $(compgen -W "--config --model --m --provider --cd --prompt --session --resume-last --list-sessions --run --apply-patch --attach --image --timeout --retries --profile --oss --local-provider --output-schema --color --json --output-last-message --c --skip-git-repo-check" -- "$cur") )
+            COMPREPLY=( $(compgen -W "--config --model --m --provider --cd --prompt --session --resume-last --list-sessions --run --apply-patch --attach --image --timeout --retries --profile --oss --local-provider --script --output-schema --output-schema-repairs --color --json --output-last-message --c --ask-for-approval --approval-mode --approval-rules --sandbox --skip-git-repo-check --undo-last --compact" -- "$cur") )
             ;;
         ping)
-            COMPREPLY=( $(compgen -W "--config --provider --model --base-url --api-key --timeout --c" -- "$cur") )
+            COMPREPLY=( $(compgen -W "--config --provider --model --profile --base-url --api-key --timeout --c" -- "$cur") )
             ;;
         *)
-            COMPREPLY=( $(compgen -W "--config --model --m --provider --reasoning-effort --cd --C --prompt --profile --oss --local-provider --search --attach --image --c --timeout --retries" -- "$cur") )
+            COMPREPLY=( $(compgen -W "--config --model --m --provider --reasoning-effort --cd --C --prompt --profile --oss --local-provider --ask-for-approval --sandbox --search --attach --image --c --timeout --retries" -- "$cur") )
             ;;
     esac
 }
@@ -79,22 +79,30 @@ _echo_cli() {
                 '--profile[Config profile]' \
                 '--oss[Use OSS provider]' \
                 '--local-provider[Which OSS provider to use]' \
+                '--script[JSONL script of turns with assertions]' \
                 '--output-schema[Schema file for structured output]' \
+                '--output-schema-repairs[Repair turns allowed for --output-schema]' \
                 '--color[Color output]' \
                 '--json[Emit JSON events]' \
                 '--output-last-message[Write last message to a file]' \
                 '--attach[Attach a file into context]' \
                 '--image[Attach an image into context]' \
+                '--sandbox[Sandbox mode for commands]' \
+                '--approval-mode[How to answer approval requests]' \
+                '--approval-rules[Approval rules file]' \
                 '--c[Config key=value override]' \
                 '--timeout[Request timeout seconds]' \
                 '--retries[Retry count on request failure]' \
-                '--skip-git-repo-check[Skip git repo validation]'
+                '--skip-git-repo-check[Skip git repo validation]' \
+                '--undo-last[Undo the last ghost snapshot and exit]' \
+                '--compact[Compact the session history and exit]'
             ;;
         ping)
             _arguments \
                 '--config[Path to config file]' \
                 '--provider[Provider name]' \
                 '--model[Model name]' \
+                '--profile[Config profile]' \
                 '--base-url[Override base URL]' \
                 '--api-key[Override API key]' \
                 '--timeout[Timeout seconds]' \
@@ -114,6 +122,7 @@ _echo_cli() {
                 '--oss[Use OSS provider]' \
                 '--local-provider[Which OSS provider to use]' \
                 '--search[Enable web search]' \
+                '--sandbox[Sandbox mode for commands]' \
                 '--attach[Attach a file into context]' \
                 '--image[Attach an image into context]' \
                 '--c[Config key=value override]' \
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,7 +19,10 @@ import (
 	"echo-cli/internal/execution"
 	"echo-cli/internal/history"
 	"echo-cli/internal/instructions"
+	"echo-cli/internal/jsonschema"
+	"echo-cli/internal/prompts"
 	"echo-cli/internal/repl"
+	"echo-cli/internal/search"
 	"echo-cli/internal/session"
 	"echo-cli/internal/tools"
 	"echo-cli/internal/tools/dispatcher"
@@ -27,35 +31,8 @@ import (
 	"github.com/google/uuid"
 )
 
-type jsonEvent struct {
-	Type      string      `json:"type"`
-	ThreadID  string      `json:"thread_id,omitempty"`
-	SessionID string      `json:"session_id,omitempty"`
-	Item      *eventItem  `json:"item,omitempty"`
-	Usage     *usage      `json:"usage,omitempty"`
-	Error     *eventError `json:"error,omitempty"`
-}
-
-type eventItem struct {
-	ID       string `json:"id"`
-	Type     string `json:"type"`
-	Status   string `json:"status,omitempty"`
-	Text     string `json:"text,omitempty"`
-	Command  string `json:"command,omitempty"`
-	Path     string `json:"path,omitempty"`
-	ExitCode *int   `json:"exit_code,omitempty"`
-	Kind     string `json:"kind,omitempty"`
-}
-
-type eventError struct {
-	Message string `json:"message"`
-}
-
-type usage struct {
-	InputTokens       int64 `json:"input_tokens"`
-	CachedInputTokens int64 `json:"cached_input_tokens"`
-	OutputTokens      int64 `json:"output_tokens"`
-}
+// exitOutputSchemaFailed 是最终回复在修复轮次后仍不符合 --output-schema 时的退出码。
+const exitOutputSchemaFailed = 3
 
 var encodeMu sync.Mutex
 
@@ -83,17 +60,25 @@ func execMain(root rootArgs, args []string) {
 	var runCmd string
 	var applyPatch string
 	var reasoningOverride string
+	var approvalPolicy string
+	var approvalModeFlag string
+	var approvalRulesPath string
+	var sandboxMode string
 	var timeoutOverride int
 	var retriesOverride int
 	var configProfile string
 	var oss bool
 	var localProvider string
 	var outputSchema string
+	var scriptPath string
+	var outputSchemaRepairs int
 	var colorMode string
 	var jsonOutput bool
 	var lastMessageFile string
 	var workdir string
 	var skipGitRepoCheck bool
+	var undoLast bool
+	var compact bool
 
 	fs.StringVar(&cfgPath, "config", "", "Path to config file (default ~/.echo/config.toml)")
 	fs.StringVar(&modelOverride, "model", "", "Model override")
@@ -105,6 +90,12 @@ func execMain(root rootArgs, args []string) {
 	fs.Var(&imagePaths, "image", "Attach an image into initial context (repeatable)")
 	fs.Var(&configOverrides, "c", "Override config value key=value (repeatable)")
 	fs.StringVar(&reasoningOverride, "reasoning-effort", "", "Reasoning effort hint")
+	fs.StringVar(&approvalPolicy, "ask-for-approval", "", "Approval policy (never|on-request|on-failure|untrusted|always; default never)")
+	fs.StringVar(&approvalPolicy, "a", "", "Alias for --ask-for-approval")
+	fs.StringVar(&approvalModeFlag, "approval-mode", "deny", "How to answer approval requests (deny|approve|stdin: read JSON decisions from stdin)")
+	fs.StringVar(&approvalRulesPath, "approval-rules", "", "TOML file with allow/deny command and path rules, merged into [approvals]")
+	fs.StringVar(&sandboxMode, "sandbox", "", "Sandbox mode for commands (read-only|workspace-write|full-access)")
+	fs.StringVar(&sandboxMode, "s", "", "Alias for --sandbox")
 	fs.StringVar(&prompt, "prompt", "", "Prompt")
 	fs.StringVar(&sessionID, "session", "", "Session id to resume")
 	fs.BoolVar(&resumeLast, "resume-last", false, "Resume most recent session")
@@ -114,13 +105,17 @@ func execMain(root rootArgs, args []string) {
 	fs.StringVar(&configProfile, "profile", "", "Config profile to use")
 	fs.BoolVar(&oss, "oss", false, "Use open-source/local provider")
 	fs.StringVar(&localProvider, "local-provider", "", "Local OSS provider (lmstudio|ollama)")
-	fs.StringVar(&outputSchema, "output-schema", "", "Path to JSON Schema describing expected final output")
+	fs.StringVar(&scriptPath, "script", "", "JSONL file of user turns with assertions to run in one session (- reads steps from stdin as they arrive)")
+	fs.StringVar(&outputSchema, "output-schema", "", "Path to JSON Schema the final message must satisfy")
+	fs.IntVar(&outputSchemaRepairs, "output-schema-repairs", 2, "Repair turns allowed when the final message fails --output-schema")
 	fs.StringVar(&colorMode, "color", "auto", "Color output (auto|always|never)")
 	fs.BoolVar(&jsonOutput, "json", false, "Print events to stdout as JSONL")
 	fs.StringVar(&lastMessageFile, "output-last-message", "", "Write last assistant message to file")
 	fs.IntVar(&timeoutOverride, "timeout", 0, "Request timeout seconds")
 	fs.IntVar(&retriesOverride, "retries", 0, "Retry count on request failure")
 	fs.BoolVar(&skipGitRepoCheck, "skip-git-repo-check", false, "Allow running outside a git repository (placeholder)")
+	fs.BoolVar(&undoLast, "undo-last", false, "Restore the workspace to the last ghost snapshot of the session (--session or most recent) and exit")
+	fs.BoolVar(&compact, "compact", false, "Compact the session history (--session or most recent) and exit; the prompt, if any, is used as the focus hint")
 
 	if err := fs.Parse(args); err != nil {
 		log.Fatalf("parse exec args: %v", err)
@@ -149,10 +144,29 @@ func execMain(root rootArgs, args []string) {
 		return
 	}
 	reviewMode := subcommand == "review"
-	if prompt == "" && sessionID == "" && !resumeLast {
+	approvalMode, err := parseExecApprovalMode(approvalModeFlag)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if (undoLast || compact) && sessionID == "" {
+		resumeLast = true
+	}
+	if scriptPath != "" {
+		switch {
+		case strings.TrimSpace(prompt) != "":
+			log.Fatalf("--script cannot be combined with a prompt; add it as the first step")
+		case outputSchema != "":
+			log.Fatalf("--script cannot be combined with --output-schema")
+		case scriptPath == "-" && approvalMode == execApprovalStdin:
+			log.Fatalf("--script - and --approval-mode stdin both read stdin; use a script file")
+		case undoLast || compact:
+			log.Fatalf("--script cannot be combined with --undo-last or --compact")
+		}
+	}
+	if prompt == "" && sessionID == "" && !resumeLast && scriptPath == "" {
 		log.Fatalf("prompt is required for exec unless resuming a session")
 	}
-	if strings.TrimSpace(prompt) != "" {
+	if strings.TrimSpace(prompt) != "" && !undoLast && !compact {
 		if hs, err := history.NewDefault(); err == nil {
 			if err := hs.Append(prompt); err != nil {
 				log.Warnf("append history failed: %v", err)
@@ -170,9 +184,19 @@ func execMain(root rootArgs, args []string) {
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
-	endpoint = config.ApplyKVOverrides(endpoint, []string(configOverrides))
+	endpoint, profileOverrides := applyConfigProfile(endpoint, configProfile)
+	echocontext.SetModelCatalog(modelCatalog(endpoint))
+	search.SetIgnorePatterns(endpoint.Search.Ignore)
+	if approvalRulesPath != "" {
+		extra, err := loadApprovalRulesFile(approvalRulesPath)
+		if err != nil {
+			log.Fatalf("failed to load approval rules: %v", err)
+		}
+		endpoint.Approvals = mergeApprovalRules(endpoint.Approvals, extra)
+	}
+	endpoint = selectProvider(endpoint, providerFlags{provider: providerOverride, oss: oss, localProvider: localProvider}, []string(configOverrides))
 
-	rt := defaultRuntimeConfig()
+	rt := applyRuntimeKVOverrides(defaultRuntimeConfig(), profileOverrides)
 	if strings.TrimSpace(endpoint.Model) != "" {
 		rt.Model = strings.TrimSpace(endpoint.Model)
 	}
@@ -182,6 +206,12 @@ func execMain(root rootArgs, args []string) {
 	if strings.TrimSpace(reasoningOverride) != "" {
 		rt.ReasoningEffort = strings.TrimSpace(reasoningOverride)
 	}
+	if strings.TrimSpace(approvalPolicy) != "" {
+		rt.ApprovalPolicy = strings.TrimSpace(approvalPolicy)
+	}
+	if strings.TrimSpace(sandboxMode) != "" {
+		rt.SandboxMode = strings.TrimSpace(sandboxMode)
+	}
 	if timeoutOverride > 0 {
 		rt.RequestTimeoutSecs = timeoutOverride
 	}
@@ -189,40 +219,47 @@ func execMain(root rootArgs, args []string) {
 		rt.Retries = retriesOverride
 	}
 	rt = applyRuntimeKVOverrides(rt, []string(configOverrides))
-	if strings.TrimSpace(providerOverride) != "" {
-		log.Warnf("provider override %q is ignored; echo-cli now configures only url/token/model", providerOverride)
-	}
-	if strings.TrimSpace(configProfile) != "" {
-		log.Warnf("config profile %q is ignored; echo-cli now configures only url/token/model", configProfile)
-	}
-	if !oss && strings.TrimSpace(localProvider) != "" {
-		log.Warnf("local-provider=%q ignored unless --oss is set", localProvider)
-	}
+	configOverrides = stringSlice(withProfileOverrides(profileOverrides, []string(configOverrides)))
 	if skipGitRepoCheck {
 		log.Info("skip-git-repo-check requested (no-op placeholder)")
 	}
 
 	workdir = resolveWorkdir(workdir)
-	client := buildModelClient(endpoint, rt.Model, oss)
+	client := buildModelClient(endpoint, rt.Model)
 	system := instructions.Discover(workdir)
 	outputSchemaContent := ""
+	var schema *jsonschema.Schema
 	if outputSchema != "" {
 		schemaPath := outputSchema
 		if !filepath.IsAbs(schemaPath) && workdir != "" {
 			schemaPath = filepath.Join(workdir, schemaPath)
 		}
-		if data, err := os.ReadFile(schemaPath); err != nil {
-			log.Warnf("attachment read failed (%s): %v", outputSchema, err)
-		} else {
-			outputSchemaContent = string(data)
+		data, err := os.ReadFile(schemaPath)
+		if err != nil {
+			log.Fatalf("failed to read output schema (%s): %v", outputSchema, err)
+		}
+		if schema, err = jsonschema.Compile(data); err != nil {
+			log.Fatalf("invalid output schema (%s): %v", outputSchema, err)
 		}
+		outputSchemaContent = string(data)
 	}
 	runner := tools.DirectRunner{}
 	bus := events.NewBus()
 	defer bus.Close()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	disp := dispatcher.New(runner, bus, workdir, dispatcher.Options{})
+	mcpManager := connectMCPServers(context.Background(), endpoint, []string(configOverrides))
+	defer mcpManager.Close()
+	// exec 无人值守，默认不请求审批；deny 规则仍然生效。
+	policy, rules := approvalOptions(rt, endpoint, tools.ApprovalNever)
+	disp := dispatcher.New(runner, bus, workdir, dispatcher.Options{
+		Handlers: mcpManager.Handlers(),
+		Reviewer: commandReviewer(client, rt.Model, policy),
+		Policy:   policy,
+		Rules:    rules,
+		Memory:   tools.NewApprovalMemory(tools.NewProjectApprovals(workdir)),
+		Sandbox:  sandboxPolicy(rt, endpoint, workdir),
+	})
 	disp.Start(ctx)
 
 	emit := func(ev jsonEvent) {
@@ -241,10 +278,12 @@ func execMain(root rootArgs, args []string) {
 		Manager:        manager,
 		Client:         client,
 		Bus:            bus,
-		Defaults:       echocontext.SessionDefaults{Model: rt.Model, System: system, OutputSchema: outputSchemaContent, ReasoningEffort: rt.ReasoningEffort, ReviewMode: reviewMode, Language: rt.DefaultLanguage},
+		Defaults:       echocontext.SessionDefaults{Model: rt.Model, System: system, OutputSchema: outputSchemaContent, ReasoningEffort: rt.ReasoningEffort, ReviewMode: reviewMode, Language: rt.DefaultLanguage, Tools: sessionTools(mcpManager, []string(configOverrides)), Sampling: rt.Sampling},
 		ToolTimeout:    toolTimeout,
 		RequestTimeout: time.Duration(rt.RequestTimeoutSecs) * time.Second,
 		Retries:        rt.Retries,
+		Snapshots:      ghostSnapshotter(workdir, []string(configOverrides)),
+		OnRecord:       recordSession(disp.ApprovalMemory(), workdir),
 	})
 	engine.Start(ctx)
 	defer engine.Close()
@@ -258,40 +297,33 @@ func execMain(root rootArgs, args []string) {
 		})
 	}
 
-	// 提取纯对话历史（不包含系统注入的内容）
-	history := []agent.Message{}
+	var resumed *session.Record
 	if sessionID != "" {
 		rec, err := session.Load(sessionID)
 		if err != nil {
 			log.Fatalf("failed to load session %s: %v", sessionID, err)
 		}
-		// 只提取对话历史，过滤掉系统注入的内容
-		history = extractConversationHistory(rec.Messages)
+		resumed = &rec
 	} else if resumeLast {
 		rec, err := session.Last()
 		if err != nil {
 			log.Fatalf("failed to resume last session: %v", err)
 		}
-		// 只提取对话历史，过滤掉系统注入的内容
-		history = extractConversationHistory(rec.Messages)
+		resumed = &rec
 		sessionID = rec.ID
 	}
-
-	seedSessionID := sessionID
-	if seedSessionID == "" && len(history) > 0 {
-		seedSessionID = uuid.NewString()
-	}
-	if seedSessionID != "" && len(history) > 0 {
-		engine.SeedHistory(seedSessionID, history)
-		if sessionID == "" {
-			sessionID = seedSessionID
-		}
-	}
-
 	if sessionID == "" {
 		sessionID = uuid.NewString()
 	}
 
+	// history 是 exec 的对话转录（不包含系统注入的内容）；模型上下文由保存的 ResponseItem 原样重建。
+	history := []agent.Message{}
+	if resumed != nil {
+		history = extractConversationHistory(resumed.Messages)
+		engine.RestoreSession(sessionID, resumed.Items, resumed.Turns)
+		disp.ApprovalMemory().Seed(sessionID, resumed.Approvals)
+	}
+
 	threadID := sessionID
 	if threadID == "" {
 		threadID = fmt.Sprintf("thread-%d", time.Now().UnixNano())
@@ -304,13 +336,37 @@ func execMain(root rootArgs, args []string) {
 		if ev.SessionID == "" {
 			ev.SessionID = sessionID
 		}
+		ev.SchemaVersion = execJSONSchemaVersion
 		emit(ev)
 	}
 
-	// In JSON mode, mirror tool/approval events from the tool bus as JSONL too.
-	// In human mode, tool events should be rendered via EQ so the output stays coherent.
-	if jsonOutput {
-		go forwardBusEvents(bus.Subscribe(), emitEvent)
+	if undoLast {
+		if !runUndoLast(ctx, gateway, engine, disp.ApprovalMemory(), sessionID, workdir, history, emitEvent) {
+			os.Exit(1)
+		}
+		return
+	}
+	if compact {
+		if !runCompact(ctx, gateway, engine, disp.ApprovalMemory(), sessionID, workdir, rt.Model, prompt, history, emitEvent) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 审批请求按 --approval-mode 回答，避免无人值守时回合阻塞在等待审批上。
+	approver := newExecApprover(approvalMode)
+	if approvalMode == execApprovalStdin {
+		go approver.ReadDecisions(os.Stdin, func(err error) {
+			log.Warnf("%v", err)
+			emitEvent(jsonEvent{Type: "error", Error: &eventError{Message: err.Error()}})
+		})
+	}
+	resolveApproval := func(op events.ApprovalDecisionOperation) {
+		if _, err := gateway.SubmitApprovalDecision(ctx, sessionID, op); err != nil {
+			log.Warnf("submit approval decision failed: %v", err)
+		}
+		approver.Resolved(op.ApprovalID)
+		emitEvent(approvalResolvedEvent(op, approvalMode))
 	}
 
 	// 准备附件内容
@@ -336,101 +392,234 @@ func execMain(root rootArgs, args []string) {
 		}
 	}
 
-	itemID := "item_0"
-	summaryID := "summary_0"
 	emitEvent(jsonEvent{Type: "thread.started"})
 
 	engineEvents := gateway.Events()
-	subID, err := gateway.SubmitUserInput(ctx, []events.InputMessage{
-		{Role: "user", Content: prompt},
-	}, events.InputContext{
-		SessionID:       sessionID,
-		Model:           rt.Model,
-		System:          system,
-		OutputSchema:    outputSchemaContent,
-		Language:        rt.DefaultLanguage,
-		ReasoningEffort: rt.ReasoningEffort,
-		ReviewMode:      reviewMode,
-		Attachments:     attachments,
-	})
-	if err != nil {
-		emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: err.Error()}})
-		log.Fatalf("submit turn failed: %v", err)
-	}
-
-	var answerBuilder strings.Builder
+	// 以下状态在每个回合开始时重置：每个回合各自输出 turn.started 与带本回合用量的 turn.completed。
 	turnStarted := false
-	answer := ""
+	// turnCalls 记录当前回合已结束的工具调用，供 --script 断言使用。
+	var turnCalls []tools.ToolResult
+	// reported 是本回合最后一次任务总结中回报的实际用量；缺失时退回按文本估算。
+	var reported agent.TokenUsage
+	hasReported := false
+	var lastTurnUsage usage
+	completeTurn := func() {
+		u := lastTurnUsage
+		emitEvent(jsonEvent{Type: "turn.completed", Usage: &u})
+	}
+
+	// runTurn 提交一条用户输入并等待任务结束，返回最终回复；失败时返回 false。
+	runTurn := func(turn int, input string, attachments []events.InputMessage) (string, bool) {
+		itemID := fmt.Sprintf("item_%d", turn)
+		summaryID := fmt.Sprintf("summary_%d", turn)
+		reasoningID := fmt.Sprintf("reasoning_%d", turn)
+		turnStarted = false
+		turnCalls = nil
+		reported, hasReported = agent.TokenUsage{}, false
+		subID, err := gateway.SubmitUserInput(ctx, []events.InputMessage{
+			{Role: "user", Content: input},
+		}, events.InputContext{
+			SessionID:       sessionID,
+			Model:           rt.Model,
+			System:          system,
+			OutputSchema:    outputSchemaContent,
+			Language:        rt.DefaultLanguage,
+			ReasoningEffort: rt.ReasoningEffort,
+			ReviewMode:      reviewMode,
+			Attachments:     attachments,
+		})
+		if err != nil {
+			emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: err.Error()}})
+			log.Fatalf("submit turn failed: %v", err)
+		}
 
-	for done := false; !done; {
-		select {
-		case <-ctx.Done():
-			emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: "context canceled"}})
-			log.Fatalf("exec cancelled")
-		case ev := <-engineEvents:
-			if ev.SubmissionID != subID {
-				continue
-			}
-			if eqRenderer != nil {
-				eqRenderer.Handle(ev)
-			}
-			switch ev.Type {
-			case events.EventTaskStarted:
-				if !turnStarted {
-					emitEvent(jsonEvent{Type: "turn.started"})
-					emitEvent(jsonEvent{Type: "item.started", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "in_progress"}})
-					turnStarted = true
-				}
-			case events.EventTaskSummary:
-				summary, ok := ev.Payload.(events.TaskSummary)
-				if !ok {
+		var answerBuilder strings.Builder
+		answer := ""
+		itemStarted := false
+		for {
+			select {
+			case <-ctx.Done():
+				emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: "context canceled"}})
+				log.Fatalf("exec cancelled")
+			case op := <-approver.Decisions():
+				resolveApproval(op)
+			case ev := <-engineEvents:
+				// 工具事件按 call id 关联提交，关联失败时 SubmissionID 为空；exec 只有一个会话，照样输出。
+				if ev.SubmissionID != subID && (ev.Type != events.EventToolEvent || ev.SubmissionID != "") {
 					continue
 				}
-				text := strings.TrimSpace(summary.Text)
-				if text != "" {
-					emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: summaryID, Type: "task_summary", Status: "completed", Text: text}})
+				if eqRenderer != nil {
+					eqRenderer.Handle(ev)
 				}
-			case events.EventAgentOutput:
-				msg, ok := ev.Payload.(events.AgentOutput)
-				if !ok {
-					continue
-				}
-				if msg.Final {
-					finalText := msg.Content
-					if finalText == "" {
-						finalText = answerBuilder.String()
-					} else {
-						answerBuilder.Reset()
-						answerBuilder.WriteString(finalText)
-					}
-					answer = answerBuilder.String()
+				switch ev.Type {
+				case events.EventTaskStarted:
 					if !turnStarted {
 						emitEvent(jsonEvent{Type: "turn.started"})
 						turnStarted = true
 					}
-					emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "completed", Text: answer}})
-					continue
-				}
-				if msg.Content != "" {
-					answerBuilder.WriteString(msg.Content)
-					emitEvent(jsonEvent{Type: "item.updated", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "in_progress", Text: msg.Content}})
-				}
-			case events.EventTaskCompleted:
-				if answer == "" {
-					answer = answerBuilder.String()
+					if !itemStarted {
+						emitEvent(jsonEvent{Type: "item.started", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "in_progress"}})
+						itemStarted = true
+					}
+				case events.EventTaskSummary:
+					summary, ok := ev.Payload.(events.TaskSummary)
+					if !ok {
+						continue
+					}
+					// 每次模型调用后都会发出任务总结，其中的用量是本次提交的累计值，取最后一次即可。
+					if summary.InputTokens+summary.CachedInputTokens > 0 {
+						reported = agent.TokenUsage{
+							InputTokens:              summary.InputTokens,
+							OutputTokens:             summary.OutputTokens,
+							CacheCreationInputTokens: summary.CacheCreationInputTokens,
+							CacheReadInputTokens:     summary.CacheReadInputTokens,
+						}
+						hasReported = true
+					}
+					if u := summaryUsage(summary); u != nil {
+						emitEvent(jsonEvent{Type: "usage.updated", Usage: u})
+					}
+					text := strings.TrimSpace(summary.Text)
+					if text != "" {
+						emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: summaryID, Type: "task_summary", Status: "completed", Text: text}})
+					}
+				case events.EventToolEvent:
+					// 人类可读模式下工具事件由 EQ 渲染器输出，这里只补充 JSONL。
+					toolEvt, isTool := ev.Payload.(tools.ToolEvent)
+					if !isTool {
+						continue
+					}
+					if toolEvt.Type == "item.completed" {
+						turnCalls = append(turnCalls, toolEvt.Result)
+					}
+					if jsonOutput {
+						if jsonEvt, mapped := toolEventToJSON(toolEvt); mapped {
+							emitEvent(jsonEvt)
+						}
+					}
+					if res := toolEvt.Result; toolEvt.Type == "item.updated" && res.Status == "requires_approval" && res.ApprovalID != "" {
+						emitEvent(approvalRequestedEvent(res, approvalMode))
+						if op, decided := approver.Request(res); decided {
+							resolveApproval(op)
+						}
+					}
+				case events.EventAgentReasoning:
+					msg, ok := ev.Payload.(events.AgentReasoning)
+					if !ok || msg.Content == "" {
+						continue
+					}
+					emitEvent(jsonEvent{Type: "item.updated", Item: &eventItem{ID: reasoningID, Type: "reasoning", Status: "in_progress", Text: msg.Content}})
+				case events.EventAgentOutput:
+					msg, ok := ev.Payload.(events.AgentOutput)
+					if !ok {
+						continue
+					}
+					if msg.Final {
+						finalText := msg.Content
+						if finalText == "" {
+							finalText = answerBuilder.String()
+						} else {
+							answerBuilder.Reset()
+							answerBuilder.WriteString(finalText)
+						}
+						answer = answerBuilder.String()
+						if !turnStarted {
+							emitEvent(jsonEvent{Type: "turn.started"})
+							turnStarted = true
+						}
+						emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "completed", Text: answer}})
+						continue
+					}
+					if msg.Content != "" {
+						answerBuilder.WriteString(msg.Content)
+						emitEvent(jsonEvent{Type: "item.updated", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "in_progress", Text: msg.Content}})
+					}
+				case events.EventTaskCompleted:
+					if answer == "" {
+						answer = answerBuilder.String()
+					}
+					lastTurnUsage = turnUsage(reported, hasReported, input, answer)
+					return answer, true
+				case events.EventError:
+					errMsg := fmt.Sprint(ev.Payload)
+					emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "failed", Text: errMsg}})
+					emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: errMsg}})
+					return answer, false
 				}
-				done = true
-			case events.EventError:
-				errMsg := fmt.Sprint(ev.Payload)
-				emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: itemID, Type: "agent_message", Status: "failed", Text: errMsg}})
-				emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: errMsg}})
-				done = true
 			}
 		}
 	}
 
-	if answer != "" {
-		history = append(history, agent.Message{Role: agent.RoleAssistant, Content: answer})
+	var answer string
+	ok := true
+	scriptFailed := false
+	if scriptPath != "" {
+		// --script：每行一个用户回合，在同一会话中依次执行并检查断言；附件随第一步发送。
+		var src io.Reader = os.Stdin
+		if scriptPath != "-" {
+			f, err := os.Open(scriptPath)
+			if err != nil {
+				log.Fatalf("failed to open script: %v", err)
+			}
+			defer f.Close()
+			src = f
+		}
+		turn := 0
+		summary, last := runExecScript(src, func(input string) scriptOutcome {
+			stepAnswer, stepOK := runTurn(turn, input, attachments)
+			if stepOK {
+				completeTurn()
+			}
+			turn++
+			attachments = nil
+			history = append(history, agent.Message{Role: agent.RoleUser, Content: input})
+			if stepAnswer != "" {
+				history = append(history, agent.Message{Role: agent.RoleAssistant, Content: stepAnswer})
+			}
+			return scriptOutcome{Answer: stepAnswer, OK: stepOK, Calls: turnCalls}
+		}, emitEvent)
+		answer = last
+		scriptFailed = summary.Failed > 0
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "script: %d steps, %d passed, %d failed\n", summary.Steps, summary.Passed, summary.Failed)
+		}
+	} else {
+		answer, ok = runTurn(0, prompt, attachments)
+		history = append(history, agent.Message{Role: agent.RoleUser, Content: prompt})
+		if answer != "" {
+			history = append(history, agent.Message{Role: agent.RoleAssistant, Content: answer})
+		}
+	}
+
+	// --output-schema：校验最终回复，不符合时把错误交给模型修复，最多 outputSchemaRepairs 轮。
+	var structured json.RawMessage
+	schemaFailure := ""
+	if schema != nil && !ok {
+		schemaFailure = "turn failed before producing output for --output-schema"
+	} else if schema != nil {
+		for turn := 1; ; turn++ {
+			value, problems := checkStructuredOutput(schema, answer)
+			if len(problems) == 0 {
+				structured = value
+				break
+			}
+			emitEvent(jsonEvent{Type: "item.completed", Item: &eventItem{ID: fmt.Sprintf("schema_%d", turn-1), Type: "output_schema", Status: "failed", Text: strings.Join(problems, "\n")}})
+			if turn > outputSchemaRepairs {
+				schemaFailure = fmt.Sprintf("final message does not match --output-schema after %d repair turn(s): %s", outputSchemaRepairs, strings.Join(problems, "; "))
+				break
+			}
+			completeTurn()
+			repair := prompts.BuildOutputSchemaRepair(problems)
+			answer, ok = runTurn(turn, repair, nil)
+			history = append(history, agent.Message{Role: agent.RoleUser, Content: repair})
+			if answer != "" {
+				history = append(history, agent.Message{Role: agent.RoleAssistant, Content: answer})
+			}
+			if !ok {
+				schemaFailure = "output schema repair turn failed"
+				break
+			}
+		}
 	}
 
 	if runCmd != "" {
@@ -460,8 +649,23 @@ func execMain(root rootArgs, args []string) {
 		}
 	}
 
-	turnUsage := calcUsage(history)
-	emitEvent(jsonEvent{Type: "turn.completed", Usage: &turnUsage})
+	if schemaFailure != "" {
+		if ok {
+			emitEvent(jsonEvent{Type: "turn.failed", Error: &eventError{Message: schemaFailure}})
+		}
+		saveExecSession(engine, disp.ApprovalMemory(), sessionID, workdir, history)
+		fmt.Fprintf(os.Stderr, "error: %s\n", schemaFailure)
+		os.Exit(exitOutputSchemaFailed)
+	}
+
+	if structured != nil {
+		emitEvent(jsonEvent{Type: "output.structured", Output: structured})
+		answer = string(structured)
+	}
+	// 脚本步骤已逐个输出 turn.completed；其他情况下最后一个回合在 --run/--apply-patch 与结构化输出之后结束。
+	if scriptPath == "" && ok {
+		completeTurn()
+	}
 
 	if lastMessageFile != "" {
 		if err := os.WriteFile(lastMessageFile, []byte(answer), 0o644); err != nil {
@@ -469,84 +673,114 @@ func execMain(root rootArgs, args []string) {
 		}
 	}
 
-	savedID, err := session.Save(sessionID, workdir, history)
-	if err != nil {
-		log.Warnf("failed to save session: %v", err)
-	} else {
-		fmt.Fprintf(os.Stderr, "session saved: %s\n", savedID)
-	}
+	saveExecSession(engine, disp.ApprovalMemory(), sessionID, workdir, history)
 	if jsonOutput {
 		fmt.Fprintf(os.Stderr, "final: %s\n", answer)
 	} else {
 		fmt.Fprintln(os.Stdout, answer)
 	}
+	if scriptFailed {
+		os.Exit(exitScriptFailed)
+	}
 }
 
-func forwardBusEvents(ch <-chan any, emit func(jsonEvent)) {
-	for evt := range ch {
-		ev, ok := evt.(tools.ToolEvent)
-		if !ok {
-			continue
-		}
-		jsonEvt, ok := toolEventToJSON(ev)
-		if !ok {
-			continue
-		}
-		emit(jsonEvt)
+// checkStructuredOutput 从回复中提取 JSON 并按 schema 校验，返回压缩后的值或问题列表。
+func checkStructuredOutput(schema *jsonschema.Schema, answer string) (json.RawMessage, []string) {
+	value, err := jsonschema.ExtractJSON(answer)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	errs, err := schema.Validate(value)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	if len(errs) == 0 {
+		return value, nil
+	}
+	problems := make([]string, 0, len(errs))
+	for _, e := range errs {
+		problems = append(problems, e.Error())
 	}
+	return nil, problems
 }
 
-func toolEventToJSON(ev tools.ToolEvent) (jsonEvent, bool) {
-	switch ev.Type {
-	case "item.started", "item.updated", "item.completed":
-		text := ev.Result.Output
-		if text == "" {
-			text = ev.Result.Status
-		}
-		status := ev.Result.Status
-		if status == "" {
-			switch ev.Type {
-			case "item.started", "item.updated":
-				status = "in_progress"
-			default:
-				status = "completed"
+// runUndoLast 通过 SQ 提交 undo，等待 undo.completed 后保存会话（已撤销的快照随之移除）。
+func runUndoLast(ctx context.Context, gateway *repl.Gateway, engine *execution.Engine, memory *tools.ApprovalMemory, sessionID string, workdir string, history []agent.Message, emit func(jsonEvent)) bool {
+	engineEvents := gateway.Events()
+	subID, err := gateway.SubmitUndo(ctx, sessionID)
+	if err != nil {
+		emit(jsonEvent{Type: "item.completed", Item: &eventItem{ID: "undo_0", Type: "undo", Status: "failed", Text: err.Error()}})
+		return false
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case ev := <-engineEvents:
+			if ev.SubmissionID != subID || ev.Type != events.EventUndoCompleted {
+				continue
 			}
+			result, _ := ev.Payload.(events.UndoResult)
+			status := "completed"
+			if !result.Success {
+				status = "failed"
+			}
+			emit(jsonEvent{Type: "item.completed", Item: &eventItem{ID: "undo_0", Type: "undo", Status: status, Text: result.Message}})
+			if result.Success {
+				saveExecSession(engine, memory, sessionID, workdir, history)
+			}
+			return result.Success
 		}
-		item := eventItem{
-			ID:      ev.Result.ID,
-			Type:    string(ev.Result.Kind),
-			Status:  status,
-			Text:    text,
-			Command: ev.Result.Command,
-			Path:    ev.Result.Path,
-		}
-		if ev.Result.ExitCode != 0 {
-			code := ev.Result.ExitCode
-			item.ExitCode = &code
-		}
-		if ev.Result.Status == "error" || ev.Result.Error != "" {
-			item.Text = ev.Result.Error
-			item.Status = "failed"
+	}
+}
+
+// runCompact 通过 SQ 提交 compact，等待 compact.completed 后保存会话（rollout 中写入 compacted 行）。
+func runCompact(ctx context.Context, gateway *repl.Gateway, engine *execution.Engine, memory *tools.ApprovalMemory, sessionID string, workdir string, model string, focus string, history []agent.Message, emit func(jsonEvent)) bool {
+	engineEvents := gateway.Events()
+	subID, err := gateway.SubmitCompact(ctx, sessionID, events.CompactOperation{Focus: focus, Model: model})
+	if err != nil {
+		emit(jsonEvent{Type: "item.completed", Item: &eventItem{ID: "compact_0", Type: "compact", Status: "failed", Text: err.Error()}})
+		return false
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case ev := <-engineEvents:
+			if ev.SubmissionID != subID || ev.Type != events.EventCompactCompleted {
+				continue
+			}
+			result, _ := ev.Payload.(events.CompactResult)
+			status := "completed"
+			text := result.Message
+			if !result.Success {
+				status = "failed"
+			} else if strings.TrimSpace(result.Summary) != "" {
+				text += "\n\n" + result.Summary
+			}
+			emit(jsonEvent{Type: "item.completed", Item: &eventItem{ID: "compact_0", Type: "compact", Status: status, Text: text}})
+			if result.Success {
+				saveExecSession(engine, memory, sessionID, workdir, history)
+			}
+			return result.Success
 		}
-		return jsonEvent{Type: ev.Type, Item: &item}, true
-	default:
-		return jsonEvent{}, false
 	}
 }
 
-func actionForKind(kind tools.ToolKind) string {
-	switch kind {
-	case tools.ToolCommand:
-		return "command"
-	case tools.ToolApplyPatch:
-		return "file_change"
-	case tools.ToolFileRead:
-		return "file_read"
-	case tools.ToolSearch:
-		return "file_search"
-	default:
-		return string(kind)
+func saveExecSession(engine *execution.Engine, memory *tools.ApprovalMemory, sessionID string, workdir string, history []agent.Message) {
+	savedID, err := session.SaveRecord(session.Record{
+		ID:        sessionID,
+		Workdir:   workdir,
+		Messages:  history,
+		Items:     engine.ResponseHistory(sessionID),
+		Turns:     engine.Turns(sessionID),
+		Approvals: memory.Grants(sessionID),
+	})
+	if err != nil {
+		log.Warnf("failed to save session: %v", err)
+		return
 	}
+	fmt.Fprintf(os.Stderr, "session saved: %s\n", savedID)
 }
 
 func calcUsage(messages []agent.Message) usage {
@@ -581,7 +815,45 @@ func emitHuman(ev jsonEvent) {
 				fmt.Fprintf(os.Stderr, "[%s] %s\n", ev.Item.Type, strings.TrimSpace(text))
 			}
 		}
-	case "turn.failed":
+	case "approval.requested":
+		if a := ev.Approval; a != nil {
+			target := strings.TrimSpace(a.Command)
+			if target == "" {
+				target = a.Path
+			}
+			fmt.Fprintf(os.Stderr, "[approval] %s requires approval (id %s): %s\n", a.ItemType, a.ID, target)
+			if a.Mode == string(execApprovalStdin) {
+				fmt.Fprintf(os.Stderr, "[approval] answer on stdin: {\"approval_id\":%q,\"decision\":\"approve|deny\"}\n", a.ID)
+			}
+		}
+	case "approval.resolved":
+		if a := ev.Approval; a != nil && a.Approved != nil {
+			verdict := "denied"
+			if *a.Approved {
+				verdict = "approved"
+			}
+			line := fmt.Sprintf("[approval] %s %s", a.ID, verdict)
+			if a.Reason != "" {
+				line += ": " + a.Reason
+			}
+			fmt.Fprintln(os.Stderr, line)
+		}
+	case "step.started":
+		if st := ev.Step; st != nil {
+			label := st.Name
+			if label == "" {
+				label = st.Prompt
+			}
+			fmt.Fprintf(os.Stderr, "[step %d] %s\n", st.Index, strings.TrimSpace(label))
+		}
+	case "step.completed":
+		if st := ev.Step; st != nil {
+			fmt.Fprintf(os.Stderr, "[step %d] %s\n", st.Index, st.Status)
+			for _, failure := range st.Failures {
+				fmt.Fprintf(os.Stderr, "  FAIL %s\n", failure)
+			}
+		}
+	case "error", "turn.failed":
 		if ev.Error != nil {
 			fmt.Fprintf(os.Stderr, "error: %s\n", ev.Error.Message)
 		}
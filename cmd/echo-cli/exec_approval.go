@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"echo-cli/internal/config"
+	"echo-cli/internal/events"
+	"echo-cli/internal/tools"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// execApprovalMode 决定 exec 无人值守时如何回答工具调用的审批请求。
+type execApprovalMode string
+
+const (
+	// execApprovalDeny 立即拒绝（默认），拒绝理由回传给模型。
+	execApprovalDeny execApprovalMode = "deny"
+	// execApprovalApprove 立即批准（仅本次）。
+	execApprovalApprove execApprovalMode = "approve"
+	// execApprovalStdin 从 stdin 逐行读取 JSON 决策，由控制进程回答。
+	execApprovalStdin execApprovalMode = "stdin"
+)
+
+func parseExecApprovalMode(raw string) (execApprovalMode, error) {
+	switch mode := execApprovalMode(strings.ToLower(strings.TrimSpace(raw))); mode {
+	case "":
+		return execApprovalDeny, nil
+	case execApprovalDeny, execApprovalApprove, execApprovalStdin:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown approval mode %q (expected deny|approve|stdin)", raw)
+	}
+}
+
+// loadApprovalRulesFile 读取 --approval-rules 文件：顶层键与配置中的 [approvals] 表相同。
+func loadApprovalRulesFile(path string) (config.ApprovalRules, error) {
+	var rules config.ApprovalRules
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	dec := toml.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return rules, fmt.Errorf("invalid approval rules file %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// mergeApprovalRules 把规则文件追加到配置规则之后；deny 仍然优先。
+func mergeApprovalRules(base, extra config.ApprovalRules) config.ApprovalRules {
+	return config.ApprovalRules{
+		AllowCommands: append(append([]string{}, base.AllowCommands...), extra.AllowCommands...),
+		DenyCommands:  append(append([]string{}, base.DenyCommands...), extra.DenyCommands...),
+		AllowPaths:    append(append([]string{}, base.AllowPaths...), extra.AllowPaths...),
+		DenyPaths:     append(append([]string{}, base.DenyPaths...), extra.DenyPaths...),
+	}
+}
+
+// execApprovalInput 是 stdin 模式下的一行决策；也接受以 params 承载同样字段的 JSON-RPC 通知。
+type execApprovalInput struct {
+	ApprovalID string `json:"approval_id"`
+	// Decision 为 approve 或 deny。
+	Decision string `json:"decision"`
+	Scope    string `json:"scope,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	Persist  bool   `json:"persist,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func parseExecApprovalInput(line string) (events.ApprovalDecisionOperation, error) {
+	var envelope struct {
+		execApprovalInput
+		Params *execApprovalInput `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(line), &envelope); err != nil {
+		return events.ApprovalDecisionOperation{}, fmt.Errorf("invalid approval decision: %w", err)
+	}
+	in := envelope.execApprovalInput
+	if envelope.Params != nil {
+		in = *envelope.Params
+	}
+	op := events.ApprovalDecisionOperation{
+		ApprovalID: strings.TrimSpace(in.ApprovalID),
+		Scope:      strings.TrimSpace(in.Scope),
+		Prefix:     strings.TrimSpace(in.Prefix),
+		Persist:    in.Persist,
+		Reason:     strings.TrimSpace(in.Reason),
+	}
+	if op.ApprovalID == "" {
+		return op, errors.New("invalid approval decision: approval_id is required")
+	}
+	switch strings.ToLower(strings.TrimSpace(in.Decision)) {
+	case "approve", "approved", "allow", "yes":
+		op.Approved = true
+	case "deny", "denied", "reject", "no":
+	default:
+		return op, fmt.Errorf("invalid approval decision: decision must be approve or deny, got %q", in.Decision)
+	}
+	if _, err := tools.ParseApprovalScope(op.Scope); err != nil {
+		return op, fmt.Errorf("invalid approval decision: %w", err)
+	}
+	return op, nil
+}
+
+// execApprover 回答 exec 中的审批请求。deny/approve 模式立即给出决策；stdin 模式记录待决请求，
+// 由后台读取的决策依次送入 Decisions()，stdin 关闭后拒绝所有待决及之后的请求，避免回合一直阻塞。
+type execApprover struct {
+	mode      execApprovalMode
+	decisions chan events.ApprovalDecisionOperation
+
+	mu      sync.Mutex
+	pending map[string]bool
+	closed  bool
+}
+
+func newExecApprover(mode execApprovalMode) *execApprover {
+	return &execApprover{
+		mode:      mode,
+		decisions: make(chan events.ApprovalDecisionOperation, 16),
+		pending:   map[string]bool{},
+	}
+}
+
+// Decisions 返回 stdin 模式下读到的决策；其他模式下永远不会有值。
+func (a *execApprover) Decisions() <-chan events.ApprovalDecisionOperation {
+	return a.decisions
+}
+
+// Request 登记一个审批请求；能立即决定时返回决策。
+func (a *execApprover) Request(res tools.ToolResult) (events.ApprovalDecisionOperation, bool) {
+	op := events.ApprovalDecisionOperation{ApprovalID: res.ApprovalID}
+	switch a.mode {
+	case execApprovalApprove:
+		op.Approved = true
+		return op, true
+	case execApprovalStdin:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.closed {
+			op.Reason = "exec approval input (stdin) is closed"
+			return op, true
+		}
+		a.pending[res.ApprovalID] = true
+		return op, false
+	default:
+		reason := "exec runs unattended (--approval-mode=deny)"
+		if r := strings.TrimSpace(res.ApprovalReason); r != "" {
+			reason += "; approval was required because " + r
+		}
+		op.Reason = reason + "; do not retry this call, choose another approach or report that it needs approval"
+		return op, true
+	}
+}
+
+// Resolved 在决策投递后移除待决请求。
+func (a *execApprover) Resolved(id string) {
+	a.mu.Lock()
+	delete(a.pending, id)
+	a.mu.Unlock()
+}
+
+// ReadDecisions 从 r 逐行读取决策直到 EOF；无法解析的行通过 onError 报告后跳过。
+func (a *execApprover) ReadDecisions(r io.Reader, onError func(error)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		op, err := parseExecApprovalInput(line)
+		if err != nil {
+			onError(err)
+			continue
+		}
+		a.Resolved(op.ApprovalID)
+		a.decisions <- op
+	}
+	a.mu.Lock()
+	a.closed = true
+	ids := make([]string, 0, len(a.pending))
+	for id := range a.pending {
+		ids = append(ids, id)
+	}
+	a.mu.Unlock()
+	for _, id := range ids {
+		a.decisions <- events.ApprovalDecisionOperation{ApprovalID: id, Reason: "exec approval input (stdin) closed before a decision was made"}
+	}
+}
+
+// approvalRequestedEvent 描述一次待决审批，供控制进程据此回答。
+func approvalRequestedEvent(res tools.ToolResult, mode execApprovalMode) jsonEvent {
+	return jsonEvent{Type: "approval.requested", Approval: &eventApproval{
+		ID:       res.ApprovalID,
+		ItemID:   res.ID,
+		ItemType: string(res.Kind),
+		Command:  res.Command,
+		Path:     res.Path,
+		Reason:   res.ApprovalReason,
+		Mode:     string(mode),
+	}}
+}
+
+func approvalResolvedEvent(op events.ApprovalDecisionOperation, mode execApprovalMode) jsonEvent {
+	approved := op.Approved
+	return jsonEvent{Type: "approval.resolved", Approval: &eventApproval{
+		ID:       op.ApprovalID,
+		Approved: &approved,
+		Scope:    op.Scope,
+		Reason:   op.Reason,
+		Mode:     string(mode),
+	}}
+}
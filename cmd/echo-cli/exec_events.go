@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/events"
+	"echo-cli/internal/tools"
+)
+
+// execJSONSchemaVersion 是 exec --json 事件流的版本号，写入每一行的 schema_version；
+// 字段只增不改，删除或改变含义时递增。格式说明见 docs/EXEC_JSON.md。
+const execJSONSchemaVersion = 1
+
+type jsonEvent struct {
+	Type          string      `json:"type"`
+	SchemaVersion int         `json:"schema_version,omitempty"`
+	ThreadID      string      `json:"thread_id,omitempty"`
+	SessionID     string      `json:"session_id,omitempty"`
+	Item          *eventItem  `json:"item,omitempty"`
+	Usage         *usage      `json:"usage,omitempty"`
+	Error         *eventError `json:"error,omitempty"`
+	// Approval 出现在 approval.requested/approval.resolved 事件中。
+	Approval *eventApproval `json:"approval,omitempty"`
+	// Step/Script 出现在 --script 的 step.* 与 script.completed 事件中。
+	Step   *eventStep   `json:"step,omitempty"`
+	Script *eventScript `json:"script,omitempty"`
+	// Output 是通过 --output-schema 校验的最终回复（output.structured 事件）。
+	Output json.RawMessage `json:"output,omitempty"`
+}
+
+type eventItem struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Status   string `json:"status,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Command  string `json:"command,omitempty"`
+	Path     string `json:"path,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+
+	// 以下字段只出现在工具调用 item 中。
+	Error         string           `json:"error,omitempty"`
+	Query         string           `json:"query,omitempty"`
+	Diff          string           `json:"diff,omitempty"`
+	ExecSessionID string           `json:"exec_session_id,omitempty"`
+	Plan          []tools.PlanItem `json:"plan,omitempty"`
+	Explanation   string           `json:"explanation,omitempty"`
+	Approval      *eventApproval   `json:"approval,omitempty"`
+}
+
+// eventApproval 描述等待或已获得人工审批的工具调用；item 中只有 id 与 reason。
+type eventApproval struct {
+	ID       string `json:"id"`
+	ItemID   string `json:"item_id,omitempty"`
+	ItemType string `json:"item_type,omitempty"`
+	Command  string `json:"command,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Approved *bool  `json:"approved,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// Mode 是回答该审批的 --approval-mode。
+	Mode string `json:"mode,omitempty"`
+}
+
+// eventStep 描述 --script 中的一个步骤；Index 从 0 开始，Line 为脚本中的行号。
+type eventStep struct {
+	Index     int      `json:"index"`
+	Line      int      `json:"line,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Prompt    string   `json:"prompt,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	Failures  []string `json:"failures,omitempty"`
+	ToolCalls int      `json:"tool_calls,omitempty"`
+}
+
+// eventScript 汇总 --script 的执行结果。
+type eventScript struct {
+	Steps    int      `json:"steps"`
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+type eventError struct {
+	Message string `json:"message"`
+}
+
+type usage struct {
+	InputTokens       int64   `json:"input_tokens"`
+	CachedInputTokens int64   `json:"cached_input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	CacheHitRate      float64 `json:"cache_hit_rate,omitempty"`
+	// Model 与 DurationMs 只出现在 usage.updated 中。
+	Model      string `json:"model,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// toolEventToJSON 把工具事件映射为 item 事件；item.id 为模型给出的 call id，同一调用的 started/updated/completed 共用。
+func toolEventToJSON(ev tools.ToolEvent) (jsonEvent, bool) {
+	switch ev.Type {
+	case "item.started", "item.updated", "item.completed":
+	default:
+		return jsonEvent{}, false
+	}
+	res := ev.Result
+	item := eventItem{
+		ID:            res.ID,
+		Type:          string(res.Kind),
+		Status:        toolItemStatus(ev.Type, res),
+		Text:          res.Output,
+		Error:         res.Error,
+		Command:       res.Command,
+		Path:          res.Path,
+		Query:         res.Query,
+		Diff:          res.Diff,
+		ExecSessionID: res.SessionID,
+		Plan:          res.Plan,
+		Explanation:   res.Explanation,
+	}
+	if res.ApprovalID != "" {
+		item.Approval = &eventApproval{ID: res.ApprovalID, Reason: res.ApprovalReason}
+	}
+	// 命令结束时总是带上退出码（包括 0），运行中只在非 0 时出现。
+	if res.ExitCode != 0 || (res.Kind == tools.ToolCommand && ev.Type == "item.completed" && res.SessionID == "") {
+		code := res.ExitCode
+		item.ExitCode = &code
+	}
+	return jsonEvent{Type: ev.Type, Item: &item}, true
+}
+
+// toolItemStatus 归一化工具状态：in_progress、requires_approval、approved、completed、failed、sandbox_denied。
+func toolItemStatus(evType string, res tools.ToolResult) string {
+	status := strings.TrimSpace(res.Status)
+	switch {
+	case status == tools.StatusSandboxDenied, status == "requires_approval", status == "approved":
+		return status
+	case status == "error" || res.Error != "":
+		return "failed"
+	case evType == "item.completed":
+		return "completed"
+	default:
+		return "in_progress"
+	}
+}
+
+// summaryUsage 取任务总结中 provider 回报的用量；没有回报时返回 nil。
+func summaryUsage(summary events.TaskSummary) *usage {
+	if summary.InputTokens+summary.CachedInputTokens+summary.OutputTokens == 0 {
+		return nil
+	}
+	return &usage{
+		InputTokens:       summary.InputTokens,
+		CachedInputTokens: summary.CacheCreationInputTokens + summary.CacheReadInputTokens,
+		OutputTokens:      summary.OutputTokens,
+		CacheHitRate:      summary.CacheHitRate,
+		Model:             summary.Model,
+		DurationMs:        summary.DurationMs,
+	}
+}
+
+// turnUsage 返回一个回合的用量：优先使用任务总结中回报的用量，没有回报时按本回合的输入与回复估算。
+func turnUsage(reported agent.TokenUsage, hasReported bool, input, answer string) usage {
+	if !hasReported {
+		return calcUsage([]agent.Message{{Role: agent.RoleUser, Content: input}, {Role: agent.RoleAssistant, Content: answer}})
+	}
+	return usage{
+		InputTokens:       reported.InputTokens,
+		CachedInputTokens: reported.CacheCreationInputTokens + reported.CacheReadInputTokens,
+		OutputTokens:      reported.OutputTokens,
+		CacheHitRate:      reported.CacheHitRate(),
+	}
+}
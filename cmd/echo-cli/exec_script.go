@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"echo-cli/internal/tools"
+)
+
+// exitScriptFailed 是 --script 中有步骤断言失败时的退出码。
+const exitScriptFailed = 4
+
+// scriptStep 是 --script 文件中的一行：一次用户输入及其断言。
+// 也接受对话记录格式 {"role":"user","content":"..."}；其他角色的行（如 assistant 回复）会被跳过。
+type scriptStep struct {
+	Name    string       `json:"name,omitempty"`
+	Prompt  string       `json:"prompt,omitempty"`
+	Role    string       `json:"role,omitempty"`
+	Content string       `json:"content,omitempty"`
+	Expect  scriptExpect `json:"expect,omitempty"`
+
+	// skip 表示这是对话记录中非用户角色的行，不构成步骤。
+	skip bool
+}
+
+// scriptExpect 是对一个步骤结果的断言，未设置的字段不检查。
+type scriptExpect struct {
+	// Contains/NotContains 检查最终回复中是否出现给定子串。
+	Contains    []string `json:"contains,omitempty"`
+	NotContains []string `json:"not_contains,omitempty"`
+	// Matches 是最终回复需要匹配的正则表达式。
+	Matches string `json:"matches,omitempty"`
+	// Tools/NoTools 是必须/不得出现的工具调用类型（与 item.type 相同，如 command_execution）。
+	Tools   []string `json:"tools,omitempty"`
+	NoTools []string `json:"no_tools,omitempty"`
+	// Commands 中的每一项都必须是某条已执行命令的子串。
+	Commands []string `json:"commands,omitempty"`
+	// Status 是回合结果：completed（默认不检查）或 failed。
+	Status string `json:"status,omitempty"`
+	// ExitCode 是最后一条执行完毕的命令的退出码。
+	ExitCode *int `json:"exit_code,omitempty"`
+	// NoToolFailures 要求本回合没有失败的工具调用。
+	NoToolFailures bool `json:"no_tool_failures,omitempty"`
+
+	matches *regexp.Regexp
+}
+
+// scriptOutcome 是一个步骤执行后的结果。
+type scriptOutcome struct {
+	Answer string
+	OK     bool
+	Calls  []tools.ToolResult
+}
+
+// parseScriptStep 解析一行脚本；纯文本行（非 JSON 对象）视为只有 prompt 的步骤。
+// 对话记录中非用户角色的行返回 skip 为 true 的步骤，其余字段不做检查，以便直接回放真实的对话记录。
+func parseScriptStep(line []byte) (scriptStep, error) {
+	var step scriptStep
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		step.Prompt = string(trimmed)
+		return step, nil
+	}
+	var probe struct {
+		Role string `json:"role"`
+	}
+	if json.Unmarshal(trimmed, &probe) == nil {
+		if role := strings.ToLower(strings.TrimSpace(probe.Role)); role != "" && role != "user" {
+			step.skip = true
+			return step, nil
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&step); err != nil {
+		return step, err
+	}
+	if step.Prompt == "" {
+		step.Prompt = step.Content
+	}
+	if strings.TrimSpace(step.Prompt) == "" {
+		return step, fmt.Errorf("step has no prompt")
+	}
+	switch step.Expect.Status {
+	case "", "completed", "failed":
+	default:
+		return step, fmt.Errorf("expect.status must be completed or failed, got %q", step.Expect.Status)
+	}
+	if step.Expect.Matches != "" {
+		re, err := regexp.Compile(step.Expect.Matches)
+		if err != nil {
+			return step, fmt.Errorf("expect.matches: %w", err)
+		}
+		step.Expect.matches = re
+	}
+	return step, nil
+}
+
+// check 返回未满足的断言，全部满足时返回 nil。
+func (e scriptExpect) check(out scriptOutcome) []string {
+	var failures []string
+	status := "completed"
+	if !out.OK {
+		status = "failed"
+	}
+	if e.Status != "" && e.Status != status {
+		failures = append(failures, fmt.Sprintf("turn %s, expected %s", status, e.Status))
+	}
+	for _, s := range e.Contains {
+		if !strings.Contains(out.Answer, s) {
+			failures = append(failures, fmt.Sprintf("final message does not contain %q", s))
+		}
+	}
+	for _, s := range e.NotContains {
+		if strings.Contains(out.Answer, s) {
+			failures = append(failures, fmt.Sprintf("final message contains %q", s))
+		}
+	}
+	if e.matches != nil && !e.matches.MatchString(out.Answer) {
+		failures = append(failures, fmt.Sprintf("final message does not match /%s/", e.Matches))
+	}
+	kinds := map[string]bool{}
+	var commands []string
+	lastExit := -1
+	for _, call := range out.Calls {
+		kinds[string(call.Kind)] = true
+		if call.Kind == tools.ToolCommand {
+			if call.Command != "" {
+				commands = append(commands, call.Command)
+			}
+			if call.SessionID == "" {
+				lastExit = call.ExitCode
+			}
+		}
+		if e.NoToolFailures && toolItemStatus("item.completed", call) != "completed" {
+			failures = append(failures, fmt.Sprintf("%s %s failed: %s", call.Kind, call.ID, firstNonEmpty(call.Error, call.Status)))
+		}
+	}
+	for _, kind := range e.Tools {
+		if !kinds[kind] {
+			failures = append(failures, fmt.Sprintf("no %s tool call", kind))
+		}
+	}
+	for _, kind := range e.NoTools {
+		if kinds[kind] {
+			failures = append(failures, fmt.Sprintf("unexpected %s tool call", kind))
+		}
+	}
+	for _, want := range e.Commands {
+		found := false
+		for _, cmd := range commands {
+			if strings.Contains(cmd, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("no command containing %q was run", want))
+		}
+	}
+	if e.ExitCode != nil {
+		switch {
+		case lastExit < 0:
+			failures = append(failures, fmt.Sprintf("expected exit code %d, but no command finished", *e.ExitCode))
+		case lastExit != *e.ExitCode:
+			failures = append(failures, fmt.Sprintf("last command exited %d, expected %d", lastExit, *e.ExitCode))
+		}
+	}
+	return failures
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// runExecScript 逐行读取脚本并依次执行步骤（r 为 stdin 时随输入到达而执行），每步前后输出 step.started/step.completed，
+// 最后输出 script.completed。断言失败不会中断后续步骤；无法解析的行计为失败步骤，非用户角色的对话记录行不计入步骤。
+func runExecScript(r io.Reader, run func(prompt string) scriptOutcome, emit func(jsonEvent)) (eventScript, string) {
+	summary := eventScript{}
+	last := ""
+	reader := bufio.NewReader(r)
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := reader.ReadBytes('\n')
+		step, err := scriptStep{skip: true}, error(nil)
+		if len(bytes.TrimSpace(line)) > 0 && !bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			step, err = parseScriptStep(line)
+		}
+		if !step.skip {
+			index := summary.Steps
+			summary.Steps++
+			if err != nil {
+				failure := fmt.Sprintf("line %d: %v", lineNo, err)
+				summary.Failed++
+				summary.Failures = append(summary.Failures, failure)
+				emit(jsonEvent{Type: "step.completed", Step: &eventStep{Index: index, Line: lineNo, Status: "failed", Failures: []string{failure}}})
+			} else {
+				emit(jsonEvent{Type: "step.started", Step: &eventStep{Index: index, Line: lineNo, Name: step.Name, Prompt: step.Prompt}})
+				out := run(step.Prompt)
+				last = out.Answer
+				failures := step.Expect.check(out)
+				result := eventStep{Index: index, Line: lineNo, Name: step.Name, Status: "passed", Failures: failures, ToolCalls: len(out.Calls)}
+				if len(failures) > 0 {
+					result.Status = "failed"
+					summary.Failed++
+					label := step.Name
+					if label == "" {
+						label = fmt.Sprintf("step %d", index)
+					}
+					for _, f := range failures {
+						summary.Failures = append(summary.Failures, label+": "+f)
+					}
+				} else {
+					summary.Passed++
+				}
+				emit(jsonEvent{Type: "step.completed", Step: &result})
+			}
+		}
+		if readErr != nil {
+			if readErr != io.EOF {
+				summary.Failures = append(summary.Failures, fmt.Sprintf("read script: %v", readErr))
+				summary.Failed++
+			}
+			break
+		}
+	}
+	emit(jsonEvent{Type: "script.completed", Script: &summary})
+	return summary, last
+}
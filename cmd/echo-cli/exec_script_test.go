@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"echo-cli/internal/tools"
+)
+
+func TestParseScriptStep(t *testing.T) {
+	step, err := parseScriptStep([]byte(`{"name":"build","prompt":"run the tests","expect":{"contains":["ok"],"matches":"^PASS","exit_code":0}}`))
+	if err != nil || step.Prompt != "run the tests" || step.Expect.matches == nil || *step.Expect.ExitCode != 0 {
+		t.Fatalf("unexpected step %+v (%v)", step, err)
+	}
+	if step, err = parseScriptStep([]byte(`{"role":"user","content":"hello"}`)); err != nil || step.Prompt != "hello" {
+		t.Fatalf("conversation line should become a prompt, got %+v (%v)", step, err)
+	}
+	if step, err = parseScriptStep([]byte("plain text prompt\n")); err != nil || step.Prompt != "plain text prompt" {
+		t.Fatalf("plain line should become a prompt, got %+v (%v)", step, err)
+	}
+	for _, line := range []string{`{"role":"assistant","content":"hi"}`, `{"role":"system","content":"x","extra":1}`} {
+		if step, err = parseScriptStep([]byte(line)); err != nil || !step.skip {
+			t.Fatalf("non-user transcript line should be skipped, got %+v (%v)", step, err)
+		}
+	}
+	for _, line := range []string{
+		`{"prompt":""}`,
+		`{"prompt":"x","expect":{"contain":["typo"]}}`,
+		`{"prompt":"x","expect":{"status":"ok"}}`,
+		`{"prompt":"x","expect":{"matches":"("}}`,
+	} {
+		if _, err := parseScriptStep([]byte(line)); err == nil {
+			t.Fatalf("expected error for %s", line)
+		}
+	}
+}
+
+func TestScriptExpectCheck(t *testing.T) {
+	zero, two := 0, 2
+	out := scriptOutcome{
+		Answer: "All tests pass.",
+		OK:     true,
+		Calls: []tools.ToolResult{
+			{ID: "c1", Kind: tools.ToolFileRead, Status: "completed", Path: "go.mod"},
+			{ID: "c2", Kind: tools.ToolCommand, Status: "completed", Command: "go test ./...", ExitCode: 0},
+		},
+	}
+	pass := scriptExpect{Contains: []string{"pass"}, Tools: []string{"command_execution"}, NoTools: []string{"file_change"}, Commands: []string{"go test"}, ExitCode: &zero, Status: "completed", NoToolFailures: true}
+	if failures := pass.check(out); len(failures) != 0 {
+		t.Fatalf("expected no failures, got %v", failures)
+	}
+	fail := scriptExpect{NotContains: []string{"pass"}, Tools: []string{"file_change"}, Commands: []string{"make"}, ExitCode: &two, Status: "failed"}
+	failures := fail.check(out)
+	if len(failures) != 5 {
+		t.Fatalf("expected 5 failures, got %v", failures)
+	}
+	out.Calls = append(out.Calls, tools.ToolResult{ID: "c3", Kind: tools.ToolCommand, Status: "error", Error: "exit status 1", Command: "go vet", ExitCode: 1})
+	failures = scriptExpect{NoToolFailures: true, ExitCode: &zero}.check(out)
+	if len(failures) != 2 || !strings.Contains(failures[0], "c3") || !strings.Contains(failures[1], "exited 1") {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+}
+
+func TestRunExecScript(t *testing.T) {
+	script := strings.Join([]string{
+		`# comments and blank lines are skipped`,
+		``,
+		`{"name":"greet","prompt":"say hi","expect":{"contains":["hi"]}}`,
+		`{"role":"assistant","content":"hi"}`,
+		`{"prompt":"say bye","expect":{"contains":["hi"]}}`,
+		`{"prompt":`,
+		`last step without newline`,
+	}, "\n")
+	var prompts []string
+	var types []string
+	summary, last := runExecScript(strings.NewReader(script), func(prompt string) scriptOutcome {
+		prompts = append(prompts, prompt)
+		return scriptOutcome{Answer: strings.TrimPrefix(prompt, "say "), OK: true}
+	}, func(ev jsonEvent) { types = append(types, ev.Type) })
+
+	if strings.Join(prompts, "|") != "say hi|say bye|last step without newline" {
+		t.Fatalf("unexpected prompts %q", prompts)
+	}
+	if summary.Steps != 4 || summary.Passed != 2 || summary.Failed != 2 || len(summary.Failures) != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if !strings.HasPrefix(summary.Failures[0], "step 1: ") || !strings.HasPrefix(summary.Failures[1], "line 6: ") {
+		t.Fatalf("unexpected failures %q", summary.Failures)
+	}
+	if last != "last step without newline" {
+		t.Fatalf("unexpected last answer %q", last)
+	}
+	want := "step.started,step.completed,step.started,step.completed,step.completed,step.started,step.completed,script.completed"
+	if got := strings.Join(types, ","); got != want {
+		t.Fatalf("unexpected events %s", got)
+	}
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"echo-cli/internal/agent"
+	"echo-cli/internal/config"
+	"echo-cli/internal/jsonschema"
 	"echo-cli/internal/tools"
 )
 
@@ -24,4 +31,160 @@ func TestToolEventToJSONItem(t *testing.T) {
 	if jsonEvt.Item == nil || jsonEvt.Item.Type != string(tools.ToolCommand) || jsonEvt.Item.Status != "completed" {
 		t.Fatalf("unexpected item event %+v", jsonEvt.Item)
 	}
+	if jsonEvt.Item.ExitCode == nil || *jsonEvt.Item.ExitCode != 0 {
+		t.Fatalf("completed command should carry exit_code 0, got %+v", jsonEvt.Item)
+	}
+}
+
+func TestToolEventToJSONApprovalsFailuresAndPlans(t *testing.T) {
+	cases := []struct {
+		name string
+		ev   tools.ToolEvent
+		want string
+	}{
+		{
+			name: "approval",
+			ev: tools.ToolEvent{Type: "item.updated", Result: tools.ToolResult{
+				ID: "c1", Kind: tools.ToolApplyPatch, Status: "requires_approval", Path: "a.go", Diff: "--- a.go",
+				ApprovalID: "ap-1", ApprovalReason: "outside workdir",
+			}},
+			want: `{"type":"item.updated","item":{"id":"c1","type":"file_change","status":"requires_approval","path":"a.go","diff":"--- a.go","approval":{"id":"ap-1","reason":"outside workdir"}}}`,
+		},
+		{
+			name: "failure",
+			ev: tools.ToolEvent{Type: "item.completed", Result: tools.ToolResult{
+				ID: "c2", Kind: tools.ToolCommand, Status: "error", Output: "boom", Error: "exit status 2", ExitCode: 2, Command: "make",
+			}},
+			want: `{"type":"item.completed","item":{"id":"c2","type":"command_execution","status":"failed","text":"boom","command":"make","exit_code":2,"error":"exit status 2"}}`,
+		},
+		{
+			name: "plan",
+			ev: tools.ToolEvent{Type: "item.completed", Result: tools.ToolResult{
+				ID: "c3", Kind: tools.ToolPlanUpdate, Status: "completed", Plan: []tools.PlanItem{{Step: "test", Status: "pending"}},
+			}},
+			want: `{"type":"item.completed","item":{"id":"c3","type":"plan_update","status":"completed","plan":[{"step":"test","status":"pending"}]}}`,
+		},
+	}
+	for _, tc := range cases {
+		jsonEvt, ok := toolEventToJSON(tc.ev)
+		if !ok {
+			t.Fatalf("%s: expected conversion", tc.name)
+		}
+		data, _ := json.Marshal(jsonEvt)
+		if string(data) != tc.want {
+			t.Fatalf("%s:\n got %s\nwant %s", tc.name, data, tc.want)
+		}
+	}
+}
+
+func TestCheckStructuredOutput(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(`{"type":"object","required":["ok"],"properties":{"ok":{"type":"boolean"}}}`))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	value, problems := checkStructuredOutput(schema, "```json\n{\"ok\": true}\n```")
+	if len(problems) != 0 || string(value) != `{"ok":true}` {
+		t.Fatalf("expected compact value, got %s %v", value, problems)
+	}
+	_, problems = checkStructuredOutput(schema, `{"ok": "yes"}`)
+	if len(problems) != 1 || !strings.Contains(problems[0], "/ok: expected boolean") {
+		t.Fatalf("unexpected problems %v", problems)
+	}
+	_, problems = checkStructuredOutput(schema, "all good")
+	if len(problems) != 1 || !strings.Contains(problems[0], "not valid JSON") {
+		t.Fatalf("expected parse problem, got %v", problems)
+	}
+}
+
+func TestTurnUsage(t *testing.T) {
+	reported := agent.TokenUsage{InputTokens: 100, OutputTokens: 20, CacheReadInputTokens: 300}
+	u := turnUsage(reported, true, "ignored", "ignored")
+	if u.InputTokens != 100 || u.CachedInputTokens != 300 || u.OutputTokens != 20 {
+		t.Fatalf("expected reported usage, got %+v", u)
+	}
+	// 没有回报时只按本回合的输入与回复估算，不含之前的回合。
+	u = turnUsage(agent.TokenUsage{}, false, "fix the build", "done")
+	if u.InputTokens != 3 || u.OutputTokens != 1 || u.CachedInputTokens != 0 {
+		t.Fatalf("expected estimate for this turn only, got %+v", u)
+	}
+}
+
+func TestParseExecApprovalInput(t *testing.T) {
+	op, err := parseExecApprovalInput(`{"approval_id":"a1","decision":"approve","scope":"prefix","prefix":"make"}`)
+	if err != nil || !op.Approved || op.ApprovalID != "a1" || op.Scope != "prefix" || op.Prefix != "make" {
+		t.Fatalf("unexpected decision %+v (%v)", op, err)
+	}
+	op, err = parseExecApprovalInput(`{"jsonrpc":"2.0","method":"approval/decision","params":{"approval_id":"a2","decision":"deny","reason":"not now"}}`)
+	if err != nil || op.Approved || op.ApprovalID != "a2" || op.Reason != "not now" {
+		t.Fatalf("unexpected JSON-RPC decision %+v (%v)", op, err)
+	}
+	for _, line := range []string{`not json`, `{"decision":"approve"}`, `{"approval_id":"a3","decision":"maybe"}`, `{"approval_id":"a3","decision":"approve","scope":"forever"}`} {
+		if _, err := parseExecApprovalInput(line); err == nil {
+			t.Fatalf("expected error for %s", line)
+		}
+	}
+}
+
+func TestExecApproverModes(t *testing.T) {
+	req := tools.ToolResult{ID: "call_1", Kind: tools.ToolCommand, Command: "rm -rf build", ApprovalID: "a1", ApprovalReason: "approval_policy=always"}
+
+	op, decided := newExecApprover(execApprovalDeny).Request(req)
+	if !decided || op.Approved || !strings.Contains(op.Reason, "approval_policy=always") {
+		t.Fatalf("deny mode should refuse with the reason, got %+v", op)
+	}
+	if op, decided = newExecApprover(execApprovalApprove).Request(req); !decided || !op.Approved {
+		t.Fatalf("approve mode should approve, got %+v", op)
+	}
+
+	// stdin：先读到的决策原样送出，stdin 关闭时拒绝仍在等待的请求以及之后的请求。
+	approver := newExecApprover(execApprovalStdin)
+	if _, decided := approver.Request(req); decided {
+		t.Fatalf("stdin mode should wait for a decision")
+	}
+	pending := req
+	pending.ApprovalID = "a2"
+	approver.Request(pending)
+	var errs []error
+	done := make(chan struct{})
+	go func() {
+		approver.ReadDecisions(strings.NewReader("{\"approval_id\":\"a1\",\"decision\":\"approve\"}\ngarbage\n"), func(err error) { errs = append(errs, err) })
+		close(done)
+	}()
+	first := <-approver.Decisions()
+	approver.Resolved(first.ApprovalID)
+	if first.ApprovalID != "a1" || !first.Approved {
+		t.Fatalf("unexpected first decision %+v", first)
+	}
+	second := <-approver.Decisions()
+	<-done
+	if second.ApprovalID != "a2" || second.Approved || !strings.Contains(second.Reason, "closed") {
+		t.Fatalf("pending approval should be denied on EOF, got %+v", second)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected one parse error, got %v", errs)
+	}
+	if op, decided := approver.Request(req); !decided || op.Approved {
+		t.Fatalf("requests after EOF should be denied, got %+v", op)
+	}
+}
+
+func TestLoadApprovalRulesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.toml")
+	if err := os.WriteFile(path, []byte("allow_commands = [\"make test\"]\ndeny_paths = [\"secrets/**\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadApprovalRulesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := mergeApprovalRules(config.ApprovalRules{AllowCommands: []string{"go test"}}, rules)
+	if strings.Join(merged.AllowCommands, ",") != "go test,make test" || strings.Join(merged.DenyPaths, ",") != "secrets/**" {
+		t.Fatalf("unexpected merged rules %+v", merged)
+	}
+	if err := os.WriteFile(path, []byte("allow_command = [\"make\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadApprovalRulesFile(path); err == nil {
+		t.Fatalf("expected unknown key to be rejected")
+	}
 }
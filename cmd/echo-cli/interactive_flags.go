@@ -9,6 +9,9 @@ import (
 type interactiveArgs struct {
 	cfgPath         string
 	modelOverride   string
+	provider        string
+	approvalPolicy  string
+	sandboxMode     string
 	workdir         string
 	prompt          string
 	imagePaths      csvSlice
@@ -31,6 +34,11 @@ func newInteractiveFlagSet(name string) (*flag.FlagSet, *interactiveArgs) {
 	fs.StringVar(&args.cfgPath, "config", "", "Path to config file (default ~/.echo/config.toml)")
 	fs.StringVar(&args.modelOverride, "model", "", "Model override")
 	fs.StringVar(&args.modelOverride, "m", "", "Alias for --model")
+	fs.StringVar(&args.provider, "provider", "", "Model provider (anthropic|openai|ollama|lmstudio)")
+	fs.StringVar(&args.approvalPolicy, "ask-for-approval", "", "Approval policy (never|on-request|on-failure|untrusted|always)")
+	fs.StringVar(&args.approvalPolicy, "a", "", "Alias for --ask-for-approval")
+	fs.StringVar(&args.sandboxMode, "sandbox", "", "Sandbox mode for commands (read-only|workspace-write|full-access)")
+	fs.StringVar(&args.sandboxMode, "s", "", "Alias for --sandbox")
 	fs.StringVar(&args.workdir, "cd", "", "Working directory to display")
 	fs.StringVar(&args.workdir, "C", "", "Alias for --cd")
 	fs.StringVar(&args.prompt, "prompt", "", "Initial prompt")
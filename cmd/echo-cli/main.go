@@ -10,22 +10,27 @@ import (
 	"time"
 
 	"echo-cli/internal/agent"
-	anthropicmodel "echo-cli/internal/agent/anthropic"
 	"echo-cli/internal/config"
 	echocontext "echo-cli/internal/context"
 	"echo-cli/internal/events"
 	"echo-cli/internal/execution"
+	"echo-cli/internal/ghost"
 	"echo-cli/internal/i18n"
 	"echo-cli/internal/instructions"
 	"echo-cli/internal/logger"
 	"echo-cli/internal/repl"
+	"echo-cli/internal/sandbox"
+	"echo-cli/internal/search"
 	"echo-cli/internal/session"
 	"echo-cli/internal/tools"
 	"echo-cli/internal/tools/dispatcher"
-	"github.com/google/uuid"
 )
 
 func main() {
+	// 沙箱辅助进程必须在任何日志/文件初始化之前接管。
+	if len(os.Args) > 1 && os.Args[1] == sandbox.HelperArg {
+		sandbox.RunHelper(os.Args[2:])
+	}
 	logger.Configure()
 	if logFile, _, err := logger.SetupFile(logger.DefaultLogPath); err != nil {
 		log.Warnf("failed to initialize log file: %v", err)
@@ -118,58 +123,65 @@ func runInteractive(root rootArgs, args []string) {
 	startInteractiveSession(cli, nil)
 }
 
-func startInteractiveSession(cli *interactiveArgs, seedMessages []agent.Message) {
+func startInteractiveSession(cli *interactiveArgs, resumed *session.Record) {
 	endpoint, err := config.Load(cli.cfgPath)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
-	endpoint = config.ApplyKVOverrides(endpoint, []string(cli.configOverrides))
+	endpoint, profileOverrides := applyConfigProfile(endpoint, cli.configProfile)
+	echocontext.SetModelCatalog(modelCatalog(endpoint))
+	search.SetIgnorePatterns(endpoint.Search.Ignore)
+	endpoint = selectProvider(endpoint, providerFlags{provider: cli.provider, oss: cli.oss, localProvider: cli.localProvider}, []string(cli.configOverrides))
 
-	rt := defaultRuntimeConfig()
+	rt := applyRuntimeKVOverrides(defaultRuntimeConfig(), profileOverrides)
 	if strings.TrimSpace(endpoint.Model) != "" {
 		rt.Model = strings.TrimSpace(endpoint.Model)
 	}
 	if strings.TrimSpace(cli.modelOverride) != "" {
 		rt.Model = strings.TrimSpace(cli.modelOverride)
 	}
+	if strings.TrimSpace(cli.approvalPolicy) != "" {
+		rt.ApprovalPolicy = strings.TrimSpace(cli.approvalPolicy)
+	}
+	if strings.TrimSpace(cli.sandboxMode) != "" {
+		rt.SandboxMode = strings.TrimSpace(cli.sandboxMode)
+	}
 	rt = applyRuntimeKVOverrides(rt, []string(cli.configOverrides))
+	cli.configOverrides = stringSlice(withProfileOverrides(profileOverrides, []string(cli.configOverrides)))
 	if strings.TrimSpace(rt.DefaultLanguage) == "" {
 		rt.DefaultLanguage = i18n.DefaultLanguage.Code()
 	}
 
 	workdir := resolveWorkdir(cli.workdir)
-	if len(seedMessages) == 0 && cli.resumeSessionID != "" {
+	if resumed == nil && cli.resumeSessionID != "" {
 		if rec, err := session.Load(cli.resumeSessionID); err == nil {
-			seedMessages = append(seedMessages, rec.Messages...)
-			if cli.resumeSessionID == "" {
-				cli.resumeSessionID = rec.ID
-			}
+			resumed = &rec
+		} else {
+			log.Warnf("failed to load session %s: %v", cli.resumeSessionID, err)
 		}
-	} else if len(seedMessages) == 0 && cli.resumeLast {
+	} else if resumed == nil && cli.resumeLast {
 		if rec, err := session.Last(); err == nil {
-			seedMessages = append(seedMessages, rec.Messages...)
-			if cli.resumeSessionID == "" {
-				cli.resumeSessionID = rec.ID
-			}
+			resumed = &rec
 		}
 	}
+	if resumed != nil {
+		cli.resumeSessionID = resumed.ID
+	}
 
-	resumeIDs := []string(nil)
+	var resumeSessions []session.Summary
 	if cli.resumePicker {
-		records, err := session.List(cli.resumeShowAll, workdir)
+		summaries, err := session.Summaries(cli.resumeShowAll, workdir)
 		if err != nil {
 			log.Fatalf("failed to load sessions: %v", err)
 		}
-		for _, rec := range records {
-			resumeIDs = append(resumeIDs, rec.ID)
-		}
-		if len(resumeIDs) == 0 {
+		resumeSessions = summaries
+		if len(resumeSessions) == 0 {
 			log.Info("no sessions available to resume; starting new chat")
 			cli.resumePicker = false
 		}
 	}
 
-	client := buildModelClient(endpoint, rt.Model, cli.oss)
+	client := buildModelClient(endpoint, rt.Model)
 	system := instructions.Discover(workdir)
 	bus := events.NewBus()
 	defer bus.Close()
@@ -183,7 +195,17 @@ func startInteractiveSession(cli *interactiveArgs, seedMessages []agent.Message)
 		}
 	}
 	runner := tools.DirectRunner{}
-	disp := dispatcher.New(runner, bus, workdir, dispatcher.Options{})
+	mcpManager := connectMCPServers(context.Background(), endpoint, []string(cli.configOverrides))
+	defer mcpManager.Close()
+	policy, rules := approvalOptions(rt, endpoint, tools.ApprovalOnRequest)
+	disp := dispatcher.New(runner, bus, workdir, dispatcher.Options{
+		Handlers: mcpManager.Handlers(),
+		Reviewer: commandReviewer(client, rt.Model, policy),
+		Policy:   policy,
+		Rules:    rules,
+		Memory:   tools.NewApprovalMemory(tools.NewProjectApprovals(workdir)),
+		Sandbox:  sandboxPolicy(rt, endpoint, workdir),
+	})
 	disp.Start(context.Background())
 
 	manager := events.NewManager(events.ManagerConfig{})
@@ -195,26 +217,26 @@ func startInteractiveSession(cli *interactiveArgs, seedMessages []agent.Message)
 		Manager:        manager,
 		Client:         client,
 		Bus:            bus,
-		Defaults:       echocontext.SessionDefaults{Model: rt.Model, System: system, ReasoningEffort: rt.ReasoningEffort, Language: rt.DefaultLanguage},
+		Defaults:       echocontext.SessionDefaults{Model: rt.Model, System: system, ReasoningEffort: rt.ReasoningEffort, Language: rt.DefaultLanguage, Tools: sessionTools(mcpManager, []string(cli.configOverrides)), Sampling: rt.Sampling},
 		ToolTimeout:    toolTimeout,
 		RequestTimeout: time.Duration(rt.RequestTimeoutSecs) * time.Second,
 		Retries:        rt.Retries,
+		Snapshots:      ghostSnapshotter(workdir, []string(cli.configOverrides)),
+		OnRecord:       recordSession(disp.ApprovalMemory(), workdir),
 	})
 	engine.Start(context.Background())
 	defer engine.Close()
 	gateway := repl.NewGateway(manager)
 
-	seedSessionID := cli.resumeSessionID
-	if seedSessionID == "" && len(seedMessages) > 0 {
-		seedSessionID = uuid.NewString()
-	}
-	if seedSessionID != "" && len(seedMessages) > 0 {
-		// 会话文件可能包含 role="tool" 的 UI 调试块；喂给模型前必须过滤。
-		engine.SeedHistory(seedSessionID, extractConversationHistory(seedMessages))
-	}
-
-	attachments := append([]agent.Message{}, seedMessages...)
-	attachments = append(attachments, loadImageAttachments([]string(cli.imagePaths), workdir)...)
+	var attachments []agent.Message
+	if resumed != nil {
+		// 以保存的 ResponseItem 原样重建上下文；UI 转录只用于重新渲染。
+		engine.RestoreSession(resumed.ID, resumed.Items, resumed.Turns)
+		disp.ApprovalMemory().Seed(resumed.ID, resumed.Approvals)
+		attachments = append(attachments, resumed.Messages...)
+	}
+	imageNotes, images := loadImageAttachments([]string(cli.imagePaths), workdir)
+	attachments = append(attachments, imageNotes...)
 	uiResult, err := repl.RunUI(repl.UIOptions{
 		Engine:          engine,
 		Gateway:         gateway,
@@ -224,14 +246,17 @@ func startInteractiveSession(cli *interactiveArgs, seedMessages []agent.Message)
 		InitialPrompt:   cli.prompt,
 		Language:        rt.DefaultLanguage,
 		InitialMessages: attachments,
+		Images:          images,
 		Events:          bus,
 		Runner:          runner,
 		ResumePicker:    cli.resumePicker,
 		ResumeShowAll:   cli.resumeShowAll,
-		ResumeSessions:  resumeIDs,
-		ResumeSessionID: seedSessionID,
+		ResumeSessions:  resumeSessions,
+		ResumeSessionID: cli.resumeSessionID,
 		ConversationLog: conversationLog,
 		CopyableOutput:  cli.copyableOutput,
+		MCP:             mcpManager,
+		ApprovalMemory:  disp.ApprovalMemory(),
 	})
 	if err != nil {
 		log.Fatalf("program exit: %v", err)
@@ -244,7 +269,14 @@ func startInteractiveSession(cli *interactiveArgs, seedMessages []agent.Message)
 	if id := uiResult.SessionID; id != "" {
 		sessionID = id
 	}
-	savedID, err := session.Save(sessionID, workdir, history)
+	savedID, err := session.SaveRecord(session.Record{
+		ID:        sessionID,
+		Workdir:   workdir,
+		Messages:  history,
+		Items:     engine.ResponseHistory(sessionID),
+		Turns:     engine.Turns(sessionID),
+		Approvals: disp.ApprovalMemory().Grants(sessionID),
+	})
 	if err != nil {
 		log.Warnf("failed to save session: %v", err)
 		return
@@ -258,28 +290,19 @@ func startInteractiveSession(cli *interactiveArgs, seedMessages []agent.Message)
 	printExitSummary(savedID, usage)
 }
 
-func buildModelClient(endpoint config.Config, model string, oss bool) agent.ModelClient {
-	if oss {
-		log.Info("OSS provider configured; using echo fallback (implement OSS client to enable responses).")
-		return agent.EchoClient{Prefix: "assistant: "}
-	}
-	token := strings.TrimSpace(endpoint.Token)
-	if token == "" {
-		return agent.EchoClient{Prefix: "assistant: "}
-	}
-	if strings.TrimSpace(endpoint.URL) == "" {
-		log.Warnf("empty url in config; falling back to echo mode")
-		return agent.EchoClient{Prefix: "assistant: "}
-	}
-	client, err := anthropicmodel.New(anthropicmodel.Options{
-		Token:   token,
-		BaseURL: endpoint.URL,
-		Model:   model,
-	})
-	if err != nil {
-		log.Fatalf("failed to init anthropic client: %v", err)
+// recordSession 返回 execution.Options.OnRecord：会话历史或回合变化时立即把新增部分追加到 rollout 文件，
+// 进程被杀或崩溃时不会丢失已记录的内容。UI 转录在退出时写入。
+func recordSession(memory *tools.ApprovalMemory, workdir string) func(string, []echocontext.ResponseItem, []echocontext.TurnRecord) {
+	recorder := session.NewRecorder()
+	return func(sessionID string, items []echocontext.ResponseItem, turns []echocontext.TurnRecord) {
+		if items == nil {
+			items = []echocontext.ResponseItem{}
+		}
+		rec := session.Record{ID: sessionID, Workdir: workdir, Items: items, Turns: turns, Approvals: memory.Grants(sessionID)}
+		if _, err := recorder.Save(rec); err != nil {
+			log.Warnf("failed to record session %s: %v", sessionID, err)
+		}
 	}
-	return client
 }
 
 type usageSummary struct {
@@ -327,6 +350,14 @@ func runUpdateAction(action string) error {
 	return cmd.Run()
 }
 
+// ghostSnapshotter 在 undo 特性开启时返回基于 git 的快照器；关闭时返回 nil 以禁用快照。
+func ghostSnapshotter(workdir string, overrides []string) execution.GhostSnapshotter {
+	if !featureEnabled("undo", overrides) {
+		return nil
+	}
+	return ghost.Snapshotter{Workdir: workdir}
+}
+
 func resolveWorkdir(input string) string {
 	if strings.TrimSpace(input) == "" {
 		wd, err := os.Getwd()
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/config"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/events"
+	"echo-cli/internal/execution"
+	"echo-cli/internal/i18n"
+	"echo-cli/internal/instructions"
+	"echo-cli/internal/mcp"
+	"echo-cli/internal/repl"
+	"echo-cli/internal/search"
+	"echo-cli/internal/tools"
+	"echo-cli/internal/tools/dispatcher"
+)
+
+// connectMCPServers 连接 config.toml 中配置的 MCP 服务器；rmcp_client 特性关闭或未配置服务器时返回 nil。
+func connectMCPServers(ctx context.Context, cfg config.Config, overrides []string) *mcp.Manager {
+	if len(cfg.MCPServers) == 0 || !featureEnabled("rmcp_client", overrides) {
+		return nil
+	}
+	return mcp.ConnectAll(ctx, cfg.MCPServers)
+}
+
+// sessionTools 返回内置工具之外向模型公开的工具：view_image（features.view_image_tool）与 MCP 工具。
+func sessionTools(mcpManager *mcp.Manager, overrides []string) []agent.ToolSpec {
+	var specs []agent.ToolSpec
+	if featureEnabled("view_image_tool", overrides) {
+		specs = append(specs, agent.ViewImageTool())
+	}
+	return append(specs, mcpManager.ToolSpecs()...)
+}
+
+// mcpMain 实现 `echo-cli mcp list`：连接所有已配置服务器并输出其健康状态与工具。
+func mcpMain(root rootArgs, args []string) {
+	sub := "list"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		sub = args[0]
+		args = args[1:]
+	}
+	if sub != "list" {
+		log.Fatalf("unknown mcp subcommand %q (supported: list)", sub)
+	}
+
+	fs := flag.NewFlagSet("mcp list", flag.ExitOnError)
+	var cfgPath string
+	var overrides stringSlice
+	var jsonOutput bool
+	fs.StringVar(&cfgPath, "config", "", "Path to config file (default ~/.echo/config.toml)")
+	fs.Var(&overrides, "c", "Override config value key=value (repeatable)")
+	fs.BoolVar(&jsonOutput, "json", false, "Print server status as JSON")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("parse mcp args: %v", err)
+	}
+	allOverrides := prependOverrides(root.overrides, []string(overrides))
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	cfg = config.ApplyKVOverrides(cfg, allOverrides)
+	if !featureEnabled("rmcp_client", allOverrides) {
+		log.Fatalf("MCP client is disabled (features.rmcp_client=false)")
+	}
+
+	manager := mcp.ConnectAll(context.Background(), cfg.MCPServers)
+	defer manager.Close()
+	statuses := manager.Status()
+	if jsonOutput {
+		data, _ := json.MarshalIndent(statuses, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Fprintln(os.Stdout, mcp.FormatStatus(statuses))
+}
+
+// mcpServerMain 实现 `echo-cli mcp-server`：在 stdio 上提供 MCP 服务，run_task 工具经 SQ 提交到执行引擎。
+// stdout 专用于协议消息，日志只写入日志文件。
+func mcpServerMain(root rootArgs, args []string) {
+	fs := flag.NewFlagSet("mcp-server", flag.ExitOnError)
+	var cfgPath string
+	var overrides stringSlice
+	var modelOverride string
+	var configProfile string
+	var workdir string
+	var sandboxMode string
+	fs.StringVar(&cfgPath, "config", "", "Path to config file (default ~/.echo/config.toml)")
+	fs.Var(&overrides, "c", "Override config value key=value (repeatable)")
+	fs.StringVar(&modelOverride, "model", "", "Model override")
+	fs.StringVar(&configProfile, "profile", "", "Config profile to use")
+	fs.StringVar(&configProfile, "p", "", "Alias for --profile")
+	fs.StringVar(&workdir, "cd", "", "Working directory for tasks")
+	fs.StringVar(&sandboxMode, "sandbox", "", "Sandbox mode for commands (read-only|workspace-write|full-access)")
+	fs.StringVar(&sandboxMode, "s", "", "Alias for --sandbox")
+	if err := fs.Parse(args); err != nil {
+		log.Fatalf("parse mcp-server args: %v", err)
+	}
+	allOverrides := prependOverrides(root.overrides, []string(overrides))
+
+	endpoint, err := config.Load(cfgPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	endpoint, profileOverrides := applyConfigProfile(endpoint, configProfile)
+	echocontext.SetModelCatalog(modelCatalog(endpoint))
+	search.SetIgnorePatterns(endpoint.Search.Ignore)
+	endpoint = selectProvider(endpoint, providerFlags{}, allOverrides)
+	rt := applyRuntimeKVOverrides(defaultRuntimeConfig(), profileOverrides)
+	if strings.TrimSpace(endpoint.Model) != "" {
+		rt.Model = strings.TrimSpace(endpoint.Model)
+	}
+	if strings.TrimSpace(modelOverride) != "" {
+		rt.Model = strings.TrimSpace(modelOverride)
+	}
+	if strings.TrimSpace(sandboxMode) != "" {
+		rt.SandboxMode = strings.TrimSpace(sandboxMode)
+	}
+	rt = applyRuntimeKVOverrides(rt, allOverrides)
+	allOverrides = withProfileOverrides(profileOverrides, allOverrides)
+	if strings.TrimSpace(rt.DefaultLanguage) == "" {
+		rt.DefaultLanguage = i18n.DefaultLanguage.Code()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workdir = resolveWorkdir(workdir)
+	client := buildModelClient(endpoint, rt.Model)
+	system := instructions.Discover(workdir)
+	bus := events.NewBus()
+	defer bus.Close()
+	mcpManager := connectMCPServers(ctx, endpoint, allOverrides)
+	defer mcpManager.Close()
+	policy, rules := approvalOptions(rt, endpoint, tools.ApprovalOnRequest)
+	disp := dispatcher.New(tools.DirectRunner{}, bus, workdir, dispatcher.Options{
+		Handlers: mcpManager.Handlers(),
+		Reviewer: commandReviewer(client, rt.Model, policy),
+		Policy:   policy,
+		Rules:    rules,
+		Memory:   tools.NewApprovalMemory(tools.NewProjectApprovals(workdir)),
+		Sandbox:  sandboxPolicy(rt, endpoint, workdir),
+	})
+	disp.Start(ctx)
+
+	manager := events.NewManager(events.ManagerConfig{})
+	toolTimeout := time.Duration(rt.ToolTimeoutSecs) * time.Second
+	if toolTimeout == 0 {
+		toolTimeout = 10 * time.Minute
+	}
+	engine := execution.NewEngine(execution.Options{
+		Manager:        manager,
+		Client:         client,
+		Bus:            bus,
+		Defaults:       echocontext.SessionDefaults{Model: rt.Model, System: system, ReasoningEffort: rt.ReasoningEffort, Language: rt.DefaultLanguage, Tools: sessionTools(mcpManager, allOverrides), Sampling: rt.Sampling},
+		ToolTimeout:    toolTimeout,
+		RequestTimeout: time.Duration(rt.RequestTimeoutSecs) * time.Second,
+		Retries:        rt.Retries,
+		Snapshots:      ghostSnapshotter(workdir, allOverrides),
+	})
+	engine.Start(ctx)
+	defer engine.Close()
+
+	server := mcp.NewServer(mcp.ServerOptions{
+		Gateway:         repl.NewGateway(manager),
+		Model:           rt.Model,
+		System:          system,
+		Language:        rt.DefaultLanguage,
+		ReasoningEffort: rt.ReasoningEffort,
+	})
+	log.Infof("mcp-server listening on stdio workdir=%s model=%s", workdir, rt.Model)
+	if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+		log.Fatalf("mcp-server: %v", err)
+	}
+}
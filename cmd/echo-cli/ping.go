@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"echo-cli/internal/agent"
-	anthropicmodel "echo-cli/internal/agent/anthropic"
 	"echo-cli/internal/config"
 )
 
@@ -28,6 +27,7 @@ func runPing(root rootArgs, args []string, out io.Writer) error {
 	var cfgPath string
 	var providerOverride string
 	var modelOverride string
+	var configProfile string
 	var baseURLOverride string
 	var apiKeyOverride string
 	var timeoutSeconds int
@@ -35,6 +35,7 @@ func runPing(root rootArgs, args []string, out io.Writer) error {
 	fs.StringVar(&cfgPath, "config", "", "Path to config file (default ~/.echo/config.toml)")
 	fs.StringVar(&providerOverride, "provider", "", "Provider name (default from config)")
 	fs.StringVar(&modelOverride, "model", "", "Model name (default from config)")
+	fs.StringVar(&configProfile, "profile", "", "Config profile to use")
 	fs.StringVar(&baseURLOverride, "base-url", "", "Override base URL (e.g. http://127.0.0.1:1234; trailing /v1 is ok)")
 	fs.StringVar(&apiKeyOverride, "api-key", "", "Override API key (prefer config.toml)")
 	fs.IntVar(&timeoutSeconds, "timeout", 0, "Timeout seconds (default from config)")
@@ -47,11 +48,12 @@ func runPing(root rootArgs, args []string, out io.Writer) error {
 	if err != nil {
 		return err
 	}
-	cfg = config.ApplyKVOverrides(cfg, prependOverrides(root.overrides, nil))
-
-	if strings.TrimSpace(providerOverride) != "" {
-		log.Warnf("provider override %q is ignored; echo-cli now configures only url/token/model", providerOverride)
+	cfg, _, err = cfg.ApplyProfile(configProfile)
+	if err != nil {
+		return err
 	}
+	cfg = selectProvider(cfg, providerFlags{provider: providerOverride}, prependOverrides(root.overrides, nil))
+	provider := config.NormalizeProvider(cfg.Provider)
 
 	model := strings.TrimSpace(modelOverride)
 	if model == "" {
@@ -61,19 +63,20 @@ func runPing(root rootArgs, args []string, out io.Writer) error {
 		model = defaultRuntimeConfig().Model
 	}
 
-	baseURL := strings.TrimSpace(baseURLOverride)
-	if baseURL == "" {
-		baseURL = strings.TrimSpace(cfg.URL)
+	if baseURL := strings.TrimSpace(baseURLOverride); baseURL != "" {
+		cfg.URL = baseURL
 	}
-	if baseURL == "" {
+	if strings.TrimSpace(cfg.URL) == "" {
 		return errors.New("missing url: set ANTHROPIC_BASE_URL or configure url in ~/.echo/config.toml")
 	}
 
-	apiKey := strings.TrimSpace(apiKeyOverride)
-	if apiKey == "" {
-		apiKey = strings.TrimSpace(cfg.Token)
+	if apiKey := strings.TrimSpace(apiKeyOverride); apiKey != "" {
+		cfg.Token = apiKey
 	}
-	if apiKey == "" {
+	if strings.TrimSpace(cfg.Token) == "" && !config.IsLocalProvider(provider) {
+		if provider == config.ProviderOpenAI {
+			return errors.New("missing token: set OPENAI_API_KEY or configure token in ~/.echo/config.toml")
+		}
 		return errors.New("missing token: set ANTHROPIC_AUTH_TOKEN or configure token in ~/.echo/config.toml")
 	}
 
@@ -84,13 +87,9 @@ func runPing(root rootArgs, args []string, out io.Writer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
 	defer cancel()
 
-	client, err := anthropicmodel.New(anthropicmodel.Options{
-		Token:   apiKey,
-		BaseURL: baseURL,
-		Model:   model,
-	})
+	client, err := newModelClient(cfg, model)
 	if err != nil {
-		return fmt.Errorf("init anthropic client: %w", err)
+		return fmt.Errorf("init %s client: %w", provider, err)
 	}
 	got, err := client.Complete(ctx, agent.Prompt{
 		Model: model,
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"echo-cli/internal/agent"
+	anthropicmodel "echo-cli/internal/agent/anthropic"
+	openaimodel "echo-cli/internal/agent/openai"
+	"echo-cli/internal/config"
+)
+
+// providerFlags 是 --provider / --oss / --local-provider 的解析结果。
+type providerFlags struct {
+	provider      string
+	oss           bool
+	localProvider string
+}
+
+// selectProvider 在 -c 覆盖之后应用 provider 相关参数：--oss 优先于 --provider，再优先于配置文件。
+// 切换到与配置文件不同的 provider 时，文件中的 url/token 不再沿用，只保留 -c 显式覆盖的值。
+func selectProvider(endpoint config.Config, flags providerFlags, overrides []string) config.Config {
+	base := config.NormalizeProvider(endpoint.Provider)
+	endpoint = config.ApplyKVOverrides(endpoint, overrides)
+	want := endpoint.Provider
+	if p := strings.TrimSpace(flags.provider); p != "" {
+		want = p
+	}
+	if flags.oss {
+		want = config.ProviderOllama
+		if lp := strings.TrimSpace(flags.localProvider); lp != "" {
+			want = lp
+		}
+	} else if strings.TrimSpace(flags.localProvider) != "" {
+		log.Warnf("local-provider=%q ignored unless --oss is set", flags.localProvider)
+	}
+	want = config.NormalizeProvider(want)
+	if flags.oss && !config.IsLocalProvider(want) {
+		log.Warnf("unknown local provider %q; using %s", flags.localProvider, config.ProviderOllama)
+		want = config.ProviderOllama
+	}
+	if want != base {
+		endpoint.URL, endpoint.Token = "", ""
+		endpoint = config.ApplyKVOverrides(endpoint, overrides)
+	}
+	endpoint.Provider = want
+	return config.ResolveEndpoint(endpoint)
+}
+
+// newModelClient 按 provider 创建模型客户端。
+func newModelClient(endpoint config.Config, model string) (agent.ModelClient, error) {
+	provider := config.NormalizeProvider(endpoint.Provider)
+	switch provider {
+	case config.ProviderAnthropic:
+		return anthropicmodel.New(anthropicmodel.Options{
+			Token:   endpoint.Token,
+			BaseURL: endpoint.URL,
+			Model:   model,
+		})
+	case config.ProviderOpenAI, config.ProviderOllama, config.ProviderLMStudio:
+		if provider == config.ProviderOpenAI && strings.TrimSpace(endpoint.Token) == "" {
+			return nil, fmt.Errorf("missing token for provider %s", provider)
+		}
+		return openaimodel.New(openaimodel.Options{
+			Token:   endpoint.Token,
+			BaseURL: endpoint.URL,
+			Model:   model,
+			WireAPI: endpoint.WireAPI,
+		})
+	default:
+		return nil, fmt.Errorf("unknown provider %q (supported: anthropic, openai, ollama, lmstudio)", endpoint.Provider)
+	}
+}
+
+// buildModelClient 创建模型客户端；缺少 anthropic 凭据时回退到 echo 模式。
+func buildModelClient(endpoint config.Config, model string) agent.ModelClient {
+	if config.NormalizeProvider(endpoint.Provider) == config.ProviderAnthropic {
+		if strings.TrimSpace(endpoint.Token) == "" {
+			return agent.EchoClient{Prefix: "assistant: "}
+		}
+		if strings.TrimSpace(endpoint.URL) == "" {
+			log.Warnf("empty url in config; falling back to echo mode")
+			return agent.EchoClient{Prefix: "assistant: "}
+		}
+	}
+	client, err := newModelClient(endpoint, model)
+	if err != nil {
+		log.Fatalf("failed to init %s client: %v", config.NormalizeProvider(endpoint.Provider), err)
+	}
+	log.Infof("model provider=%s url=%s model=%s", config.NormalizeProvider(endpoint.Provider), endpoint.URL, model)
+	return client
+}
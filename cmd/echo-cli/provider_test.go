@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"echo-cli/internal/config"
+)
+
+func TestSelectProvider_OSSDropsFileEndpoint(t *testing.T) {
+	file := config.Config{URL: "https://anthropic.example", Token: "secret", Model: "glm4.6"}
+
+	got := selectProvider(file, providerFlags{oss: true, localProvider: "lm-studio"}, nil)
+	if got.Provider != config.ProviderLMStudio || got.URL != "http://localhost:1234/v1" || got.Token != "" {
+		t.Fatalf("unexpected endpoint %+v", got)
+	}
+
+	got = selectProvider(file, providerFlags{oss: true}, []string{"url=http://gpu-box:11434/v1"})
+	if got.Provider != config.ProviderOllama || got.URL != "http://gpu-box:11434/v1" {
+		t.Fatalf("explicit -c url should survive provider switch, got %+v", got)
+	}
+
+	got = selectProvider(file, providerFlags{}, nil)
+	if got.Provider != config.ProviderAnthropic || got.URL != file.URL || got.Token != file.Token {
+		t.Fatalf("default provider should keep file endpoint, got %+v", got)
+	}
+}
+
+func TestNewModelClient_UnknownProvider(t *testing.T) {
+	if _, err := newModelClient(config.Config{Provider: "bedrock"}, "m"); err == nil {
+		t.Fatalf("expected unknown provider error")
+	}
+	if _, err := newModelClient(config.Config{Provider: config.ProviderOllama, URL: "http://localhost:11434/v1"}, "m"); err != nil {
+		t.Fatalf("local provider should not need a token: %v", err)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"strings"
 
-	"echo-cli/internal/agent"
 	"echo-cli/internal/session"
 )
 
@@ -34,7 +33,7 @@ func resumeMain(root rootArgs, args []string) {
 	cli.resumeShowAll = resumeAll
 	cli.configOverrides = stringSlice(prependOverrides(root.overrides, []string(cli.configOverrides)))
 
-	var seed []agent.Message
+	var resumed *session.Record
 	if sessionID != "" || resumeLast {
 		var rec session.Record
 		var err error
@@ -46,8 +45,8 @@ func resumeMain(root rootArgs, args []string) {
 		if err != nil {
 			log.Fatalf("failed to load session: %v", err)
 		}
-		seed = append(seed, rec.Messages...)
+		resumed = &rec
 	}
 
-	startInteractiveSession(cli, seed)
+	startInteractiveSession(cli, resumed)
 }
@@ -4,7 +4,12 @@ import (
 	"strconv"
 	"strings"
 
+	"echo-cli/internal/agent"
+	"echo-cli/internal/config"
+	echocontext "echo-cli/internal/context"
 	"echo-cli/internal/i18n"
+	"echo-cli/internal/sandbox"
+	"echo-cli/internal/tools"
 )
 
 type runtimeConfig struct {
@@ -14,6 +19,14 @@ type runtimeConfig struct {
 	RequestTimeoutSecs int
 	ToolTimeoutSecs    int
 	Retries            int
+	// ApprovalPolicy 为空时由入口决定默认值（交互式 on-request，exec never）。
+	ApprovalPolicy string
+	// SandboxMode 为空时沿用 full-access（不启用沙箱）。
+	SandboxMode string
+	// SandboxNetworkAccess 非 nil 时覆盖 [sandbox] network_access。
+	SandboxNetworkAccess *bool
+	// Sampling 覆盖 [models.<name>] 中的采样参数（max_output_tokens、temperature 等）。
+	Sampling agent.SamplingOptions
 }
 
 func defaultRuntimeConfig() runtimeConfig {
@@ -50,11 +63,148 @@ func applyRuntimeKVOverrides(cfg runtimeConfig, overrides []string) runtimeConfi
 			if n, err := strconv.Atoi(val); err == nil && n > 0 {
 				cfg.ToolTimeoutSecs = n
 			}
+		case "approval_policy", "approval-policy", "ask_for_approval":
+			cfg.ApprovalPolicy = val
+		case "sandbox_mode", "sandbox-mode", "sandbox":
+			cfg.SandboxMode = val
+		case "sandbox.network_access", "sandbox_network_access":
+			if b, err := strconv.ParseBool(val); err == nil {
+				cfg.SandboxNetworkAccess = &b
+			}
 		case "retries":
 			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
 				cfg.Retries = n
 			}
+		case "max_output_tokens", "max_tokens", "max-tokens":
+			if n, err := strconv.ParseInt(val, 10, 64); err == nil && n > 0 {
+				cfg.Sampling.MaxTokens = n
+			}
+		case "temperature":
+			if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 {
+				cfg.Sampling.Temperature = &f
+			}
+		case "top_p", "top-p":
+			if f, err := strconv.ParseFloat(val, 64); err == nil && f > 0 && f <= 1 {
+				cfg.Sampling.TopP = &f
+			}
+		case "stop_sequences", "stop":
+			cfg.Sampling.StopSequences = nil
+			for _, seq := range strings.Split(val, ",") {
+				if seq = strings.TrimSpace(seq); seq != "" {
+					cfg.Sampling.StopSequences = append(cfg.Sampling.StopSequences, seq)
+				}
+			}
+		case "thinking_budget", "thinking-budget":
+			if n, err := strconv.ParseInt(val, 10, 64); err == nil && n >= 0 {
+				cfg.Sampling.ThinkingBudget = n
+			}
 		}
 	}
 	return cfg
 }
+
+// applyConfigProfile 选择配置 profile（--profile 优先于配置文件中的 profile 字段）。
+// 返回的覆盖项只应先于 flag 与 -c 应用，使优先级保持：默认值 < 配置文件 < profile < flag < -c。
+func applyConfigProfile(endpoint config.Config, name string) (config.Config, []string) {
+	merged, overrides, err := endpoint.ApplyProfile(name)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if merged.Profile != "" {
+		log.Infof("using config profile %q", merged.Profile)
+	}
+	// 顶层的运行时设置同样以覆盖项表示，排在 profile 之前。
+	var base []string
+	if policy := strings.TrimSpace(endpoint.ApprovalPolicy); policy != "" {
+		base = append(base, "approval_policy="+policy)
+	}
+	if mode := strings.TrimSpace(endpoint.SandboxMode); mode != "" {
+		base = append(base, "sandbox_mode="+mode)
+	}
+	return merged, withProfileOverrides(base, overrides)
+}
+
+// withProfileOverrides 把 profile 展开的覆盖项放在命令行覆盖之前，供 feature 开关等按顺序读取覆盖的调用方使用。
+func withProfileOverrides(profile []string, overrides []string) []string {
+	if len(profile) == 0 {
+		return overrides
+	}
+	out := make([]string, 0, len(profile)+len(overrides))
+	out = append(out, profile...)
+	return append(out, overrides...)
+}
+
+// modelCatalog 由 [models.<name>] 构建模型目录，供 token 估算与自动压缩使用。
+func modelCatalog(endpoint config.Config) *echocontext.ModelCatalog {
+	models := make(map[string]echocontext.ModelInfo, len(endpoint.Models))
+	for name, m := range endpoint.Models {
+		models[name] = echocontext.ModelInfo{
+			ContextWindow:         m.ContextWindow,
+			MaxOutputTokens:       m.MaxOutputTokens,
+			AutoCompactTokenLimit: m.AutoCompactTokenLimit,
+			Tokenizer:             m.Tokenizer,
+			Reasoning:             m.Reasoning,
+			Temperature:           m.Temperature,
+			TopP:                  m.TopP,
+			StopSequences:         m.StopSequences,
+			ThinkingBudget:        m.ThinkingBudget,
+		}
+	}
+	return echocontext.NewModelCatalog(models)
+}
+
+// approvalOptions 解析审批策略与 [approvals] 规则；未配置策略时使用 fallback。
+func approvalOptions(rt runtimeConfig, endpoint config.Config, fallback tools.ApprovalPolicy) (tools.ApprovalPolicy, tools.ApprovalRules) {
+	policy := fallback
+	if strings.TrimSpace(rt.ApprovalPolicy) != "" {
+		parsed, err := tools.ParseApprovalPolicy(rt.ApprovalPolicy)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		policy = parsed
+	}
+	rules := tools.ApprovalRules{
+		AllowCommands: endpoint.Approvals.AllowCommands,
+		DenyCommands:  endpoint.Approvals.DenyCommands,
+		AllowPaths:    endpoint.Approvals.AllowPaths,
+		DenyPaths:     endpoint.Approvals.DenyPaths,
+	}
+	log.Infof("approval policy=%s allow_commands=%d deny_commands=%d allow_paths=%d deny_paths=%d",
+		policy, len(rules.AllowCommands), len(rules.DenyCommands), len(rules.AllowPaths), len(rules.DenyPaths))
+	return policy, rules
+}
+
+// sandboxPolicy 解析沙箱模式与 [sandbox] 设置；受限模式下内核不支持时仍按配置执行（命令会被拒绝并可提权审批）。
+func sandboxPolicy(rt runtimeConfig, endpoint config.Config, workdir string) sandbox.Policy {
+	mode, err := sandbox.ParseMode(rt.SandboxMode)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	policy := sandbox.Policy{
+		Mode:          mode,
+		Workdir:       workdir,
+		WritableRoots: endpoint.Sandbox.WritableRoots,
+		NetworkAccess: endpoint.Sandbox.NetworkAccess,
+	}
+	if rt.SandboxNetworkAccess != nil {
+		policy.NetworkAccess = *rt.SandboxNetworkAccess
+	}
+	if policy.Active() {
+		if err := sandbox.Supported(); err != nil {
+			log.Warnf("sandbox mode %s requested but unavailable: %v", mode, err)
+		}
+	}
+	log.Infof("sandbox %s", policy.Describe())
+	return policy
+}
+
+// commandReviewer 只在 on-request 策略且连接了真实模型时启用 LLM 命令审查。
+func commandReviewer(client agent.ModelClient, model string, policy tools.ApprovalPolicy) tools.CommandReviewer {
+	if policy != tools.ApprovalOnRequest {
+		return nil
+	}
+	if _, echo := client.(agent.EchoClient); echo {
+		return nil
+	}
+	return tools.NewLLMCommandReviewer(client, model)
+}
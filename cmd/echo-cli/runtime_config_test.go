@@ -13,3 +13,36 @@ func TestApplyRuntimeKVOverrides_ToolTimeoutSeconds(t *testing.T) {
 		t.Fatalf("expected ToolTimeoutSecs=900, got %d", got.ToolTimeoutSecs)
 	}
 }
+
+func TestProfileOverridesRankBelowFlagsAndCLI(t *testing.T) {
+	profile := []string{"reasoning_effort=low", "retries=2", "features.undo=false"}
+	cli := []string{"retries=5"}
+
+	rt := applyRuntimeKVOverrides(defaultRuntimeConfig(), profile)
+	rt.ReasoningEffort = "high" // --reasoning-effort
+	rt = applyRuntimeKVOverrides(rt, cli)
+	if rt.ReasoningEffort != "high" || rt.Retries != 5 {
+		t.Fatalf("unexpected runtime config %+v", rt)
+	}
+
+	all := withProfileOverrides(profile, append(cli, "features.undo=true"))
+	if !featureEnabled("undo", all) {
+		t.Fatalf("-c feature override should win over profile")
+	}
+	if featureEnabled("undo", withProfileOverrides(profile, cli)) {
+		t.Fatalf("profile feature override should apply")
+	}
+}
+
+func TestApplyRuntimeKVOverrides_Sampling(t *testing.T) {
+	rt := applyRuntimeKVOverrides(defaultRuntimeConfig(), []string{
+		"max_output_tokens=4096", "temperature=0.2", "top_p=0.9", "stop_sequences=END, ###", "thinking_budget=2048", "top_p=3",
+	})
+	s := rt.Sampling
+	if s.MaxTokens != 4096 || s.Temperature == nil || *s.Temperature != 0.2 || s.TopP == nil || *s.TopP != 0.9 || s.ThinkingBudget != 2048 {
+		t.Fatalf("unexpected sampling %+v", s)
+	}
+	if len(s.StopSequences) != 2 || s.StopSequences[1] != "###" {
+		t.Fatalf("unexpected stop sequences %q", s.StopSequences)
+	}
+}
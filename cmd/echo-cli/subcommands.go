@@ -122,18 +122,6 @@ func applyMain(root rootArgs, args []string) {
 	fmt.Printf("Applied patch from %s\n", patchPath)
 }
 
-func mcpMain(root rootArgs, args []string) {
-	if err := delegateEchoRS("mcp", root, args); err != nil {
-		log.Fatalf("mcp command is not available: %v", err)
-	}
-}
-
-func mcpServerMain(root rootArgs, args []string) {
-	if err := delegateEchoRS("mcp-server", root, args); err != nil {
-		log.Fatalf("mcp-server command is not available: %v", err)
-	}
-}
-
 func cloudMain(root rootArgs, args []string) {
 	if err := delegateEchoRS("cloud", root, args); err != nil {
 		log.Fatalf("cloud tasks are not available: %v", err)
@@ -110,7 +110,7 @@ func (c *Client) streamOnce(ctx context.Context, params anthropic.MessageNewPara
 	buffered := make([]agent.StreamEvent, 0, 8)
 	wrappedOnEvent := func(evt agent.StreamEvent) {
 		switch evt.Type {
-		case agent.StreamEventTextDelta:
+		case agent.StreamEventTextDelta, agent.StreamEventReasoningDelta:
 			if evt.Text != "" {
 				emittedText = true
 			}
@@ -129,7 +129,7 @@ func (c *Client) streamOnce(ctx context.Context, params anthropic.MessageNewPara
 			return
 		}
 		buffered = append(buffered, evt)
-		if (evt.Type == agent.StreamEventTextDelta && evt.Text != "") || (evt.Type == agent.StreamEventItem && len(evt.Item) > 0) {
+		if ((evt.Type == agent.StreamEventTextDelta || evt.Type == agent.StreamEventReasoningDelta) && evt.Text != "") || (evt.Type == agent.StreamEventItem && len(evt.Item) > 0) {
 			streaming = true
 			for _, queued := range buffered {
 				onEvent(queued)
@@ -234,6 +234,8 @@ func (c *Client) streamOnce(ctx context.Context, params anthropic.MessageNewPara
 func buildMessageParams(prompt agent.Prompt, model anthropic.Model) anthropic.MessageNewParams {
 	var system []anthropic.TextBlockParam
 	var messages []anthropic.MessageParam
+	// stableSystem 是对话之前的 system 块数量（系统提示词、AGENTS 指令等），它们在各轮之间保持不变。
+	stableSystem := 0
 
 	for _, msg := range prompt.Messages {
 		switch msg.Role {
@@ -243,34 +245,150 @@ func buildMessageParams(prompt agent.Prompt, model anthropic.Model) anthropic.Me
 				continue
 			}
 			system = append(system, anthropic.TextBlockParam{Text: text})
-		case agent.RoleAssistant:
-			blocks := messageBlocks(msg)
-			if len(blocks) == 0 {
-				continue
+			if len(messages) == 0 {
+				stableSystem = len(system)
 			}
-			messages = append(messages, anthropic.NewAssistantMessage(blocks...))
+		case agent.RoleAssistant:
+			messages = appendBlocks(messages, anthropic.MessageParamRoleAssistant, messageBlocks(msg))
 		default:
-			blocks := messageBlocks(msg)
-			if len(blocks) == 0 {
-				continue
-			}
-			messages = append(messages, anthropic.NewUserMessage(blocks...))
+			messages = appendBlocks(messages, anthropic.MessageParamRoleUser, messageBlocks(msg))
 		}
 	}
 
 	params := anthropic.MessageNewParams{
 		Model:     model,
-		MaxTokens: 1024,
+		MaxTokens: agent.DefaultMaxOutputTokens,
 		Messages:  messages,
 		Tools:     toolSpecsToParams(prompt.Tools),
 	}
 	if len(system) > 0 {
 		params.System = system
 	}
+	sampling := prompt.Sampling
+	if sampling.ThinkingBudget <= 0 {
+		sampling.ThinkingBudget = agent.ThinkingBudgetForEffort(prompt.ReasoningEffort)
+	}
+	if sampling.ThinkingBudget > 0 && pendingToolTurnWithoutThinking(messages) {
+		// 思考不能在一次工具调用循环中途开启：未带思考块的 tool_use 轮次之后本次请求不启用思考。
+		sampling.ThinkingBudget = 0
+	}
+	applySampling(&params, sampling)
+	applyCacheBreakpoints(&params, stableSystem)
 	return params
 }
 
+// maxHistoryBreakpoints 是历史中的滚动断点数；加上工具与 system 共 4 个，即 API 允许的上限。
+const maxHistoryBreakpoints = 2
+
+// applyCacheBreakpoints 在稳定前缀上放置 cache_control：工具定义末尾、对话前的 system 块末尾，
+// 以及最近两条 user 消息末尾。下一轮请求的前缀与本轮逐字节一致，可直接读取上一轮写入的缓存。
+func applyCacheBreakpoints(params *anthropic.MessageNewParams, stableSystem int) {
+	if n := len(params.Tools); n > 0 {
+		if cc := params.Tools[n-1].GetCacheControl(); cc != nil {
+			*cc = anthropic.NewCacheControlEphemeralParam()
+		}
+	}
+	if stableSystem > 0 && stableSystem <= len(params.System) {
+		params.System[stableSystem-1].CacheControl = anthropic.NewCacheControlEphemeralParam()
+	}
+	marked := 0
+	for i := len(params.Messages) - 1; i >= 0 && marked < maxHistoryBreakpoints; i-- {
+		if params.Messages[i].Role == anthropic.MessageParamRoleUser && markLastBlock(params.Messages[i].Content) {
+			marked++
+		}
+	}
+}
+
+// markLastBlock 在最后一个可缓存的内容块上设置断点；思考块不能携带 cache_control。
+func markLastBlock(blocks []anthropic.ContentBlockParamUnion) bool {
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if cc := blocks[i].GetCacheControl(); cc != nil {
+			*cc = anthropic.NewCacheControlEphemeralParam()
+			return true
+		}
+	}
+	return false
+}
+
+// appendBlocks 把内容块追加到消息列表；与上一条同角色时合并，使思考块、文本与 tool_use 位于同一轮，
+// 并行工具调用的结果也位于同一条 user 消息。
+func appendBlocks(messages []anthropic.MessageParam, role anthropic.MessageParamRole, blocks []anthropic.ContentBlockParamUnion) []anthropic.MessageParam {
+	if len(blocks) == 0 {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	if role == anthropic.MessageParamRoleAssistant {
+		return append(messages, anthropic.NewAssistantMessage(blocks...))
+	}
+	return append(messages, anthropic.NewUserMessage(blocks...))
+}
+
+// pendingToolTurnWithoutThinking 报告最后一个 assistant 轮次是否含 tool_use 却没有思考块，且其后只有工具结果。
+func pendingToolTurnWithoutThinking(messages []anthropic.MessageParam) bool {
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role == anthropic.MessageParamRoleUser {
+			for _, block := range msg.Content {
+				if block.OfToolResult == nil {
+					return false
+				}
+			}
+			continue
+		}
+		hasToolUse, hasThinking := false, false
+		for _, block := range msg.Content {
+			hasToolUse = hasToolUse || block.OfToolUse != nil
+			hasThinking = hasThinking || block.OfThinking != nil || block.OfRedactedThinking != nil
+		}
+		return hasToolUse && !hasThinking
+	}
+	return false
+}
+
+// applySampling 将采样参数映射到 Messages API；思考预算要求 max_tokens 大于预算，必要时抬高上限。
+func applySampling(params *anthropic.MessageNewParams, sampling agent.SamplingOptions) {
+	if sampling.MaxTokens > 0 {
+		params.MaxTokens = sampling.MaxTokens
+	}
+	if sampling.Temperature != nil {
+		params.Temperature = anthropic.Float(*sampling.Temperature)
+	}
+	if sampling.TopP != nil {
+		params.TopP = anthropic.Float(*sampling.TopP)
+	}
+	if len(sampling.StopSequences) > 0 {
+		params.StopSequences = append([]string(nil), sampling.StopSequences...)
+	}
+	if budget := sampling.ThinkingBudget; budget > 0 {
+		budget = max(budget, minThinkingBudget)
+		if params.MaxTokens <= budget {
+			params.MaxTokens = budget + agent.DefaultMaxOutputTokens
+		}
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(budget)
+		// 启用思考时 API 不接受自定义 temperature/top_p。
+		params.Temperature = anthropic.MessageNewParams{}.Temperature
+		params.TopP = anthropic.MessageNewParams{}.TopP
+	}
+}
+
+// minThinkingBudget 是 Messages API 接受的最小思考预算。
+const minThinkingBudget int64 = 1024
+
 func messageBlocks(msg agent.Message) []anthropic.ContentBlockParamUnion {
+	if r := msg.Reasoning; r != nil {
+		// 只有带签名（或已加密）的思考块可以回传；其他来源的思考内容不发送。
+		switch {
+		case r.Redacted != "":
+			return []anthropic.ContentBlockParamUnion{anthropic.NewRedactedThinkingBlock(r.Redacted)}
+		case r.Signature != "":
+			return []anthropic.ContentBlockParamUnion{anthropic.NewThinkingBlock(r.Signature, r.Text)}
+		default:
+			return nil
+		}
+	}
 	if msg.ToolResult != nil && msg.ToolResult.ToolUseID != "" {
 		return []anthropic.ContentBlockParamUnion{
 			anthropic.NewToolResultBlock(
@@ -285,6 +403,9 @@ func messageBlocks(msg agent.Message) []anthropic.ContentBlockParamUnion {
 			anthropic.NewToolUseBlock(msg.ToolUse.ID, msg.ToolUse.Input, msg.ToolUse.Name),
 		}
 	}
+	if len(msg.Parts) > 0 {
+		return partBlocks(msg.Parts)
+	}
 	text := strings.TrimSpace(msg.Content)
 	if text == "" {
 		return nil
@@ -292,6 +413,24 @@ func messageBlocks(msg agent.Message) []anthropic.ContentBlockParamUnion {
 	return []anthropic.ContentBlockParamUnion{anthropic.NewTextBlock(text)}
 }
 
+// partBlocks 把多模态片段映射为文本块与 base64 图片块。
+func partBlocks(parts []agent.ContentPart) []anthropic.ContentBlockParamUnion {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case agent.ContentPartImage:
+			if part.Data != "" {
+				blocks = append(blocks, anthropic.NewImageBlockBase64(part.MediaType, part.Data))
+			}
+		default:
+			if text := strings.TrimSpace(part.Text); text != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(text))
+			}
+		}
+	}
+	return blocks
+}
+
 func toolSpecsToParams(specs []agent.ToolSpec) []anthropic.ToolUnionParam {
 	out := make([]anthropic.ToolUnionParam, 0, len(specs))
 	for _, spec := range specs {
@@ -378,8 +517,16 @@ type pendingToolUse struct {
 	partial   strings.Builder
 }
 
+// pendingThinking 累积一个思考块的内容与签名，块结束时作为 reasoning item 下发。
+type pendingThinking struct {
+	text      strings.Builder
+	signature strings.Builder
+	redacted  string
+}
+
 type toolUseStreamState struct {
-	pending map[int64]*pendingToolUse
+	pending  map[int64]*pendingToolUse
+	thinking map[int64]*pendingThinking
 }
 
 type streamSummary struct {
@@ -523,7 +670,7 @@ func resolveFinishReason(summary *streamSummary) string {
 }
 
 func newToolUseStreamState() *toolUseStreamState {
-	return &toolUseStreamState{pending: make(map[int64]*pendingToolUse)}
+	return &toolUseStreamState{pending: make(map[int64]*pendingToolUse), thinking: make(map[int64]*pendingThinking)}
 }
 
 func (s *toolUseStreamState) Handle(event any, onEvent func(agent.StreamEvent)) (completed bool) {
@@ -546,6 +693,13 @@ func (s *toolUseStreamState) Handle(event any, onEvent func(agent.StreamEvent))
 				name:      b.Name,
 				startArgs: b.Input,
 			}
+		case anthropic.ThinkingBlock:
+			pending := &pendingThinking{}
+			pending.text.WriteString(b.Thinking)
+			pending.signature.WriteString(b.Signature)
+			s.thinking[v.Index] = pending
+		case anthropic.RedactedThinkingBlock:
+			s.thinking[v.Index] = &pendingThinking{redacted: b.Data}
 		}
 	case anthropic.ContentBlockDeltaEvent:
 		switch d := v.Delta.AsAny().(type) {
@@ -557,6 +711,17 @@ func (s *toolUseStreamState) Handle(event any, onEvent func(agent.StreamEvent))
 			if pending := s.pending[v.Index]; pending != nil {
 				pending.partial.WriteString(d.PartialJSON)
 			}
+		case anthropic.ThinkingDelta:
+			if pending := s.thinking[v.Index]; pending != nil {
+				pending.text.WriteString(d.Thinking)
+			}
+			if d.Thinking != "" {
+				onEvent(agent.StreamEvent{Type: agent.StreamEventReasoningDelta, Text: d.Thinking})
+			}
+		case anthropic.SignatureDelta:
+			if pending := s.thinking[v.Index]; pending != nil {
+				pending.signature.WriteString(d.Signature)
+			}
 		}
 	case anthropic.ContentBlockStopEvent:
 		s.flushIndex(v.Index, onEvent)
@@ -569,13 +734,16 @@ func (s *toolUseStreamState) Handle(event any, onEvent func(agent.StreamEvent))
 }
 
 func (s *toolUseStreamState) Flush(onEvent func(agent.StreamEvent)) {
-	if len(s.pending) == 0 {
+	if len(s.pending) == 0 && len(s.thinking) == 0 {
 		return
 	}
-	indexes := make([]int64, 0, len(s.pending))
+	indexes := make([]int64, 0, len(s.pending)+len(s.thinking))
 	for idx := range s.pending {
 		indexes = append(indexes, idx)
 	}
+	for idx := range s.thinking {
+		indexes = append(indexes, idx)
+	}
 	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
 	for _, idx := range indexes {
 		s.flushIndex(idx, onEvent)
@@ -583,6 +751,12 @@ func (s *toolUseStreamState) Flush(onEvent func(agent.StreamEvent)) {
 }
 
 func (s *toolUseStreamState) flushIndex(idx int64, onEvent func(agent.StreamEvent)) {
+	if thinking := s.thinking[idx]; thinking != nil {
+		delete(s.thinking, idx)
+		if raw := reasoningItem(thinking.text.String(), thinking.signature.String(), thinking.redacted); len(raw) > 0 {
+			onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: raw})
+		}
+	}
 	pending := s.pending[idx]
 	if pending == nil {
 		return
@@ -601,6 +775,31 @@ func (s *toolUseStreamState) flushIndex(idx int64, onEvent func(agent.StreamEven
 	onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: raw})
 }
 
+// reasoningItem 构造 reasoning ResponseItem 的 JSON；签名与加密内容原样保留以便回传。
+func reasoningItem(text, signature, redacted string) json.RawMessage {
+	if text == "" && redacted == "" {
+		return nil
+	}
+	payload := map[string]any{
+		"type":    "reasoning",
+		"summary": []any{},
+	}
+	if text != "" {
+		payload["content"] = []map[string]string{{"type": "reasoning_text", "text": text}}
+	}
+	if signature != "" {
+		payload["signature"] = signature
+	}
+	if redacted != "" {
+		payload["encrypted_content"] = redacted
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
 func functionCallItem(name, callID, args string) json.RawMessage {
 	args = strings.TrimSpace(args)
 	if args == "" {
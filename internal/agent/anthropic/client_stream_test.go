@@ -103,3 +103,53 @@ func TestToolUseStreamState_FlushesPendingToolUseOnMessageStop(t *testing.T) {
 		t.Fatalf("unexpected item: %#v", item)
 	}
 }
+
+func TestToolUseStreamState_StreamsThinkingAndKeepsSignature(t *testing.T) {
+	state := newToolUseStreamState()
+	raw := []string{
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":"","signature":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"check the "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"README first"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig-abc"}}`,
+	}
+	var deltas string
+	var items []json.RawMessage
+	onEvent := func(evt agent.StreamEvent) {
+		switch evt.Type {
+		case agent.StreamEventReasoningDelta:
+			deltas += evt.Text
+		case agent.StreamEventItem:
+			items = append(items, evt.Item)
+		case agent.StreamEventTextDelta:
+			t.Fatalf("thinking must not be streamed as text: %q", evt.Text)
+		}
+	}
+	for i, line := range raw {
+		var evt anthropic.MessageStreamEventUnion
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			t.Fatalf("unmarshal %d: %v", i, err)
+		}
+		state.Handle(evt.AsAny(), onEvent)
+	}
+	state.Handle(anthropic.ContentBlockStopEvent{Index: 0}, onEvent)
+
+	if deltas != "check the README first" {
+		t.Fatalf("reasoning deltas = %q", deltas)
+	}
+	if len(items) != 1 {
+		t.Fatalf("items = %d, want 1", len(items))
+	}
+	var item struct {
+		Type      string `json:"type"`
+		Signature string `json:"signature"`
+		Content   []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(items[0], &item); err != nil {
+		t.Fatalf("unmarshal item: %v", err)
+	}
+	if item.Type != "reasoning" || item.Signature != "sig-abc" || len(item.Content) != 1 || item.Content[0].Text != "check the README first" {
+		t.Fatalf("unexpected reasoning item %s", items[0])
+	}
+}
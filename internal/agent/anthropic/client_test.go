@@ -82,3 +82,145 @@ func TestBuildMessageParamsRegistersToolsAndEncodesToolBlocks(t *testing.T) {
 		t.Fatalf("tool_result.content = %#v, want text ok", toolResult.Content)
 	}
 }
+
+func TestBuildMessageParamsAppliesSampling(t *testing.T) {
+	temp := 0.2
+	prompt := agent.Prompt{
+		Model:    "claude-test",
+		Messages: []agent.Message{{Role: agent.RoleUser, Content: "hi"}},
+	}
+	params := buildMessageParams(prompt, anthropic.Model("claude-test"))
+	if params.MaxTokens != agent.DefaultMaxOutputTokens {
+		t.Fatalf("max_tokens = %d, want default %d", params.MaxTokens, agent.DefaultMaxOutputTokens)
+	}
+
+	prompt.Sampling = agent.SamplingOptions{MaxTokens: 4000, Temperature: &temp, StopSequences: []string{"END"}}
+	params = buildMessageParams(prompt, anthropic.Model("claude-test"))
+	if params.MaxTokens != 4000 || params.Temperature.Value != 0.2 || len(params.StopSequences) != 1 || params.StopSequences[0] != "END" {
+		t.Fatalf("sampling not applied: max_tokens=%d temperature=%v stop=%v", params.MaxTokens, params.Temperature, params.StopSequences)
+	}
+
+	prompt.Sampling.ThinkingBudget = 6000
+	params = buildMessageParams(prompt, anthropic.Model("claude-test"))
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != 6000 {
+		t.Fatalf("thinking not enabled: %#v", params.Thinking)
+	}
+	if params.MaxTokens <= 6000 || params.Temperature.Valid() {
+		t.Fatalf("thinking requires max_tokens above budget and no temperature, got max_tokens=%d temperature=%v", params.MaxTokens, params.Temperature)
+	}
+}
+
+func TestBuildMessageParamsReplaysThinkingWithToolUse(t *testing.T) {
+	prompt := agent.Prompt{
+		Model:           "claude-test",
+		ReasoningEffort: "low",
+		Messages: []agent.Message{
+			{Role: agent.RoleUser, Content: "list files"},
+			{Role: agent.RoleAssistant, Content: "need ls", Reasoning: &agent.Reasoning{Text: "need ls", Signature: "sig-1"}},
+			{Role: agent.RoleAssistant, Content: "listing"},
+			{Role: agent.RoleAssistant, ToolUse: &agent.ToolUse{ID: "toolu_1", Name: "exec_command", Input: json.RawMessage(`{"cmd":"ls"}`)}},
+			{Role: agent.RoleUser, ToolResult: &agent.ToolResult{ToolUseID: "toolu_1", Content: "a.go"}},
+		},
+	}
+	params := buildMessageParams(prompt, anthropic.Model("claude-test"))
+	if len(params.Messages) != 3 {
+		t.Fatalf("expected assistant blocks merged into one turn, got %d messages", len(params.Messages))
+	}
+	blocks := params.Messages[1].Content
+	if len(blocks) != 3 || blocks[0].OfThinking == nil || blocks[0].OfThinking.Signature != "sig-1" || blocks[1].OfText == nil || blocks[2].OfToolUse == nil {
+		t.Fatalf("expected thinking, text, tool_use in order, got %#v", blocks)
+	}
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != agent.ThinkingBudgetForEffort("low") {
+		t.Fatalf("expected reasoning effort mapped to thinking budget, got %#v", params.Thinking)
+	}
+
+	// 工具循环中途（上一轮 tool_use 没有思考块）不能开启思考。
+	prompt.Messages[1].Reasoning = &agent.Reasoning{Text: "unsigned"}
+	params = buildMessageParams(prompt, anthropic.Model("claude-test"))
+	if params.Thinking.OfEnabled != nil {
+		t.Fatalf("thinking must stay disabled after an unsigned tool turn")
+	}
+	if blocks := params.Messages[1].Content; len(blocks) != 2 || blocks[0].OfText == nil {
+		t.Fatalf("unsigned reasoning must not be replayed, got %#v", blocks)
+	}
+}
+
+func TestBuildMessageParamsPlacesCacheBreakpointsOnStablePrefix(t *testing.T) {
+	turn := []agent.Message{
+		{Role: agent.RoleSystem, Content: "core prompt"},
+		{Role: agent.RoleSystem, Content: "agents.md"},
+		{Role: agent.RoleUser, Content: "list files"},
+		{Role: agent.RoleAssistant, ToolUse: &agent.ToolUse{ID: "toolu_1", Name: "exec_command", Input: json.RawMessage(`{"cmd":"ls"}`)}},
+		{Role: agent.RoleUser, ToolResult: &agent.ToolResult{ToolUseID: "toolu_1", Content: "a.go"}},
+	}
+	language := agent.Message{Role: agent.RoleSystem, Content: "reply in English"}
+	first := buildMessageParams(agent.Prompt{Messages: append(append([]agent.Message{}, turn...), language), Tools: agent.DefaultTools()}, "claude-test")
+
+	if n := len(first.Tools); n == 0 || first.Tools[n-1].GetCacheControl().Type == "" || first.Tools[0].GetCacheControl().Type != "" {
+		t.Fatalf("expected a breakpoint on the last tool only")
+	}
+	if len(first.System) != 3 || first.System[1].CacheControl.Type == "" || first.System[0].CacheControl.Type != "" || first.System[2].CacheControl.Type != "" {
+		t.Fatalf("expected a breakpoint after the leading system blocks, got %#v", first.System)
+	}
+	marked := func(params anthropic.MessageNewParams) []int {
+		var out []int
+		for i, msg := range params.Messages {
+			for _, block := range msg.Content {
+				if cc := block.GetCacheControl(); cc != nil && cc.Type != "" {
+					out = append(out, i)
+				}
+			}
+		}
+		return out
+	}
+	if got := marked(first); len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Fatalf("expected rolling breakpoints on the last two user messages, got %v", got)
+	}
+
+	next := append(append([]agent.Message{}, turn...),
+		agent.Message{Role: agent.RoleAssistant, Content: "one file"},
+		agent.Message{Role: agent.RoleUser, Content: "thanks"},
+		language,
+	)
+	second := buildMessageParams(agent.Prompt{Messages: next, Tools: agent.DefaultTools()}, "claude-test")
+	if got := marked(second); len(got) != 2 || got[0] != 2 || got[1] != 4 {
+		t.Fatalf("expected the previous tail to stay marked, got %v", got)
+	}
+	prefix := func(params anthropic.MessageNewParams) string {
+		raw, _ := json.Marshal(struct {
+			Tools  []anthropic.ToolUnionParam
+			System []anthropic.TextBlockParam
+			First  []anthropic.MessageParam
+		}{params.Tools, params.System, params.Messages[1:3]})
+		return string(raw)
+	}
+	if prefix(first) != prefix(second) {
+		t.Fatalf("cached prefix must be byte-stable across turns")
+	}
+}
+
+func TestBuildMessageParamsMapsImageParts(t *testing.T) {
+	prompt := agent.Prompt{Messages: []agent.Message{{
+		Role:    agent.RoleUser,
+		Content: "what is broken here?",
+		Parts: []agent.ContentPart{
+			{Type: agent.ContentPartText, Text: "what is broken here?"},
+			{Type: agent.ContentPartImage, MediaType: "image/png", Data: "iVBORw0K"},
+		},
+	}}}
+	params := buildMessageParams(prompt, "claude-test")
+	if len(params.Messages) != 1 || len(params.Messages[0].Content) != 2 {
+		t.Fatalf("unexpected messages %#v", params.Messages)
+	}
+	content := params.Messages[0].Content
+	if content[0].OfText == nil || content[0].OfText.Text != "what is broken here?" {
+		t.Fatalf("expected leading text block, got %#v", content[0])
+	}
+	image := content[1].OfImage
+	if image == nil || image.Source.OfBase64 == nil {
+		t.Fatalf("expected base64 image block, got %#v", content[1])
+	}
+	if image.Source.OfBase64.MediaType != "image/png" || image.Source.OfBase64.Data != "iVBORw0K" {
+		t.Fatalf("unexpected image source %#v", image.Source.OfBase64)
+	}
+}
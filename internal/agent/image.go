@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxImageBytes 是单张图片的大小上限（Anthropic 对 base64 图片的限制为 5MB）。
+const MaxImageBytes = 5 * 1024 * 1024
+
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// LoadImage 读取图片文件并编码为图片片段；只接受 png/jpeg/gif/webp。
+func LoadImage(path string) (ContentPart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentPart{}, err
+	}
+	return NewImagePart(filepath.Base(path), data)
+}
+
+// NewImagePart 按内容识别媒体类型并编码；name 仅用于错误信息。
+func NewImagePart(name string, data []byte) (ContentPart, error) {
+	if len(data) == 0 {
+		return ContentPart{}, fmt.Errorf("%s: empty image", name)
+	}
+	if len(data) > MaxImageBytes {
+		return ContentPart{}, fmt.Errorf("%s: image is %d bytes, limit is %d", name, len(data), MaxImageBytes)
+	}
+	mediaType := http.DetectContentType(data)
+	if !supportedImageTypes[mediaType] {
+		return ContentPart{}, fmt.Errorf("%s: unsupported image type %s (want png, jpeg, gif or webp)", name, mediaType)
+	}
+	return ContentPart{Type: ContentPartImage, MediaType: mediaType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+// DataURL 返回图片片段的 data URL（data:<media>;base64,<data>），用于 OpenAI 兼容接口与会话记录。
+func (p ContentPart) DataURL() string {
+	return "data:" + p.MediaType + ";base64," + p.Data
+}
+
+// ImagePartFromDataURL 解析 base64 data URL；不是图片 data URL 时返回 false。
+func ImagePartFromDataURL(url string) (ContentPart, bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return ContentPart{}, false
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return ContentPart{}, false
+	}
+	mediaType, ok := strings.CutSuffix(meta, ";base64")
+	if !ok || !strings.HasPrefix(mediaType, "image/") {
+		return ContentPart{}, false
+	}
+	return ContentPart{Type: ContentPartImage, MediaType: mediaType, Data: data}, true
+}
@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestLoadImageEncodesSupportedTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(path, pngHeader, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	part, err := LoadImage(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if part.Type != ContentPartImage || part.MediaType != "image/png" || part.Data == "" {
+		t.Fatalf("unexpected part %+v", part)
+	}
+	back, ok := ImagePartFromDataURL(part.DataURL())
+	if !ok || back != part {
+		t.Fatalf("data url round trip failed: %+v", back)
+	}
+}
+
+func TestNewImagePartRejectsInvalidImages(t *testing.T) {
+	if _, err := NewImagePart("notes.txt", []byte("plain text")); err == nil || !strings.Contains(err.Error(), "unsupported image type") {
+		t.Fatalf("expected unsupported type error, got %v", err)
+	}
+	big := make([]byte, MaxImageBytes+1)
+	copy(big, pngHeader)
+	if _, err := NewImagePart("big.png", big); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("expected size error, got %v", err)
+	}
+	if _, ok := ImagePartFromDataURL("data:text/plain;base64,aGk="); ok {
+		t.Fatalf("non-image data url should be rejected")
+	}
+}
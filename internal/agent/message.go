@@ -22,9 +22,36 @@ type ToolResult struct {
 	IsError   bool
 }
 
+// Reasoning 是模型的思考块；Signature 与 Redacted 需原样回传，provider 才能在多轮工具调用中校验思考内容。
+type Reasoning struct {
+	Text      string
+	Signature string
+	// Redacted 为 provider 加密后的思考内容（anthropic redacted_thinking）。
+	Redacted string
+}
+
+// ContentPartType 区分多模态消息中的片段类型。
+type ContentPartType string
+
+const (
+	ContentPartText  ContentPartType = "text"
+	ContentPartImage ContentPartType = "image"
+)
+
+// ContentPart 是消息的一个片段；图片以 base64 编码携带，MediaType 如 image/png。
+type ContentPart struct {
+	Type      ContentPartType
+	Text      string
+	MediaType string
+	Data      string
+}
+
 type Message struct {
-	Role       Role
-	Content    string
+	Role    Role
+	Content string
+	// Parts 非空时 provider 按片段发送（文本与图片）；Content 仍保留纯文本形式，用于估算、日志与转录。
+	Parts      []ContentPart
 	ToolUse    *ToolUse
 	ToolResult *ToolResult
+	Reasoning  *Reasoning
 }
@@ -40,9 +40,11 @@ type StreamEventType string
 
 const (
 	StreamEventTextDelta StreamEventType = "text_delta"
-	StreamEventItem      StreamEventType = "item_done"
-	StreamEventCompleted StreamEventType = "completed"
-	StreamEventUsage     StreamEventType = "usage"
+	// StreamEventReasoningDelta 携带思考内容的增量（Text），完整思考块随后以 reasoning item 下发。
+	StreamEventReasoningDelta StreamEventType = "reasoning_delta"
+	StreamEventItem           StreamEventType = "item_done"
+	StreamEventCompleted      StreamEventType = "completed"
+	StreamEventUsage          StreamEventType = "usage"
 )
 
 // StreamEvent 统一描述模型流式返回的结构化事件或文本增量。
@@ -66,3 +68,17 @@ type TokenUsage struct {
 	CacheCreationInputTokens int64
 	CacheReadInputTokens     int64
 }
+
+// TotalInputTokens 返回全部输入 token：未缓存、写入缓存与读取缓存之和。
+func (u TokenUsage) TotalInputTokens() int64 {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// CacheHitRate 返回缓存读取占全部输入 token 的比例（0~1）。
+func (u TokenUsage) CacheHitRate() float64 {
+	total := u.TotalInputTokens()
+	if total <= 0 {
+		return 0
+	}
+	return float64(u.CacheReadInputTokens) / float64(total)
+}
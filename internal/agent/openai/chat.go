@@ -0,0 +1,321 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"echo-cli/internal/agent"
+
+	"github.com/google/uuid"
+)
+
+type chatRequest struct {
+	Model             string         `json:"model"`
+	Messages          []chatMessage  `json:"messages"`
+	Tools             []chatTool     `json:"tools,omitempty"`
+	ParallelToolCalls *bool          `json:"parallel_tool_calls,omitempty"`
+	Stream            bool           `json:"stream"`
+	StreamOptions     map[string]any `json:"stream_options,omitempty"`
+	MaxTokens         int64          `json:"max_tokens,omitempty"`
+	Temperature       *float64       `json:"temperature,omitempty"`
+	TopP              *float64       `json:"top_p,omitempty"`
+	Stop              []string       `json:"stop,omitempty"`
+	ReasoningEffort   string         `json:"reasoning_effort,omitempty"`
+}
+
+type chatMessage struct {
+	Role string `json:"role"`
+	// Content 为字符串，或多模态消息的片段数组（[]chatContentPart）。
+	Content    any            `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *chatImageURL `json:"image_url,omitempty"`
+}
+
+type chatImageURL struct {
+	URL string `json:"url"`
+}
+
+type chatToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function chatFunctionCall `json:"function"`
+}
+
+type chatFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type chatTool struct {
+	Type     string       `json:"type"`
+	Function chatFunction `json:"function"`
+}
+
+type chatFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+			// ReasoningContent 是 DeepSeek/GLM 等兼容服务返回的思考内容。
+			ReasoningContent string `json:"reasoning_content"`
+			ToolCalls        []struct {
+				Index    *int   `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens        int64 `json:"prompt_tokens"`
+		CompletionTokens    int64 `json:"completion_tokens"`
+		PromptTokensDetails *struct {
+			CachedTokens int64 `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+	Error *streamError `json:"error"`
+}
+
+func buildChatRequest(model string, prompt agent.Prompt) chatRequest {
+	req := chatRequest{
+		Model:         model,
+		Messages:      chatMessages(prompt.Messages),
+		Tools:         chatTools(prompt.Tools),
+		Stream:        true,
+		StreamOptions: map[string]any{"include_usage": true},
+		MaxTokens:     prompt.Sampling.MaxTokens,
+		Temperature:   prompt.Sampling.Temperature,
+		TopP:          prompt.Sampling.TopP,
+		Stop:          prompt.Sampling.StopSequences,
+		// 兼容服务不一定支持推理强度；只有 context 判断模型支持原生思考时才会设置。
+		ReasoningEffort: strings.TrimSpace(prompt.ReasoningEffort),
+	}
+	if len(req.Tools) > 0 && prompt.ParallelToolCalls {
+		parallel := true
+		req.ParallelToolCalls = &parallel
+	}
+	return req
+}
+
+// chatMessages 转换历史消息；连续的 assistant 文本与工具调用合并为一条带 tool_calls 的消息。
+func chatMessages(msgs []agent.Message) []chatMessage {
+	out := make([]chatMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		switch {
+		case msg.Reasoning != nil:
+			// Chat Completions 不接受回传的思考内容。
+			continue
+		case msg.ToolResult != nil && msg.ToolResult.ToolUseID != "":
+			out = append(out, chatMessage{Role: "tool", ToolCallID: msg.ToolResult.ToolUseID, Content: msg.ToolResult.Content})
+		case msg.ToolUse != nil && msg.ToolUse.ID != "" && msg.ToolUse.Name != "":
+			call := chatToolCall{
+				ID:       msg.ToolUse.ID,
+				Type:     "function",
+				Function: chatFunctionCall{Name: msg.ToolUse.Name, Arguments: toolArguments(msg.ToolUse.Input)},
+			}
+			if n := len(out); n > 0 && out[n-1].Role == "assistant" {
+				out[n-1].ToolCalls = append(out[n-1].ToolCalls, call)
+				continue
+			}
+			out = append(out, chatMessage{Role: "assistant", ToolCalls: []chatToolCall{call}})
+		default:
+			role := string(msg.Role)
+			if role == "" {
+				role = string(agent.RoleUser)
+			}
+			if len(msg.Parts) > 0 {
+				out = append(out, chatMessage{Role: role, Content: chatContent(msg.Parts)})
+				continue
+			}
+			text := strings.TrimSpace(msg.Content)
+			if text == "" {
+				continue
+			}
+			out = append(out, chatMessage{Role: role, Content: text})
+		}
+	}
+	return out
+}
+
+// chatContent 把多模态片段映射为 text / image_url（data URL）片段。
+func chatContent(parts []agent.ContentPart) []chatContentPart {
+	out := make([]chatContentPart, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case agent.ContentPartImage:
+			out = append(out, chatContentPart{Type: "image_url", ImageURL: &chatImageURL{URL: part.DataURL()}})
+		default:
+			if text := strings.TrimSpace(part.Text); text != "" {
+				out = append(out, chatContentPart{Type: "text", Text: text})
+			}
+		}
+	}
+	return out
+}
+
+func chatTools(specs []agent.ToolSpec) []chatTool {
+	out := make([]chatTool, 0, len(specs))
+	for _, spec := range specs {
+		name := strings.TrimSpace(spec.Name)
+		if name == "" {
+			continue
+		}
+		out = append(out, chatTool{
+			Type: "function",
+			Function: chatFunction{
+				Name:        name,
+				Description: strings.TrimSpace(spec.Description),
+				Parameters:  spec.Parameters,
+			},
+		})
+	}
+	return out
+}
+
+type pendingChatCall struct {
+	id   string
+	name string
+	args strings.Builder
+}
+
+// chatToolCallState 按 index 累积流式 tool_calls 增量，结束时按顺序输出 function_call item。
+type chatToolCallState struct {
+	calls map[int]*pendingChatCall
+	next  int
+}
+
+func (s *chatToolCallState) add(index *int, id, name, args string) {
+	if s.calls == nil {
+		s.calls = map[int]*pendingChatCall{}
+	}
+	idx := s.next
+	if index != nil {
+		idx = *index
+	}
+	// 部分本地服务对每个完整调用都返回 index=0，遇到新的 id 时另起一个调用。
+	if pending := s.calls[idx]; pending != nil && id != "" && pending.id != "" && pending.id != id {
+		idx = s.next
+	}
+	pending := s.calls[idx]
+	if pending == nil {
+		pending = &pendingChatCall{}
+		s.calls[idx] = pending
+	}
+	if idx >= s.next {
+		s.next = idx + 1
+	}
+	if id != "" {
+		pending.id = id
+	}
+	if name != "" {
+		pending.name = name
+	}
+	pending.args.WriteString(args)
+}
+
+func (s *chatToolCallState) flush(onEvent func(agent.StreamEvent)) {
+	indexes := make([]int, 0, len(s.calls))
+	for idx := range s.calls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	for _, idx := range indexes {
+		pending := s.calls[idx]
+		if pending.name == "" {
+			continue
+		}
+		id := pending.id
+		if id == "" {
+			id = "call_" + uuid.NewString()
+		}
+		if raw := functionCallItem(pending.name, id, pending.args.String()); len(raw) > 0 {
+			onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: raw})
+		}
+	}
+	s.calls = nil
+}
+
+func (c *Client) streamChat(ctx context.Context, model string, prompt agent.Prompt, onEvent func(agent.StreamEvent)) (err error) {
+	counts := map[string]int{}
+	finish := ""
+	defer func() { logStreamSummary(model, WireAPIChat, finish, counts, err) }()
+
+	resp, err := c.post(ctx, "/chat/completions", buildChatRequest(model, prompt))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var calls chatToolCallState
+	var reasoning strings.Builder
+	err = readSSE(resp.Body, func(ev sseEvent) (bool, error) {
+		data := strings.TrimSpace(ev.Data)
+		if data == "[DONE]" {
+			return false, nil
+		}
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("decode chat chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return false, chunk.Error.err()
+		}
+		counts["chunk"]++
+		for _, choice := range chunk.Choices {
+			if choice.Delta.ReasoningContent != "" {
+				counts["reasoning_delta"]++
+				reasoning.WriteString(choice.Delta.ReasoningContent)
+				onEvent(agent.StreamEvent{Type: agent.StreamEventReasoningDelta, Text: choice.Delta.ReasoningContent})
+			}
+			if choice.Delta.Content != "" {
+				counts["text_delta"]++
+				onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: choice.Delta.Content})
+			}
+			for _, tc := range choice.Delta.ToolCalls {
+				counts["tool_call_delta"]++
+				calls.add(tc.Index, tc.ID, tc.Function.Name, tc.Function.Arguments)
+			}
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finish = *choice.FinishReason
+			}
+		}
+		if u := chunk.Usage; u != nil {
+			var cached int64
+			if u.PromptTokensDetails != nil {
+				cached = u.PromptTokensDetails.CachedTokens
+			}
+			onEvent(agent.StreamEvent{Type: agent.StreamEventUsage, Usage: &agent.TokenUsage{
+				InputTokens:          u.PromptTokens - cached,
+				OutputTokens:         u.CompletionTokens,
+				CacheReadInputTokens: cached,
+			}})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	if raw := reasoningItem(reasoning.String()); len(raw) > 0 {
+		onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: raw})
+	}
+	calls.flush(onEvent)
+	onEvent(agent.StreamEvent{Type: agent.StreamEventCompleted, StopReason: mapStopReason(finish), FinishReason: finish})
+	return nil
+}
@@ -0,0 +1,232 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/logger"
+)
+
+const (
+	WireAPIChat      = "chat"
+	WireAPIResponses = "responses"
+)
+
+// Options 配置 OpenAI 兼容客户端；Ollama、LM Studio 等本地服务同样走该客户端。
+type Options struct {
+	Token   string
+	BaseURL string
+	Model   string
+	// WireAPI 选择 chat（/chat/completions，默认）或 responses（/responses）。
+	WireAPI    string
+	HTTPClient *http.Client
+}
+
+// Client 通过 OpenAI Chat Completions / Responses 流式协议调用模型。
+type Client struct {
+	http    *http.Client
+	baseURL string
+	token   string
+	model   string
+	wireAPI string
+}
+
+var _ agent.ModelClient = (*Client)(nil)
+var streamLog = logger.Named("llm")
+
+const maxErrorBody = 4 * 1024
+
+func New(opts Options) (*Client, error) {
+	base := strings.TrimRight(strings.TrimSpace(opts.BaseURL), "/")
+	if base == "" {
+		return nil, errors.New("missing base url")
+	}
+	wire := strings.ToLower(strings.TrimSpace(opts.WireAPI))
+	switch wire {
+	case "":
+		wire = WireAPIChat
+	case WireAPIChat, WireAPIResponses:
+	default:
+		return nil, fmt.Errorf("unsupported wire api %q", opts.WireAPI)
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		http:    httpClient,
+		baseURL: base,
+		token:   strings.TrimSpace(opts.Token),
+		model:   strings.TrimSpace(opts.Model),
+		wireAPI: wire,
+	}, nil
+}
+
+func (c *Client) resolveModel(m string) string {
+	if strings.TrimSpace(m) != "" {
+		return strings.TrimSpace(m)
+	}
+	return c.model
+}
+
+// Complete 复用流式接口并拼接文本输出。
+func (c *Client) Complete(ctx context.Context, prompt agent.Prompt) (string, error) {
+	var sb strings.Builder
+	err := c.Stream(ctx, prompt, func(evt agent.StreamEvent) {
+		if evt.Type == agent.StreamEventTextDelta {
+			sb.WriteString(evt.Text)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+func (c *Client) Stream(ctx context.Context, prompt agent.Prompt, onEvent func(agent.StreamEvent)) error {
+	model := c.resolveModel(prompt.Model)
+	if c.wireAPI == WireAPIResponses {
+		return c.streamResponses(ctx, model, prompt, onEvent)
+	}
+	return c.streamChat(ctx, model, prompt, onEvent)
+}
+
+// post 发送流式请求；非 2xx 响应转换为包含响应体摘要的错误。
+func (c *Client) post(ctx context.Context, path string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	return resp, nil
+}
+
+// APIError 描述服务端返回的非 2xx 响应。
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("openai-compatible api error: status %d", e.StatusCode)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// streamError 对应流中 {"error": {...}} 形式的错误负载。
+type streamError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    any    `json:"code"`
+}
+
+func (e *streamError) err() error {
+	if e == nil {
+		return nil
+	}
+	msg := strings.TrimSpace(e.Message)
+	if msg == "" {
+		msg = "unknown stream error"
+	}
+	if e.Type != "" {
+		msg = e.Type + ": " + msg
+	}
+	return errors.New("openai-compatible stream error: " + msg)
+}
+
+// mapStopReason 把 OpenAI 的 finish_reason 映射为与 anthropic 一致的 stop_reason。
+func mapStopReason(finish string) string {
+	switch finish {
+	case "stop":
+		return "end_turn"
+	case "length", "max_output_tokens":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	default:
+		return finish
+	}
+}
+
+func functionCallItem(name, callID, args string) json.RawMessage {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		args = "{}"
+	}
+	payload := map[string]any{
+		"type":      "function_call",
+		"name":      name,
+		"arguments": args,
+		"call_id":   callID,
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// reasoningItem 把流式累积的思考内容构造为 reasoning ResponseItem 的 JSON。
+func reasoningItem(text string) json.RawMessage {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	raw, err := json.Marshal(map[string]any{
+		"type":    "reasoning",
+		"summary": []any{},
+		"content": []map[string]string{{"type": "reasoning_text", "text": text}},
+	})
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+func toolArguments(input json.RawMessage) string {
+	args := strings.TrimSpace(string(input))
+	if args == "" || args == "null" {
+		return "{}"
+	}
+	return args
+}
+
+func logStreamSummary(model, wire, finish string, counts map[string]int, err error) {
+	fields := logger.Fields{
+		"model":         model,
+		"wire_api":      wire,
+		"finish_reason": finish,
+	}
+	if len(counts) > 0 {
+		fields["event_counts"] = counts
+	}
+	if err != nil {
+		fields["stream_error"] = err.Error()
+	}
+	streamLog.WithFields(fields).Info("llm stream summary")
+}
@@ -0,0 +1,309 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"echo-cli/internal/agent"
+)
+
+func sseServer(t *testing.T, path string, inspect func(map[string]any), chunks ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if inspect != nil {
+			inspect(body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collect(t *testing.T, client *Client, prompt agent.Prompt) (string, []map[string]any, agent.StreamEvent, *agent.TokenUsage) {
+	t.Helper()
+	var (
+		text      strings.Builder
+		items     []map[string]any
+		completed agent.StreamEvent
+		usage     *agent.TokenUsage
+	)
+	err := client.Stream(context.Background(), prompt, func(evt agent.StreamEvent) {
+		switch evt.Type {
+		case agent.StreamEventTextDelta:
+			text.WriteString(evt.Text)
+		case agent.StreamEventItem:
+			var item map[string]any
+			_ = json.Unmarshal(evt.Item, &item)
+			items = append(items, item)
+		case agent.StreamEventUsage:
+			usage = evt.Usage
+		case agent.StreamEventCompleted:
+			completed = evt
+		}
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	return text.String(), items, completed, usage
+}
+
+func TestChatStreamAccumulatesToolCalls(t *testing.T) {
+	var request map[string]any
+	srv := sseServer(t, "/v1/chat/completions", func(body map[string]any) { request = body },
+		`{"choices":[{"delta":{"content":"Let me "}}]}`,
+		`{"choices":[{"delta":{"content":"check."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","function":{"name":"exec_command","arguments":"{\"comm"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"apply_patch","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"and\":\"ls\"}"}}]}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"prompt_tokens_details":{"cached_tokens":2}}}`,
+		`[DONE]`,
+	)
+	client, err := New(Options{BaseURL: srv.URL + "/v1/", Model: "qwen"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	text, items, completed, usage := collect(t, client, agent.Prompt{
+		Messages: []agent.Message{
+			{Role: agent.RoleSystem, Content: "sys"},
+			{Role: agent.RoleUser, Content: "hi"},
+			{Role: agent.RoleAssistant, Content: "running"},
+			{Role: agent.RoleAssistant, ToolUse: &agent.ToolUse{ID: "t1", Name: "exec_command", Input: json.RawMessage(`{"command":"pwd"}`)}},
+			{Role: agent.RoleUser, ToolResult: &agent.ToolResult{ToolUseID: "t1", Content: "/tmp"}},
+		},
+		Tools: agent.DefaultTools(),
+	})
+
+	if text != "Let me check." {
+		t.Fatalf("unexpected text %q", text)
+	}
+	if len(items) != 2 || items[0]["call_id"] != "call_a" || items[0]["arguments"] != `{"command":"ls"}` || items[1]["name"] != "apply_patch" {
+		t.Fatalf("unexpected items %+v", items)
+	}
+	if completed.StopReason != "tool_use" || completed.FinishReason != "tool_calls" {
+		t.Fatalf("unexpected completion %+v", completed)
+	}
+	if usage == nil || usage.InputTokens != 10 || usage.CacheReadInputTokens != 2 || usage.OutputTokens != 5 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	if request["model"] != "qwen" || request["stream"] != true {
+		t.Fatalf("unexpected request %+v", request)
+	}
+	msgs, _ := request["messages"].([]any)
+	if len(msgs) != 4 {
+		t.Fatalf("expected assistant text and tool call merged into 4 messages, got %+v", msgs)
+	}
+	assistant := msgs[2].(map[string]any)
+	if calls, _ := assistant["tool_calls"].([]any); len(calls) != 1 || assistant["content"] != "running" {
+		t.Fatalf("unexpected assistant message %+v", assistant)
+	}
+	if tool := msgs[3].(map[string]any); tool["role"] != "tool" || tool["tool_call_id"] != "t1" {
+		t.Fatalf("unexpected tool message %+v", tool)
+	}
+	if tools, _ := request["tools"].([]any); len(tools) != len(agent.DefaultTools()) {
+		t.Fatalf("expected tools to be forwarded, got %d", len(tools))
+	}
+}
+
+func TestChatStreamSplitsRepeatedIndex(t *testing.T) {
+	srv := sseServer(t, "/chat/completions", nil,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"a","function":{"name":"x","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"b","function":{"name":"y","arguments":"{}"}}]},"finish_reason":"stop"}]}`,
+	)
+	client, _ := New(Options{BaseURL: srv.URL})
+	_, items, completed, _ := collect(t, client, agent.Prompt{Messages: []agent.Message{{Role: agent.RoleUser, Content: "hi"}}})
+	if len(items) != 2 || items[0]["name"] != "x" || items[1]["name"] != "y" {
+		t.Fatalf("expected two separate calls, got %+v", items)
+	}
+	if completed.StopReason != "end_turn" {
+		t.Fatalf("unexpected stop reason %q", completed.StopReason)
+	}
+}
+
+func TestResponsesStream(t *testing.T) {
+	var request map[string]any
+	srv := sseServer(t, "/v1/responses", func(body map[string]any) { request = body },
+		`{"type":"response.output_text.delta","delta":"done"}`,
+		`{"type":"response.output_item.done","item":{"type":"message","role":"assistant"}}`,
+		`{"type":"response.output_item.done","item":{"type":"function_call","call_id":"c1","name":"exec_command","arguments":"{\"command\":\"ls\"}"}}`,
+		`{"type":"response.incomplete","response":{"status":"incomplete","incomplete_details":{"reason":"max_output_tokens"},"usage":{"input_tokens":7,"output_tokens":3}}}`,
+	)
+	client, _ := New(Options{BaseURL: srv.URL + "/v1", WireAPI: WireAPIResponses, Token: "k"})
+	text, items, completed, usage := collect(t, client, agent.Prompt{
+		Model: "gpt-test",
+		Messages: []agent.Message{
+			{Role: agent.RoleSystem, Content: "sys"},
+			{Role: agent.RoleUser, Content: "hi"},
+			{Role: agent.RoleAssistant, ToolUse: &agent.ToolUse{ID: "t1", Name: "exec_command"}},
+			{Role: agent.RoleUser, ToolResult: &agent.ToolResult{ToolUseID: "t1", Content: "ok"}},
+		},
+	})
+	if text != "done" || len(items) != 1 || items[0]["call_id"] != "c1" {
+		t.Fatalf("unexpected output text=%q items=%+v", text, items)
+	}
+	if completed.StopReason != "max_tokens" || usage == nil || usage.InputTokens != 7 {
+		t.Fatalf("unexpected completion %+v usage %+v", completed, usage)
+	}
+	if request["instructions"] != "sys" || request["model"] != "gpt-test" {
+		t.Fatalf("unexpected request %+v", request)
+	}
+	input, _ := request["input"].([]any)
+	if len(input) != 3 || input[1].(map[string]any)["arguments"] != "{}" || input[2].(map[string]any)["type"] != "function_call_output" {
+		t.Fatalf("unexpected input %+v", input)
+	}
+}
+
+func TestRequestsCarrySamplingOptions(t *testing.T) {
+	temp, topP := 0.3, 0.9
+	sampling := agent.SamplingOptions{MaxTokens: 2048, Temperature: &temp, TopP: &topP, StopSequences: []string{"END"}}
+	prompt := agent.Prompt{Model: "gpt-test", Messages: []agent.Message{{Role: agent.RoleUser, Content: "hi"}}, Sampling: sampling}
+
+	var chatReq map[string]any
+	chat := sseServer(t, "/v1/chat/completions", func(body map[string]any) { chatReq = body },
+		`{"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`,
+	)
+	client, _ := New(Options{BaseURL: chat.URL + "/v1", Token: "k"})
+	collect(t, client, prompt)
+	if chatReq["max_tokens"] != float64(2048) || chatReq["temperature"] != 0.3 || chatReq["top_p"] != 0.9 {
+		t.Fatalf("unexpected chat request %+v", chatReq)
+	}
+	if stop, _ := chatReq["stop"].([]any); len(stop) != 1 || stop[0] != "END" {
+		t.Fatalf("unexpected stop %+v", chatReq["stop"])
+	}
+
+	var respReq map[string]any
+	resp := sseServer(t, "/v1/responses", func(body map[string]any) { respReq = body },
+		`{"type":"response.completed","response":{"status":"completed"}}`,
+	)
+	client, _ = New(Options{BaseURL: resp.URL + "/v1", WireAPI: WireAPIResponses, Token: "k"})
+	collect(t, client, prompt)
+	if respReq["max_output_tokens"] != float64(2048) || respReq["temperature"] != 0.3 {
+		t.Fatalf("unexpected responses request %+v", respReq)
+	}
+
+	client, _ = New(Options{BaseURL: chat.URL + "/v1", Token: "k"})
+	collect(t, client, agent.Prompt{Model: "gpt-test", Messages: prompt.Messages})
+	if _, ok := chatReq["max_tokens"]; ok {
+		t.Fatalf("unset sampling options should be omitted, got %+v", chatReq)
+	}
+}
+
+func TestStreamsNativeReasoning(t *testing.T) {
+	var chatReq map[string]any
+	chat := sseServer(t, "/v1/chat/completions", func(body map[string]any) { chatReq = body },
+		`{"choices":[{"delta":{"reasoning_content":"check "}}]}`,
+		`{"choices":[{"delta":{"reasoning_content":"files"}}]}`,
+		`{"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`,
+	)
+	prompt := agent.Prompt{Model: "glm-4.6", ReasoningEffort: "high", Messages: []agent.Message{{Role: agent.RoleUser, Content: "hi"}}}
+	var reasoning strings.Builder
+	onEvent := func(evt agent.StreamEvent) {
+		if evt.Type == agent.StreamEventReasoningDelta {
+			reasoning.WriteString(evt.Text)
+		}
+	}
+	client, _ := New(Options{BaseURL: chat.URL + "/v1", Token: "k"})
+	if err := client.Stream(context.Background(), prompt, onEvent); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if reasoning.String() != "check files" || chatReq["reasoning_effort"] != "high" {
+		t.Fatalf("unexpected reasoning %q request %+v", reasoning.String(), chatReq)
+	}
+
+	var respReq map[string]any
+	resp := sseServer(t, "/v1/responses", func(body map[string]any) { respReq = body },
+		`{"type":"response.reasoning_summary_text.delta","delta":"plan"}`,
+		`{"type":"response.output_item.done","item":{"type":"reasoning","summary":[{"type":"summary_text","text":"plan"}],"encrypted_content":"enc"}}`,
+		`{"type":"response.completed","response":{"status":"completed"}}`,
+	)
+	reasoning.Reset()
+	client, _ = New(Options{BaseURL: resp.URL + "/v1", WireAPI: WireAPIResponses, Token: "k"})
+	_, items, _, _ := collect(t, client, prompt)
+	if err := client.Stream(context.Background(), prompt, onEvent); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if reasoning.String() != "plan" || len(items) != 1 || items[0]["encrypted_content"] != "enc" {
+		t.Fatalf("unexpected reasoning %q items %+v", reasoning.String(), items)
+	}
+	effort, _ := respReq["reasoning"].(map[string]any)
+	include, _ := respReq["include"].([]any)
+	if effort["effort"] != "high" || len(include) != 1 || include[0] != "reasoning.encrypted_content" {
+		t.Fatalf("unexpected responses request %+v", respReq)
+	}
+}
+
+func TestStreamSurfacesHTTPErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+	client, _ := New(Options{BaseURL: srv.URL})
+	_, err := client.Complete(context.Background(), agent.Prompt{Messages: []agent.Message{{Role: agent.RoleUser, Content: "hi"}}})
+	if err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Fatalf("expected api error, got %v", err)
+	}
+}
+
+func TestRequestsCarryImageParts(t *testing.T) {
+	prompt := agent.Prompt{Model: "gpt-test", Messages: []agent.Message{{
+		Role:    agent.RoleUser,
+		Content: "look",
+		Parts: []agent.ContentPart{
+			{Type: agent.ContentPartText, Text: "look"},
+			{Type: agent.ContentPartImage, MediaType: "image/png", Data: "iVBORw0K"},
+		},
+	}}}
+	const url = "data:image/png;base64,iVBORw0K"
+
+	var chatReq map[string]any
+	chat := sseServer(t, "/v1/chat/completions", func(body map[string]any) { chatReq = body },
+		`{"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`,
+	)
+	client, _ := New(Options{BaseURL: chat.URL + "/v1", Token: "k"})
+	collect(t, client, prompt)
+	messages, _ := chatReq["messages"].([]any)
+	msg, _ := messages[len(messages)-1].(map[string]any)
+	parts, _ := msg["content"].([]any)
+	if len(parts) != 2 {
+		t.Fatalf("expected content array, got %+v", msg)
+	}
+	image, _ := parts[1].(map[string]any)
+	if image["type"] != "image_url" || image["image_url"].(map[string]any)["url"] != url {
+		t.Fatalf("unexpected chat image part %+v", image)
+	}
+
+	var respReq map[string]any
+	resp := sseServer(t, "/v1/responses", func(body map[string]any) { respReq = body },
+		`{"type":"response.completed","response":{"status":"completed"}}`,
+	)
+	client, _ = New(Options{BaseURL: resp.URL + "/v1", WireAPI: WireAPIResponses, Token: "k"})
+	collect(t, client, prompt)
+	input, _ := respReq["input"].([]any)
+	item, _ := input[len(input)-1].(map[string]any)
+	content, _ := item["content"].([]any)
+	if len(content) != 2 {
+		t.Fatalf("expected content array, got %+v", item)
+	}
+	image, _ = content[1].(map[string]any)
+	if image["type"] != "input_image" || image["image_url"] != url {
+		t.Fatalf("unexpected responses image part %+v", image)
+	}
+}
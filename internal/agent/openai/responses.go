@@ -0,0 +1,250 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"echo-cli/internal/agent"
+)
+
+type responsesRequest struct {
+	Model             string           `json:"model"`
+	Instructions      string           `json:"instructions,omitempty"`
+	Input             []map[string]any `json:"input"`
+	Tools             []responsesTool  `json:"tools,omitempty"`
+	ParallelToolCalls *bool            `json:"parallel_tool_calls,omitempty"`
+	Stream            bool             `json:"stream"`
+	Store             bool             `json:"store"`
+	MaxOutputTokens   int64            `json:"max_output_tokens,omitempty"`
+	Temperature       *float64         `json:"temperature,omitempty"`
+	TopP              *float64         `json:"top_p,omitempty"`
+	Reasoning         map[string]any   `json:"reasoning,omitempty"`
+	Include           []string         `json:"include,omitempty"`
+}
+
+type responsesTool struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type responsesEvent struct {
+	Type  string          `json:"type"`
+	Delta string          `json:"delta"`
+	Item  json.RawMessage `json:"item"`
+	// Response 出现在 response.completed / response.incomplete / response.failed 中。
+	Response *struct {
+		Status            string `json:"status"`
+		IncompleteDetails *struct {
+			Reason string `json:"reason"`
+		} `json:"incomplete_details"`
+		Error *streamError `json:"error"`
+		Usage *struct {
+			InputTokens        int64 `json:"input_tokens"`
+			OutputTokens       int64 `json:"output_tokens"`
+			InputTokensDetails *struct {
+				CachedTokens int64 `json:"cached_tokens"`
+			} `json:"input_tokens_details"`
+		} `json:"usage"`
+	} `json:"response"`
+	Message string `json:"message"`
+}
+
+type responsesItem struct {
+	Type      string `json:"type"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// buildResponsesRequest 把 system 消息合并为 instructions，其余消息转换为 Responses input item。
+func buildResponsesRequest(model string, prompt agent.Prompt) responsesRequest {
+	req := responsesRequest{
+		Model:           model,
+		Stream:          true,
+		MaxOutputTokens: prompt.Sampling.MaxTokens,
+		Temperature:     prompt.Sampling.Temperature,
+		TopP:            prompt.Sampling.TopP,
+	}
+	if effort := strings.TrimSpace(prompt.ReasoningEffort); effort != "" {
+		// store=false 时需要取回加密的推理内容，下一轮原样回传以延续工具调用中的推理。
+		req.Reasoning = map[string]any{"effort": effort, "summary": "auto"}
+		req.Include = []string{"reasoning.encrypted_content"}
+	}
+	var instructions []string
+	for _, msg := range prompt.Messages {
+		switch {
+		case msg.Reasoning != nil:
+			// 只回传 Responses 自己返回的加密推理（anthropic 的签名思考块不适用）。
+			if msg.Reasoning.Redacted == "" || msg.Reasoning.Signature != "" {
+				continue
+			}
+			summary := []map[string]string{}
+			if text := strings.TrimSpace(msg.Reasoning.Text); text != "" {
+				summary = append(summary, map[string]string{"type": "summary_text", "text": text})
+			}
+			req.Input = append(req.Input, map[string]any{
+				"type":              "reasoning",
+				"summary":           summary,
+				"encrypted_content": msg.Reasoning.Redacted,
+			})
+		case msg.ToolResult != nil && msg.ToolResult.ToolUseID != "":
+			req.Input = append(req.Input, map[string]any{
+				"type":    "function_call_output",
+				"call_id": msg.ToolResult.ToolUseID,
+				"output":  msg.ToolResult.Content,
+			})
+		case msg.ToolUse != nil && msg.ToolUse.ID != "" && msg.ToolUse.Name != "":
+			req.Input = append(req.Input, map[string]any{
+				"type":      "function_call",
+				"call_id":   msg.ToolUse.ID,
+				"name":      msg.ToolUse.Name,
+				"arguments": toolArguments(msg.ToolUse.Input),
+			})
+		case msg.Role == agent.RoleSystem:
+			if text := strings.TrimSpace(msg.Content); text != "" {
+				instructions = append(instructions, text)
+			}
+		default:
+			role := string(msg.Role)
+			if role == "" {
+				role = string(agent.RoleUser)
+			}
+			if len(msg.Parts) > 0 {
+				req.Input = append(req.Input, map[string]any{"type": "message", "role": role, "content": responsesContent(msg.Parts)})
+				continue
+			}
+			text := strings.TrimSpace(msg.Content)
+			if text == "" {
+				continue
+			}
+			req.Input = append(req.Input, map[string]any{"type": "message", "role": role, "content": text})
+		}
+	}
+	req.Instructions = strings.Join(instructions, "\n\n")
+	for _, spec := range prompt.Tools {
+		name := strings.TrimSpace(spec.Name)
+		if name == "" {
+			continue
+		}
+		req.Tools = append(req.Tools, responsesTool{
+			Type:        "function",
+			Name:        name,
+			Description: strings.TrimSpace(spec.Description),
+			Parameters:  spec.Parameters,
+		})
+	}
+	if len(req.Tools) > 0 && prompt.ParallelToolCalls {
+		parallel := true
+		req.ParallelToolCalls = &parallel
+	}
+	return req
+}
+
+func (c *Client) streamResponses(ctx context.Context, model string, prompt agent.Prompt, onEvent func(agent.StreamEvent)) (err error) {
+	counts := map[string]int{}
+	finish := ""
+	defer func() { logStreamSummary(model, WireAPIResponses, finish, counts, err) }()
+
+	resp, err := c.post(ctx, "/responses", buildResponsesRequest(model, prompt))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(ev sseEvent) (bool, error) {
+		data := strings.TrimSpace(ev.Data)
+		if data == "[DONE]" {
+			return false, nil
+		}
+		var evt responsesEvent
+		if err := json.Unmarshal([]byte(data), &evt); err != nil {
+			return false, fmt.Errorf("decode responses event: %w", err)
+		}
+		if evt.Type == "" {
+			evt.Type = ev.Event
+		}
+		counts[evt.Type]++
+		switch evt.Type {
+		case "response.output_text.delta":
+			if evt.Delta != "" {
+				onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: evt.Delta})
+			}
+		case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+			if evt.Delta != "" {
+				onEvent(agent.StreamEvent{Type: agent.StreamEventReasoningDelta, Text: evt.Delta})
+			}
+		case "response.output_item.done":
+			var item responsesItem
+			if json.Unmarshal(evt.Item, &item) != nil {
+				return true, nil
+			}
+			if item.Type == "reasoning" {
+				// reasoning item 与 ResponseItem 的 JSON 形态一致（summary、encrypted_content），直接下发。
+				onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: evt.Item})
+				return true, nil
+			}
+			if item.Type != "function_call" {
+				return true, nil
+			}
+			if raw := functionCallItem(item.Name, item.CallID, item.Arguments); len(raw) > 0 {
+				onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: raw})
+			}
+		case "response.completed", "response.incomplete":
+			if r := evt.Response; r != nil {
+				finish = r.Status
+				if r.IncompleteDetails != nil && r.IncompleteDetails.Reason != "" {
+					finish = r.IncompleteDetails.Reason
+				}
+				if u := r.Usage; u != nil {
+					var cached int64
+					if u.InputTokensDetails != nil {
+						cached = u.InputTokensDetails.CachedTokens
+					}
+					onEvent(agent.StreamEvent{Type: agent.StreamEventUsage, Usage: &agent.TokenUsage{
+						InputTokens:          u.InputTokens - cached,
+						OutputTokens:         u.OutputTokens,
+						CacheReadInputTokens: cached,
+					}})
+				}
+			}
+			return false, nil
+		case "response.failed":
+			if evt.Response != nil && evt.Response.Error != nil {
+				return false, evt.Response.Error.err()
+			}
+			return false, (&streamError{Message: "response failed"}).err()
+		case "error":
+			return false, (&streamError{Message: evt.Message}).err()
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	stop := mapStopReason(finish)
+	if finish == "completed" {
+		stop = "end_turn"
+	}
+	onEvent(agent.StreamEvent{Type: agent.StreamEventCompleted, StopReason: stop, FinishReason: finish})
+	return nil
+}
+
+// responsesContent 把多模态片段映射为 input_text / input_image（data URL）。
+func responsesContent(parts []agent.ContentPart) []map[string]string {
+	out := make([]map[string]string, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case agent.ContentPartImage:
+			out = append(out, map[string]string{"type": "input_image", "image_url": part.DataURL()})
+		default:
+			if text := strings.TrimSpace(part.Text); text != "" {
+				out = append(out, map[string]string{"type": "input_text", "text": text})
+			}
+		}
+	}
+	return out
+}
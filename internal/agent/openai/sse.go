@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+const maxSSELine = 4 * 1024 * 1024
+
+// sseEvent 是一条 server-sent event；data 多行时按换行拼接。
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// readSSE 逐条解析 SSE 事件并交给 fn；fn 返回 false 时停止读取。
+func readSSE(r io.Reader, fn func(sseEvent) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxSSELine)
+	var (
+		event string
+		data  []string
+	)
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			event = ""
+			return true, nil
+		}
+		ev := sseEvent{Event: event, Data: strings.Join(data, "\n")}
+		event, data = "", nil
+		return fn(ev)
+	}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			more, err := dispatch()
+			if err != nil || !more {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	_, err := dispatch()
+	return err
+}
@@ -1,5 +1,7 @@
 package agent
 
+import "strings"
+
 // ToolSpec 描述可供模型调用的工具定义，遵循 function 工具的通用 schema 约定。
 type ToolSpec struct {
 	Name        string
@@ -7,6 +9,40 @@ type ToolSpec struct {
 	Parameters  map[string]any
 }
 
+// DefaultMaxOutputTokens 是未配置 max_output_tokens 时 provider 请求使用的输出上限。
+const DefaultMaxOutputTokens int64 = 8192
+
+// SamplingOptions 描述一次请求的采样参数；零值表示交给 provider 默认值。
+type SamplingOptions struct {
+	// MaxTokens 为输出 token 上限，0 表示 DefaultMaxOutputTokens（anthropic 必填）或 provider 默认。
+	MaxTokens     int64
+	Temperature   *float64
+	TopP          *float64
+	StopSequences []string
+	// ThinkingBudget 为扩展思考的 token 预算，0 表示不启用。
+	ThinkingBudget int64
+}
+
+// Merge 用 override 中已设置的字段覆盖 s，返回新的采样参数。
+func (s SamplingOptions) Merge(override SamplingOptions) SamplingOptions {
+	if override.MaxTokens > 0 {
+		s.MaxTokens = override.MaxTokens
+	}
+	if override.Temperature != nil {
+		s.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		s.TopP = override.TopP
+	}
+	if len(override.StopSequences) > 0 {
+		s.StopSequences = append([]string(nil), override.StopSequences...)
+	}
+	if override.ThinkingBudget > 0 {
+		s.ThinkingBudget = override.ThinkingBudget
+	}
+	return s
+}
+
 // Prompt 代表一次模型调用的完整请求，包括模型、消息与工具配置。
 type Prompt struct {
 	Model             string
@@ -14,6 +50,44 @@ type Prompt struct {
 	Tools             []ToolSpec
 	ParallelToolCalls bool
 	OutputSchema      string
+	Sampling          SamplingOptions
+	// ReasoningEffort 非空时由 provider 映射为原生思考参数（anthropic thinking、OpenAI reasoning.effort）。
+	ReasoningEffort string
+}
+
+// ThinkingBudgetForEffort 把推理强度映射为思考 token 预算；未知强度返回 0。
+func ThinkingBudgetForEffort(effort string) int64 {
+	switch strings.ToLower(strings.TrimSpace(effort)) {
+	case "minimal":
+		return 1024
+	case "low":
+		return 4096
+	case "medium":
+		return 10240
+	case "high":
+		return 24576
+	default:
+		return 0
+	}
+}
+
+// ViewImageTool 是 view_image 工具的定义；由 features.view_image_tool 控制是否公开给模型。
+func ViewImageTool() ToolSpec {
+	return ToolSpec{
+		Name:        "view_image",
+		Description: "查看工作区内的本地图片（png/jpeg/gif/webp），图片会作为输入附在工具结果之后，例如 UI 截图。",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "图片相对工作区的路径。",
+				},
+			},
+			"required":             []string{"path"},
+			"additionalProperties": false,
+		},
+	}
 }
 
 // DefaultTools 返回 Echo CLI 内置的工具规范，供模型端暴露调用能力。
@@ -75,7 +149,7 @@ func DefaultTools() []ToolSpec {
 		},
 		{
 			Name:        "apply_patch",
-			Description: "应用补丁（支持 unified diff 或 Echo Patch 格式）。Echo Patch 需要以 \"*** Begin Patch\" 开头、以 \"*** End Patch\" 结束，并且仅允许使用 \"*** Add File:\" / \"*** Update File:\" / \"*** Delete File:\"（可选 \"*** Move to:\" 重命名；\"*** End of File\" 可用于标注文件结束）。注意：\"*** Update File\" 的 hunk 需要使用 \"@@\" 分隔，每一行必须以前缀开头：空格=上下文，\"-\"=删除，\"+\"=新增；不要直接粘贴无前缀的文件内容。若要整文件替换，优先用 \"*** Delete File\" + \"*** Add File\"。",
+			Description: "应用补丁（支持 unified diff 或 Echo Patch 格式）。Echo Patch 需要以 \"*** Begin Patch\" 开头、以 \"*** End Patch\" 结束，并且仅允许使用 \"*** Add File:\" / \"*** Update File:\" / \"*** Delete File:\"（可选 \"*** Move to:\" 重命名；\"*** End of File\" 可用于标注文件结束）。注意：\"*** Update File\" 的 hunk 需要使用 \"@@\" 分隔，每一行必须以前缀开头：空格=上下文，\"-\"=删除，\"+\"=新增；不要直接粘贴无前缀的文件内容。若要整文件替换，优先用 \"*** Delete File\" + \"*** Add File\"。unified diff 会自动识别 a/ b/ 等路径前缀，容忍行号偏移与空白差异。补丁整体生效或整体不生效；失败时会逐个列出未匹配的 hunk，请重新读取文件后只重写这些 hunk。",
 			Parameters: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -90,13 +164,21 @@ func DefaultTools() []ToolSpec {
 		},
 		{
 			Name:        "file_read",
-			Description: "读取给定文件内容。",
+			Description: "读取文件内容（带行号，默认前 2000 行，输出上限 64KB，超出时提示续读的 offset）；二进制文件只返回类型与大小，目录返回条目列表。读取工作目录之外的路径可能需要审批。",
 			Parameters: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"path": map[string]any{
 						"type":        "string",
-						"description": "要读取的文件相对路径。",
+						"description": "要读取的文件或目录路径（相对工作目录）。",
+					},
+					"offset": map[string]any{
+						"type":        "integer",
+						"description": "可选：起始行号（从 1 开始）。",
+					},
+					"limit": map[string]any{
+						"type":        "integer",
+						"description": "可选：最多读取的行数，默认 2000。",
 					},
 				},
 				"required":             []string{"path"},
@@ -105,19 +187,64 @@ func DefaultTools() []ToolSpec {
 		},
 		{
 			Name:        "file_search",
-			Description: "枚举工作区文件列表，可选提供关键词过滤。",
+			Description: "按路径模糊匹配查找工作区文件（遵循 .gitignore），结果按匹配度排序，最多 200 条。",
 			Parameters: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"query": map[string]any{
 						"type":        "string",
-						"description": "用于过滤结果的关键词或模式（可传空字符串获取全部）。",
+						"description": "路径关键词，按子序列模糊匹配（如 \"tuimodel\" 匹配 internal/tui/model.go）；传空字符串列出文件。",
 					},
 				},
 				"required":             []string{"query"},
 				"additionalProperties": false,
 			},
 		},
+		{
+			Name:        "grep",
+			Description: "在工作区文件内容中搜索（遵循 .gitignore，跳过二进制文件），输出 path:line:text；优先使用它代替 shell grep/rg。",
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"pattern": map[string]any{
+						"type":        "string",
+						"description": "搜索模式，默认为 Go 正则（RE2 语法）。",
+					},
+					"literal": map[string]any{
+						"type":        "boolean",
+						"description": "可选：为 true 时按字面量匹配 pattern。",
+					},
+					"ignore_case": map[string]any{
+						"type":        "boolean",
+						"description": "可选：忽略大小写。",
+					},
+					"path": map[string]any{
+						"type":        "string",
+						"description": "可选：搜索的子目录或文件（相对工作目录）。",
+					},
+					"include": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "可选：只搜索匹配这些 glob 的文件（如 \"*.go\"、\"internal/**/*.ts\"）。",
+					},
+					"exclude": map[string]any{
+						"type":        "array",
+						"items":       map[string]any{"type": "string"},
+						"description": "可选：跳过匹配这些 glob 的文件（如 \"*_test.go\"）。",
+					},
+					"context": map[string]any{
+						"type":        "integer",
+						"description": "可选：每个匹配前后附带的上下文行数（最多 10）。",
+					},
+					"max_results": map[string]any{
+						"type":        "integer",
+						"description": "可选：最多返回的匹配行数，默认 100。",
+					},
+				},
+				"required":             []string{"pattern"},
+				"additionalProperties": false,
+			},
+		},
 		{
 			Name:        "update_plan",
 			Description: "更新当前计划列表，支持 pending/in_progress/completed 三种状态。",
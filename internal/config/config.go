@@ -11,10 +11,92 @@ import (
 
 // Config is the only persisted config file schema.
 type Config struct {
-	URL    string `toml:"url"`
-	Token  string `toml:"token"`
-	Model  string `toml:"model"`
-	Source string `toml:"-"`
+	URL   string `toml:"url"`
+	Token string `toml:"token"`
+	Model string `toml:"model"`
+	// Provider 选择模型协议：anthropic（默认）、openai、ollama、lmstudio。
+	Provider string `toml:"provider,omitempty"`
+	// WireAPI 仅对 OpenAI 兼容 provider 生效：chat（Chat Completions，默认）或 responses。
+	WireAPI string `toml:"wire_api,omitempty"`
+	// MCPServers 以服务器名为 key 配置外部 MCP 工具服务器（[mcp_servers.<name>]）。
+	MCPServers map[string]MCPServerConfig `toml:"mcp_servers,omitempty"`
+	// ApprovalPolicy 是默认审批策略：never、on-request、on-failure、untrusted、always。
+	ApprovalPolicy string `toml:"approval_policy,omitempty"`
+	// Approvals 定义在 LLM 审查之前求值的 allow/deny 规则（[approvals]）。
+	Approvals ApprovalRules `toml:"approvals,omitempty"`
+	// SandboxMode 是命令执行的沙箱级别：read-only、workspace-write、full-access（默认）。
+	SandboxMode string `toml:"sandbox_mode,omitempty"`
+	// Sandbox 是受限模式的附加设置（[sandbox]）。
+	Sandbox SandboxSettings `toml:"sandbox,omitempty"`
+	// Search 是文件遍历与搜索的设置（[search]）。
+	Search SearchSettings `toml:"search,omitempty"`
+	// Models 以模型名（或名称前缀）为 key 声明上下文窗口、输出上限与自动压缩阈值（[models.<name>]）。
+	Models map[string]ModelConfig `toml:"models,omitempty"`
+	// Profile 是未指定 --profile 时默认启用的 profile 名称。
+	Profile string `toml:"profile,omitempty"`
+	// Profiles 以名称为 key 定义可切换的配置组合（[profiles.<name>]）。
+	Profiles map[string]Profile `toml:"profiles,omitempty"`
+	Source   string             `toml:"-"`
+}
+
+// ApprovalRules 按命令前缀与路径 glob 放行或拒绝工具调用，deny 优先。
+type ApprovalRules struct {
+	AllowCommands []string `toml:"allow_commands,omitempty"`
+	DenyCommands  []string `toml:"deny_commands,omitempty"`
+	AllowPaths    []string `toml:"allow_paths,omitempty"`
+	DenyPaths     []string `toml:"deny_paths,omitempty"`
+}
+
+// SandboxSettings 补充 workspace-write 可写目录与网络开关。
+type SandboxSettings struct {
+	WritableRoots []string `toml:"writable_roots,omitempty"`
+	NetworkAccess bool     `toml:"network_access,omitempty"`
+}
+
+// SearchSettings 配置 @ 选择器、file_search 与 grep 共用的文件索引。
+type SearchSettings struct {
+	// Ignore 是 gitignore 语法的附加忽略规则，相对工作目录求值，优先级低于仓库内的 .gitignore/.ignore。
+	Ignore []string `toml:"ignore,omitempty"`
+}
+
+// ModelConfig 描述一个模型的 token 预算，未填写的字段沿用内置模型表。
+type ModelConfig struct {
+	ContextWindow   int64 `toml:"context_window,omitempty"`
+	MaxOutputTokens int64 `toml:"max_output_tokens,omitempty"`
+	// AutoCompactTokenLimit 为触发自动压缩的 prompt token 数，缺省为窗口的 90%。
+	AutoCompactTokenLimit int64 `toml:"auto_compact_token_limit,omitempty"`
+	// Tokenizer 选择 token 计数器：bpe（默认）或 approx（bytes/4）。
+	Tokenizer string `toml:"tokenizer,omitempty"`
+	// Reasoning 声明模型是否支持原生思考参数；未设置时沿用内置模型表。
+	Reasoning *bool `toml:"reasoning,omitempty"`
+
+	// 以下为该模型请求的默认采样参数；max_output_tokens 同时作为单次请求的 max_tokens。
+	Temperature    *float64 `toml:"temperature,omitempty"`
+	TopP           *float64 `toml:"top_p,omitempty"`
+	StopSequences  []string `toml:"stop_sequences,omitempty"`
+	ThinkingBudget int64    `toml:"thinking_budget,omitempty"`
+}
+
+// MCPServerConfig 描述一个 MCP 服务器：设置 command 走 stdio，设置 url 走 streamable HTTP。
+type MCPServerConfig struct {
+	Command string            `toml:"command,omitempty"`
+	Args    []string          `toml:"args,omitempty"`
+	Env     map[string]string `toml:"env,omitempty"`
+	Cwd     string            `toml:"cwd,omitempty"`
+
+	URL               string            `toml:"url,omitempty"`
+	BearerTokenEnvVar string            `toml:"bearer_token_env_var,omitempty"`
+	HTTPHeaders       map[string]string `toml:"http_headers,omitempty"`
+
+	// Enabled 为 false 时跳过该服务器；缺省视为启用。
+	Enabled           *bool `toml:"enabled,omitempty"`
+	StartupTimeoutSec int   `toml:"startup_timeout_sec,omitempty"`
+	ToolTimeoutSec    int   `toml:"tool_timeout_sec,omitempty"`
+}
+
+// IsEnabled 报告服务器是否启用。
+func (c MCPServerConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
 }
 
 func Default() Config {
@@ -58,6 +140,10 @@ func Load(path string) (Config, error) {
 	if err := toml.Unmarshal(content, &cfg); err != nil {
 		return cfg, err
 	}
+	if NormalizeProvider(cfg.Provider) != ProviderAnthropic {
+		// ANTHROPIC_* 环境变量只作用于 anthropic provider。
+		return cfg, nil
+	}
 	if env := strings.TrimSpace(os.Getenv("ANTHROPIC_BASE_URL")); env != "" {
 		cfg.URL = env
 	}
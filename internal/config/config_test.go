@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -62,3 +63,158 @@ func TestApplyKVOverrides_Model(t *testing.T) {
 		t.Fatalf("ApplyKVOverrides(...).Model = %q, want %q", got.Model, "override-model")
 	}
 }
+
+func TestLoad_NonAnthropicProviderIgnoresAnthropicEnv(t *testing.T) {
+	t.Setenv("ANTHROPIC_BASE_URL", "https://anthropic.example")
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "anthropic-token")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+provider = "ollama"
+model = "qwen2.5-coder"
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg = ResolveEndpoint(cfg)
+	if cfg.URL != "http://localhost:11434/v1" || cfg.Token != "" || cfg.WireAPI != WireAPIChat {
+		t.Fatalf("unexpected endpoint %+v", cfg)
+	}
+}
+
+func TestResolveEndpoint_OpenAIFallsBackToEnvToken(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("OPENAI_BASE_URL", "")
+	cfg := ResolveEndpoint(ApplyKVOverrides(Default(), []string{"provider=OpenAI", "wire_api=responses"}))
+	if cfg.Provider != ProviderOpenAI || cfg.Token != "sk-test" || cfg.URL != "https://api.openai.com/v1" || cfg.WireAPI != WireAPIResponses {
+		t.Fatalf("unexpected endpoint %+v", cfg)
+	}
+}
+
+func TestApplyProfile_MergesEndpointAndExpandsRuntimeSettings(t *testing.T) {
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+url = "https://anthropic.example"
+token = "top-token"
+model = "glm4.6"
+profile = "triage"
+
+[profiles.triage]
+model = "glm4.5-air"
+reasoning_effort = "low"
+retries = 0
+max_output_tokens = 16000
+temperature = 0.2
+stop_sequences = ["END"]
+
+[profiles.refactor]
+provider = "openai"
+model = "gpt-5"
+request_timeout_seconds = 600
+approval_policy = "on-request"
+
+[profiles.refactor.features]
+undo = false
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	triage, overrides, err := cfg.ApplyProfile("")
+	if err != nil {
+		t.Fatalf("ApplyProfile default: %v", err)
+	}
+	if triage.Profile != "triage" || triage.Model != "glm4.5-air" || triage.URL != "https://anthropic.example" {
+		t.Fatalf("unexpected triage config %+v", triage)
+	}
+	if got := strings.Join(overrides, ","); got != "reasoning_effort=low,retries=0,max_output_tokens=16000,temperature=0.2,stop_sequences=END" {
+		t.Fatalf("unexpected triage overrides %q", got)
+	}
+
+	refactor, overrides, err := cfg.ApplyProfile("refactor")
+	if err != nil {
+		t.Fatalf("ApplyProfile refactor: %v", err)
+	}
+	if refactor.Provider != "openai" || refactor.URL != "" || refactor.Token != "" || refactor.Model != "gpt-5" {
+		t.Fatalf("switching provider should drop top-level endpoint, got %+v", refactor)
+	}
+	if got := strings.Join(overrides, ","); got != "request_timeout_seconds=600,approval_policy=on-request,features.undo=false" {
+		t.Fatalf("unexpected refactor overrides %q", got)
+	}
+
+	if _, _, err := cfg.ApplyProfile("missing"); err == nil || !strings.Contains(err.Error(), "refactor, triage") {
+		t.Fatalf("expected not found error listing profiles, got %v", err)
+	}
+}
+
+func TestResolveEndpoint_ProfileSwitchToAnthropicUsesEnv(t *testing.T) {
+	t.Setenv("ANTHROPIC_BASE_URL", "https://anthropic.example")
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "anthropic-token")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+provider = "openai"
+url = "https://api.openai.com/v1"
+token = "sk-top"
+
+[profiles.claude]
+provider = "anthropic"
+model = "glm4.6"
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg, _, err = cfg.ApplyProfile("claude")
+	if err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+	cfg = ResolveEndpoint(cfg)
+	if cfg.Provider != ProviderAnthropic || cfg.URL != "https://anthropic.example" || cfg.Token != "anthropic-token" {
+		t.Fatalf("expected ANTHROPIC_* fallback after switching provider, got %+v", cfg)
+	}
+}
+
+func TestLoad_ModelsCatalog(t *testing.T) {
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	t.Setenv("ANTHROPIC_AUTH_TOKEN", "")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(`
+model = "glm4.6"
+
+[models."glm4.6"]
+context_window = 200000
+max_output_tokens = 32000
+auto_compact_token_limit = 160000
+
+[models.qwen]
+context_window = 32768
+tokenizer = "approx"
+`), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	glm := cfg.Models["glm4.6"]
+	if glm.ContextWindow != 200000 || glm.MaxOutputTokens != 32000 || glm.AutoCompactTokenLimit != 160000 {
+		t.Fatalf("unexpected glm4.6 entry: %+v", glm)
+	}
+	if qwen := cfg.Models["qwen"]; qwen.ContextWindow != 32768 || qwen.Tokenizer != "approx" {
+		t.Fatalf("unexpected qwen entry: %+v", qwen)
+	}
+}
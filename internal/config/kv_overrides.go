@@ -23,6 +23,10 @@ func ApplyKVOverrides(cfg Config, overrides []string) Config {
 			cfg.Token = val
 		case "model":
 			cfg.Model = val
+		case "provider", "model_provider":
+			cfg.Provider = val
+		case "wire_api":
+			cfg.WireAPI = val
 		}
 	}
 	return cfg
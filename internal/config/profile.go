@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profile 是 [profiles.<name>] 中的一组设置，未填写的字段沿用顶层配置。
+type Profile struct {
+	Provider string `toml:"provider,omitempty"`
+	URL      string `toml:"url,omitempty"`
+	Token    string `toml:"token,omitempty"`
+	WireAPI  string `toml:"wire_api,omitempty"`
+	Model    string `toml:"model,omitempty"`
+
+	ReasoningEffort       string `toml:"reasoning_effort,omitempty"`
+	Language              string `toml:"language,omitempty"`
+	RequestTimeoutSeconds int    `toml:"request_timeout_seconds,omitempty"`
+	ToolTimeoutSeconds    int    `toml:"tool_timeout_seconds,omitempty"`
+	Retries               *int   `toml:"retries,omitempty"`
+	ApprovalPolicy        string `toml:"approval_policy,omitempty"`
+	SandboxMode           string `toml:"sandbox_mode,omitempty"`
+	// 采样参数，等价于 -c max_output_tokens= / temperature= / top_p= / stop_sequences= / thinking_budget=。
+	MaxOutputTokens int64    `toml:"max_output_tokens,omitempty"`
+	Temperature     *float64 `toml:"temperature,omitempty"`
+	TopP            *float64 `toml:"top_p,omitempty"`
+	StopSequences   []string `toml:"stop_sequences,omitempty"`
+	ThinkingBudget  int64    `toml:"thinking_budget,omitempty"`
+	// Features 按 feature key 开关功能，等价于 -c features.<key>=<bool>。
+	Features map[string]bool `toml:"features,omitempty"`
+}
+
+// ProfileNames 返回已定义的 profile 名称（排序后）。
+func (c Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile 将 profile 的端点设置合并进配置，并把运行时设置展开为 key=value 覆盖。
+// name 为空时使用顶层 profile 字段；两者都为空时原样返回。
+// 返回的覆盖项优先级低于命令行 -c，调用方应将其放在命令行覆盖之前。
+// profile 切换到与顶层不同的 provider 时，顶层的 url/token 不再沿用。
+func (c Config) ApplyProfile(name string) (Config, []string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = strings.TrimSpace(c.Profile)
+	}
+	if name == "" {
+		return c, nil, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		available := "none defined"
+		if names := c.ProfileNames(); len(names) > 0 {
+			available = "available: " + strings.Join(names, ", ")
+		}
+		return c, nil, fmt.Errorf("config profile %q not found (%s)", name, available)
+	}
+	c.Profile = name
+
+	if v := strings.TrimSpace(p.Provider); v != "" {
+		if NormalizeProvider(v) != NormalizeProvider(c.Provider) {
+			c.URL, c.Token, c.WireAPI = "", "", ""
+		}
+		c.Provider = v
+	}
+	if v := strings.TrimSpace(p.URL); v != "" {
+		c.URL = v
+	}
+	if v := strings.TrimSpace(p.Token); v != "" {
+		c.Token = v
+	}
+	if v := strings.TrimSpace(p.WireAPI); v != "" {
+		c.WireAPI = v
+	}
+	if v := strings.TrimSpace(p.Model); v != "" {
+		c.Model = v
+	}
+
+	var overrides []string
+	add := func(key, val string) {
+		if strings.TrimSpace(val) != "" {
+			overrides = append(overrides, key+"="+strings.TrimSpace(val))
+		}
+	}
+	add("reasoning_effort", p.ReasoningEffort)
+	add("language", p.Language)
+	if p.RequestTimeoutSeconds > 0 {
+		add("request_timeout_seconds", strconv.Itoa(p.RequestTimeoutSeconds))
+	}
+	if p.ToolTimeoutSeconds > 0 {
+		add("tool_timeout_seconds", strconv.Itoa(p.ToolTimeoutSeconds))
+	}
+	if p.Retries != nil {
+		add("retries", strconv.Itoa(*p.Retries))
+	}
+	add("approval_policy", p.ApprovalPolicy)
+	add("sandbox_mode", p.SandboxMode)
+	if p.MaxOutputTokens > 0 {
+		add("max_output_tokens", strconv.FormatInt(p.MaxOutputTokens, 10))
+	}
+	if p.Temperature != nil {
+		add("temperature", strconv.FormatFloat(*p.Temperature, 'g', -1, 64))
+	}
+	if p.TopP != nil {
+		add("top_p", strconv.FormatFloat(*p.TopP, 'g', -1, 64))
+	}
+	add("stop_sequences", strings.Join(p.StopSequences, ","))
+	if p.ThinkingBudget > 0 {
+		add("thinking_budget", strconv.FormatInt(p.ThinkingBudget, 10))
+	}
+	keys := make([]string, 0, len(p.Features))
+	for key := range p.Features {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add("features."+key, strconv.FormatBool(p.Features[key]))
+	}
+	return c, overrides, nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+	ProviderLMStudio  = "lmstudio"
+)
+
+const (
+	WireAPIChat      = "chat"
+	WireAPIResponses = "responses"
+)
+
+// NormalizeProvider 统一 provider 名称的大小写与常见别名；空值视为 anthropic。
+func NormalizeProvider(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "anthropic", "claude":
+		return ProviderAnthropic
+	case "openai", "openai-compatible", "openai_compatible":
+		return ProviderOpenAI
+	case "ollama":
+		return ProviderOllama
+	case "lmstudio", "lm-studio", "lm_studio":
+		return ProviderLMStudio
+	default:
+		return strings.ToLower(strings.TrimSpace(name))
+	}
+}
+
+// IsKnownProvider 报告 provider 是否有对应的模型客户端实现。
+func IsKnownProvider(name string) bool {
+	switch NormalizeProvider(name) {
+	case ProviderAnthropic, ProviderOpenAI, ProviderOllama, ProviderLMStudio:
+		return true
+	}
+	return false
+}
+
+// IsLocalProvider 报告 provider 是否为本地 OSS 推理服务（无需 token）。
+func IsLocalProvider(name string) bool {
+	switch NormalizeProvider(name) {
+	case ProviderOllama, ProviderLMStudio:
+		return true
+	}
+	return false
+}
+
+// DefaultProviderURL 返回 provider 的默认 base URL；anthropic 没有默认值。
+func DefaultProviderURL(name string) string {
+	switch NormalizeProvider(name) {
+	case ProviderOpenAI:
+		return "https://api.openai.com/v1"
+	case ProviderOllama:
+		return "http://localhost:11434/v1"
+	case ProviderLMStudio:
+		return "http://localhost:1234/v1"
+	}
+	return ""
+}
+
+// ResolveEndpoint 按 provider 补全 url/token/wire_api：
+// url 为空时使用 provider 默认地址，OpenAI 的 token 可回退到 OPENAI_API_KEY；
+// anthropic 的 url/token 为空时（如 profile 或 --provider 从其他 provider 切换过来）回退到 ANTHROPIC_BASE_URL/ANTHROPIC_AUTH_TOKEN。
+func ResolveEndpoint(cfg Config) Config {
+	cfg.Provider = NormalizeProvider(cfg.Provider)
+	if cfg.Provider == ProviderAnthropic {
+		if strings.TrimSpace(cfg.URL) == "" {
+			cfg.URL = strings.TrimSpace(os.Getenv("ANTHROPIC_BASE_URL"))
+		}
+		if strings.TrimSpace(cfg.Token) == "" {
+			cfg.Token = strings.TrimSpace(os.Getenv("ANTHROPIC_AUTH_TOKEN"))
+		}
+		return cfg
+	}
+	if strings.TrimSpace(cfg.URL) == "" {
+		if env := strings.TrimSpace(os.Getenv("OPENAI_BASE_URL")); env != "" && cfg.Provider == ProviderOpenAI {
+			cfg.URL = env
+		} else {
+			cfg.URL = DefaultProviderURL(cfg.Provider)
+		}
+	}
+	if strings.TrimSpace(cfg.Token) == "" && cfg.Provider == ProviderOpenAI {
+		cfg.Token = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.WireAPI)) {
+	case WireAPIResponses:
+		cfg.WireAPI = WireAPIResponses
+	default:
+		cfg.WireAPI = WireAPIChat
+	}
+	return cfg
+}
@@ -17,16 +17,21 @@ var ErrContextWindowTooSmallForCompaction = errors.New("context window too small
 // 1) 用 compact prompt 让模型生成“交接摘要”
 // 2) 将历史重建为：最近若干 user 消息 + summary（作为 user 消息注入）
 // 3) 当 compact prompt 超出窗口时，从最旧处裁剪以尽量保留 prefix cache 与最近消息
+// focus 非空时作为关注点附加到 compact prompt 之后（/compact <hint>）。
 func CompactConversationHistory(
 	ctx stdcontext.Context,
 	client agent.ModelClient,
 	turn TurnContext,
 	historyItems []ResponseItem,
+	focus string,
 ) (newHistory []ResponseItem, trimmedOlderItems int, summaryText string, err error) {
 	compactPrompt, ok := prompts.Builtin(prompts.PromptCompact)
 	if !ok {
 		return nil, 0, "", errors.New("missing builtin compact prompt")
 	}
+	if focus = strings.TrimSpace(focus); focus != "" {
+		compactPrompt = strings.TrimRight(compactPrompt, "\n") + "\n\n本次摘要请重点关注：" + focus
+	}
 	summaryPrefix, ok := prompts.Builtin(prompts.PromptCompactSummaryPrefix)
 	if !ok {
 		return nil, 0, "", errors.New("missing builtin compact summary prefix")
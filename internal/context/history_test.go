@@ -1,8 +1,12 @@
 package context
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/events"
 )
 
 func TestProcessResponseItemsForHistory_KeepsGhostSnapshot(t *testing.T) {
@@ -95,3 +99,47 @@ func TestRemoveFirstItem_RemovesLocalShellPairWhenOutputRemoved(t *testing.T) {
 		t.Fatalf("unexpected remaining content: %#v", out[0])
 	}
 }
+
+func TestReasoningItemKeepsSignatureForReplay(t *testing.T) {
+	t.Parallel()
+
+	raw := `{"type":"reasoning","content":[{"type":"reasoning_text","text":"look at go.mod"}],"signature":"sig-1"}`
+	var item ResponseItem
+	if err := json.Unmarshal([]byte(raw), &item); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	out, err := json.Marshal(item)
+	if err != nil || !strings.Contains(string(out), `"signature":"sig-1"`) {
+		t.Fatalf("signature lost on round trip: %s err=%v", out, err)
+	}
+	msgs := ResponseItemsToAgentMessages([]ResponseItem{item})
+	if len(msgs) != 1 || msgs[0].Reasoning == nil || msgs[0].Reasoning.Signature != "sig-1" || msgs[0].Reasoning.Text != "look at go.mod" {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+}
+
+func TestInputImagesBecomeContentParts(t *testing.T) {
+	t.Parallel()
+
+	item := inputResponseItem(events.InputMessage{
+		Role:    "user",
+		Content: "why is this red?",
+		Images:  []string{"data:image/png;base64,iVBORw0K"},
+	})
+	msgs := responseItemToAgentMessages(item)
+	if len(msgs) != 1 || len(msgs[0].Parts) != 2 {
+		t.Fatalf("expected text and image parts, got %#v", msgs)
+	}
+	image := msgs[0].Parts[1]
+	if image.Type != agent.ContentPartImage || image.MediaType != "image/png" || image.Data != "iVBORw0K" {
+		t.Fatalf("unexpected image part %#v", image)
+	}
+	if flat := FlattenContentItems(item.Message.Content); strings.Contains(flat, "iVBORw0K") || !strings.Contains(flat, "[image: image/png]") {
+		t.Fatalf("flattened content should describe the image without its data: %q", flat)
+	}
+
+	plain := responseItemToAgentMessages(NewUserMessageItem("hello"))
+	if len(plain) != 1 || plain[0].Parts != nil {
+		t.Fatalf("text-only messages should not carry parts: %#v", plain)
+	}
+}
@@ -2,6 +2,7 @@ package context
 
 import (
 	"sync"
+	"time"
 
 	"echo-cli/internal/agent"
 	"echo-cli/internal/events"
@@ -16,6 +17,10 @@ type SessionDefaults struct {
 	ReasoningEffort string
 	ReviewMode      bool
 	Language        string
+	// Tools 是在内置工具之外额外暴露给模型的工具（例如 MCP 服务器提供的工具）。
+	Tools []agent.ToolSpec
+	// Sampling 是命令行/profile 指定的采样参数，优先于 [models.<name>] 中的设置。
+	Sampling agent.SamplingOptions
 }
 
 type sessionState struct {
@@ -29,6 +34,17 @@ type sessionState struct {
 
 	history         []agent.Message
 	responseHistory []ResponseItem
+	turns           []TurnRecord
+}
+
+// TurnRecord 记录一次用户回合的元数据（模型、用量、起止时间），随会话一起持久化。
+type TurnRecord struct {
+	Model             string    `json:"model,omitempty"`
+	InputTokens       int64     `json:"input_tokens,omitempty"`
+	CachedInputTokens int64     `json:"cached_input_tokens,omitempty"`
+	OutputTokens      int64     `json:"output_tokens,omitempty"`
+	Started           time.Time `json:"started"`
+	Completed         time.Time `json:"completed"`
 }
 
 // TurnContext 聚合生成提示词所需的上下文数据。
@@ -39,9 +55,11 @@ type TurnContext struct {
 	OutputSchema    string
 	Language        string
 	ReasoningEffort string
-	ReviewMode      bool            // 是否启用审查模式
-	Attachments     []agent.Message // 附件内容（文件、图片等）
-	History         []agent.Message // 纯对话历史（不包括系统注入的内容）
+	ReviewMode      bool             // 是否启用审查模式
+	Attachments     []agent.Message  // 附件内容（文件、图片等）
+	History         []agent.Message  // 纯对话历史（不包括系统注入的内容）
+	Tools           []agent.ToolSpec // 内置工具之外的额外工具
+	Sampling        agent.SamplingOptions
 
 	AttachmentItems []ResponseItem // 附件的 ResponseItem 表示
 	ResponseHistory []ResponseItem // 纯对话历史（ResponseItem 形态）
@@ -71,6 +89,8 @@ func NewContextManager(defaults SessionDefaults) *ContextManager {
 			ReasoningEffort: defaults.ReasoningEffort,
 			ReviewMode:      defaults.ReviewMode,
 			Language:        defaults.Language,
+			Tools:           append([]agent.ToolSpec(nil), defaults.Tools...),
+			Sampling:        defaults.Sampling,
 		},
 		sessions: map[string]*sessionState{},
 	}
@@ -97,6 +117,24 @@ func (m *ContextManager) PrepareTurn(sessionID string, ctx events.InputContext,
 	state.history = append(state.history, userMessages...)
 	state.responseHistory = append(state.responseHistory, userResponseItems...)
 
+	turn := m.turnContextLocked(state, ctx, history, responseHistory)
+	m.mu.Unlock()
+
+	return TurnState{Model: turn.Model, Context: turn}
+}
+
+// CurrentTurn 基于会话当前历史构建提示上下文，不追加任何输入（用于 /compact 等会话级操作）。
+func (m *ContextManager) CurrentTurn(sessionID string, ctx events.InputContext) TurnContext {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.ensureSession(sessionID, ctx)
+	history := append([]agent.Message(nil), state.history...)
+	responseHistory := append([]ResponseItem(nil), state.responseHistory...)
+	return m.turnContextLocked(state, ctx, history, responseHistory)
+}
+
+// turnContextLocked 合并会话默认值与 InputContext 覆盖项；调用方需持有 m.mu。
+func (m *ContextManager) turnContextLocked(state *sessionState, ctx events.InputContext, history []agent.Message, responseHistory []ResponseItem) TurnContext {
 	// 从会话状态获取默认值
 	model := state.model
 	system := state.system
@@ -129,26 +167,20 @@ func (m *ContextManager) PrepareTurn(sessionID string, ctx events.InputContext,
 		reviewMode = true
 	}
 
-	attachments := toAgentMessages(ctx.Attachments)
-	attachmentItems := toResponseItems(ctx.Attachments)
-
-	m.mu.Unlock()
-
-	return TurnState{
-		Model: model,
-		Context: TurnContext{
-			Model:           model,
-			System:          system,
-			OutputSchema:    outputSchema,
-			Instructions:    instructions,
-			ReasoningEffort: reasoningEffort,
-			ReviewMode:      reviewMode,
-			Language:        language,
-			Attachments:     attachments,
-			AttachmentItems: attachmentItems,
-			History:         history,
-			ResponseHistory: responseHistory,
-		},
+	return TurnContext{
+		Model:           model,
+		System:          system,
+		OutputSchema:    outputSchema,
+		Instructions:    instructions,
+		ReasoningEffort: reasoningEffort,
+		ReviewMode:      reviewMode,
+		Language:        language,
+		Attachments:     toAgentMessages(ctx.Attachments),
+		AttachmentItems: toResponseItems(ctx.Attachments),
+		History:         history,
+		Tools:           m.defaults.Tools,
+		Sampling:        m.defaults.Sampling,
+		ResponseHistory: responseHistory,
 	}
 }
 
@@ -169,7 +201,7 @@ func (m *ContextManager) AppendMessages(sessionID string, msgs []agent.Message)
 	m.mu.Lock()
 	state := m.ensureSession(sessionID, events.InputContext{})
 	state.history = append(state.history, msgs...)
-	state.responseHistory = append(state.responseHistory, messagesToResponseItems(msgs)...)
+	state.responseHistory = append(state.responseHistory, MessagesToResponseItems(msgs)...)
 	m.mu.Unlock()
 }
 
@@ -221,6 +253,36 @@ func (m *ContextManager) ReplaceHistory(sessionID string, items []ResponseItem)
 	m.mu.Unlock()
 }
 
+// RestoreSession 用已保存的 ResponseItem 与回合记录原样重建会话历史（不再截断或转换），
+// 使恢复后的会话与原会话看到相同的上下文。
+func (m *ContextManager) RestoreSession(sessionID string, items []ResponseItem, turns []TurnRecord) {
+	m.mu.Lock()
+	state := m.ensureSession(sessionID, events.InputContext{})
+	state.responseHistory = append([]ResponseItem(nil), items...)
+	state.history = ResponseItemsToAgentMessages(state.responseHistory)
+	state.turns = append([]TurnRecord(nil), turns...)
+	m.mu.Unlock()
+}
+
+// RecordTurn 追加一条回合记录。
+func (m *ContextManager) RecordTurn(sessionID string, turn TurnRecord) {
+	m.mu.Lock()
+	state := m.ensureSession(sessionID, events.InputContext{})
+	state.turns = append(state.turns, turn)
+	m.mu.Unlock()
+}
+
+// Turns 返回会话回合记录的拷贝。
+func (m *ContextManager) Turns(sessionID string) []TurnRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.sessions[sessionID]
+	if state == nil || len(state.turns) == 0 {
+		return nil
+	}
+	return append([]TurnRecord(nil), state.turns...)
+}
+
 func (m *ContextManager) ensureSession(sessionID string, ctx events.InputContext) *sessionState {
 	state, ok := m.sessions[sessionID]
 	if ok {
@@ -273,10 +335,7 @@ func (m *ContextManager) ensureSession(sessionID string, ctx events.InputContext
 func toAgentMessages(items []events.InputMessage) []agent.Message {
 	msgs := make([]agent.Message, 0, len(items))
 	for _, item := range items {
-		msgs = append(msgs, agent.Message{
-			Role:    agent.Role(item.Role),
-			Content: item.Content,
-		})
+		msgs = append(msgs, responseItemToAgentMessages(inputResponseItem(item))...)
 	}
 	return msgs
 }
@@ -284,20 +343,25 @@ func toAgentMessages(items []events.InputMessage) []agent.Message {
 func toResponseItems(items []events.InputMessage) []ResponseItem {
 	msgs := make([]ResponseItem, 0, len(items))
 	for _, item := range items {
-		msgs = append(msgs, ResponseItem{
-			Type: ResponseItemTypeMessage,
-			Message: &MessageResponseItem{
-				Role: item.Role,
-				Content: []ContentItem{
-					{Type: ContentItemInputText, Text: item.Content},
-				},
-			},
-		})
+		msgs = append(msgs, inputResponseItem(item))
 	}
 	return msgs
 }
 
-func messagesToResponseItems(msgs []agent.Message) []ResponseItem {
+// inputResponseItem 把一条输入转为 message 条目：文本在前，图片作为 input_image（data URL）。
+func inputResponseItem(item events.InputMessage) ResponseItem {
+	content := []ContentItem{{Type: ContentItemInputText, Text: item.Content}}
+	for _, url := range item.Images {
+		content = append(content, ContentItem{Type: ContentItemInputImage, ImageURL: url})
+	}
+	return ResponseItem{
+		Type:    ResponseItemTypeMessage,
+		Message: &MessageResponseItem{Role: item.Role, Content: content},
+	}
+}
+
+// MessagesToResponseItems 把扁平的 agent.Message 转为 ResponseItem（旧会话迁移与 SeedHistory 使用）。
+func MessagesToResponseItems(msgs []agent.Message) []ResponseItem {
 	items := make([]ResponseItem, 0, len(msgs))
 	for _, msg := range msgs {
 		ri := ResponseItem{
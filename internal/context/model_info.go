@@ -1,55 +1,318 @@
 package context
 
 import (
+	"encoding/json"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+
+	"echo-cli/internal/agent"
 )
 
 const (
 	contextWindow272K int64 = 272_000
+	contextWindow200K int64 = 200_000
+	contextWindow128K int64 = 128_000
 )
 
-// ContextWindowForModel 尝试推导模型的上下文窗口（tokens）。
-// 优先读取环境变量 `ECHO_MODEL_CONTEXT_WINDOW`（若存在）。
-//
-// 注意：Echo 的 Go 实现当前无法从 provider 获取真实窗口；这里对齐 codex-rs 的已知映射并提供可覆盖入口。
-func ContextWindowForModel(model string) (int64, bool) {
-	if v := strings.TrimSpace(os.Getenv("ECHO_MODEL_CONTEXT_WINDOW")); v != "" {
-		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
-			return n, true
+// 每条消息在 provider 侧的固定开销（角色、分隔符）。
+const perMessageTokenOverhead = 4
+
+// 每张图片按 provider 缩放后的上限估算（约 1.15MP 的图片）。
+const imageTokenEstimate = 1_600
+
+// 校准比例的上下限与平滑系数：单次异常的用量回报不应让估算大幅漂移。
+const (
+	minCalibration   = 0.25
+	maxCalibration   = 4.0
+	calibrationAlpha = 0.5
+)
+
+// ModelInfo 描述一个模型的窗口与计数方式，对应配置中的 [models.<name>]。
+type ModelInfo struct {
+	ContextWindow   int64
+	MaxOutputTokens int64
+	// AutoCompactTokenLimit 为触发自动压缩的 prompt token 数；0 表示窗口的 90%。
+	AutoCompactTokenLimit int64
+	// Tokenizer 为 bpe（默认）或 approx。
+	Tokenizer string
+	// Reasoning 表示模型支持原生思考参数；nil 时沿用内置表。
+	Reasoning *bool
+
+	// 以下采样参数只来自配置条目，作为该模型请求的默认值。
+	Temperature    *float64
+	TopP           *float64
+	StopSequences  []string
+	ThinkingBudget int64
+}
+
+// ModelCatalog 合并内置模型表与配置中的模型条目，并按 provider 回报的用量校准 token 估算。
+type ModelCatalog struct {
+	mu          sync.Mutex
+	models      map[string]ModelInfo
+	calibration map[string]float64
+}
+
+// NewModelCatalog 使用配置中的模型条目创建目录；条目优先于内置表。
+func NewModelCatalog(models map[string]ModelInfo) *ModelCatalog {
+	c := &ModelCatalog{models: map[string]ModelInfo{}, calibration: map[string]float64{}}
+	for name, info := range models {
+		if name = strings.TrimSpace(name); name != "" {
+			c.models[name] = info
 		}
 	}
+	return c
+}
+
+var (
+	catalogMu      sync.RWMutex
+	defaultCatalog = NewModelCatalog(nil)
+)
+
+// SetModelCatalog 替换进程级的模型目录（启动时由配置构建）。
+func SetModelCatalog(c *ModelCatalog) {
+	if c == nil {
+		c = NewModelCatalog(nil)
+	}
+	catalogMu.Lock()
+	defaultCatalog = c
+	catalogMu.Unlock()
+}
 
+// Models 返回进程级的模型目录。
+func Models() *ModelCatalog {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	return defaultCatalog
+}
+
+// Lookup 返回模型信息：配置条目（精确匹配，其次最长前缀）优先，缺失字段由内置表补齐。
+func (c *ModelCatalog) Lookup(model string) (ModelInfo, bool) {
 	slug := strings.TrimSpace(model)
 	if slug == "" {
+		return ModelInfo{}, false
+	}
+	builtin, hasBuiltin := builtinModelInfo(slug)
+	info, ok := c.configured(slug)
+	if !ok {
+		return builtin, hasBuiltin
+	}
+	if info.ContextWindow <= 0 {
+		info.ContextWindow = builtin.ContextWindow
+	}
+	if info.MaxOutputTokens <= 0 {
+		info.MaxOutputTokens = builtin.MaxOutputTokens
+	}
+	if strings.TrimSpace(info.Tokenizer) == "" {
+		info.Tokenizer = builtin.Tokenizer
+	}
+	if info.Reasoning == nil {
+		info.Reasoning = builtin.Reasoning
+	}
+	return info, true
+}
+
+// SupportsReasoning 报告是否把推理强度映射为 provider 的原生思考参数；否则以提示词形式注入。
+func (c *ModelCatalog) SupportsReasoning(model string) bool {
+	info, _ := c.Lookup(model)
+	return info.Reasoning != nil && *info.Reasoning
+}
+
+// Sampling 返回配置条目为该模型声明的采样参数；内置表只提供上限，不改变请求默认值。
+func (c *ModelCatalog) Sampling(model string) agent.SamplingOptions {
+	info, ok := c.configured(strings.TrimSpace(model))
+	if !ok {
+		return agent.SamplingOptions{}
+	}
+	return agent.SamplingOptions{
+		MaxTokens:      info.MaxOutputTokens,
+		Temperature:    info.Temperature,
+		TopP:           info.TopP,
+		StopSequences:  append([]string(nil), info.StopSequences...),
+		ThinkingBudget: info.ThinkingBudget,
+	}
+}
+
+// MaxOutputTokens 返回模型允许的最大输出 token 数（配置条目或内置表），未知时为 0。
+func (c *ModelCatalog) MaxOutputTokens(model string) int64 {
+	info, _ := c.Lookup(model)
+	return info.MaxOutputTokens
+}
+
+func (c *ModelCatalog) configured(slug string) (ModelInfo, bool) {
+	if slug == "" {
+		return ModelInfo{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if info, ok := c.models[slug]; ok {
+		return info, true
+	}
+	var (
+		best  string
+		info  ModelInfo
+		found bool
+	)
+	for name, candidate := range c.models {
+		if strings.HasPrefix(slug, name) && len(name) > len(best) {
+			best, info, found = name, candidate, true
+		}
+	}
+	return info, found
+}
+
+// AutoCompactLimit 返回触发自动压缩的 prompt token 数；0 表示未知窗口、不触发。
+func (c *ModelCatalog) AutoCompactLimit(model string) int64 {
+	info, _ := c.Lookup(model)
+	if info.AutoCompactTokenLimit > 0 {
+		return info.AutoCompactTokenLimit
+	}
+	window, ok := c.ContextWindow(model)
+	if !ok {
+		return 0
+	}
+	return DefaultAutoCompactLimit(window)
+}
+
+// ContextWindow 返回模型的上下文窗口；环境变量 `ECHO_MODEL_CONTEXT_WINDOW` 优先。
+func (c *ModelCatalog) ContextWindow(model string) (int64, bool) {
+	if v := strings.TrimSpace(os.Getenv("ECHO_MODEL_CONTEXT_WINDOW")); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n, true
+		}
+	}
+	info, ok := c.Lookup(model)
+	if !ok || info.ContextWindow <= 0 {
 		return 0, false
 	}
+	return info.ContextWindow, true
+}
+
+// Tokenizer 返回模型使用的计数器。
+func (c *ModelCatalog) Tokenizer(model string) Tokenizer {
+	info, _ := c.Lookup(model)
+	return NewTokenizer(info.Tokenizer)
+}
+
+// CountTokens 按模型的计数器与校准比例统计一段文本。
+func (c *ModelCatalog) CountTokens(model string, text string) int64 {
+	return c.calibrate(model, int64(c.Tokenizer(model).Count(text)))
+}
+
+// EstimatePromptTokens 估算 prompt 的输入 token 数（已按该模型的用量回报校准）。
+func (c *ModelCatalog) EstimatePromptTokens(prompt agent.Prompt) int64 {
+	return c.calibrate(prompt.Model, c.rawPromptTokens(prompt))
+}
+
+// Reconcile 用 provider 回报的实际输入 token 数（含缓存部分）修正该模型的校准比例。
+func (c *ModelCatalog) Reconcile(prompt agent.Prompt, actualInputTokens int64) {
+	raw := c.rawPromptTokens(prompt)
+	if raw <= 0 || actualInputTokens <= 0 {
+		return
+	}
+	key := strings.TrimSpace(prompt.Model)
+	observed := float64(actualInputTokens) / float64(raw)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ratio, ok := c.calibration[key]
+	if !ok {
+		ratio = observed
+	} else {
+		ratio = ratio*(1-calibrationAlpha) + observed*calibrationAlpha
+	}
+	c.calibration[key] = min(max(ratio, minCalibration), maxCalibration)
+}
+
+// Calibration 返回模型当前的校准比例（实际/估算），未校准时为 1。
+func (c *ModelCatalog) Calibration(model string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ratio, ok := c.calibration[strings.TrimSpace(model)]; ok {
+		return ratio
+	}
+	return 1
+}
+
+func (c *ModelCatalog) calibrate(model string, raw int64) int64 {
+	ratio := c.Calibration(model)
+	if ratio == 1 {
+		return raw
+	}
+	return int64(float64(raw)*ratio + 0.5)
+}
+
+func (c *ModelCatalog) rawPromptTokens(prompt agent.Prompt) int64 {
+	tok := c.Tokenizer(prompt.Model)
+	var total int64
+	for _, msg := range prompt.Messages {
+		total += perMessageTokenOverhead + int64(tok.Count(msg.Content))
+		for _, part := range msg.Parts {
+			if part.Type == agent.ContentPartImage {
+				total += imageTokenEstimate
+			}
+		}
+		if msg.ToolUse != nil {
+			total += int64(tok.Count(msg.ToolUse.Name) + tok.Count(string(msg.ToolUse.Input)))
+		}
+		if msg.ToolResult != nil {
+			total += int64(tok.Count(msg.ToolResult.Content))
+		}
+	}
+	if len(prompt.Tools) > 0 {
+		if raw, err := json.Marshal(prompt.Tools); err == nil {
+			total += int64(tok.Count(string(raw)))
+		}
+	}
+	total += int64(tok.Count(prompt.OutputSchema))
+	return total
+}
 
+// ContextWindowForModel 尝试推导模型的上下文窗口（tokens）。
+// 优先读取环境变量 `ECHO_MODEL_CONTEXT_WINDOW`（若存在），其次是模型目录（配置条目与内置表）。
+func ContextWindowForModel(model string) (int64, bool) {
+	return Models().ContextWindow(model)
+}
+
+// builtinModelInfo 对齐 codex-rs 的已知映射，并补充 echo 默认使用的 GLM 与 Claude 系列。
+func builtinModelInfo(slug string) (ModelInfo, bool) {
+	reasoning := func(info ModelInfo) ModelInfo {
+		supported := true
+		info.Reasoning = &supported
+		return info
+	}
 	switch slug {
 	case "gpt-oss-20b", "gpt-oss-120b":
-		return 96_000, true
+		return ModelInfo{ContextWindow: 96_000}, true
 	case "o3", "o4-mini", "codex-mini-latest":
-		return 200_000, true
+		return reasoning(ModelInfo{ContextWindow: contextWindow200K, MaxOutputTokens: 100_000}), true
 	case "gpt-4.1", "gpt-4.1-2025-04-14":
-		return 1_047_576, true
+		return ModelInfo{ContextWindow: 1_047_576, MaxOutputTokens: 32_768}, true
 	case "gpt-4o", "gpt-4o-2024-08-06", "gpt-4o-2024-05-13", "gpt-4o-2024-11-20":
-		return 128_000, true
+		return ModelInfo{ContextWindow: contextWindow128K, MaxOutputTokens: 16_384}, true
 	case "gpt-3.5-turbo":
-		return 16_385, true
+		return ModelInfo{ContextWindow: 16_385, MaxOutputTokens: 4_096}, true
 	}
 
+	lower := strings.ToLower(slug)
 	switch {
 	case strings.HasPrefix(slug, "gpt-5-codex"),
 		strings.HasPrefix(slug, "gpt-5.1-codex"),
-		strings.HasPrefix(slug, "gpt-5.1-codex-max"),
 		strings.HasPrefix(slug, "gpt-5"),
 		strings.HasPrefix(slug, "codex-"),
 		strings.HasPrefix(slug, "exp-"):
-		return contextWindow272K, true
+		return reasoning(ModelInfo{ContextWindow: contextWindow272K, MaxOutputTokens: 128_000}), true
+	case strings.HasPrefix(lower, "glm-4.6"), strings.HasPrefix(lower, "glm4.6"):
+		return reasoning(ModelInfo{ContextWindow: contextWindow200K, MaxOutputTokens: 128_000}), true
+	case strings.HasPrefix(lower, "glm-4.5"), strings.HasPrefix(lower, "glm4.5"):
+		return reasoning(ModelInfo{ContextWindow: contextWindow128K, MaxOutputTokens: 96_000}), true
+	case strings.HasPrefix(lower, "claude-3-5"), strings.HasPrefix(lower, "claude-3-haiku"), strings.HasPrefix(lower, "claude-3-opus"):
+		// 3.7 之前的 Claude 不支持扩展思考。
+		return ModelInfo{ContextWindow: contextWindow200K, MaxOutputTokens: 8_192}, true
+	case strings.HasPrefix(lower, "claude-"):
+		return reasoning(ModelInfo{ContextWindow: contextWindow200K, MaxOutputTokens: 64_000}), true
 	}
-
-	return 0, false
+	return ModelInfo{}, false
 }
 
 func DefaultAutoCompactLimit(contextWindow int64) int64 {
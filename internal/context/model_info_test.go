@@ -0,0 +1,69 @@
+package context
+
+import (
+	"testing"
+
+	"echo-cli/internal/agent"
+)
+
+func TestModelCatalog_ConfigEntriesOverrideBuiltins(t *testing.T) {
+	t.Setenv("ECHO_MODEL_CONTEXT_WINDOW", "")
+
+	c := NewModelCatalog(map[string]ModelInfo{
+		"glm4.6":    {AutoCompactTokenLimit: 150_000},
+		"my-local":  {ContextWindow: 32_000, MaxOutputTokens: 4_096, Tokenizer: TokenizerApprox},
+		"my-local-": {ContextWindow: 8_000},
+	})
+
+	if window, ok := c.ContextWindow("glm4.6"); !ok || window != 200_000 {
+		t.Fatalf("expected builtin window for glm4.6, got %d ok=%v", window, ok)
+	}
+	if limit := c.AutoCompactLimit("glm4.6"); limit != 150_000 {
+		t.Fatalf("expected configured auto-compact limit, got %d", limit)
+	}
+	if limit := c.AutoCompactLimit("my-local"); limit != 28_800 {
+		t.Fatalf("expected 90%% of configured window, got %d", limit)
+	}
+	if window, _ := c.ContextWindow("my-local-7b"); window != 8_000 {
+		t.Fatalf("expected longest prefix entry to win, got %d", window)
+	}
+	if c.Tokenizer("my-local").Name() != TokenizerApprox || c.Tokenizer("glm4.6").Name() != TokenizerBPE {
+		t.Fatalf("unexpected tokenizer selection")
+	}
+	if _, ok := c.ContextWindow("unknown-model"); ok {
+		t.Fatalf("unknown model must not report a window")
+	}
+
+	t.Setenv("ECHO_MODEL_CONTEXT_WINDOW", "1000")
+	if window, _ := c.ContextWindow("glm4.6"); window != 1000 {
+		t.Fatalf("expected env override, got %d", window)
+	}
+}
+
+func TestModelCatalog_ReconcileCalibratesEstimates(t *testing.T) {
+	t.Parallel()
+
+	c := NewModelCatalog(nil)
+	prompt := agent.Prompt{Model: "glm4.6", Messages: []agent.Message{{Role: agent.RoleUser, Content: "please refactor the session store to use rollouts"}}}
+	raw := c.EstimatePromptTokens(prompt)
+	if raw <= 0 {
+		t.Fatalf("expected positive estimate")
+	}
+
+	// provider 报告的用量是估算的两倍：多次回报后估算应收敛到真实值附近。
+	for i := 0; i < 6; i++ {
+		c.Reconcile(prompt, raw*2)
+	}
+	if got := c.EstimatePromptTokens(prompt); got < raw*2-1 || got > raw*2+1 {
+		t.Fatalf("expected calibrated estimate ~%d, got %d", raw*2, got)
+	}
+	if other := c.EstimatePromptTokens(agent.Prompt{Model: "claude-sonnet-4", Messages: prompt.Messages}); other != raw {
+		t.Fatalf("calibration must be per model, got %d want %d", other, raw)
+	}
+
+	// 异常回报被限制在校准上下限内。
+	c.Reconcile(prompt, raw*1000)
+	if ratio := c.Calibration("glm4.6"); ratio > maxCalibration {
+		t.Fatalf("calibration not clamped: %f", ratio)
+	}
+}
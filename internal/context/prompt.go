@@ -18,12 +18,23 @@ func (ctx TurnContext) BuildPrompt() Prompt {
 	return Prompt{
 		Model:             ctx.Model,
 		Messages:          ctx.BuildMessages(),
-		Tools:             agent.DefaultTools(),
+		Tools:             append(agent.DefaultTools(), ctx.Tools...),
 		ParallelToolCalls: true,
 		OutputSchema:      strings.TrimSpace(ctx.OutputSchema),
+		Sampling:          Models().Sampling(ctx.Model).Merge(ctx.Sampling),
+		ReasoningEffort:   ctx.nativeReasoningEffort(),
 	}
 }
 
+// nativeReasoningEffort 返回交给 provider 原生思考参数的推理强度；模型不支持时为空，改用提示词。
+func (ctx TurnContext) nativeReasoningEffort() string {
+	effort := strings.TrimSpace(ctx.ReasoningEffort)
+	if effort == "" || !Models().SupportsReasoning(ctx.Model) {
+		return ""
+	}
+	return effort
+}
+
 // BuildMessages 按 system → instructions → attachments → history 生成消息，并支持 @internal/prompts 引用。
 func (ctx TurnContext) BuildMessages() []agent.Message {
 	capacity := len(ctx.History) + len(ctx.Attachments) + 6
@@ -38,7 +49,7 @@ func (ctx TurnContext) BuildMessages() []agent.Message {
 	}
 	languagePrompt := prompts.BuildLanguagePrompt(i18n.Normalize(ctx.Language))
 
-	if reason := prompts.BuildReasoningEffort(ctx.ReasoningEffort); reason != "" && !hasReasoningEffort(ctx.History, instructions, system) {
+	if reason := prompts.BuildReasoningEffort(ctx.ReasoningEffort); reason != "" && ctx.nativeReasoningEffort() == "" && !hasReasoningEffort(ctx.History, instructions, system) {
 		messages = append(messages, agent.Message{Role: agent.RoleSystem, Content: reason})
 	}
 	if ctx.ReviewMode {
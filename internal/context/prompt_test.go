@@ -229,3 +229,43 @@ func TestTurnContextAvoidsDuplicateLanguagePrompt(t *testing.T) {
 		t.Fatalf("language prompt missing or incorrect at tail: %+v", state.Messages[1])
 	}
 }
+
+func TestTurnContextMergesModelAndSessionSampling(t *testing.T) {
+	temp := 0.1
+	SetModelCatalog(NewModelCatalog(map[string]ModelInfo{
+		"glm": {MaxOutputTokens: 16_000, Temperature: &temp, StopSequences: []string{"</done>"}},
+	}))
+	defer SetModelCatalog(nil)
+
+	prompt := TurnContext{Model: "glm-4.6", Sampling: agent.SamplingOptions{MaxTokens: 4000}}.BuildPrompt()
+	got := prompt.Sampling
+	if got.MaxTokens != 4000 || got.Temperature == nil || *got.Temperature != 0.1 || len(got.StopSequences) != 1 {
+		t.Fatalf("unexpected merged sampling %+v", got)
+	}
+	if other := (TurnContext{Model: "gpt-4o"}).BuildPrompt().Sampling; other.MaxTokens != 0 || other.Temperature != nil {
+		t.Fatalf("builtin models should not set request sampling, got %+v", other)
+	}
+}
+
+func TestTurnContextUsesNativeReasoningForThinkingModels(t *testing.T) {
+	ctx := TurnContext{
+		Model:           "claude-sonnet-4",
+		System:          "sys",
+		ReasoningEffort: "high",
+		History:         []agent.Message{{Role: agent.RoleUser, Content: "hi"}},
+	}
+	state := ctx.BuildPrompt()
+	if state.ReasoningEffort != "high" {
+		t.Fatalf("expected native reasoning effort, got %q", state.ReasoningEffort)
+	}
+	for _, msg := range state.Messages {
+		if msg.Content == prompts.BuildReasoningEffort("high") {
+			t.Fatalf("text hint must not be injected when native thinking is used")
+		}
+	}
+
+	ctx.Model = "gpt-test"
+	if state := ctx.BuildPrompt(); state.ReasoningEffort != "" || state.Messages[0].Content != prompts.BuildReasoningEffort("high") {
+		t.Fatalf("expected text hint fallback for non-reasoning model, got %+v", state)
+	}
+}
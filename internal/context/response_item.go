@@ -38,6 +38,8 @@ const (
 	ContentItemInputText  ContentItemType = "input_text"
 	ContentItemInputImage ContentItemType = "input_image"
 	ContentItemOutputText ContentItemType = "output_text"
+	// ContentItemReasoningText 是思考块的原文。
+	ContentItemReasoningText ContentItemType = "reasoning_text"
 )
 
 // ResponseItem is a tagged union mirroring codex-rs `ResponseItem`.
@@ -82,6 +84,8 @@ type ReasoningResponseItem struct {
 	Summary          []ReasoningItemReasoningSummary `json:"summary"`
 	Content          []ReasoningItemContent          `json:"content,omitempty"`
 	EncryptedContent string                          `json:"encrypted_content,omitempty"`
+	// Signature 是 anthropic 思考块的签名，回传时用于校验思考内容未被改动。
+	Signature string `json:"signature,omitempty"`
 }
 
 // LocalShellCallResponseItem records a shell call request/result.
@@ -121,7 +125,7 @@ type WebSearchCallResponseItem struct {
 	Action WebSearchAction `json:"action"`
 }
 
-// GhostSnapshotResponseItem is a placeholder for ghost commits.
+// GhostSnapshotResponseItem records a ghost commit so /undo can restore the workspace.
 type GhostSnapshotResponseItem struct {
 	GhostCommit GhostCommit `json:"ghost_commit"`
 }
@@ -182,9 +186,10 @@ type FunctionCallOutputPayload struct {
 	Success      *bool                           `json:"success,omitempty"`
 }
 
-// GhostCommit is a lightweight placeholder for the upstream type.
+// GhostCommit identifies an unreferenced git commit capturing the workspace before a turn mutated it.
 type GhostCommit struct {
-	ID string `json:"id,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Parent string `json:"parent,omitempty"`
 }
 
 // ToResponseItem mirrors the Rust From<ResponseInputItem> implementation.
@@ -238,6 +243,14 @@ func NewUserMessageItem(text string) ResponseItem {
 	}
 }
 
+// NewGhostSnapshotItem wraps a ghost commit into a history item.
+func NewGhostSnapshotItem(commit GhostCommit) ResponseItem {
+	return ResponseItem{
+		Type:          ResponseItemTypeGhostSnapshot,
+		GhostSnapshot: &GhostSnapshotResponseItem{GhostCommit: commit},
+	}
+}
+
 // MarshalJSON customizes tagged-union encoding.
 func (r ResponseItem) MarshalJSON() ([]byte, error) {
 	switch r.Type {
@@ -267,12 +280,14 @@ func (r ResponseItem) MarshalJSON() ([]byte, error) {
 			Summary          []ReasoningItemReasoningSummary `json:"summary"`
 			Content          []ReasoningItemContent          `json:"content,omitempty"`
 			EncryptedContent string                          `json:"encrypted_content,omitempty"`
+			Signature        string                          `json:"signature,omitempty"`
 		}{
 			Type:             r.Type,
 			ID:               r.Reasoning.ID,
 			Summary:          r.Reasoning.Summary,
 			Content:          r.Reasoning.Content,
 			EncryptedContent: r.Reasoning.EncryptedContent,
+			Signature:        r.Reasoning.Signature,
 		}
 		return json.Marshal(payload)
 	case ResponseItemTypeLocalShellCall:
@@ -400,11 +415,12 @@ func (r *ResponseItem) UnmarshalJSON(data []byte) error {
 			Summary          []ReasoningItemReasoningSummary `json:"summary"`
 			Content          []ReasoningItemContent          `json:"content,omitempty"`
 			EncryptedContent string                          `json:"encrypted_content,omitempty"`
+			Signature        string                          `json:"signature,omitempty"`
 		}
 		if err := json.Unmarshal(data, &payload); err != nil {
 			return err
 		}
-		r.Reasoning = &ReasoningResponseItem{ID: payload.ID, Summary: payload.Summary, Content: payload.Content, EncryptedContent: payload.EncryptedContent}
+		r.Reasoning = &ReasoningResponseItem{ID: payload.ID, Summary: payload.Summary, Content: payload.Content, EncryptedContent: payload.EncryptedContent, Signature: payload.Signature}
 	case ResponseItemTypeLocalShellCall:
 		var payload struct {
 			ID     string           `json:"id,omitempty"`
@@ -499,7 +515,11 @@ func responseItemToAgentMessages(item ResponseItem) []agent.Message {
 		if item.Message == nil {
 			return nil
 		}
-		return []agent.Message{{Role: agent.Role(item.Message.Role), Content: FlattenContentItems(item.Message.Content)}}
+		return []agent.Message{{
+			Role:    agent.Role(item.Message.Role),
+			Content: FlattenContentItems(item.Message.Content),
+			Parts:   contentParts(item.Message.Content),
+		}}
 	case ResponseItemTypeFunctionCall:
 		if item.FunctionCall == nil {
 			return nil
@@ -538,10 +558,19 @@ func responseItemToAgentMessages(item ResponseItem) []agent.Message {
 			return nil
 		}
 		text := FlattenReasoning(*item.Reasoning)
-		if strings.TrimSpace(text) == "" {
+		if strings.TrimSpace(text) == "" && item.Reasoning.EncryptedContent == "" {
 			return nil
 		}
-		return []agent.Message{{Role: agent.RoleAssistant, Content: text}}
+		// Content 保留思考原文供估算与摘要使用；provider 根据 Reasoning 决定如何回传。
+		return []agent.Message{{
+			Role:    agent.RoleAssistant,
+			Content: text,
+			Reasoning: &agent.Reasoning{
+				Text:      text,
+				Signature: item.Reasoning.Signature,
+				Redacted:  item.Reasoning.EncryptedContent,
+			},
+		}}
 	default:
 		return nil
 	}
@@ -554,7 +583,10 @@ func FlattenContentItems(items []ContentItem) string {
 		case ContentItemInputText, ContentItemOutputText:
 			parts = append(parts, item.Text)
 		case ContentItemInputImage:
-			if item.ImageURL != "" {
+			if image, ok := agent.ImagePartFromDataURL(item.ImageURL); ok {
+				// 不把 base64 数据写入纯文本形式。
+				parts = append(parts, fmt.Sprintf("[image: %s]", image.MediaType))
+			} else if item.ImageURL != "" {
 				parts = append(parts, fmt.Sprintf("[image: %s]", item.ImageURL))
 			}
 		}
@@ -562,6 +594,29 @@ func FlattenContentItems(items []ContentItem) string {
 	return strings.TrimSpace(strings.Join(parts, "\n"))
 }
 
+// contentParts 在消息含内联图片时返回多模态片段；纯文本消息返回 nil，沿用 Content。
+func contentParts(items []ContentItem) []agent.ContentPart {
+	var parts []agent.ContentPart
+	hasImage := false
+	for _, item := range items {
+		switch item.Type {
+		case ContentItemInputText, ContentItemOutputText:
+			if strings.TrimSpace(item.Text) != "" {
+				parts = append(parts, agent.ContentPart{Type: agent.ContentPartText, Text: item.Text})
+			}
+		case ContentItemInputImage:
+			if image, ok := agent.ImagePartFromDataURL(item.ImageURL); ok {
+				parts = append(parts, image)
+				hasImage = true
+			}
+		}
+	}
+	if !hasImage {
+		return nil
+	}
+	return parts
+}
+
 func FlattenReasoning(item ReasoningResponseItem) string {
 	var parts []string
 	for _, summary := range item.Summary {
@@ -581,3 +636,24 @@ func LastAssistantMessage(items []ResponseItem) string {
 	}
 	return ""
 }
+
+// LastGhostSnapshotIndex returns the index of the newest ghost snapshot item, or -1.
+func LastGhostSnapshotIndex(items []ResponseItem) int {
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Type == ResponseItemTypeGhostSnapshot && items[i].GhostSnapshot != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// GhostCommits collects ghost commits from history in chronological order.
+func GhostCommits(items []ResponseItem) []GhostCommit {
+	var out []GhostCommit
+	for _, item := range items {
+		if item.Type == ResponseItemTypeGhostSnapshot && item.GhostSnapshot != nil {
+			out = append(out, item.GhostSnapshot.GhostCommit)
+		}
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package context
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 可在 [models.<name>] tokenizer 中选择的计数器。
+const (
+	// TokenizerBPE 模拟 cl100k/o200k 一类 BPE 的预分词与合并规律（默认）。
+	TokenizerBPE = "bpe"
+	// TokenizerApprox 是 codex 的 bytes/4 粗估，作为校准后的兜底。
+	TokenizerApprox = "approx"
+)
+
+// Tokenizer 统计一段文本的 token 数。
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// NewTokenizer 按名称返回计数器；未知名称回退到 BPE 计数器。
+func NewTokenizer(name string) Tokenizer {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case TokenizerApprox, "bytes":
+		return approxTokenizer{}
+	default:
+		return bpeTokenizer{}
+	}
+}
+
+type approxTokenizer struct{}
+
+func (approxTokenizer) Name() string { return TokenizerApprox }
+
+func (approxTokenizer) Count(text string) int { return ApproxTokenCount(text) }
+
+// bpeTokenizer 不携带词表：按 BPE 预分词规则切分（词、数字三位一组、标点串、空白串），
+// 再按常见合并长度估算每段的 token 数。对英文与代码的误差通常在 10% 以内，
+// 剩余偏差由 ModelCatalog 依据 provider 回报的用量校准。
+type bpeTokenizer struct{}
+
+func (bpeTokenizer) Name() string { return TokenizerBPE }
+
+func (bpeTokenizer) Count(text string) int {
+	total := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\'' && i+1 < len(text) && isContraction(text[i+1:]):
+			// 's 't 're 've 'm 'll 'd 各自是单个 token。
+			n := contractionLen(text[i+1:])
+			total++
+			i += 1 + n
+		case isWordRune(r) || (r == ' ' && i+1 < len(text) && startsWord(text[i+1:])):
+			j := i
+			if r == ' ' {
+				j++
+			}
+			start := j
+			for j < len(text) {
+				wr, ws := utf8.DecodeRuneInString(text[j:])
+				if !isWordRune(wr) {
+					break
+				}
+				j += ws
+			}
+			total += wordTokens(text[start:j])
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(text) {
+				dr, ds := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsDigit(dr) {
+					break
+				}
+				j += ds
+			}
+			total += (utf8.RuneCountInString(text[i:j]) + 2) / 3
+			i = j
+		case r == ' ' && i+1 < len(text) && isASCIIPunct(text[i+1]):
+			// 前导空格与随后的标点合并（" (", " {"），只计标点串。
+			i++
+			j := punctRunEnd(text, i)
+			total += (j - i + 1) / 2
+			i = j
+		case r == '\n' || r == '\r':
+			j := i
+			for j < len(text) && (text[j] == '\n' || text[j] == '\r') {
+				j++
+			}
+			total++
+			i = j
+		case unicode.IsSpace(r):
+			j := i
+			for j < len(text) && (text[j] == ' ' || text[j] == '\t') {
+				j++
+			}
+			if j == i {
+				j = i + size
+			}
+			// 缩进等连续空白会被合并为一个 token，超长时按 16 个一组。
+			total += (j - i + 15) / 16
+			i = j
+		case r < utf8.RuneSelf:
+			// 常见的标点组合（"{\"", "();", "=>"）通常两两合并。
+			j := punctRunEnd(text, i)
+			total += (j - i + 1) / 2
+			i = j
+		default:
+			// emoji 等符号按 UTF-8 字节粗估。
+			total += (size + 2) / 3
+			i += size
+		}
+	}
+	return total
+}
+
+// wordTokens 估算一个词（可能是 camelCase 或 snake_case 标识符）的 token 数。
+func wordTokens(word string) int {
+	total := 0
+	for _, part := range splitSubwords(word) {
+		if part == "" {
+			continue
+		}
+		if r, _ := utf8.DecodeRuneInString(part); r >= utf8.RuneSelf {
+			total += nonASCIITokens(part)
+			continue
+		}
+		// 常见英文词与子词（≤8 个字母）通常是单个 token，更长的按约 6 个字母一段。
+		n := len(part)
+		if n <= 8 {
+			total++
+		} else {
+			total += (n + 5) / 6
+		}
+	}
+	return total
+}
+
+// nonASCIITokens：CJK 字符大多一字一 token，其他脚本约两字一 token。
+func nonASCIITokens(part string) int {
+	cjk, other := 0, 0
+	for _, r := range part {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+1)/2
+}
+
+// splitSubwords 按大小写边界、下划线与脚本切换切分标识符。
+func splitSubwords(word string) []string {
+	var parts []string
+	start := 0
+	var prev rune
+	for i, r := range word {
+		if i > start {
+			boundary := r == '_' ||
+				(unicode.IsUpper(r) && unicode.IsLower(prev)) ||
+				((r >= utf8.RuneSelf) != (prev >= utf8.RuneSelf))
+			if boundary {
+				parts = append(parts, word[start:i])
+				start = i
+			}
+		}
+		prev = r
+	}
+	parts = append(parts, word[start:])
+	for i, p := range parts {
+		parts[i] = strings.Trim(p, "_")
+	}
+	return parts
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func startsWord(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return isWordRune(r)
+}
+
+// punctRunEnd 返回从 i 开始的 ASCII 标点串的结束位置（至少前进一个字节）。
+func punctRunEnd(text string, i int) int {
+	j := i
+	for j < len(text) && isASCIIPunct(text[j]) {
+		j++
+	}
+	if j == i {
+		j = i + 1
+	}
+	return j
+}
+
+func isASCIIPunct(c byte) bool {
+	return c < utf8.RuneSelf && c > ' ' && c != 0x7f && !isASCIIAlnum(c) && c != '_'
+}
+
+func isASCIIAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+var contractions = []string{"ll", "re", "ve", "s", "t", "m", "d"}
+
+func isContraction(s string) bool {
+	return contractionLen(s) > 0
+}
+
+func contractionLen(s string) int {
+	for _, c := range contractions {
+		if len(s) >= len(c) && strings.EqualFold(s[:len(c)], c) {
+			if len(s) == len(c) || !isWordRune(rune(s[len(c)])) {
+				return len(c)
+			}
+		}
+	}
+	return 0
+}
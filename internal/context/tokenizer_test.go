@@ -0,0 +1,51 @@
+package context
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBPETokenizer_CountsCloseToReferenceTokenizers(t *testing.T) {
+	t.Parallel()
+
+	tok := NewTokenizer(TokenizerBPE)
+	cases := []struct {
+		text     string
+		min, max int
+	}{
+		// cl100k: 8 tokens.
+		{"Hello world, this is a simple test.", 7, 10},
+		// cl100k: 10 tokens.
+		{"func (m *ContextManager) History() []Message {", 9, 14},
+		// 中文大致一字一 token。
+		{"压缩上下文并继续任务", 8, 14},
+		// 数字按三位一组。
+		{"1234567890", 3, 5},
+		{"", 0, 0},
+	}
+	for _, tc := range cases {
+		got := tok.Count(tc.text)
+		if got < tc.min || got > tc.max {
+			t.Fatalf("Count(%q) = %d, want [%d,%d]", tc.text, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestBPETokenizer_SplitsIdentifiersAndCollapsesIndentation(t *testing.T) {
+	t.Parallel()
+
+	tok := NewTokenizer("")
+	if tok.Name() != TokenizerBPE {
+		t.Fatalf("expected bpe default, got %s", tok.Name())
+	}
+	if got := tok.Count("ResponseItemsToAgentMessages"); got != 5 {
+		t.Fatalf("expected camelCase split into 5 subwords, got %d", got)
+	}
+	indented := strings.Repeat(" ", 16) + "return"
+	if got := tok.Count(indented); got != 2 {
+		t.Fatalf("expected indentation run + word = 2 tokens, got %d", got)
+	}
+	if got := NewTokenizer(TokenizerApprox).Count(indented); got != ApproxTokenCount(indented) {
+		t.Fatalf("approx tokenizer must match ApproxTokenCount, got %d", got)
+	}
+}
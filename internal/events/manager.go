@@ -33,9 +33,11 @@ type EventPublisher interface {
 type ManagerConfig struct {
 	SubmissionBuffer int
 	EventBuffer      int
-	Workers          int
-	SQLogPath        string
-	EQLogPath        string
+	// Workers 是并发处理提交的 worker 数；同一会话的普通提交仍按顺序逐条处理。
+	// 另有一个控制 worker 专门处理 High 提交。
+	Workers   int
+	SQLogPath string
+	EQLogPath string
 }
 
 func (cfg ManagerConfig) withDefaults() ManagerConfig {
@@ -117,8 +119,11 @@ func (m *Manager) Start(ctx context.Context) {
 		m.cancel = cancel
 		for i := 0; i < m.workers; i++ {
 			m.wg.Add(1)
-			go m.worker(runCtx)
+			go m.worker(runCtx, m.queue.Receive)
 		}
+		// 控制 worker 只处理 High 提交，保证中断与审批在所有 worker 都忙于长回合时也能及时生效。
+		m.wg.Add(1)
+		go m.worker(runCtx, m.queue.ReceiveControl)
 	})
 }
 
@@ -171,7 +176,7 @@ func (m *Manager) Submit(ctx context.Context, submission Submission) (string, er
 		submission.Timestamp = time.Now()
 	}
 	if submission.Priority == 0 {
-		submission.Priority = PriorityNormal
+		submission.Priority = defaultPriority(submission.Operation.Kind)
 	}
 	if submission.Operation.Kind == "" {
 		return "", errors.New("submission operation kind required")
@@ -190,77 +195,98 @@ func (m *Manager) Submit(ctx context.Context, submission Submission) (string, er
 	return submission.ID, nil
 }
 
+// defaultPriority 返回未指定优先级时的默认值：中断与审批决策需要抢占排队中的普通输入。
+func defaultPriority(kind OperationKind) Priority {
+	switch kind {
+	case OperationInterrupt, OperationApprovalDecision:
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+// QueueStats 返回 SQ 的深度与等待延迟指标。
+func (m *Manager) QueueStats() SubmissionQueueStats {
+	return m.queue.Stats()
+}
+
 // PublishEvent 允许外部模块向 EQ 直接发布事件。
 func (m *Manager) PublishEvent(ctx context.Context, event Event) error {
 	return m.events.Publish(ctx, event)
 }
 
-func (m *Manager) worker(ctx context.Context) {
+func (m *Manager) worker(ctx context.Context, receive func(context.Context) (Submission, error)) {
 	defer m.wg.Done()
 	for {
-		sub, err := m.queue.Receive(ctx)
+		sub, err := receive(ctx)
 		if err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, ErrSubmissionQueueClosed) {
 				return
 			}
 			continue
 		}
+		m.process(ctx, sub)
+		m.queue.Done(sub)
+	}
+}
+
+// process 执行一条提交，并在 EQ 上发出开始、错误与完成事件。
+func (m *Manager) process(ctx context.Context, sub Submission) {
+	_ = m.events.Publish(ctx, Event{
+		Type:         EventTaskStarted,
+		SubmissionID: sub.ID,
+		SessionID:    sub.SessionID,
+		Timestamp:    time.Now(),
+		Payload:      sub.Operation.Kind,
+		Metadata:     sub.Metadata,
+	})
+	m.hmu.RLock()
+	handler := m.handlers[sub.Operation.Kind]
+	m.hmu.RUnlock()
+	if handler == nil {
+		msg := fmt.Sprintf("no handler registered for %s", sub.Operation.Kind)
+		_ = m.events.Publish(ctx, Event{
+			Type:         EventError,
+			SubmissionID: sub.ID,
+			SessionID:    sub.SessionID,
+			Timestamp:    time.Now(),
+			Payload:      msg,
+			Metadata:     sub.Metadata,
+		})
 		_ = m.events.Publish(ctx, Event{
-			Type:         EventTaskStarted,
+			Type:         EventTaskCompleted,
 			SubmissionID: sub.ID,
 			SessionID:    sub.SessionID,
 			Timestamp:    time.Now(),
-			Payload:      sub.Operation.Kind,
+			Payload:      TaskResult{Status: "failed", Error: msg},
 			Metadata:     sub.Metadata,
 		})
-		m.hmu.RLock()
-		handler := m.handlers[sub.Operation.Kind]
-		m.hmu.RUnlock()
-		if handler == nil {
-			msg := fmt.Sprintf("no handler registered for %s", sub.Operation.Kind)
+		return
+	}
+	result := TaskResult{Status: "completed"}
+	if err := handler.Handle(ctx, sub, m.events); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Status = "Done"
+		} else {
 			_ = m.events.Publish(ctx, Event{
 				Type:         EventError,
 				SubmissionID: sub.ID,
 				SessionID:    sub.SessionID,
 				Timestamp:    time.Now(),
-				Payload:      msg,
-				Metadata:     sub.Metadata,
-			})
-			_ = m.events.Publish(ctx, Event{
-				Type:         EventTaskCompleted,
-				SubmissionID: sub.ID,
-				SessionID:    sub.SessionID,
-				Timestamp:    time.Now(),
-				Payload:      TaskResult{Status: "failed", Error: msg},
+				Payload:      err.Error(),
 				Metadata:     sub.Metadata,
 			})
-			continue
-		}
-		result := TaskResult{Status: "completed"}
-		if err := handler.Handle(ctx, sub, m.events); err != nil {
-			if errors.Is(err, context.DeadlineExceeded) {
-				result.Status = "Done"
-			} else {
-				_ = m.events.Publish(ctx, Event{
-					Type:         EventError,
-					SubmissionID: sub.ID,
-					SessionID:    sub.SessionID,
-					Timestamp:    time.Now(),
-					Payload:      err.Error(),
-					Metadata:     sub.Metadata,
-				})
-				result = TaskResult{Status: "failed", Error: err.Error()}
-			}
+			result = TaskResult{Status: "failed", Error: err.Error()}
 		}
-		_ = m.events.Publish(ctx, Event{
-			Type:         EventTaskCompleted,
-			SubmissionID: sub.ID,
-			SessionID:    sub.SessionID,
-			Timestamp:    time.Now(),
-			Payload:      result,
-			Metadata:     sub.Metadata,
-		})
 	}
+	_ = m.events.Publish(ctx, Event{
+		Type:         EventTaskCompleted,
+		SubmissionID: sub.ID,
+		SessionID:    sub.SessionID,
+		Timestamp:    time.Now(),
+		Payload:      result,
+		Metadata:     sub.Metadata,
+	})
 }
 
 func cloneMetadata(meta map[string]string) map[string]string {
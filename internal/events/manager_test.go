@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -43,6 +44,125 @@ func TestSubmissionQueueSubmitReceive(t *testing.T) {
 	}
 }
 
+func receiveIDs(t *testing.T, q *SubmissionQueue, n int, done bool) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	for range n {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		sub, err := q.Receive(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("receive after %v: %v", ids, err)
+		}
+		ids = append(ids, sub.ID)
+		if done {
+			q.Done(sub)
+		}
+	}
+	return ids
+}
+
+func TestSubmissionQueuePriorityAndSessionOrder(t *testing.T) {
+	q := NewSubmissionQueue(8)
+	ctx := context.Background()
+	for _, sub := range []Submission{
+		{ID: "a1", SessionID: "a", Priority: PriorityNormal},
+		{ID: "a-low", SessionID: "a", Priority: PriorityLow},
+		{ID: "a2", SessionID: "a", Priority: PriorityNormal},
+		{ID: "a-int", SessionID: "a", Priority: PriorityHigh},
+	} {
+		sub.Operation.Kind = OperationUserInput
+		if err := q.Submit(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := strings.Join(receiveIDs(t, q, 4, true), ",")
+	if got != "a-int,a1,a2,a-low" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestSubmissionQueueSerializesSessionsAndLetsControlThrough(t *testing.T) {
+	q := NewSubmissionQueue(8)
+	ctx := context.Background()
+	for _, sub := range []Submission{
+		{ID: "a1", SessionID: "a"},
+		{ID: "a2", SessionID: "a"},
+		{ID: "b1", SessionID: "b"},
+	} {
+		sub.Operation.Kind = OperationUserInput
+		if err := q.Submit(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := receiveIDs(t, q, 2, false)
+	if strings.Join(first, ",") != "a1,b1" {
+		t.Fatalf("expected a1 and b1 to run concurrently, got %v", first)
+	}
+	// a 仍在处理 a1：a2 不能出队，但同一会话的审批决策可以。
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	if sub, err := q.Receive(short); err == nil {
+		t.Fatalf("a2 must wait for a1, got %s", sub.ID)
+	}
+	cancel()
+	if err := q.Submit(ctx, Submission{ID: "a-approval", SessionID: "a", Priority: PriorityHigh, Operation: Operation{Kind: OperationApprovalDecision}}); err != nil {
+		t.Fatal(err)
+	}
+	control, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	sub, err := q.ReceiveControl(control)
+	if err != nil || sub.ID != "a-approval" {
+		t.Fatalf("expected approval on the control lane, got %q (%v)", sub.ID, err)
+	}
+	q.Done(sub)
+	q.Done(Submission{ID: "a1", SessionID: "a", Priority: PriorityNormal})
+	if got := receiveIDs(t, q, 1, true); got[0] != "a2" {
+		t.Fatalf("expected a2 after a1 is done, got %v", got)
+	}
+}
+
+func TestSubmissionQueueRotatesBetweenSessions(t *testing.T) {
+	q := NewSubmissionQueue(8)
+	ctx := context.Background()
+	for _, id := range []string{"a1", "a2", "a3", "b1", "b2"} {
+		if err := q.Submit(ctx, Submission{ID: id, SessionID: id[:1], Operation: Operation{Kind: OperationUserInput}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(receiveIDs(t, q, 5, true), ","); got != "a1,b1,a2,b2,a3" {
+		t.Fatalf("unexpected order %s", got)
+	}
+}
+
+func TestSubmissionQueueCapacityAndStats(t *testing.T) {
+	q := NewSubmissionQueue(1)
+	ctx := context.Background()
+	if err := q.Submit(ctx, Submission{ID: "n1", Operation: Operation{Kind: OperationUserInput}}); err != nil {
+		t.Fatal(err)
+	}
+	full, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := q.Submit(full, Submission{ID: "n2", Operation: Operation{Kind: OperationUserInput}}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected full queue to block, got %v", err)
+	}
+	if err := q.Submit(ctx, Submission{ID: "h1", Priority: PriorityHigh, Operation: Operation{Kind: OperationInterrupt}}); err != nil {
+		t.Fatalf("high priority must not be blocked by capacity: %v", err)
+	}
+	stats := q.Stats()
+	if stats.Depth != 2 || stats.DepthByPriority[PriorityHigh] != 1 || stats.DepthByPriority[PriorityNormal] != 1 {
+		t.Fatalf("unexpected depth %+v", stats)
+	}
+	time.Sleep(5 * time.Millisecond)
+	receiveIDs(t, q, 1, false)
+	stats = q.Stats()
+	if stats.Depth != 1 || stats.InFlight != 1 || stats.Enqueued != 2 || stats.Dequeued != 1 {
+		t.Fatalf("unexpected counters %+v", stats)
+	}
+	if wait := stats.Wait[PriorityHigh]; wait.Count != 1 || wait.Max < 5*time.Millisecond || wait.Mean() != wait.Max {
+		t.Fatalf("unexpected wait stats %+v", wait)
+	}
+}
+
 func TestEventQueueFanout(t *testing.T) {
 	q := NewEventQueue(4)
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -263,3 +383,56 @@ func TestManagerTreatsDeadlineExceededAsDone(t *testing.T) {
 		t.Fatalf("expected empty error message, got %q", gotResult.Error)
 	}
 }
+
+func TestManagerControlLaneRunsWhileWorkersAreBusy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	mgr := NewManager(ManagerConfig{SubmissionBuffer: 4, EventBuffer: 16, Workers: 1})
+	defer mgr.Close()
+
+	decided := make(chan string, 1)
+	mgr.RegisterHandler(OperationUserInput, HandlerFunc(func(ctx context.Context, _ Submission, _ EventPublisher) error {
+		// 模拟等待审批的回合：只有审批决策被处理后才能结束。
+		select {
+		case id := <-decided:
+			if id != "ap-1" {
+				return errors.New("unexpected approval " + id)
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}))
+	mgr.RegisterHandler(OperationApprovalDecision, HandlerFunc(func(_ context.Context, sub Submission, _ EventPublisher) error {
+		decided <- sub.Operation.ApprovalDecision.ApprovalID
+		return nil
+	}))
+	mgr.Start(ctx)
+
+	events := mgr.Subscribe()
+	inputID, err := mgr.SubmitUserInput(ctx, []InputMessage{{Role: "user", Content: "run it"}}, InputContext{SessionID: "sess"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Submit(ctx, Submission{SessionID: "sess", Operation: Operation{Kind: OperationApprovalDecision, ApprovalDecision: &ApprovalDecisionOperation{ApprovalID: "ap-1", Approved: true}}}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("user input never completed; approval was stuck behind it")
+		case ev := <-events:
+			if ev.SubmissionID != inputID || ev.Type != EventTaskCompleted {
+				continue
+			}
+			if res, _ := ev.Payload.(TaskResult); res.Status != "completed" {
+				t.Fatalf("unexpected result %+v", res)
+			}
+			if stats := mgr.QueueStats(); stats.Dequeued != 2 || stats.Wait[PriorityHigh].Count != 1 {
+				t.Fatalf("unexpected stats %+v", stats)
+			}
+			return
+		}
+	}
+}
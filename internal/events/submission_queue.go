@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"echo-cli/internal/logger"
 )
@@ -13,21 +14,88 @@ var (
 	ErrSubmissionQueueClosed = errors.New("submission queue closed")
 )
 
-// SubmissionQueue 是一个有界的提交队列（SQ）。
+// SubmissionQueue 是一个有界的优先级提交队列（SQ）。
+//
+// 出队顺序：先按优先级（High > Normal > Low）；同一优先级内在各会话间轮转，避免单个会话的积压饿死其他会话；
+// 同一会话同一优先级内保持 FIFO。Normal/Low 提交按会话串行：会话有提交在处理中（未调用 Done）时，
+// 其后续 Normal/Low 提交不会被取出，因此多个 worker 可以并发处理不同会话而不打乱同一会话的顺序。
+// High 提交（中断、审批决策）是控制通道：不受会话串行限制，也不受容量限制，可在会话处理中被取出。
 type SubmissionQueue struct {
-	ch        chan Submission
-	closeOnce sync.Once
-	log       *logger.LogEntry
+	capacity int
+	log      *logger.LogEntry
+
+	mu       sync.Mutex
+	lanes    map[string]*sessionLane
+	pending  int
+	inFlight int
+	seq      uint64
+	changed  chan struct{}
+	closed   bool
+	stats    SubmissionQueueStats
+}
+
+// sessionLane 保存一个会话排队中的提交；无会话的提交共用 key 为空的 lane，且不串行。
+type sessionLane struct {
+	queues [3][]queuedSubmission // 按 priorityIndex 分桶
+	busy   bool
+	served uint64 // 最近一次出队的序号，用于会话间轮转
 }
 
-// NewSubmissionQueue 创建一个新的 SubmissionQueue。
+type queuedSubmission struct {
+	sub      Submission
+	seq      uint64
+	enqueued time.Time
+}
+
+// SubmissionQueueStats 是队列的深度与等待延迟指标。
+type SubmissionQueueStats struct {
+	// Depth 是排队中的提交数，DepthByPriority 按优先级细分。
+	Depth           int
+	DepthByPriority map[Priority]int
+	// InFlight 是已取出但尚未 Done 的提交数。
+	InFlight int
+	Enqueued uint64
+	Dequeued uint64
+	// Wait 是各优先级从入队到出队的等待时间。
+	Wait map[Priority]LatencyStats
+}
+
+// LatencyStats 汇总一组延迟样本。
+type LatencyStats struct {
+	Count uint64
+	Total time.Duration
+	Max   time.Duration
+	Last  time.Duration
+}
+
+// Mean 返回平均延迟；没有样本时为 0。
+func (s LatencyStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+func (s *LatencyStats) observe(d time.Duration) {
+	s.Count++
+	s.Total += d
+	s.Last = d
+	if d > s.Max {
+		s.Max = d
+	}
+}
+
+// NewSubmissionQueue 创建一个新的 SubmissionQueue；capacity 限制排队中的提交数，High 提交不受限制。
 func NewSubmissionQueue(capacity int) *SubmissionQueue {
 	if capacity <= 0 {
 		capacity = 64
 	}
 	return &SubmissionQueue{
-		ch:  make(chan Submission, capacity),
-		log: logger.Named("sq"),
+		capacity: capacity,
+		log:      logger.Named("sq"),
+		lanes:    map[string]*sessionLane{},
+		changed:  make(chan struct{}),
+		stats:    SubmissionQueueStats{Wait: map[Priority]LatencyStats{}},
 	}
 }
 
@@ -39,54 +107,214 @@ func (q *SubmissionQueue) SetLogger(entry *logger.LogEntry) {
 	q.log = entry
 }
 
-// Submit 将提交放入队列；支持 ctx 取消。
+// Submit 将提交放入队列；队列已满时阻塞（High 除外），支持 ctx 取消。
 func (q *SubmissionQueue) Submit(ctx context.Context, submission Submission) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
+	if submission.Priority == 0 {
+		submission.Priority = PriorityNormal
 	}
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case q.ch <- submission:
-		q.logSubmission(submission)
-		return nil
-	default:
-		// 等待一个可写位或取消
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return ErrSubmissionQueueClosed
+		}
+		if submission.Priority == PriorityHigh || q.pending < q.capacity {
+			q.enqueueLocked(submission)
+			q.mu.Unlock()
+			q.logSubmission(submission)
+			return nil
+		}
+		changed := q.changed
+		q.mu.Unlock()
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case q.ch <- submission:
-			q.logSubmission(submission)
-			return nil
+		case <-changed:
 		}
 	}
 }
 
-// Receive 读取一条提交；若队列已关闭则返回 ErrSubmissionQueueClosed。
+// Receive 取出下一条可处理的提交；队列关闭且已取空时返回 ErrSubmissionQueueClosed。
+// 取出的 Normal/Low 提交处理完后必须调用 Done，否则该会话的后续提交不会被取出。
 func (q *SubmissionQueue) Receive(ctx context.Context) (Submission, error) {
-	select {
-	case <-ctx.Done():
-		return Submission{}, ctx.Err()
-	case sub, ok := <-q.ch:
-		if !ok {
+	return q.receive(ctx, false)
+}
+
+// ReceiveControl 只取出 High 提交，供专门处理中断与审批的 worker 使用，使其不被长时间运行的回合阻塞。
+func (q *SubmissionQueue) ReceiveControl(ctx context.Context) (Submission, error) {
+	return q.receive(ctx, true)
+}
+
+func (q *SubmissionQueue) receive(ctx context.Context, controlOnly bool) (Submission, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return Submission{}, err
+		}
+		q.mu.Lock()
+		if item, ok := q.dequeueLocked(controlOnly); ok {
+			wait := time.Since(item.enqueued)
+			q.mu.Unlock()
+			q.logDequeue(item.sub, wait)
+			return item.sub, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
 			return Submission{}, ErrSubmissionQueueClosed
 		}
-		return sub, nil
+		changed := q.changed
+		q.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return Submission{}, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Done 标记通过 Receive 取出的提交已处理完毕，放行同一会话的下一条提交。
+func (q *SubmissionQueue) Done(submission Submission) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+	if lane := q.lanes[submission.SessionID]; lane != nil && serialized(submission) {
+		lane.busy = false
+		q.dropIdleLocked(submission.SessionID, lane)
 	}
+	q.broadcastLocked()
 }
 
-// Len 返回当前队列长度。
+// Len 返回当前排队中的提交数。
 func (q *SubmissionQueue) Len() int {
-	return len(q.ch)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
+
+// Stats 返回队列深度与等待延迟的快照。
+func (q *SubmissionQueue) Stats() SubmissionQueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := q.stats
+	out.Depth = q.pending
+	out.InFlight = q.inFlight
+	out.DepthByPriority = map[Priority]int{}
+	for _, lane := range q.lanes {
+		for i, items := range lane.queues {
+			if len(items) > 0 {
+				out.DepthByPriority[priorities[i]] += len(items)
+			}
+		}
+	}
+	out.Wait = make(map[Priority]LatencyStats, len(q.stats.Wait))
+	for p, s := range q.stats.Wait {
+		out.Wait[p] = s
+	}
+	return out
 }
 
-// Close 关闭队列，停止进一步提交。
+// Close 关闭队列，停止进一步提交；已排队的提交仍可被取出。
 func (q *SubmissionQueue) Close() {
-	q.closeOnce.Do(func() {
-		close(q.ch)
-	})
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.broadcastLocked()
+}
+
+// priorities 与 priorityIndex 对应，按出队顺序排列。
+var priorities = [3]Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+func priorityIndex(p Priority) int {
+	switch {
+	case p >= PriorityHigh:
+		return 0
+	case p <= PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// serialized 报告提交是否参与会话串行：有会话的 Normal/Low 提交。
+func serialized(sub Submission) bool {
+	return sub.SessionID != "" && sub.Priority != PriorityHigh
+}
+
+func (q *SubmissionQueue) enqueueLocked(sub Submission) {
+	lane := q.lanes[sub.SessionID]
+	if lane == nil {
+		lane = &sessionLane{}
+		q.lanes[sub.SessionID] = lane
+	}
+	q.seq++
+	idx := priorityIndex(sub.Priority)
+	lane.queues[idx] = append(lane.queues[idx], queuedSubmission{sub: sub, seq: q.seq, enqueued: time.Now()})
+	q.pending++
+	q.stats.Enqueued++
+	q.broadcastLocked()
+}
+
+// dequeueLocked 按优先级取出下一条可处理的提交：同一优先级内选择最久未被服务的会话，再按入队顺序。
+func (q *SubmissionQueue) dequeueLocked(controlOnly bool) (queuedSubmission, bool) {
+	for idx := range priorities {
+		if controlOnly && idx > 0 {
+			break
+		}
+		var best *sessionLane
+		bestKey := ""
+		for key, lane := range q.lanes {
+			items := lane.queues[idx]
+			if len(items) == 0 || (lane.busy && serialized(items[0].sub)) {
+				continue
+			}
+			if best == nil || lane.served < best.served || (lane.served == best.served && items[0].seq < best.queues[idx][0].seq) {
+				best, bestKey = lane, key
+			}
+		}
+		if best == nil {
+			continue
+		}
+		item := best.queues[idx][0]
+		best.queues[idx] = best.queues[idx][1:]
+		q.seq++
+		best.served = q.seq
+		if serialized(item.sub) {
+			best.busy = true
+		}
+		q.pending--
+		q.inFlight++
+		q.stats.Dequeued++
+		wait := q.stats.Wait[item.sub.Priority]
+		wait.observe(time.Since(item.enqueued))
+		q.stats.Wait[item.sub.Priority] = wait
+		q.dropIdleLocked(bestKey, best)
+		q.broadcastLocked()
+		return item, true
+	}
+	return queuedSubmission{}, false
+}
+
+func (q *SubmissionQueue) dropIdleLocked(key string, lane *sessionLane) {
+	if lane.busy {
+		return
+	}
+	for _, items := range lane.queues {
+		if len(items) > 0 {
+			return
+		}
+	}
+	delete(q.lanes, key)
+}
+
+// broadcastLocked 唤醒所有等待队列变化的 Submit/Receive。
+func (q *SubmissionQueue) broadcastLocked() {
+	close(q.changed)
+	q.changed = make(chan struct{})
 }
 
 func (q *SubmissionQueue) logSubmission(submission Submission) {
@@ -109,3 +337,20 @@ func (q *SubmissionQueue) logSubmission(submission Submission) {
 	}
 	q.log.WithFields(fields).Info("enqueued submission into SQ")
 }
+
+func (q *SubmissionQueue) logDequeue(submission Submission, wait time.Duration) {
+	if q.log == nil {
+		return
+	}
+	fields := logger.Fields{
+		"submission_id": submission.ID,
+		"operation":     submission.Operation.Kind,
+		"priority":      submission.Priority,
+		"wait_ms":       wait.Milliseconds(),
+		"depth":         q.Len(),
+	}
+	if submission.SessionID != "" {
+		fields["session_id"] = submission.SessionID
+	}
+	q.log.WithFields(fields).Info("dequeued submission from SQ")
+}
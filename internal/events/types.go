@@ -18,12 +18,16 @@ const (
 	OperationUserInput        OperationKind = "user_input"
 	OperationInterrupt        OperationKind = "interrupt"
 	OperationApprovalDecision OperationKind = "approval_decision"
+	OperationUndo             OperationKind = "undo"
+	OperationCompact          OperationKind = "compact"
 )
 
 // InputMessage 代表一次用户输入（或上下文中的历史消息）。
 type InputMessage struct {
 	Role    string
 	Content string
+	// Images 是随消息发送的图片，每项为 data URL（data:image/png;base64,...）。
+	Images []string
 }
 
 // InputContext 为提交提供会话和额外元数据。
@@ -50,6 +54,22 @@ type UserInputOperation struct {
 type ApprovalDecisionOperation struct {
 	ApprovalID string
 	Approved   bool
+	// Scope 为批准范围（once/session/prefix），空值表示仅本次。
+	Scope string
+	// Prefix 为 scope=prefix 时放行的命令前缀，为空时由命令推导。
+	Prefix string
+	// Persist 表示前缀规则同时按项目持久化。
+	Persist bool
+	// Reason 为拒绝理由，会回传给模型。
+	Reason string
+}
+
+// CompactOperation 描述一次用户触发的上下文压缩（/compact）。
+type CompactOperation struct {
+	// Focus 是可选的关注点提示，会附加到压缩提示词之后。
+	Focus string
+	// Model 为空时使用会话当前模型。
+	Model string
 }
 
 // Operation 描述一次提交的操作载荷。
@@ -57,6 +77,7 @@ type Operation struct {
 	Kind             OperationKind
 	UserInput        *UserInputOperation
 	ApprovalDecision *ApprovalDecisionOperation
+	Compact          *CompactOperation
 }
 
 // Submission 代表进入 SQ 的提交。
@@ -79,12 +100,24 @@ const (
 	EventTaskSummary   EventType = "task.summary"
 	EventTaskCompleted EventType = "task.completed"
 	EventAgentOutput   EventType = "agent.output"
-	EventError         EventType = "task.error"
-	EventToolEvent     EventType = "tool.event"
+	// EventAgentReasoning 携带模型思考内容的流式增量，与最终回答分开渲染。
+	EventAgentReasoning EventType = "agent.reasoning"
+	EventError          EventType = "task.error"
+	EventToolEvent      EventType = "tool.event"
 	// EventPlanUpdated 表示 update_plan 工具成功后生成的新计划快照。
 	EventPlanUpdated EventType = "plan.updated"
+	// EventUndoCompleted 表示一次 /undo 处理结束（成功恢复或无可撤销快照）。
+	EventUndoCompleted EventType = "undo.completed"
+	// EventCompactCompleted 表示一次 /compact 处理结束（成功替换历史或失败原因）。
+	EventCompactCompleted EventType = "compact.completed"
 )
 
+// AgentReasoning 是一段思考增量；Sequence 与 AgentOutput 共用递增序号。
+type AgentReasoning struct {
+	Content  string
+	Sequence int
+}
+
 // AgentOutput 表示智能体的输出（可流式）。
 type AgentOutput struct {
 	Content  string
@@ -99,6 +132,24 @@ type TaskResult struct {
 	Error  string
 }
 
+// UndoResult 描述 /undo 的结果：CommitID 为被恢复的 ghost commit。
+type UndoResult struct {
+	Success  bool   `json:"success"`
+	Message  string `json:"message"`
+	CommitID string `json:"commit_id,omitempty"`
+}
+
+// CompactResult 描述 /compact 的结果：压缩前后的估算 token 数与生成的摘要。
+type CompactResult struct {
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+	Summary      string `json:"summary,omitempty"`
+	TokensBefore int64  `json:"tokens_before,omitempty"`
+	TokensAfter  int64  `json:"tokens_after,omitempty"`
+	// TrimmedItems 是因压缩提示词超出窗口而丢弃的最旧历史项数。
+	TrimmedItems int `json:"trimmed_items,omitempty"`
+}
+
 // TaskSummary 描述一次 turn 结束后的汇总信息。
 // Text 为面向用户的汇总文本（包含完成工作/问题）；结构化字段用于 exec/TUI 做更丰富的渲染或后续扩展。
 type TaskSummary struct {
@@ -113,6 +164,11 @@ type TaskSummary struct {
 	InputTokens       int64 `json:"input_tokens,omitempty"`
 	CachedInputTokens int64 `json:"cached_input_tokens,omitempty"`
 	OutputTokens      int64 `json:"output_tokens,omitempty"`
+
+	// 以下为 provider 回报的提示词缓存用量；CacheHitRate 为缓存读取占全部输入 token 的比例（0~1）。
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens,omitempty"`
+	CacheHitRate             float64 `json:"cache_hit_rate,omitempty"`
 }
 
 // Event 是 EQ 中传递的唯一消息格式。
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,7 @@ import (
 	echocontext "echo-cli/internal/context"
 	"echo-cli/internal/events"
 	"echo-cli/internal/logger"
+	"echo-cli/internal/prompts"
 	"echo-cli/internal/tools"
 )
 
@@ -31,6 +33,17 @@ type Options struct {
 	RequestTimeout time.Duration
 	Retries        int
 	RetryDelay     time.Duration
+	// Snapshots 为会修改工作区的回合记录 ghost commit，供 undo 恢复；nil 表示禁用。
+	Snapshots GhostSnapshotter
+	// OnRecord 在会话的 ResponseItem 历史或回合记录变化后调用，参数为变化后的完整快照，
+	// 供调用方逐步持久化会话；在处理提交的 goroutine 中同步调用。nil 表示不通知。
+	OnRecord func(sessionID string, items []echocontext.ResponseItem, turns []echocontext.TurnRecord)
+}
+
+// GhostSnapshotter 负责创建与恢复工作区的 ghost commit。
+type GhostSnapshotter interface {
+	Create(ctx context.Context) (echocontext.GhostCommit, error)
+	Restore(ctx context.Context, commit echocontext.GhostCommit) error
 }
 
 // Engine 实现 SQ→核心→EQ 的执行流程。
@@ -47,6 +60,8 @@ type Engine struct {
 	requestTimeout time.Duration
 	retries        int
 	retryDelay     time.Duration
+	snapshots      GhostSnapshotter
+	onRecord       func(sessionID string, items []echocontext.ResponseItem, turns []echocontext.TurnRecord)
 
 	toolCtxMu sync.Mutex
 	toolCtx   map[string]toolCallContext // tool call id -> submission context
@@ -97,6 +112,8 @@ func NewEngine(opts Options) *Engine {
 		requestTimeout: reqTimeout,
 		retries:        opts.Retries,
 		retryDelay:     retryDelay,
+		snapshots:      opts.Snapshots,
+		onRecord:       opts.OnRecord,
 		toolCtx:        map[string]toolCallContext{},
 	}
 }
@@ -106,6 +123,8 @@ func (e *Engine) Start(ctx context.Context) {
 	e.manager.RegisterHandler(events.OperationUserInput, events.HandlerFunc(e.handleUserInput))
 	e.manager.RegisterHandler(events.OperationInterrupt, events.HandlerFunc(e.handleInterrupt))
 	e.manager.RegisterHandler(events.OperationApprovalDecision, events.HandlerFunc(e.handleApprovalDecision))
+	e.manager.RegisterHandler(events.OperationUndo, events.HandlerFunc(e.handleUndo))
+	e.manager.RegisterHandler(events.OperationCompact, events.HandlerFunc(e.handleCompact))
 	e.manager.Start(ctx)
 	e.startToolForwarder(ctx)
 }
@@ -152,6 +171,44 @@ func (e *Engine) SeedHistory(sessionID string, history []agent.Message) {
 	e.contexts.AppendMessages(sessionID, history)
 }
 
+// SeedGhostSnapshots 预载入已保存的 ghost commit，使恢复后的会话仍可 undo。
+func (e *Engine) SeedGhostSnapshots(sessionID string, commits []echocontext.GhostCommit) {
+	items := make([]echocontext.ResponseItem, 0, len(commits))
+	for _, commit := range commits {
+		items = append(items, echocontext.NewGhostSnapshotItem(commit))
+	}
+	e.contexts.AppendResponseItems(sessionID, items)
+}
+
+// RestoreSession 用会话记录中的 ResponseItem 与回合元数据原样重建上下文，
+// 保留推理项、工具调用配对、ghost snapshot 与压缩摘要。
+func (e *Engine) RestoreSession(sessionID string, items []echocontext.ResponseItem, turns []echocontext.TurnRecord) {
+	e.contexts.RestoreSession(sessionID, items, turns)
+}
+
+// ResponseHistory 返回会话的完整 ResponseItem 历史，用于持久化。
+func (e *Engine) ResponseHistory(sessionID string) []echocontext.ResponseItem {
+	return e.contexts.ResponseHistory(sessionID)
+}
+
+// Turns 返回会话已完成回合的元数据（模型、用量、时间）。
+func (e *Engine) Turns(sessionID string) []echocontext.TurnRecord {
+	return e.contexts.Turns(sessionID)
+}
+
+// recorded 把会话当前的历史与回合记录交给 OnRecord。
+func (e *Engine) recorded(sessionID string) {
+	if e.onRecord == nil {
+		return
+	}
+	e.onRecord(sessionID, e.contexts.ResponseHistory(sessionID), e.contexts.Turns(sessionID))
+}
+
+// GhostSnapshots 返回会话中尚未撤销的 ghost commit（按时间顺序）。
+func (e *Engine) GhostSnapshots(sessionID string) []echocontext.GhostCommit {
+	return echocontext.GhostCommits(e.contexts.ResponseHistory(sessionID))
+}
+
 func (e *Engine) handleUserInput(ctx context.Context, submission events.Submission, emit events.EventPublisher) error {
 	if e.client == nil {
 		return errors.New("model client not configured")
@@ -193,13 +250,123 @@ func (e *Engine) handleApprovalDecision(ctx context.Context, submission events.S
 	if strings.TrimSpace(dec.ApprovalID) == "" {
 		return errors.New("approval id required")
 	}
+	scope, err := tools.ParseApprovalScope(dec.Scope)
+	if err != nil {
+		return err
+	}
 	e.bus.Publish(tools.ApprovalDecision{
 		ApprovalID: strings.TrimSpace(dec.ApprovalID),
 		Approved:   dec.Approved,
+		Scope:      scope,
+		Prefix:     strings.TrimSpace(dec.Prefix),
+		Persist:    dec.Persist,
+		Reason:     strings.TrimSpace(dec.Reason),
+	})
+	return nil
+}
+
+// handleUndo 将工作区恢复到最近一次 ghost snapshot，并把该快照从历史中移除，
+// 因此连续 undo 会依次回退更早的回合。对话历史本身保持不变。
+func (e *Engine) handleUndo(ctx context.Context, submission events.Submission, emit events.EventPublisher) error {
+	result := e.undoLast(ctx, submission.SessionID)
+	_ = emit.Publish(ctx, events.Event{
+		Type:         events.EventUndoCompleted,
+		SubmissionID: submission.ID,
+		SessionID:    submission.SessionID,
+		Timestamp:    time.Now(),
+		Payload:      result,
+		Metadata:     submission.Metadata,
 	})
 	return nil
 }
 
+func (e *Engine) undoLast(ctx context.Context, sessionID string) events.UndoResult {
+	if e.snapshots == nil {
+		return events.UndoResult{Message: "undo is disabled"}
+	}
+	history := e.contexts.ResponseHistory(sessionID)
+	idx := echocontext.LastGhostSnapshotIndex(history)
+	if idx < 0 {
+		return events.UndoResult{Message: "no snapshot available to undo"}
+	}
+	commit := history[idx].GhostSnapshot.GhostCommit
+	if err := e.snapshots.Restore(ctx, commit); err != nil {
+		log.Warnf("undo failed session=%s commit=%s err=%v", sessionID, commit.ID, err)
+		return events.UndoResult{Message: fmt.Sprintf("undo failed: %v", err), CommitID: commit.ID}
+	}
+	remaining := append(append([]echocontext.ResponseItem{}, history[:idx]...), history[idx+1:]...)
+	e.contexts.ReplaceHistory(sessionID, remaining)
+	e.recorded(sessionID)
+	log.Infof("undo restored session=%s commit=%s", sessionID, commit.ID)
+	return events.UndoResult{Success: true, Message: "restored workspace to snapshot " + shortCommitID(commit.ID), CommitID: commit.ID}
+}
+
+// handleCompact 处理用户触发的 /compact：复用自动压缩的摘要流程替换会话历史，
+// 并通过 compact.completed 报告压缩前后的估算 token 数与摘要。
+func (e *Engine) handleCompact(ctx context.Context, submission events.Submission, emit events.EventPublisher) error {
+	result := e.compactSession(ctx, submission)
+	_ = emit.Publish(ctx, events.Event{
+		Type:         events.EventCompactCompleted,
+		SubmissionID: submission.ID,
+		SessionID:    submission.SessionID,
+		Timestamp:    time.Now(),
+		Payload:      result,
+		Metadata:     submission.Metadata,
+	})
+	return nil
+}
+
+func (e *Engine) compactSession(ctx context.Context, submission events.Submission) events.CompactResult {
+	if e.client == nil {
+		return events.CompactResult{Message: "model client not configured"}
+	}
+	e.activeMu.Lock()
+	busy := e.active[submission.SessionID] != nil
+	e.activeMu.Unlock()
+	if busy {
+		return events.CompactResult{Message: "cannot compact while a task is running"}
+	}
+	var op events.CompactOperation
+	if submission.Operation.Compact != nil {
+		op = *submission.Operation.Compact
+	}
+	turnCtx := e.contexts.CurrentTurn(submission.SessionID, events.InputContext{Model: op.Model})
+	if len(turnCtx.ResponseHistory) == 0 {
+		return events.CompactResult{Message: "nothing to compact"}
+	}
+	before := echocontext.EstimatePromptTokens(turnCtx.BuildPrompt())
+	compacted, trimmed, summary, err := e.compactHistory(ctx, submission.SessionID, turnCtx, op.Focus)
+	if err != nil {
+		log.Warnf("manual compaction failed session=%s model=%s trimmed=%d err=%v", submission.SessionID, turnCtx.Model, trimmed, err)
+		return events.CompactResult{Message: fmt.Sprintf("compaction failed: %v", err), TokensBefore: before, TrimmedItems: trimmed}
+	}
+	after := echocontext.EstimatePromptTokens(compacted.BuildPrompt())
+	log.Infof("manual compaction completed session=%s model=%s tokens=%d->%d trimmed=%d", submission.SessionID, turnCtx.Model, before, after, trimmed)
+	return events.CompactResult{
+		Success:      true,
+		Message:      fmt.Sprintf("compacted context: ~%d → ~%d tokens", before, after),
+		Summary:      compactSummaryBody(summary),
+		TokensBefore: before,
+		TokensAfter:  after,
+		TrimmedItems: trimmed,
+	}
+}
+
+// compactSummaryBody 去掉注入历史时使用的交接前缀，只保留模型生成的摘要正文。
+func compactSummaryBody(summary string) string {
+	if prefix, ok := prompts.Builtin(prompts.PromptCompactSummaryPrefix); ok {
+		summary = strings.TrimPrefix(summary, strings.TrimSpace(prefix))
+	}
+	return strings.TrimSpace(summary)
+}
+
+func shortCommitID(id string) string {
+	if len(id) > 7 {
+		return id[:7]
+	}
+	return id
+}
+
 type turnResult struct {
 	responses     []echocontext.ResponseInputItem
 	itemsToRecord []echocontext.ResponseItem
@@ -210,8 +377,12 @@ type modelTurnOutput struct {
 	fullResponse string
 	toolCalls    []tools.ToolCall
 	items        []echocontext.ResponseItem
+	stopReason   string
 }
 
+// maxTruncatedToolCallRetries 是工具调用因 max_tokens 被截断后，加大预算重试的最多次数。
+const maxTruncatedToolCallRetries = 2
+
 const toolErrorOutputLimit = 400
 const toolPayloadPreviewLimit = 2000
 const toolDiffPreviewLimit = 2000
@@ -352,6 +523,13 @@ type runTaskState struct {
 	turnStart   time.Time
 	turn        turnResult
 	toolResults []tools.ToolResult
+
+	// snapshotTaken 表示本任务已记录 ghost snapshot；每个用户回合只在首次修改工作区前快照一次。
+	snapshotTaken bool
+
+	// taskStart 与 usage 汇总整个用户回合，结束时写入会话的回合记录。
+	taskStart time.Time
+	usage     agent.TokenUsage
 }
 
 // runTask 对应 codex-rs 的 run_task：负责回合循环，内部委托 runTurn 处理单轮。
@@ -408,6 +586,7 @@ func (e *Engine) runTaskStagePreflightAndStart(ctx context.Context, submission e
 		turnIndex:      0,
 		exitReason:     "unknown",
 		exitStage:      "unknown",
+		taskStart:      time.Now(),
 	}
 	return context.WithValue(ctx, runTaskStateKey{}, runState)
 }
@@ -541,6 +720,7 @@ func (e *Engine) runTaskEmitTurnSummary(ctx context.Context, finalContent string
 		ExitReason:   reason,
 		ExitStage:    stage,
 		Err:          err,
+		Usage:        runState.usage,
 	})
 	runState.lastSummary = summary
 	runState.hasSummary = true
@@ -571,6 +751,11 @@ func (e *Engine) runTaskLogSummary(ctx context.Context, kind string, summary eve
 		"output_tokens": summary.OutputTokens,
 		"turn_index":    runState.turnIndex,
 	}
+	if summary.CachedInputTokens > 0 {
+		fields["cache_read_input_tokens"] = summary.CacheReadInputTokens
+		fields["cache_creation_input_tokens"] = summary.CacheCreationInputTokens
+		fields["cache_hit_rate"] = summary.CacheHitRate
+	}
 	if summary.Error != "" {
 		log.Infof("run_task.log_summary kind=%s has_error=true", kind)
 		fields["error"] = sanitizeLogText(summary.Error)
@@ -582,6 +767,15 @@ func (e *Engine) runTaskLogSummary(ctx context.Context, kind string, summary eve
 
 func (e *Engine) runTaskFinalize(ctx context.Context) {
 	runState := runTaskStateFromContext(ctx)
+	e.contexts.RecordTurn(runState.submission.SessionID, echocontext.TurnRecord{
+		Model:             runState.turnCtx.Model,
+		InputTokens:       runState.usage.InputTokens,
+		CachedInputTokens: runState.usage.CacheCreationInputTokens + runState.usage.CacheReadInputTokens,
+		OutputTokens:      runState.usage.OutputTokens,
+		Started:           runState.taskStart,
+		Completed:         time.Now(),
+	})
+	e.recorded(runState.submission.SessionID)
 	if runState.stopTools != nil {
 		log.Infof("run_task.finalize stop_tools=call")
 		runState.stopTools()
@@ -656,7 +850,18 @@ func (e *Engine) runTurn(ctx context.Context, submission events.Submission, turn
 
 	modelStart := time.Now()
 	log.Infof("run_task.model_interaction start session=%s submission=%s model=%s sequence=%d", submission.SessionID, submission.ID, turnCtx.Model, *seq)
-	output, err := e.runModelInteraction(ctx, submission, prompt, emit, seq)
+	output, err := e.runModelInteraction(ctx, submission, prompt, emit, seq, "")
+	for attempt := 1; err == nil && output.stopReason == "max_tokens" && len(output.toolCalls) > 0; attempt++ {
+		// 工具参数可能被截断：加大预算重新请求，已展示的文本不再重复输出。
+		budget, ok := retryMaxTokens(prompt, attempt)
+		if !ok {
+			log.Warnf("run_task.model_interaction truncated tool call session=%s submission=%s max_tokens=%d retries exhausted", submission.SessionID, submission.ID, prompt.Sampling.MaxTokens)
+			break
+		}
+		log.Infof("run_task.model_interaction retry session=%s submission=%s reason=max_tokens attempt=%d max_tokens=%d", submission.SessionID, submission.ID, attempt, budget)
+		prompt.Sampling.MaxTokens = budget
+		output, err = e.runModelInteraction(ctx, submission, prompt, emit, seq, output.fullResponse)
+	}
 	if err != nil {
 		log.Infof("run_task.model_interaction finish status=error duration_ms=%d err=%v timeout=%t", time.Since(modelStart).Milliseconds(), err, errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled))
 		return turnResult{}, nil, stageError{Stage: "model_interaction", Err: err}
@@ -664,6 +869,9 @@ func (e *Engine) runTurn(ctx context.Context, submission events.Submission, turn
 	log.Infof("run_task.model_interaction finish status=ok duration_ms=%d response_items=%d tool_calls=%d", time.Since(modelStart).Milliseconds(), len(output.items), len(output.toolCalls))
 
 	processed := e.identifyTools(output)
+	if item, ok := e.captureGhostSnapshot(ctx, submission, output.toolCalls); ok {
+		processed = append([]ProcessedResponseItem{{Item: item}}, processed...)
+	}
 
 	toolCtx := ctx
 	toolCancel := func() {}
@@ -705,9 +913,66 @@ func (e *Engine) runTurn(ctx context.Context, submission events.Submission, turn
 	}, results, nil
 }
 
+// retryMaxTokens 返回第 attempt 次重试的 max_tokens：每次翻倍，不超过模型的输出上限。
+func retryMaxTokens(prompt agent.Prompt, attempt int) (int64, bool) {
+	if attempt > maxTruncatedToolCallRetries {
+		return 0, false
+	}
+	current := prompt.Sampling.MaxTokens
+	if current <= 0 {
+		current = agent.DefaultMaxOutputTokens
+	}
+	limit := echocontext.Models().MaxOutputTokens(prompt.Model)
+	if limit <= 0 {
+		limit = 4 * agent.DefaultMaxOutputTokens
+	}
+	if current >= limit {
+		return 0, false
+	}
+	return min(current*2, limit), true
+}
+
+// mutatingToolNames 列出会修改工作区、需要在执行前创建 ghost snapshot 的工具。
+var mutatingToolNames = map[string]struct{}{
+	"exec_command": {},
+	"write_stdin":  {},
+	"apply_patch":  {},
+}
+
+// captureGhostSnapshot 在本任务首次执行修改型工具之前记录工作区快照。
+// 快照失败（例如非 git 仓库）只记录日志，不阻断回合。
+func (e *Engine) captureGhostSnapshot(ctx context.Context, submission events.Submission, calls []tools.ToolCall) (echocontext.ResponseItem, bool) {
+	if e.snapshots == nil {
+		return echocontext.ResponseItem{}, false
+	}
+	runState, _ := ctx.Value(runTaskStateKey{}).(*runTaskState)
+	if runState == nil || runState.snapshotTaken {
+		return echocontext.ResponseItem{}, false
+	}
+	mutating := false
+	for _, call := range calls {
+		if _, ok := mutatingToolNames[call.Name]; ok {
+			mutating = true
+			break
+		}
+	}
+	if !mutating {
+		return echocontext.ResponseItem{}, false
+	}
+	runState.snapshotTaken = true
+	commit, err := e.snapshots.Create(ctx)
+	if err != nil {
+		log.Warnf("ghost snapshot skipped session=%s submission=%s err=%v", submission.SessionID, submission.ID, err)
+		return echocontext.ResponseItem{}, false
+	}
+	log.Infof("ghost snapshot recorded session=%s submission=%s commit=%s", submission.SessionID, submission.ID, commit.ID)
+	return echocontext.NewGhostSnapshotItem(commit), true
+}
+
 // runModelInteraction 负责模型流式交互与输出收集，仅处理「模型交互」层。
 // 对齐 Codex：拉取流式事件、发布增量输出、收集工具标记与 ResponseItem。
-func (e *Engine) runModelInteraction(ctx context.Context, submission events.Submission, prompt agent.Prompt, emit events.EventPublisher, seq *int) (modelTurnOutput, error) {
+// replayed 为重试前已发布的文本：新输出与其重合的前缀不再重复发布。
+func (e *Engine) runModelInteraction(ctx context.Context, submission events.Submission, prompt agent.Prompt, emit events.EventPublisher, seq *int, replayed string) (modelTurnOutput, error) {
 	collector := newModelStreamCollector()
 	seqStart := *seq
 	var usage *agent.TokenUsage
@@ -722,6 +987,11 @@ func (e *Engine) runModelInteraction(ctx context.Context, submission events.Subm
 				return
 			}
 			collector.OnTextDelta(evt.Text)
+			text := evt.Text
+			text, replayed = skipReplayedText(text, replayed)
+			if text == "" {
+				return
+			}
 
 			_ = emit.Publish(ctx, events.Event{
 				Type:         events.EventAgentOutput,
@@ -729,12 +999,25 @@ func (e *Engine) runModelInteraction(ctx context.Context, submission events.Subm
 				SessionID:    submission.SessionID,
 				Timestamp:    time.Now(),
 				Payload: events.AgentOutput{
-					Content:  evt.Text,
+					Content:  text,
 					Sequence: *seq,
 				},
 				Metadata: submission.Metadata,
 			})
 			*seq++
+		case agent.StreamEventReasoningDelta:
+			if evt.Text == "" {
+				return
+			}
+			_ = emit.Publish(ctx, events.Event{
+				Type:         events.EventAgentReasoning,
+				SubmissionID: submission.ID,
+				SessionID:    submission.SessionID,
+				Timestamp:    time.Now(),
+				Payload:      events.AgentReasoning{Content: evt.Text, Sequence: *seq},
+				Metadata:     submission.Metadata,
+			})
+			*seq++
 		case agent.StreamEventItem:
 			collector.OnItem(evt.Item)
 		case agent.StreamEventUsage:
@@ -760,6 +1043,17 @@ func (e *Engine) runModelInteraction(ctx context.Context, submission events.Subm
 	}
 
 	output := collector.Result()
+	output.stopReason = stopReason
+	if runState, _ := ctx.Value(runTaskStateKey{}).(*runTaskState); runState != nil && usage != nil {
+		runState.usage.InputTokens += usage.InputTokens
+		runState.usage.OutputTokens += usage.OutputTokens
+		runState.usage.CacheCreationInputTokens += usage.CacheCreationInputTokens
+		runState.usage.CacheReadInputTokens += usage.CacheReadInputTokens
+	}
+	if usage != nil {
+		// 用 provider 回报的真实输入用量校准该模型的 token 估算。
+		echocontext.Models().Reconcile(prompt, usage.InputTokens+usage.CacheCreationInputTokens+usage.CacheReadInputTokens)
+	}
 	in := llmIn()
 	model := strings.TrimSpace(prompt.Model)
 	encoded := encodeLLMLogJSON(llmResponseLogPayload{
@@ -804,12 +1098,35 @@ func (e *Engine) runModelInteraction(ctx context.Context, submission events.Subm
 	return output, nil
 }
 
+// skipReplayedText 去掉 chunk 中与 replayed 重合的部分，返回待发布的文本与剩余的 replayed；
+// 一旦新输出与先前不同，之后的增量全部发布。
+func skipReplayedText(chunk, replayed string) (string, string) {
+	switch {
+	case replayed == "":
+		return chunk, ""
+	case strings.HasPrefix(replayed, chunk):
+		return "", replayed[len(chunk):]
+	case strings.HasPrefix(chunk, replayed):
+		return chunk[len(replayed):], ""
+	default:
+		return chunk, ""
+	}
+}
+
 // identifyTools 负责从模型输出中识别工具调用并构造历史记录项（「工具识别」层）。
 func (e *Engine) identifyTools(output modelTurnOutput) []ProcessedResponseItem {
 	processed := make([]ProcessedResponseItem, 0, len(output.items)+1)
 	processed = append(processed, processedFromResponseItems(output.items)...)
 	if strings.TrimSpace(output.fullResponse) != "" && !hasAssistantMessageItem(output.items) {
-		processed = append(processed, ProcessedResponseItem{Item: echocontext.NewAssistantMessageItem(output.fullResponse)})
+		// 文本位于思考块之后、工具调用之前，与模型输出顺序一致（anthropic 回传思考块时要求同一轮的顺序）。
+		at := len(processed)
+		for i, p := range processed {
+			if p.Item.Type == echocontext.ResponseItemTypeFunctionCall {
+				at = i
+				break
+			}
+		}
+		processed = slices.Insert(processed, at, ProcessedResponseItem{Item: echocontext.NewAssistantMessageItem(output.fullResponse)})
 	}
 	return processed
 }
@@ -879,6 +1196,39 @@ func encodeLLMLogJSON(value any) llmEncodedLogJSON {
 	}
 }
 
+// redactImageData 把 prompt 中的 base64 图片替换为长度说明，避免请求日志写入图片数据。
+func redactImageData(prompt agent.Prompt) agent.Prompt {
+	var messages []agent.Message
+	for i, msg := range prompt.Messages {
+		if !hasImageData(msg.Parts) {
+			continue
+		}
+		if messages == nil {
+			messages = append([]agent.Message(nil), prompt.Messages...)
+		}
+		parts := append([]agent.ContentPart(nil), msg.Parts...)
+		for j := range parts {
+			if parts[j].Type == agent.ContentPartImage && parts[j].Data != "" {
+				parts[j].Data = fmt.Sprintf("<%d base64 bytes>", len(parts[j].Data))
+			}
+		}
+		messages[i].Parts = parts
+	}
+	if messages != nil {
+		prompt.Messages = messages
+	}
+	return prompt
+}
+
+func hasImageData(parts []agent.ContentPart) bool {
+	for _, part := range parts {
+		if part.Type == agent.ContentPartImage && part.Data != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func approxTokensFromBytes(n int) int64 {
 	if n <= 0 {
 		return 0
@@ -960,12 +1310,8 @@ func textFromResponseItem(item echocontext.ResponseItem) string {
 			return ""
 		}
 		return echocontext.FlattenContentItems(item.Message.Content)
-	case echocontext.ResponseItemTypeReasoning:
-		if item.Reasoning == nil {
-			return ""
-		}
-		return echocontext.FlattenReasoning(*item.Reasoning)
 	default:
+		// 思考内容经 EventAgentReasoning 单独展示，不计入回答文本。
 		return ""
 	}
 }
@@ -1007,7 +1353,7 @@ func (e *Engine) streamPrompt(ctx context.Context, submission events.Submission,
 	if model == "" {
 		return errors.New("model not specified")
 	}
-	encoded := encodeLLMLogJSON(prompt)
+	encoded := encodeLLMLogJSON(redactImageData(prompt))
 	retryDelay := e.retryDelay
 	if retryDelay <= 0 {
 		retryDelay = time.Second
@@ -1331,7 +1677,7 @@ func (e *Engine) dispatchToolCalls(ctx context.Context, submission events.Submis
 			seen[call.ID] = struct{}{}
 		}
 		e.registerToolCallContext(submission, call.ID)
-		e.bus.Publish(tools.DispatchRequest{Ctx: ctx, Call: call})
+		e.bus.Publish(tools.DispatchRequest{Ctx: ctx, Call: call, SessionID: submission.SessionID})
 	}
 }
 
@@ -1453,20 +1799,16 @@ func (e *Engine) recordConversationItems(sessionID string, turnCtx *echocontext.
 		return
 	}
 	e.contexts.AppendResponseItems(sessionID, items)
+	e.recorded(sessionID)
 	turnCtx.ResponseHistory = append(turnCtx.ResponseHistory, items...)
 	turnCtx.History = append(turnCtx.History, echocontext.ResponseItemsToAgentMessages(items)...)
 }
 
 func (e *Engine) tokenLimitReached(turnCtx echocontext.TurnContext) bool {
-	// 基于当前模型的上下文窗口判断是否触达自动压缩阈值：
-	// 1) 先获取模型的上下文窗口大小，缺失或无效则不触发；
-	// 2) 将窗口换算为默认自动压缩阈值，阈值无效则不触发；
-	// 3) 估算当前 turn 构建出的 prompt token 数，达到/超过阈值则需要压缩。
-	window, ok := echocontext.ContextWindowForModel(turnCtx.Model)
-	if !ok || window <= 0 {
-		return false
-	}
-	limit := echocontext.DefaultAutoCompactLimit(window)
+	// 基于模型目录判断是否触达自动压缩阈值：
+	// 1) 取配置的 auto_compact_token_limit，缺省为上下文窗口的 90%；窗口未知则不触发；
+	// 2) 用校准后的计数器估算当前 turn 构建出的 prompt token 数，达到/超过阈值则需要压缩。
+	limit := echocontext.Models().AutoCompactLimit(turnCtx.Model)
 	if limit <= 0 {
 		return false
 	}
@@ -1478,14 +1820,24 @@ func (e *Engine) runInlineAutoCompactTask(ctx context.Context, sessionID string,
 	if e.client == nil {
 		return turnCtx, false
 	}
-	newHistory, trimmed, _, err := echocontext.CompactConversationHistory(ctx, e.client, turnCtx, turnCtx.ResponseHistory)
+	updated, trimmed, _, err := e.compactHistory(ctx, sessionID, turnCtx, "")
 	if err != nil {
 		log.Warnf("auto-compaction failed model=%s trimmed=%d err=%v", turnCtx.Model, trimmed, err)
 		return turnCtx, false
 	}
+	log.Infof("auto-compaction completed model=%s trimmed=%d new_items=%d", turnCtx.Model, trimmed, len(updated.ResponseHistory))
+	return updated, true
+}
+
+// compactHistory 生成交接摘要并用压缩后的历史替换会话历史，返回更新后的 turn 上下文。
+func (e *Engine) compactHistory(ctx context.Context, sessionID string, turnCtx echocontext.TurnContext, focus string) (echocontext.TurnContext, int, string, error) {
+	newHistory, trimmed, summary, err := echocontext.CompactConversationHistory(ctx, e.client, turnCtx, turnCtx.ResponseHistory, focus)
+	if err != nil {
+		return turnCtx, trimmed, "", err
+	}
 	e.contexts.ReplaceHistory(sessionID, newHistory)
+	e.recorded(sessionID)
 	turnCtx.ResponseHistory = newHistory
 	turnCtx.History = echocontext.ResponseItemsToAgentMessages(newHistory)
-	log.Infof("auto-compaction completed model=%s trimmed=%d new_items=%d", turnCtx.Model, trimmed, len(newHistory))
-	return turnCtx, true
+	return turnCtx, trimmed, summary, nil
 }
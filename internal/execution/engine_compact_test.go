@@ -0,0 +1,90 @@
+package execution
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echo-cli/internal/agent"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/events"
+)
+
+type summaryModelClient struct {
+	fakeModelClient
+	mu     sync.Mutex
+	prompt agent.Prompt
+}
+
+func (c *summaryModelClient) Complete(_ context.Context, prompt agent.Prompt) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompt = prompt
+	return "fixed the parser; next: add tests", nil
+}
+
+func TestEngineCompactReplacesHistoryWithSummary(t *testing.T) {
+	client := &summaryModelClient{}
+	manager := events.NewManager(events.ManagerConfig{SubmissionBuffer: 8, EventBuffer: 16, Workers: 1})
+	engine := NewEngine(Options{Manager: manager, Client: client, Bus: events.NewBus()})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine.Start(ctx)
+	defer engine.Close()
+
+	var items []echocontext.ResponseItem
+	for i := 0; i < 20; i++ {
+		items = append(items,
+			echocontext.NewUserMessageItem("step "+strings.Repeat("x", 200)),
+			echocontext.NewAssistantMessageItem("done "+strings.Repeat("y", 400)),
+		)
+	}
+	engine.RestoreSession("sess-compact", items, nil)
+	eventsCh := engine.Events()
+
+	subID, err := manager.Submit(ctx, events.Submission{
+		SessionID: "sess-compact",
+		Operation: events.Operation{Kind: events.OperationCompact, Compact: &events.CompactOperation{Focus: "the parser bug"}},
+	})
+	if err != nil {
+		t.Fatalf("submit compact: %v", err)
+	}
+	var result events.CompactResult
+	deadline := time.After(2 * time.Second)
+wait:
+	for {
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for compact result")
+		case ev := <-eventsCh:
+			if ev.SubmissionID == subID && ev.Type == events.EventCompactCompleted {
+				result, _ = ev.Payload.(events.CompactResult)
+				break wait
+			}
+		}
+	}
+
+	if !result.Success || result.Summary != "fixed the parser; next: add tests" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if result.TokensAfter <= 0 || result.TokensAfter >= result.TokensBefore {
+		t.Fatalf("expected fewer tokens after compaction, got %d -> %d", result.TokensBefore, result.TokensAfter)
+	}
+	client.mu.Lock()
+	last := client.prompt.Messages[len(client.prompt.Messages)-1].Content
+	client.mu.Unlock()
+	if !strings.Contains(last, "the parser bug") {
+		t.Fatalf("expected focus hint in compact prompt, got %q", last)
+	}
+	history := engine.ResponseHistory("sess-compact")
+	if got := echocontext.FlattenContentItems(history[len(history)-1].Message.Content); !strings.Contains(got, "fixed the parser") {
+		t.Fatalf("expected summary as last history item, got %q", got)
+	}
+	for _, item := range history {
+		if item.Message != nil && item.Message.Role == "assistant" {
+			t.Fatalf("expected assistant turns to be replaced by the summary, got %+v", history)
+		}
+	}
+}
@@ -82,7 +82,7 @@ func TestLLMLogIncludesDirectionForPromptAndStream(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	seq := 0
-	if _, err := engine.runModelInteraction(ctx, sub, prompt, discardPublisher{}, &seq); err != nil {
+	if _, err := engine.runModelInteraction(ctx, sub, prompt, discardPublisher{}, &seq, ""); err != nil {
 		t.Fatalf("runModelInteraction failed: %v", err)
 	}
 
@@ -170,7 +170,7 @@ func TestLLMLogIncludesStopReasons(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 	seq := 0
-	if _, err := engine.runModelInteraction(ctx, sub, prompt, discardPublisher{}, &seq); err != nil {
+	if _, err := engine.runModelInteraction(ctx, sub, prompt, discardPublisher{}, &seq, ""); err != nil {
 		t.Fatalf("runModelInteraction failed: %v", err)
 	}
 
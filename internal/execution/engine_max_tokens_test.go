@@ -0,0 +1,149 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"echo-cli/internal/agent"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/events"
+	"echo-cli/internal/tools"
+)
+
+// truncatingModelClient 第一次请求在工具参数中途以 max_tokens 停止，之后返回完整调用与最终回答。
+type truncatingModelClient struct {
+	mu        sync.Mutex
+	maxTokens []int64
+}
+
+func (c *truncatingModelClient) Complete(_ context.Context, _ agent.Prompt) (string, error) {
+	return "", nil
+}
+
+func (c *truncatingModelClient) Stream(_ context.Context, prompt agent.Prompt, onEvent func(agent.StreamEvent)) error {
+	c.mu.Lock()
+	c.maxTokens = append(c.maxTokens, prompt.Sampling.MaxTokens)
+	calls := len(c.maxTokens)
+	c.mu.Unlock()
+
+	callItem := func(args string) json.RawMessage {
+		raw, _ := json.Marshal(echocontext.ResponseItem{
+			Type:         echocontext.ResponseItemTypeFunctionCall,
+			FunctionCall: &echocontext.FunctionCallResponseItem{Name: "command", Arguments: args, CallID: "call-1"},
+		})
+		return raw
+	}
+	switch calls {
+	case 1:
+		onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: "Writing "})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: "the file"})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: callItem(`{"command":"cat > big.txt <<EOF`)})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventCompleted, StopReason: "max_tokens"})
+	case 2:
+		onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: "Writing the file"})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: " now"})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: callItem(`{"command":"echo done"}`)})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventCompleted, StopReason: "tool_use"})
+	default:
+		onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: "finished"})
+		onEvent(agent.StreamEvent{Type: agent.StreamEventCompleted, StopReason: "end_turn"})
+	}
+	return nil
+}
+
+func TestEngineRetriesTruncatedToolCallWithLargerBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	bus := events.NewBus()
+	manager := events.NewManager(events.ManagerConfig{SubmissionBuffer: 8, EventBuffer: 32, Workers: 2})
+	client := &truncatingModelClient{}
+	engine := NewEngine(Options{
+		Manager:     manager,
+		Client:      client,
+		Bus:         bus,
+		Defaults:    echocontext.SessionDefaults{Model: "gpt-test", System: "system", Sampling: agent.SamplingOptions{MaxTokens: 1000}},
+		ToolTimeout: time.Second,
+	})
+	engine.Start(ctx)
+	defer engine.Close()
+
+	dispatched := make(chan tools.ToolCall, 4)
+	go func() {
+		for evt := range bus.Subscribe() {
+			req, ok := evt.(tools.DispatchRequest)
+			if !ok {
+				continue
+			}
+			dispatched <- req.Call
+			bus.Publish(tools.ToolEvent{
+				Type:   "item.completed",
+				Result: tools.ToolResult{ID: req.Call.ID, Kind: tools.ToolCommand, Status: "completed", Output: "ok"},
+			})
+		}
+	}()
+
+	eventsCh := engine.Events()
+	subID, err := engine.SubmitUserInput(ctx, []events.InputMessage{{Role: "user", Content: "write a big file"}}, events.InputContext{SessionID: "sess-trunc"})
+	if err != nil {
+		t.Fatalf("submit user input: %v", err)
+	}
+	var streamed strings.Builder
+wait:
+	for {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for task completion")
+		case ev := <-eventsCh:
+			if ev.SubmissionID != subID {
+				continue
+			}
+			switch ev.Type {
+			case events.EventAgentOutput:
+				if out, ok := ev.Payload.(events.AgentOutput); ok && !out.Final {
+					streamed.WriteString(out.Content)
+				}
+			case events.EventTaskCompleted:
+				break wait
+			}
+		}
+	}
+
+	client.mu.Lock()
+	budgets := append([]int64(nil), client.maxTokens...)
+	client.mu.Unlock()
+	if len(budgets) != 3 || budgets[0] != 1000 || budgets[1] != 2000 || budgets[2] != 1000 {
+		t.Fatalf("expected retry with doubled budget only for the truncated request, got %v", budgets)
+	}
+	if len(dispatched) != 1 {
+		t.Fatalf("expected only the complete tool call to be dispatched, got %d", len(dispatched))
+	}
+	if call := <-dispatched; !strings.Contains(string(call.Payload), "echo done") {
+		t.Fatalf("unexpected dispatched call %s", call.Payload)
+	}
+	if got := streamed.String(); got != "Writing the file nowfinished" {
+		t.Fatalf("expected replayed text to be streamed once, got %q", got)
+	}
+}
+
+func TestRetryMaxTokensCapsAtModelLimit(t *testing.T) {
+	prompt := agent.Prompt{Model: "gpt-4o", Sampling: agent.SamplingOptions{MaxTokens: 12_000}}
+	if got, ok := retryMaxTokens(prompt, 1); !ok || got != 16_384 {
+		t.Fatalf("expected budget capped at model limit, got %d ok=%v", got, ok)
+	}
+	prompt.Sampling.MaxTokens = 16_384
+	if _, ok := retryMaxTokens(prompt, 1); ok {
+		t.Fatalf("expected no retry once the model limit is reached")
+	}
+	prompt = agent.Prompt{Model: "unknown"}
+	if got, ok := retryMaxTokens(prompt, 1); !ok || got != 2*agent.DefaultMaxOutputTokens {
+		t.Fatalf("expected default budget doubled, got %d ok=%v", got, ok)
+	}
+	if _, ok := retryMaxTokens(prompt, maxTruncatedToolCallRetries+1); ok {
+		t.Fatalf("expected retries to be bounded")
+	}
+}
@@ -574,6 +574,55 @@ func TestEngineAddsLanguageDirectiveEveryModelCall(t *testing.T) {
 	}
 }
 
+func TestEngineStreamsReasoningSeparatelyFromAnswer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	manager := events.NewManager(events.ManagerConfig{SubmissionBuffer: 8, EventBuffer: 16, Workers: 2})
+	engine := NewEngine(Options{
+		Manager:     manager,
+		Client:      reasoningModelClient{},
+		Bus:         events.NewBus(),
+		Defaults:    echocontext.SessionDefaults{Model: "gpt-test", System: "system"},
+		ToolTimeout: time.Second,
+	})
+	engine.Start(ctx)
+	defer engine.Close()
+
+	eventsCh := engine.Events()
+	subID, err := engine.SubmitUserInput(ctx, []events.InputMessage{{Role: "user", Content: "hi"}}, events.InputContext{SessionID: "sess-reasoning"})
+	if err != nil {
+		t.Fatalf("submit user input: %v", err)
+	}
+
+	var reasoning strings.Builder
+	var final string
+	for final == "" {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for final output")
+		case ev := <-eventsCh:
+			if ev.SubmissionID != subID {
+				continue
+			}
+			switch payload := ev.Payload.(type) {
+			case events.AgentReasoning:
+				reasoning.WriteString(payload.Content)
+			case events.AgentOutput:
+				if payload.Final {
+					final = payload.Content
+				}
+			}
+		}
+	}
+	if reasoning.String() != "think first" {
+		t.Fatalf("unexpected reasoning stream %q", reasoning.String())
+	}
+	if final != "answer" {
+		t.Fatalf("reasoning must not leak into the answer, got %q", final)
+	}
+}
+
 func containsChineseLanguagePrompt(msgs []agent.Message) bool {
 	if len(msgs) == 0 {
 		return false
@@ -585,6 +634,25 @@ func containsChineseLanguagePrompt(msgs []agent.Message) bool {
 	return prompts.IsLanguagePrompt(last.Content) && strings.Contains(last.Content, "中文")
 }
 
+type reasoningModelClient struct{}
+
+func (reasoningModelClient) Complete(_ context.Context, _ agent.Prompt) (string, error) {
+	return "", nil
+}
+
+func (reasoningModelClient) Stream(_ context.Context, _ agent.Prompt, onEvent func(agent.StreamEvent)) error {
+	onEvent(agent.StreamEvent{Type: agent.StreamEventReasoningDelta, Text: "think "})
+	onEvent(agent.StreamEvent{Type: agent.StreamEventReasoningDelta, Text: "first"})
+	raw, _ := json.Marshal(echocontext.ResponseItem{
+		Type:      echocontext.ResponseItemTypeReasoning,
+		Reasoning: &echocontext.ReasoningResponseItem{Content: []echocontext.ReasoningItemContent{{Type: echocontext.ContentItemReasoningText, Text: "think first"}}},
+	})
+	onEvent(agent.StreamEvent{Type: agent.StreamEventItem, Item: raw})
+	onEvent(agent.StreamEvent{Type: agent.StreamEventTextDelta, Text: "answer"})
+	onEvent(agent.StreamEvent{Type: agent.StreamEventCompleted})
+	return nil
+}
+
 type fakeModelClient struct {
 	chunks       []string
 	usage        *agent.TokenUsage
@@ -804,3 +872,116 @@ func (c errorModelClient) Complete(_ context.Context, _ agent.Prompt) (string, e
 func (c errorModelClient) Stream(_ context.Context, _ agent.Prompt, _ func(agent.StreamEvent)) error {
 	return c.err
 }
+
+func TestEngineRecordsTurnsAndRestoresSessionExactly(t *testing.T) {
+	bus := events.NewBus()
+	manager := events.NewManager(events.ManagerConfig{SubmissionBuffer: 8, EventBuffer: 16, Workers: 1})
+	engine := NewEngine(Options{
+		Manager:  manager,
+		Client:   fakeModelClient{chunks: []string{"done"}, usage: &agent.TokenUsage{InputTokens: 40, OutputTokens: 5, CacheReadInputTokens: 10}},
+		Bus:      bus,
+		Defaults: echocontext.SessionDefaults{Model: "gpt-test"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine.Start(ctx)
+	defer engine.Close()
+
+	saved := []echocontext.ResponseItem{
+		echocontext.NewUserMessageItem("earlier"),
+		{Type: echocontext.ResponseItemTypeReasoning, Reasoning: &echocontext.ReasoningResponseItem{ID: "rs_1", Summary: []echocontext.ReasoningItemReasoningSummary{{Type: "summary_text", Text: "think"}}}},
+		{Type: echocontext.ResponseItemTypeFunctionCall, FunctionCall: &echocontext.FunctionCallResponseItem{Name: "exec_command", Arguments: `{}`, CallID: "c1"}},
+		{Type: echocontext.ResponseItemTypeFunctionCallOutput, FunctionCallOutput: &echocontext.FunctionCallOutputResponseItem{CallID: "c1", Output: echocontext.FunctionCallOutputPayload{Content: "ok"}}},
+		echocontext.NewGhostSnapshotItem(echocontext.GhostCommit{ID: "g1"}),
+	}
+	engine.RestoreSession("sess-r", saved, []echocontext.TurnRecord{{Model: "old-model"}})
+	if got := engine.ResponseHistory("sess-r"); len(got) != len(saved) || got[1].Reasoning == nil || got[4].GhostSnapshot == nil {
+		t.Fatalf("expected restored history to keep every item, got %+v", got)
+	}
+
+	eventsCh := engine.Events()
+	subID, err := engine.SubmitUserInput(ctx, []events.InputMessage{{Role: "user", Content: "next"}}, events.InputContext{SessionID: "sess-r"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	deadline := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for completion")
+		case ev := <-eventsCh:
+			if ev.SubmissionID == subID && ev.Type == events.EventTaskCompleted {
+				done = true
+			}
+		}
+	}
+
+	turns := engine.Turns("sess-r")
+	if len(turns) != 2 || turns[0].Model != "old-model" {
+		t.Fatalf("expected restored turn plus new turn, got %+v", turns)
+	}
+	last := turns[1]
+	if last.Model != "gpt-test" || last.InputTokens != 40 || last.CachedInputTokens != 10 || last.OutputTokens != 5 || last.Completed.Before(last.Started) {
+		t.Fatalf("unexpected turn record %+v", last)
+	}
+	if got := engine.ResponseHistory("sess-r"); len(got) != len(saved)+2 {
+		t.Fatalf("expected new user and assistant items appended, got %d items", len(got))
+	}
+}
+
+func TestEngineReportsEachRecordedStep(t *testing.T) {
+	type snapshot struct {
+		items int
+		turns int
+	}
+	var (
+		mu    sync.Mutex
+		steps []snapshot
+	)
+	bus := events.NewBus()
+	manager := events.NewManager(events.ManagerConfig{SubmissionBuffer: 8, EventBuffer: 16, Workers: 1})
+	engine := NewEngine(Options{
+		Manager:  manager,
+		Client:   fakeModelClient{chunks: []string{"done"}},
+		Bus:      bus,
+		Defaults: echocontext.SessionDefaults{Model: "gpt-test"},
+		OnRecord: func(sessionID string, items []echocontext.ResponseItem, turns []echocontext.TurnRecord) {
+			if sessionID != "sess-rec" {
+				t.Errorf("unexpected session id %q", sessionID)
+			}
+			mu.Lock()
+			steps = append(steps, snapshot{items: len(items), turns: len(turns)})
+			mu.Unlock()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine.Start(ctx)
+	defer engine.Close()
+
+	eventsCh := engine.Events()
+	subID, err := engine.SubmitUserInput(ctx, []events.InputMessage{{Role: "user", Content: "hi"}}, events.InputContext{SessionID: "sess-rec"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	deadline := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case <-deadline:
+			t.Fatalf("timeout waiting for completion")
+		case ev := <-eventsCh:
+			if ev.SubmissionID == subID && ev.Type == events.EventTaskCompleted {
+				done = true
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(steps) < 2 || steps[0].items != 2 || steps[0].turns != 0 {
+		t.Fatalf("expected the model call to be reported before the turn completes, got %+v", steps)
+	}
+	if last := steps[len(steps)-1]; last.items != 2 || last.turns != 1 {
+		t.Fatalf("expected the finished turn to be reported, got %+v", steps)
+	}
+}
@@ -0,0 +1,87 @@
+package execution
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/events"
+)
+
+type fakeSnapshotter struct {
+	mu       sync.Mutex
+	restored []echocontext.GhostCommit
+}
+
+func (f *fakeSnapshotter) Create(ctx context.Context) (echocontext.GhostCommit, error) {
+	return echocontext.GhostCommit{ID: "created"}, nil
+}
+
+func (f *fakeSnapshotter) Restore(ctx context.Context, commit echocontext.GhostCommit) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restored = append(f.restored, commit)
+	return nil
+}
+
+func TestEngineUndoRestoresLatestSnapshot(t *testing.T) {
+	snapshots := &fakeSnapshotter{}
+	manager := events.NewManager(events.ManagerConfig{SubmissionBuffer: 8, EventBuffer: 16, Workers: 1})
+	engine := NewEngine(Options{
+		Manager:   manager,
+		Client:    fakeModelClient{},
+		Bus:       events.NewBus(),
+		Snapshots: snapshots,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine.Start(ctx)
+	defer engine.Close()
+
+	engine.SeedGhostSnapshots("sess-undo", []echocontext.GhostCommit{{ID: "first"}, {ID: "second"}})
+	eventsCh := engine.Events()
+
+	undo := func() events.UndoResult {
+		subID, err := manager.Submit(ctx, events.Submission{
+			SessionID: "sess-undo",
+			Operation: events.Operation{Kind: events.OperationUndo},
+		})
+		if err != nil {
+			t.Fatalf("submit undo: %v", err)
+		}
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case <-deadline:
+				t.Fatalf("timeout waiting for undo result")
+			case ev := <-eventsCh:
+				if ev.SubmissionID != subID || ev.Type != events.EventUndoCompleted {
+					continue
+				}
+				result, ok := ev.Payload.(events.UndoResult)
+				if !ok {
+					t.Fatalf("unexpected payload %#v", ev.Payload)
+				}
+				return result
+			}
+		}
+	}
+
+	if result := undo(); !result.Success || result.CommitID != "second" {
+		t.Fatalf("expected undo of second snapshot, got %+v", result)
+	}
+	if got := engine.GhostSnapshots("sess-undo"); len(got) != 1 || got[0].ID != "first" {
+		t.Fatalf("expected remaining snapshot first, got %+v", got)
+	}
+	if result := undo(); !result.Success || result.CommitID != "first" {
+		t.Fatalf("expected undo of first snapshot, got %+v", result)
+	}
+	if result := undo(); result.Success {
+		t.Fatalf("expected failure when no snapshot left, got %+v", result)
+	}
+	if len(snapshots.restored) != 2 {
+		t.Fatalf("expected two restores, got %+v", snapshots.restored)
+	}
+}
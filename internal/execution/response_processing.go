@@ -52,14 +52,33 @@ func ResponseInputFromToolResult(result tools.ToolResult) echocontext.ResponseIn
 }
 
 // processedFromToolResults pairs tool outputs with their ResponseInputItem.
+// view_image 读取的图片作为一条用户消息放在全部工具输出之后（tool_result 必须紧跟 tool_use）。
 func processedFromToolResults(results []tools.ToolResult) []ProcessedResponseItem {
-	items := make([]ProcessedResponseItem, 0, len(results))
+	items := make([]ProcessedResponseItem, 0, len(results)+1)
+	var images []echocontext.ContentItem
 	for _, result := range results {
 		resp := ResponseInputFromToolResult(result)
 		items = append(items, ProcessedResponseItem{
 			Item:     resp.ToResponseItem(),
 			Response: &resp,
 		})
+		if len(result.Images) == 0 {
+			continue
+		}
+		images = append(images, echocontext.ContentItem{Type: echocontext.ContentItemInputText, Text: "Image from view_image: " + result.Path})
+		for _, url := range result.Images {
+			images = append(images, echocontext.ContentItem{Type: echocontext.ContentItemInputImage, ImageURL: url})
+		}
+	}
+	if len(images) > 0 {
+		resp := echocontext.ResponseInputItem{
+			Type:    echocontext.ResponseInputTypeMessage,
+			Message: &echocontext.MessageResponseItem{Role: "user", Content: images},
+		}
+		items = append(items, ProcessedResponseItem{
+			Item:     resp.ToResponseItem(),
+			Response: &resp,
+		})
 	}
 	return items
 }
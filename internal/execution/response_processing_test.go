@@ -0,0 +1,47 @@
+package execution
+
+import (
+	"strings"
+	"testing"
+
+	"echo-cli/internal/agent"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/tools"
+)
+
+func TestProcessedFromToolResultsAppendsImagesAfterOutputs(t *testing.T) {
+	url := "data:image/png;base64,iVBORw0K"
+	items := processedFromToolResults([]tools.ToolResult{
+		{ID: "call_1", Kind: tools.ToolViewImage, Status: "completed", Path: "ui.png", Output: "attached image ui.png (image/png)", Images: []string{url}},
+		{ID: "call_2", Kind: tools.ToolFileRead, Status: "completed", Output: "package main"},
+	})
+	if len(items) != 3 {
+		t.Fatalf("expected two outputs and one image message, got %d", len(items))
+	}
+	for i, want := range []string{"call_1", "call_2"} {
+		if out := items[i].Item.FunctionCallOutput; out == nil || out.CallID != want || strings.Contains(out.Output.Content, "base64") {
+			t.Fatalf("unexpected output %d: %#v", i, items[i].Item)
+		}
+	}
+	msg := items[2].Item.Message
+	if msg == nil || msg.Role != "user" || len(msg.Content) != 2 || msg.Content[1].ImageURL != url {
+		t.Fatalf("unexpected image message %#v", items[2].Item)
+	}
+	if msg.Content[0].Type != echocontext.ContentItemInputText || !strings.Contains(msg.Content[0].Text, "ui.png") {
+		t.Fatalf("expected image caption, got %#v", msg.Content[0])
+	}
+}
+
+func TestRedactImageDataKeepsPromptIntact(t *testing.T) {
+	prompt := agent.Prompt{Messages: []agent.Message{
+		{Role: agent.RoleUser, Content: "hi"},
+		{Role: agent.RoleUser, Parts: []agent.ContentPart{{Type: agent.ContentPartImage, MediaType: "image/png", Data: "iVBORw0K"}}},
+	}}
+	redacted := redactImageData(prompt)
+	if got := redacted.Messages[1].Parts[0].Data; got != "<8 base64 bytes>" {
+		t.Fatalf("unexpected redacted data %q", got)
+	}
+	if prompt.Messages[1].Parts[0].Data != "iVBORw0K" {
+		t.Fatalf("redaction must not modify the prompt sent to the model")
+	}
+}
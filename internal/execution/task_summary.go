@@ -8,6 +8,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"echo-cli/internal/agent"
 	echocontext "echo-cli/internal/context"
 	"echo-cli/internal/events"
 	"echo-cli/internal/tools"
@@ -22,6 +23,8 @@ type turnSummaryInput struct {
 	ExitReason   string
 	ExitStage    string
 	Err          error
+	// Usage 是本任务内各次模型请求的用量合计；为空时按输入输出文本估算。
+	Usage agent.TokenUsage
 }
 
 func buildTurnSummary(args turnSummaryInput) events.TaskSummary {
@@ -32,6 +35,12 @@ func buildTurnSummary(args turnSummaryInput) events.TaskSummary {
 	status := taskSummaryStatus(args.Err)
 	inputTokens := countApproxTokensFromInput(args.Submission)
 	outputTokens := countApproxTokens(args.FinalContent)
+	usage := args.Usage
+	hasUsage := usage.TotalInputTokens()+usage.OutputTokens > 0
+	if hasUsage {
+		inputTokens = usage.InputTokens
+		outputTokens = usage.OutputTokens
+	}
 
 	summaryText := formatTurnSummaryText(turnSummaryTextArgs{
 		Status:       status,
@@ -41,6 +50,9 @@ func buildTurnSummary(args turnSummaryInput) events.TaskSummary {
 		ExitStage:    args.ExitStage,
 		Err:          args.Err,
 	})
+	if hasUsage {
+		summaryText += "\n" + formatUsageLine(usage)
+	}
 
 	return events.TaskSummary{
 		Status:     status,
@@ -52,11 +64,20 @@ func buildTurnSummary(args turnSummaryInput) events.TaskSummary {
 		Model:      args.TurnCtx.Model,
 
 		InputTokens:       inputTokens,
-		CachedInputTokens: 0,
+		CachedInputTokens: usage.CacheCreationInputTokens + usage.CacheReadInputTokens,
 		OutputTokens:      outputTokens,
+
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		CacheHitRate:             usage.CacheHitRate(),
 	}
 }
 
+func formatUsageLine(usage agent.TokenUsage) string {
+	return fmt.Sprintf("用量：输入 %d tokens（缓存命中 %.0f%%，写入缓存 %d）· 输出 %d tokens",
+		usage.TotalInputTokens(), usage.CacheHitRate()*100, usage.CacheCreationInputTokens, usage.OutputTokens)
+}
+
 func taskSummaryStatus(err error) string {
 	if err == nil {
 		return "completed"
@@ -227,12 +248,16 @@ func formatToolSuccess(res tools.ToolResult) string {
 			path = "<unknown>"
 		}
 		return fmt.Sprintf("读取文件：`%s`", path)
+	case tools.ToolViewImage:
+		return fmt.Sprintf("查看图片：`%s`", strings.TrimSpace(res.Path))
 	case tools.ToolSearch:
 		count := countOutputLines(res.Output)
 		if count > 0 {
 			return fmt.Sprintf("扫描文件（%d 条）", count)
 		}
 		return "扫描文件"
+	case tools.ToolGrep:
+		return fmt.Sprintf("搜索内容：`%s`", strings.TrimSpace(res.Query))
 	case tools.ToolPlanUpdate:
 		if count := len(res.Plan); count > 0 {
 			return fmt.Sprintf("更新计划（%d 项）", count)
@@ -0,0 +1,33 @@
+package execution
+
+import (
+	"strings"
+	"testing"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/events"
+)
+
+func TestBuildTurnSummaryReportsProviderUsageAndCacheHitRate(t *testing.T) {
+	summary := buildTurnSummary(turnSummaryInput{
+		FinalContent: "done",
+		Usage:        agent.TokenUsage{InputTokens: 100, CacheCreationInputTokens: 300, CacheReadInputTokens: 600, OutputTokens: 40},
+	})
+	if summary.InputTokens != 100 || summary.CachedInputTokens != 900 || summary.OutputTokens != 40 {
+		t.Fatalf("unexpected token counts %+v", summary)
+	}
+	if summary.CacheReadInputTokens != 600 || summary.CacheCreationInputTokens != 300 || summary.CacheHitRate != 0.6 {
+		t.Fatalf("unexpected cache usage %+v", summary)
+	}
+	if !strings.Contains(summary.Text, "缓存命中 60%") {
+		t.Fatalf("expected cache hit rate in summary text, got:\n%s", summary.Text)
+	}
+
+	estimated := buildTurnSummary(turnSummaryInput{
+		Submission:   events.Submission{Operation: events.Operation{UserInput: &events.UserInputOperation{Items: []events.InputMessage{{Role: "user", Content: "hello there"}}}}},
+		FinalContent: "done",
+	})
+	if estimated.InputTokens == 0 || estimated.CacheHitRate != 0 || strings.Contains(estimated.Text, "用量") {
+		t.Fatalf("expected estimated usage without cache line, got %+v", estimated)
+	}
+}
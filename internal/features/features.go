@@ -24,7 +24,7 @@ var Specs = []Spec{
 	{Key: "view_image_tool", Stage: StageStable, DefaultEnabled: true},
 	{Key: "shell_tool", Stage: StageStable, DefaultEnabled: true},
 	{Key: "unified_exec", Stage: StageExperimental, DefaultEnabled: false},
-	{Key: "rmcp_client", Stage: StageExperimental, DefaultEnabled: false},
+	{Key: "rmcp_client", Stage: StageBeta, DefaultEnabled: true},
 	{Key: "apply_patch_freeform", Stage: StageBeta, DefaultEnabled: false},
 	{Key: "web_search_request", Stage: StageStable, DefaultEnabled: false},
 	{Key: "remote_compaction", Stage: StageExperimental, DefaultEnabled: true},
@@ -0,0 +1,194 @@
+package ghost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	echocontext "echo-cli/internal/context"
+)
+
+// ErrNotGitRepository 表示工作目录不在 git 仓库内，无法创建快照。
+var ErrNotGitRepository = errors.New("workdir is not inside a git repository")
+
+const snapshotMessage = "echo-cli ghost snapshot"
+
+// Snapshotter 基于 git 对象库为工作区创建“幽灵提交”（不挂任何 ref、不改动真实 index），
+// 用于 /undo 把工作区恢复到某一轮修改之前的状态。
+type Snapshotter struct {
+	Workdir string
+}
+
+// Create 将当前工作区（包含未被忽略的未跟踪文件）写入一个 ghost commit 并返回其描述。
+func (s Snapshotter) Create(ctx context.Context) (echocontext.GhostCommit, error) {
+	root, err := s.repoRoot(ctx)
+	if err != nil {
+		return echocontext.GhostCommit{}, err
+	}
+	index, cleanup, err := tempIndex()
+	if err != nil {
+		return echocontext.GhostCommit{}, err
+	}
+	defer cleanup()
+
+	parent, _ := git(ctx, root, nil, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	parent = strings.TrimSpace(parent)
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if parent != "" {
+		// 先载入 HEAD 的 tree，git add 只需处理变更的文件。
+		if _, err := git(ctx, root, env, "read-tree", parent); err != nil {
+			return echocontext.GhostCommit{}, err
+		}
+	}
+	if _, err := git(ctx, root, env, "add", "--all", "--", "."); err != nil {
+		return echocontext.GhostCommit{}, err
+	}
+	tree, err := git(ctx, root, env, "write-tree")
+	if err != nil {
+		return echocontext.GhostCommit{}, err
+	}
+	args := []string{"commit-tree", strings.TrimSpace(tree), "-m", snapshotMessage}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	id, err := git(ctx, root, append(env, identityEnv()...), args...)
+	if err != nil {
+		return echocontext.GhostCommit{}, err
+	}
+	return echocontext.GhostCommit{ID: strings.TrimSpace(id), Parent: parent}, nil
+}
+
+// Restore 将工作区恢复为 ghost commit 记录的内容：
+// 快照之后新增的文件会被删除，快照中的文件会被覆盖回当时的内容；真实 index 与 HEAD 不受影响。
+func (s Snapshotter) Restore(ctx context.Context, commit echocontext.GhostCommit) error {
+	id := strings.TrimSpace(commit.ID)
+	if id == "" {
+		return errors.New("ghost commit id required")
+	}
+	root, err := s.repoRoot(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := git(ctx, root, nil, "cat-file", "-e", id+"^{commit}"); err != nil {
+		return fmt.Errorf("ghost commit %s not found: %w", shortID(id), err)
+	}
+
+	snapshotFiles, err := gitPaths(ctx, root, nil, "ls-tree", "-r", "-z", "--name-only", id)
+	if err != nil {
+		return err
+	}
+	currentFiles, err := gitPaths(ctx, root, nil, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]struct{}, len(snapshotFiles))
+	for _, p := range snapshotFiles {
+		keep[p] = struct{}{}
+	}
+	for _, p := range currentFiles {
+		if _, ok := keep[p]; ok {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", p, err)
+		}
+		removeEmptyParents(root, filepath.Dir(target))
+	}
+
+	index, cleanup, err := tempIndex()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	env := []string{"GIT_INDEX_FILE=" + index}
+	if _, err := git(ctx, root, env, "read-tree", id); err != nil {
+		return err
+	}
+	if _, err := git(ctx, root, env, "checkout-index", "--all", "--force"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s Snapshotter) repoRoot(ctx context.Context) (string, error) {
+	dir := s.Workdir
+	if strings.TrimSpace(dir) == "" {
+		dir = "."
+	}
+	out, err := git(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", ErrNotGitRepository
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func git(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return stdout.String(), fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+func gitPaths(ctx context.Context, dir string, env []string, args ...string) ([]string, error) {
+	out, err := git(ctx, dir, env, args...)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range strings.Split(out, "\x00") {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+func tempIndex() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "echo-ghost-")
+	if err != nil {
+		return "", func() {}, err
+	}
+	return filepath.Join(dir, "index"), func() { _ = os.RemoveAll(dir) }, nil
+}
+
+// identityEnv 固定 ghost commit 的作者信息，保证在未配置 user.name/user.email 的机器上 commit-tree 也能成功。
+func identityEnv() []string {
+	return []string{
+		"GIT_AUTHOR_NAME=echo-cli",
+		"GIT_AUTHOR_EMAIL=echo-cli@localhost",
+		"GIT_COMMITTER_NAME=echo-cli",
+		"GIT_COMMITTER_EMAIL=echo-cli@localhost",
+	}
+}
+
+func removeEmptyParents(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,81 @@
+package ghost
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), identityEnv()...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestSnapshotRestoresWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("tracked.txt", "v1\n")
+	runGit(t, dir, "add", "tracked.txt")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	write("untracked.txt", "keep me\n")
+
+	ctx := context.Background()
+	snap := Snapshotter{Workdir: dir}
+	commit, err := snap.Create(ctx)
+	if err != nil {
+		t.Fatalf("create snapshot: %v", err)
+	}
+	if commit.ID == "" || commit.Parent == "" {
+		t.Fatalf("expected commit and parent ids, got %+v", commit)
+	}
+
+	write("tracked.txt", "v2\n")
+	write("nested/new.txt", "new\n")
+	if err := os.Remove(filepath.Join(dir, "untracked.txt")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	if err := snap.Restore(ctx, commit); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "tracked.txt")); string(data) != "v1\n" {
+		t.Fatalf("tracked.txt not restored: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "untracked.txt")); string(data) != "keep me\n" {
+		t.Fatalf("untracked.txt not restored: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nested")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected nested/ to be removed, stat err=%v", err)
+	}
+}
+
+func TestSnapshotOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	_, err := Snapshotter{Workdir: t.TempDir()}.Create(context.Background())
+	if !errors.Is(err, ErrNotGitRepository) {
+		t.Fatalf("expected ErrNotGitRepository, got %v", err)
+	}
+}
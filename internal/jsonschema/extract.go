@@ -0,0 +1,59 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ExtractJSON 从模型回复中取出 JSON 值并压缩为单行：依次尝试整段文本、``` 代码块，
+// 以及首个 { 或 [ 到最后一个 } 或 ] 之间的内容。
+func ExtractJSON(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("the reply is empty")
+	}
+	candidates := []string{text}
+	if block, ok := fencedBlock(text); ok {
+		candidates = append(candidates, block)
+	}
+	if start := strings.IndexAny(text, "{["); start >= 0 {
+		if end := strings.LastIndexAny(text, "}]"); end > start {
+			candidates = append(candidates, text[start:end+1])
+		}
+	}
+	var firstErr error
+	for _, candidate := range candidates {
+		if _, err := decode([]byte(candidate)); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(candidate)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, errors.New("the reply is not valid JSON: " + firstErr.Error())
+}
+
+func fencedBlock(text string) (string, bool) {
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return "", false
+	}
+	body := text[start+3:]
+	newline := strings.IndexByte(body, '\n')
+	if newline < 0 {
+		return "", false
+	}
+	body = body[newline+1:]
+	end := strings.Index(body, "```")
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(body[:end]), true
+}
@@ -0,0 +1,563 @@
+// Package jsonschema 实现 exec --output-schema 使用的 JSON Schema 校验。
+// 覆盖结构化输出常用的关键字（draft-07 / 2020-12）：类型、枚举、对象/数组结构、
+// 字符串与数值约束、组合关键字以及文档内 $ref；format 等注解类关键字被忽略。
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema 是编译后的 JSON Schema 文档。
+type Schema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// ValidationError 描述实例中某个位置（JSON Pointer）不满足的约束。
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + e.Message
+}
+
+// Compile 解析 schema 文档并预编译其中的正则。
+func Compile(data []byte) (*Schema, error) {
+	root, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, errors.New("schema must be an object or a boolean")
+	}
+	s := &Schema{root: root, patterns: map[string]*regexp.Regexp{}}
+	if err := s.compilePatterns(root); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate 校验 JSON 文本，返回按位置排序的错误；文本不是合法 JSON 时返回 error。
+func (s *Schema) Validate(data []byte) ([]ValidationError, error) {
+	value, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.ValidateValue(value), nil
+}
+
+// ValidateValue 校验已解码的值（数字需以 json.Number 或 float64 表示）。
+func (s *Schema) ValidateValue(value any) []ValidationError {
+	var errs []ValidationError
+	s.validate(s.root, value, "", &errs, 0)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// 以下关键字的值是子 schema（单个、数组或以名称为 key 的 map）；compilePatterns 只沿这些关键字递归，
+// 因此 enum/const/default 等数据值、以及恰好名为这些关键字的属性不会被当作 schema 处理。
+var (
+	schemaKeywords    = []string{"items", "additionalItems", "additionalProperties", "contains", "not", "if", "then", "else", "propertyNames"}
+	schemaListKeyword = []string{"prefixItems", "allOf", "anyOf", "oneOf"}
+	schemaMapKeywords = []string{"properties", "patternProperties", "$defs", "definitions", "dependentSchemas"}
+)
+
+// compilePatterns 校验 schema 结构（组合关键字必须是非空数组）并预编译 pattern / patternProperties 中的正则。
+func (s *Schema) compilePatterns(node any) error {
+	n, ok := node.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if p, ok := n["pattern"].(string); ok {
+		if err := s.addPattern(p); err != nil {
+			return err
+		}
+	}
+	if props, ok := n["patternProperties"].(map[string]any); ok {
+		for p := range props {
+			if err := s.addPattern(p); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range schemaKeywords {
+		if child, ok := n[key]; ok {
+			if err := s.compilePatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+	// draft-07 的数组形式 items 已在上面按单个值处理，这里补上逐项递归。
+	if tuple, ok := n["items"].([]any); ok {
+		for _, child := range tuple {
+			if err := s.compilePatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range schemaListKeyword {
+		raw, ok := n[key]
+		if !ok {
+			continue
+		}
+		list, ok := raw.([]any)
+		if !ok || (len(list) == 0 && key != "prefixItems") {
+			return fmt.Errorf("%s must be a non-empty array of schemas", key)
+		}
+		for _, child := range list {
+			if err := s.compilePatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range schemaMapKeywords {
+		children, _ := n[key].(map[string]any)
+		for _, child := range children {
+			if err := s.compilePatterns(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) addPattern(p string) error {
+	if _, ok := s.patterns[p]; ok {
+		return nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", p, err)
+	}
+	s.patterns[p] = re
+	return nil
+}
+
+// match 用预编译的正则匹配 v；正则不在缓存中（例如只经由非常规位置的 $ref 可达）时现场编译，
+// 无法编译时返回错误而不是 panic。
+func (s *Schema) match(p, v string) (bool, error) {
+	re := s.patterns[p]
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(p); err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return re.MatchString(v), nil
+}
+
+// maxRefDepth 防止自引用的 $ref 无限递归。
+const maxRefDepth = 64
+
+func (s *Schema) validate(node any, value any, path string, errs *[]ValidationError, depth int) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	switch n := node.(type) {
+	case bool:
+		if !n {
+			fail("no value is allowed here")
+		}
+		return
+	case map[string]any:
+		if ref, ok := n["$ref"].(string); ok {
+			target, err := s.resolve(ref)
+			if err != nil {
+				fail("%v", err)
+				return
+			}
+			if depth >= maxRefDepth {
+				fail("$ref %s nests too deeply", ref)
+				return
+			}
+			s.validate(target, value, path, errs, depth+1)
+		}
+		s.validateObjectSchema(n, value, path, errs, depth)
+	}
+}
+
+func (s *Schema) validateObjectSchema(n map[string]any, value any, path string, errs *[]ValidationError, depth int) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if t, ok := n["type"]; ok && !matchesType(t, value) {
+		fail("expected %s, got %s", describeType(t), typeName(value))
+		return
+	}
+	if enum, ok := n["enum"].([]any); ok && !containsValue(enum, value) {
+		fail("value %s is not one of %s", compact(value), compact(enum))
+	}
+	if c, ok := n["const"]; ok && !equal(c, value) {
+		fail("value %s must equal %s", compact(value), compact(c))
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		subs, ok := n[key].([]any)
+		if !ok {
+			continue
+		}
+		matched := 0
+		var firstErrs []ValidationError
+		for _, sub := range subs {
+			var subErrs []ValidationError
+			s.validate(sub, value, path, &subErrs, depth)
+			if len(subErrs) == 0 {
+				matched++
+			} else if firstErrs == nil {
+				firstErrs = subErrs
+			}
+			if key == "allOf" {
+				*errs = append(*errs, subErrs...)
+			}
+		}
+		switch {
+		case key == "anyOf" && matched == 0 && len(firstErrs) > 0:
+			fail("value does not match any schema in anyOf (first mismatch: %s)", firstErrs[0].Error())
+		case key == "anyOf" && matched == 0:
+			fail("value does not match any schema in anyOf")
+		case key == "oneOf" && matched != 1:
+			fail("value must match exactly one schema in oneOf, matched %d", matched)
+		}
+	}
+	if not, ok := n["not"]; ok {
+		var subErrs []ValidationError
+		s.validate(not, value, path, &subErrs, depth)
+		if len(subErrs) == 0 {
+			fail("value must not match the schema in not")
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(n, v, path, errs, depth)
+	case []any:
+		s.validateArray(n, v, path, errs, depth)
+	case string:
+		length := utf8.RuneCountInString(v)
+		if min, ok := number(n["minLength"]); ok && float64(length) < min {
+			fail("string is shorter than %s characters", formatNumber(min))
+		}
+		if max, ok := number(n["maxLength"]); ok && float64(length) > max {
+			fail("string is longer than %s characters", formatNumber(max))
+		}
+		if p, ok := n["pattern"].(string); ok {
+			if matched, err := s.match(p, v); err != nil {
+				fail("%v", err)
+			} else if !matched {
+				fail("string does not match pattern %q", p)
+			}
+		}
+	case json.Number, float64:
+		f, _ := number(v)
+		if min, ok := number(n["minimum"]); ok && f < min {
+			fail("%s is less than the minimum %s", formatNumber(f), formatNumber(min))
+		}
+		if max, ok := number(n["maximum"]); ok && f > max {
+			fail("%s is greater than the maximum %s", formatNumber(f), formatNumber(max))
+		}
+		if min, ok := number(n["exclusiveMinimum"]); ok && f <= min {
+			fail("%s must be greater than %s", formatNumber(f), formatNumber(min))
+		}
+		if max, ok := number(n["exclusiveMaximum"]); ok && f >= max {
+			fail("%s must be less than %s", formatNumber(f), formatNumber(max))
+		}
+		if m, ok := number(n["multipleOf"]); ok && m > 0 {
+			if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("%s is not a multiple of %s", formatNumber(f), formatNumber(m))
+			}
+		}
+	}
+}
+
+func (s *Schema) validateObject(n map[string]any, obj map[string]any, path string, errs *[]ValidationError, depth int) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if required, ok := n["required"].([]any); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				fail("missing required property %q", name)
+			}
+		}
+	}
+	if min, ok := number(n["minProperties"]); ok && float64(len(obj)) < min {
+		fail("object has fewer than %s properties", formatNumber(min))
+	}
+	if max, ok := number(n["maxProperties"]); ok && float64(len(obj)) > max {
+		fail("object has more than %s properties", formatNumber(max))
+	}
+	props, _ := n["properties"].(map[string]any)
+	patternProps, _ := n["patternProperties"].(map[string]any)
+	additional, hasAdditional := n["additionalProperties"]
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := path + "/" + escapePointer(key)
+		matched := false
+		if sub, ok := props[key]; ok {
+			matched = true
+			s.validate(sub, obj[key], child, errs, depth)
+		}
+		for p, sub := range patternProps {
+			if ok, err := s.match(p, key); err != nil {
+				fail("%v", err)
+			} else if ok {
+				matched = true
+				s.validate(sub, obj[key], child, errs, depth)
+			}
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			fail("unexpected property %q", key)
+			continue
+		}
+		s.validate(additional, obj[key], child, errs, depth)
+	}
+}
+
+func (s *Schema) validateArray(n map[string]any, arr []any, path string, errs *[]ValidationError, depth int) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if min, ok := number(n["minItems"]); ok && float64(len(arr)) < min {
+		fail("array has fewer than %s items", formatNumber(min))
+	}
+	if max, ok := number(n["maxItems"]); ok && float64(len(arr)) > max {
+		fail("array has more than %s items", formatNumber(max))
+	}
+	if unique, _ := n["uniqueItems"].(bool); unique {
+		for i := 1; i < len(arr); i++ {
+			for j := 0; j < i; j++ {
+				if equal(arr[i], arr[j]) {
+					fail("items %d and %d are equal", j, i)
+				}
+			}
+		}
+	}
+	// prefixItems（2020-12）或数组形式的 items（draft-07）按位置校验，其余元素由 items / additionalItems 校验。
+	prefix, _ := n["prefixItems"].([]any)
+	rest, hasRest := n["items"]
+	if tuple, ok := rest.([]any); ok {
+		prefix = tuple
+		rest, hasRest = n["additionalItems"]
+	}
+	for i, item := range arr {
+		child := path + "/" + strconv.Itoa(i)
+		if i < len(prefix) {
+			s.validate(prefix[i], item, child, errs, depth)
+			continue
+		}
+		if hasRest {
+			s.validate(rest, item, child, errs, depth)
+		}
+	}
+	if contains, ok := n["contains"]; ok {
+		found := false
+		for _, item := range arr {
+			var subErrs []ValidationError
+			s.validate(contains, item, path, &subErrs, depth)
+			if len(subErrs) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("array does not contain a matching item")
+		}
+	}
+}
+
+// resolve 解析文档内引用（#、#/$defs/x、#/definitions/x 等 JSON Pointer）。
+func (s *Schema) resolve(ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q (only local references are supported)", ref)
+	}
+	if unescaped, err := url.PathUnescape(pointer); err == nil {
+		pointer = unescaped
+	}
+	node := s.root
+	if pointer == "" {
+		return node, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]any:
+			next, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("$ref %q not found", ref)
+			}
+			node = next
+		case []any:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(n) {
+				return nil, fmt.Errorf("$ref %q not found", ref)
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+	return node, nil
+}
+
+func matchesType(t any, value any) bool {
+	switch tt := t.(type) {
+	case string:
+		return isType(tt, value)
+	case []any:
+		for _, item := range tt {
+			if name, ok := item.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func isType(name string, value any) bool {
+	switch name {
+	case "integer":
+		f, ok := number(value)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := number(value)
+		return ok
+	default:
+		return typeName(value) == name
+	}
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number, float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func describeType(t any) string {
+	if list, ok := t.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, item := range list {
+			names = append(names, fmt.Sprint(item))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// normalize 把 json.Number 统一为 float64，使 1 与 1.0 比较相等。
+func normalize(v any) any {
+	switch n := v.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case []any:
+		out := make([]any, len(n))
+		for i, item := range n {
+			out[i] = normalize(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(n))
+		for k, item := range n {
+			out[k] = normalize(item)
+		}
+		return out
+	}
+	return v
+}
+
+func equal(a, b any) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func containsValue(list []any, value any) bool {
+	for _, item := range list {
+		if equal(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compact(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if len(data) > 80 {
+		return string(data[:77]) + "..."
+	}
+	return string(data)
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
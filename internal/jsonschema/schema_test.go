@@ -0,0 +1,129 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const reportSchema = `{
+  "type": "object",
+  "required": ["status", "findings"],
+  "additionalProperties": false,
+  "properties": {
+    "status": {"enum": ["pass", "fail"]},
+    "score": {"type": "integer", "minimum": 0, "maximum": 100},
+    "findings": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/finding"}}
+  },
+  "$defs": {
+    "finding": {
+      "type": "object",
+      "required": ["file"],
+      "properties": {
+        "file": {"type": "string", "pattern": "\\.go$"},
+        "line": {"type": ["integer", "null"]}
+      }
+    }
+  }
+}`
+
+func TestValidateAcceptsConformingValue(t *testing.T) {
+	schema, err := Compile([]byte(reportSchema))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	errs, err := schema.Validate([]byte(`{"status":"pass","score":90,"findings":[{"file":"main.go","line":null}]}`))
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("expected valid, got %v %v", errs, err)
+	}
+}
+
+func TestValidateReportsEveryViolationWithPath(t *testing.T) {
+	schema, err := Compile([]byte(reportSchema))
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	errs, err := schema.Validate([]byte(`{"status":"unknown","score":1.5,"findings":[{"file":"main.py","line":"3"}],"extra":true}`))
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	joined := strings.Join(got, "\n")
+	for _, want := range []string{
+		`/status: value "unknown" is not one of ["pass","fail"]`,
+		`/: unexpected property "extra"`,
+		`/findings/0/file: string does not match pattern`,
+		`/findings/0/line: expected integer or null, got string`,
+		`/score: expected integer, got number`,
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("missing %q in:\n%s", want, joined)
+		}
+	}
+	if errs, _ := schema.Validate([]byte(`{"findings":[]}`)); len(errs) != 2 {
+		t.Fatalf("expected missing status and minItems errors, got %v", errs)
+	}
+}
+
+func TestCompileRejectsInvalidSchemas(t *testing.T) {
+	if _, err := Compile([]byte(`{"type":"string","pattern":"("}`)); err == nil {
+		t.Fatalf("expected invalid pattern error")
+	}
+	if _, err := Compile([]byte(`[1]`)); err == nil {
+		t.Fatalf("expected non-object schema error")
+	}
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		if _, err := Compile([]byte(`{"` + key + `":[]}`)); err == nil {
+			t.Fatalf("expected empty %s to be rejected", key)
+		}
+	}
+}
+
+func TestPropertiesNamedLikeKeywordsAreCompiled(t *testing.T) {
+	schema, err := Compile([]byte(`{"properties":{"enum":{"type":"string","pattern":"^a"},"const":{"patternProperties":{"^x":{"type":"integer"}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := schema.Validate([]byte(`{"enum":"b","const":{"xy":"no"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(errs))
+	for i, e := range errs {
+		got[i] = e.Error()
+	}
+	if len(errs) != 2 || !strings.Contains(got[0], "expected integer") || !strings.Contains(got[1], `pattern "^a"`) {
+		t.Fatalf("unexpected errors %q", got)
+	}
+}
+
+func TestPatternReachedOnlyThroughRefDoesNotPanic(t *testing.T) {
+	// enum 中的值不会被预编译；通过 $ref 指向它时应现场编译而不是 panic。
+	schema, err := Compile([]byte(`{"enum":[{"pattern":"^a"}],"properties":{"name":{"$ref":"#/enum/0"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := schema.ValidateValue(map[string]any{"name": "b"})
+	if len(errs) == 0 || !strings.Contains(errs[len(errs)-1].Error(), "does not match pattern") {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"a": 1}`:                      `{"a":1}`,
+		"```json\n{\"a\": [1, 2]}\n```": `{"a":[1,2]}`,
+		"Here is the result:\n{\"ok\": true}\nDone.": `{"ok":true}`,
+	}
+	for in, want := range cases {
+		got, err := ExtractJSON(in)
+		if err != nil || string(got) != want {
+			t.Fatalf("ExtractJSON(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	if _, err := ExtractJSON("no json here"); err == nil {
+		t.Fatalf("expected error for plain text")
+	}
+}
@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ClientInfo 是 echo-cli 在 initialize 中上报的客户端信息。
+var ClientInfo = Implementation{Name: "echo-cli", Version: "0.1.0"}
+
+// Client 是单个 MCP 服务器的会话：完成握手后可列出与调用工具。
+type Client struct {
+	transport Transport
+	server    InitializeResult
+}
+
+// Connect 在已建立的传输上完成 initialize 握手。
+func Connect(ctx context.Context, transport Transport) (*Client, error) {
+	raw, err := transport.Call(ctx, "initialize", InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      ClientInfo,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	var init InitializeResult
+	if err := json.Unmarshal(raw, &init); err != nil {
+		return nil, fmt.Errorf("decode initialize result: %w", err)
+	}
+	if err := transport.Notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, fmt.Errorf("initialized notification: %w", err)
+	}
+	return &Client{transport: transport, server: init}, nil
+}
+
+// ServerInfo 返回握手时服务器上报的信息。
+func (c *Client) ServerInfo() InitializeResult { return c.server }
+
+// ListTools 列出服务器全部工具（自动翻页）。
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var out []Tool
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		raw, err := c.transport.Call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		var page ListToolsResult
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("decode tools/list: %w", err)
+		}
+		out = append(out, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return out, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool 调用工具；arguments 为空时发送空对象。
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (CallToolResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	raw, err := c.transport.Call(ctx, "tools/call", CallToolParams{Name: name, Arguments: arguments})
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	var result CallToolResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return CallToolResult{}, fmt.Errorf("decode tools/call: %w", err)
+	}
+	return result, nil
+}
+
+// Ping 检查服务器是否仍可响应。
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.transport.Call(ctx, "ping", nil)
+	return err
+}
+
+// Err 返回底层传输的失效原因。
+func (c *Client) Err() error { return c.transport.Err() }
+
+// Close 关闭底层传输。
+func (c *Client) Close() error { return c.transport.Close() }
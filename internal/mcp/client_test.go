@@ -0,0 +1,241 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"echo-cli/internal/config"
+	"echo-cli/internal/tools"
+)
+
+// fakeServer 实现一个最小的 MCP 服务器：两页工具列表 + echo 工具。
+func fakeServer(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return InitializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "fake", Version: "1.0"}}, nil
+	case "tools/list":
+		var p struct {
+			Cursor string `json:"cursor"`
+		}
+		_ = json.Unmarshal(params, &p)
+		if p.Cursor == "" {
+			return ListToolsResult{Tools: []Tool{{Name: "echo", Description: "echo text", InputSchema: map[string]any{"type": "object"}}}, NextCursor: "page2"}, nil
+		}
+		readOnly := true
+		return ListToolsResult{Tools: []Tool{{Name: "fail", Annotations: &ToolAnnotations{ReadOnlyHint: &readOnly}}}}, nil
+	case "tools/call":
+		var p CallToolParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		if p.Name == "fail" {
+			return CallToolResult{Content: []Content{TextContent("boom")}, IsError: true}, nil
+		}
+		var args struct {
+			Text string `json:"text"`
+		}
+		_ = json.Unmarshal(p.Arguments, &args)
+		return CallToolResult{Content: []Content{TextContent("echo: " + args.Text)}}, nil
+	}
+	return nil, &RPCError{Code: CodeMethodNotFound, Message: method}
+}
+
+func TestClientOverConn(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	server := NewConn(serverR, serverW, ConnOptions{OnRequest: fakeServer})
+	defer server.Close()
+	conn := NewConn(clientR, clientW, ConnOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	client, err := Connect(ctx, conn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if client.ServerInfo().ServerInfo.Name != "fake" {
+		t.Fatalf("unexpected server info %+v", client.ServerInfo())
+	}
+	list, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "echo" || list[1].Name != "fail" {
+		t.Fatalf("expected both pages of tools, got %+v", list)
+	}
+	res, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil {
+		t.Fatalf("call tool: %v", err)
+	}
+	if got := FormatContent(res); got != "echo: hi" {
+		t.Fatalf("unexpected tool output %q", got)
+	}
+	if _, err := conn.Call(ctx, "unknown/method", nil); err == nil {
+		t.Fatalf("expected method not found error")
+	}
+}
+
+func TestHTTPTransportSessionAndSSE(t *testing.T) {
+	var sawSession bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		var msg Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if msg.IsNotification() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if msg.Method != "initialize" && r.Header.Get(sessionHeader) == "sess-1" {
+			sawSession = true
+		}
+		result, err := fakeServer(r.Context(), msg.Method, msg.Params)
+		resp := Message{JSONRPC: jsonRPCVersion, ID: msg.ID}
+		if err != nil {
+			resp.Error = err.(*RPCError)
+		} else {
+			resp.Result, _ = json.Marshal(result)
+		}
+		data, _ := json.Marshal(resp)
+		if msg.Method == "initialize" {
+			w.Header().Set(sessionHeader, "sess-1")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	}))
+	defer srv.Close()
+
+	transport, err := NewHTTPTransport(HTTPConfig{URL: srv.URL}, ConnOptions{})
+	if err != nil {
+		t.Fatalf("new transport: %v", err)
+	}
+	ctx := context.Background()
+	client, err := Connect(ctx, transport)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	res, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"sse"}`))
+	if err != nil {
+		t.Fatalf("call tool: %v", err)
+	}
+	if got := FormatContent(res); got != "echo: sse" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	if !sawSession {
+		t.Fatalf("expected Mcp-Session-Id to be sent after initialize")
+	}
+}
+
+func TestManagerRegistersStdioServerTools(t *testing.T) {
+	manager := ConnectAll(context.Background(), map[string]config.MCPServerConfig{
+		"fake": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestHelperStdioServer"},
+			Env:     map[string]string{"ECHO_MCP_HELPER": "1"},
+		},
+		"broken": {},
+	})
+	defer manager.Close()
+
+	statuses := manager.Status()
+	if len(statuses) != 2 {
+		t.Fatalf("expected two servers, got %+v", statuses)
+	}
+	if statuses[0].Name != "broken" || statuses[0].Connected || statuses[0].Error == "" {
+		t.Fatalf("expected broken server to report an error, got %+v", statuses[0])
+	}
+	if !statuses[1].Connected || len(statuses[1].Tools) != 2 {
+		t.Fatalf("expected fake server with two tools, got %+v", statuses[1])
+	}
+
+	specs := manager.ToolSpecs()
+	if len(specs) != 2 || specs[0].Name != "mcp__fake__echo" {
+		t.Fatalf("unexpected tool specs %+v", specs)
+	}
+	registry := tools.NewRegistry(manager.Handlers()...)
+	handler, ok := registry.Handler("mcp__fake__echo")
+	if !ok {
+		t.Fatalf("mcp tool not registered")
+	}
+	res, err := handler.Handle(context.Background(), tools.Invocation{Call: tools.ToolCall{ID: "c1", Name: "mcp__fake__echo", Payload: json.RawMessage(`{"text":"x"}`)}})
+	if err != nil || res.Status != "completed" || res.Output != "echo: x" {
+		t.Fatalf("unexpected result %+v err=%v", res, err)
+	}
+	failing, _ := registry.Handler("mcp__fake__fail")
+	if failing.IsMutating(tools.Invocation{}) {
+		t.Fatalf("readOnlyHint tool should not be mutating")
+	}
+	res, _ = failing.Handle(context.Background(), tools.Invocation{Call: tools.ToolCall{ID: "c2", Name: "mcp__fake__fail"}})
+	if res.Status != "error" || res.Error != "boom" {
+		t.Fatalf("expected tool error to surface, got %+v", res)
+	}
+	if !strings.Contains(FormatStatus(statuses), "mcp__fake__echo") {
+		t.Fatalf("status output should list qualified tool names")
+	}
+}
+
+func TestQualifiedToolName(t *testing.T) {
+	if got := QualifiedToolName("my server", "do.thing"); got != "mcp__my_server__do_thing" {
+		t.Fatalf("unexpected name %q", got)
+	}
+	long := QualifiedToolName(strings.Repeat("s", 40), strings.Repeat("t", 40))
+	if len(long) != toolNameMaxLen {
+		t.Fatalf("expected name truncated to %d chars, got %d (%s)", toolNameMaxLen, len(long), long)
+	}
+}
+
+func TestStdioTransportReportsExitAfterSlowShutdown(t *testing.T) {
+	transport, err := StartStdio(StdioConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=TestHelperStdioServer"},
+		Env:     map[string]string{"ECHO_MCP_HELPER": "linger"},
+	}, ConnOptions{})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer transport.Close()
+	// 服务器关闭 stdout 后才退出：exitError 先超时返回，wait 之后才写入退出状态。
+	_, err = transport.Call(context.Background(), "initialize", InitializeParams{ProtocolVersion: ProtocolVersion})
+	if !errors.Is(err, ErrConnClosed) || !strings.Contains(err.Error(), "closing stdout") {
+		t.Fatalf("expected closed connection with stderr tail, got %v", err)
+	}
+	<-transport.exited
+	if err := transport.Err(); !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("expected exit status after the server exited, got %v", err)
+	}
+}
+
+// TestHelperStdioServer 在子进程中充当 stdio MCP 服务器；ECHO_MCP_HELPER=linger 时关闭 stdout 后稍等再退出。
+func TestHelperStdioServer(t *testing.T) {
+	switch os.Getenv("ECHO_MCP_HELPER") {
+	case "1":
+		conn := NewConn(os.Stdin, os.Stdout, ConnOptions{OnRequest: fakeServer})
+		<-conn.Done()
+		os.Exit(0)
+	case "linger":
+		fmt.Fprintln(os.Stderr, "closing stdout")
+		os.Stdout.Close()
+		time.Sleep(400 * time.Millisecond)
+		os.Exit(3)
+	}
+	t.Skip("helper process")
+}
@@ -0,0 +1,278 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrConnClosed 表示连接已关闭或对端已退出。
+var ErrConnClosed = errors.New("mcp connection closed")
+
+// RequestHandler 处理对端发起的请求，返回值会作为 result 回写。
+// 返回 *RPCError 可指定错误码，其它 error 按 internal error 处理。
+type RequestHandler func(ctx context.Context, method string, params json.RawMessage) (any, error)
+
+// NotificationHandler 处理对端发送的通知。
+type NotificationHandler func(method string, params json.RawMessage)
+
+// ConnOptions 配置 Conn 对入站消息的处理方式；未设置时请求返回 method not found（ping 除外）。
+type ConnOptions struct {
+	OnRequest      RequestHandler
+	OnNotification NotificationHandler
+}
+
+// Conn 是基于换行分隔 JSON 的双向 JSON-RPC 连接，stdio 客户端与服务器共用。
+type Conn struct {
+	w   io.Writer
+	wmu sync.Mutex
+
+	opts   ConnOptions
+	nextID atomic.Int64
+
+	mu      sync.Mutex
+	pending map[string]chan Message
+	// inbound 记录对端请求的取消函数，收到 notifications/cancelled 时取消对应处理。
+	inbound map[string]context.CancelFunc
+	err     error
+	done    chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewConn 创建连接并启动读循环；r 到达 EOF 后连接进入关闭状态。
+func NewConn(r io.Reader, w io.Writer, opts ConnOptions) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Conn{
+		w:       w,
+		opts:    opts,
+		pending: map[string]chan Message{},
+		inbound: map[string]context.CancelFunc{},
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go c.readLoop(r)
+	return c
+}
+
+// Done 在连接关闭后关闭。
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// Err 返回连接关闭原因；连接仍可用时为 nil。
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Call 发送请求并等待响应。
+func (c *Conn) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := strconv.FormatInt(c.nextID.Add(1), 10)
+	ch := make(chan Message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	raw, err := marshalParams(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.write(Message{JSONRPC: jsonRPCVersion, ID: json.RawMessage(id), Method: method, Params: raw}); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		_ = c.Notify(context.Background(), "notifications/cancelled", map[string]any{"requestId": json.RawMessage(id), "reason": ctx.Err().Error()})
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, c.Err()
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	}
+}
+
+// Notify 发送通知（不等待响应）。
+func (c *Conn) Notify(_ context.Context, method string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	return c.write(Message{JSONRPC: jsonRPCVersion, Method: method, Params: raw})
+}
+
+// Close 关闭连接并唤醒所有等待中的调用；底层流由调用方负责关闭。
+func (c *Conn) Close() error {
+	c.shutdown(ErrConnClosed)
+	return nil
+}
+
+func (c *Conn) write(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := c.Err(); err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+func (c *Conn) readLoop(r io.Reader) {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.dispatch(line)
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = ErrConnClosed
+			}
+			c.shutdown(err)
+			return
+		}
+	}
+}
+
+func (c *Conn) dispatch(line []byte) {
+	var msg Message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		_ = c.write(Message{JSONRPC: jsonRPCVersion, ID: json.RawMessage("null"), Error: &RPCError{Code: CodeParseError, Message: err.Error()}})
+		return
+	}
+	switch {
+	case msg.IsResponse():
+		c.mu.Lock()
+		ch := c.pending[normalizeID(msg.ID)]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	case msg.IsRequest():
+		go c.serveRequest(msg)
+	case msg.IsNotification():
+		if msg.Method == "notifications/cancelled" {
+			c.cancelInbound(msg.Params)
+		}
+		if c.opts.OnNotification != nil {
+			c.opts.OnNotification(msg.Method, msg.Params)
+		}
+	}
+}
+
+func (c *Conn) serveRequest(msg Message) {
+	id := normalizeID(msg.ID)
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.mu.Lock()
+	c.inbound[id] = cancel
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.inbound, id)
+		c.mu.Unlock()
+		cancel()
+	}()
+
+	var (
+		result any
+		err    error
+	)
+	switch {
+	case c.opts.OnRequest != nil:
+		result, err = c.opts.OnRequest(ctx, msg.Method, msg.Params)
+	case msg.Method == "ping":
+		result = struct{}{}
+	default:
+		err = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	resp := Message{JSONRPC: jsonRPCVersion, ID: msg.ID}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+	} else {
+		if result == nil {
+			result = struct{}{}
+		}
+		data, mErr := json.Marshal(result)
+		if mErr != nil {
+			resp.Error = &RPCError{Code: CodeInternalError, Message: mErr.Error()}
+		} else {
+			resp.Result = data
+		}
+	}
+	_ = c.write(resp)
+}
+
+func (c *Conn) cancelInbound(params json.RawMessage) {
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(params, &p) != nil || len(p.RequestID) == 0 {
+		return
+	}
+	c.mu.Lock()
+	cancel := c.inbound[normalizeID(p.RequestID)]
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (c *Conn) shutdown(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	c.mu.Unlock()
+	c.cancel()
+	close(c.done)
+}
+
+func marshalParams(params any) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	if raw, ok := params.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(params)
+}
+
+// normalizeID 统一数字与字符串形式的 id，便于匹配 pending 请求。
+func normalizeID(id json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(id, &s); err == nil {
+		return s
+	}
+	return string(bytes.TrimSpace(id))
+}
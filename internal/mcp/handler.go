@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/tools"
+)
+
+// toolNameMaxLen 是模型侧工具名的长度上限。
+const toolNameMaxLen = 64
+
+// QualifiedToolName 生成暴露给模型的工具名：mcp__<server>__<tool>，仅保留 [A-Za-z0-9_-]。
+func QualifiedToolName(server, tool string) string {
+	name := "mcp__" + sanitizeName(server) + "__" + sanitizeName(tool)
+	if len(name) <= toolNameMaxLen {
+		return name
+	}
+	sum := sha1.Sum([]byte(server + "/" + tool))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	return name[:toolNameMaxLen-len(suffix)-1] + "_" + suffix
+}
+
+func sanitizeName(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// ToolHandler 把单个 MCP 工具适配为 tools.Handler。
+type ToolHandler struct {
+	server  string
+	tool    Tool
+	name    string
+	client  *Client
+	timeout time.Duration
+}
+
+func (h ToolHandler) Name() string           { return h.name }
+func (h ToolHandler) Kind() tools.ToolKind   { return tools.ToolMCP }
+func (h ToolHandler) SupportsParallel() bool { return true }
+
+// IsMutating 仅在服务器声明 readOnlyHint 时视为只读。
+func (h ToolHandler) IsMutating(tools.Invocation) bool {
+	if h.tool.Annotations != nil && h.tool.Annotations.ReadOnlyHint != nil {
+		return !*h.tool.Annotations.ReadOnlyHint
+	}
+	return true
+}
+
+func (h ToolHandler) Describe(inv tools.Invocation) tools.ToolResult {
+	return tools.ToolResult{
+		ID:      inv.Call.ID,
+		Kind:    tools.ToolMCP,
+		Command: h.server + "/" + h.tool.Name,
+	}
+}
+
+func (h ToolHandler) Handle(ctx context.Context, inv tools.Invocation) (tools.ToolResult, error) {
+	base := h.Describe(inv)
+	args := inv.Call.Payload
+	if len(strings.TrimSpace(string(args))) > 0 && !json.Valid(args) {
+		base.Status = "error"
+		base.Error = "invalid arguments: payload is not valid JSON"
+		return base, fmt.Errorf("invalid %s payload", h.name)
+	}
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	result, err := h.client.CallTool(ctx, h.tool.Name, args)
+	if err != nil {
+		base.Status = "error"
+		base.Error = err.Error()
+		return base, err
+	}
+	base.Output = FormatContent(result)
+	if result.IsError {
+		base.Status = "error"
+		base.Error = base.Output
+		if base.Error == "" {
+			base.Error = "tool reported an error"
+		}
+		return base, nil
+	}
+	base.Status = "completed"
+	return base, nil
+}
+
+// Spec 返回供模型使用的工具定义。
+func (h ToolHandler) Spec() agent.ToolSpec {
+	desc := strings.TrimSpace(h.tool.Description)
+	if desc == "" {
+		desc = h.tool.Title
+	}
+	params := h.tool.InputSchema
+	if len(params) == 0 {
+		params = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return agent.ToolSpec{
+		Name:        h.name,
+		Description: fmt.Sprintf("[MCP %s] %s", h.server, desc),
+		Parameters:  params,
+	}
+}
+
+// FormatContent 将工具结果的内容块折叠为文本；非文本内容以占位描述代替。
+func FormatContent(result CallToolResult) string {
+	parts := make([]string, 0, len(result.Content))
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s content: %s, %d bytes base64]", c.Type, c.MimeType, len(c.Data)))
+		case "resource", "resource_link":
+			parts = append(parts, fmt.Sprintf("[%s] %s", c.Type, strings.TrimSpace(string(c.Resource))))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content]", c.Type))
+		}
+	}
+	if len(parts) == 0 && result.StructuredContent != nil {
+		if data, err := json.Marshal(result.StructuredContent); err == nil {
+			parts = append(parts, string(data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,261 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const sessionHeader = "Mcp-Session-Id"
+
+// HTTPConfig 描述 streamable HTTP 传输的 MCP 服务器。
+type HTTPConfig struct {
+	URL         string
+	BearerToken string
+	Headers     map[string]string
+	Client      *http.Client
+}
+
+// HTTPTransport 实现 MCP streamable HTTP：每条消息单独 POST，响应可能是 JSON 或 SSE 流。
+type HTTPTransport struct {
+	cfg    HTTPConfig
+	client *http.Client
+	opts   ConnOptions
+	nextID atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string
+	protocol  string
+	err       error
+}
+
+// NewHTTPTransport 创建 HTTP 传输；连接在首个请求时建立。
+func NewHTTPTransport(cfg HTTPConfig, opts ConnOptions) (*HTTPTransport, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, fmt.Errorf("mcp http server requires a url")
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPTransport{cfg: cfg, client: client, opts: opts}, nil
+}
+
+func (t *HTTPTransport) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return nil, err
+	}
+	id := strconv.FormatInt(t.nextID.Add(1), 10)
+	resp, err := t.post(ctx, Message{JSONRPC: jsonRPCVersion, ID: json.RawMessage(id), Method: method, Params: raw})
+	if err != nil {
+		t.setErr(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if method == "initialize" {
+		if sid := resp.Header.Get(sessionHeader); sid != "" {
+			t.mu.Lock()
+			t.sessionID = sid
+			t.mu.Unlock()
+		}
+	}
+
+	var msg Message
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		msg, err = t.readStream(ctx, resp.Body, id)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&msg)
+	}
+	if err != nil {
+		t.setErr(err)
+		return nil, err
+	}
+	t.setErr(nil)
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	if method == "initialize" {
+		var init InitializeResult
+		if json.Unmarshal(msg.Result, &init) == nil && init.ProtocolVersion != "" {
+			t.mu.Lock()
+			t.protocol = init.ProtocolVersion
+			t.mu.Unlock()
+		}
+	}
+	return msg.Result, nil
+}
+
+func (t *HTTPTransport) Notify(ctx context.Context, method string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	resp, err := t.post(ctx, Message{JSONRPC: jsonRPCVersion, Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (t *HTTPTransport) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// Close 在存在会话时发送 DELETE 通知服务器释放会话。
+func (t *HTTPTransport) Close() error {
+	t.mu.Lock()
+	sid := t.sessionID
+	t.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.cfg.URL, nil)
+	if err != nil {
+		return err
+	}
+	t.decorate(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (t *HTTPTransport) post(ctx context.Context, msg Message) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.decorate(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("mcp http %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+func (t *HTTPTransport) decorate(req *http.Request) {
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if token := strings.TrimSpace(t.cfg.BearerToken); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionID != "" {
+		req.Header.Set(sessionHeader, t.sessionID)
+	}
+	if t.protocol != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocol)
+	}
+}
+
+// readStream 读取 SSE 流直到拿到指定 id 的响应；期间的通知与服务器请求就地处理。
+func (t *HTTPTransport) readStream(ctx context.Context, body io.Reader, id string) (Message, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var data strings.Builder
+	flush := func() (Message, bool) {
+		payload := data.String()
+		data.Reset()
+		if strings.TrimSpace(payload) == "" {
+			return Message{}, false
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			return Message{}, false
+		}
+		switch {
+		case msg.IsResponse() && normalizeID(msg.ID) == id:
+			return msg, true
+		case msg.IsRequest():
+			go t.answer(ctx, msg)
+		case msg.IsNotification():
+			if t.opts.OnNotification != nil {
+				t.opts.OnNotification(msg.Method, msg.Params)
+			}
+		}
+		return Message{}, false
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if msg, ok := flush(); ok {
+				return msg, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "data:") {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if msg, ok := flush(); ok {
+		return msg, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return Message{}, err
+	}
+	return Message{}, fmt.Errorf("mcp http stream ended without a response")
+}
+
+func (t *HTTPTransport) answer(ctx context.Context, req Message) {
+	resp := Message{JSONRPC: jsonRPCVersion, ID: req.ID}
+	var (
+		result any
+		err    error
+	)
+	switch {
+	case t.opts.OnRequest != nil:
+		result, err = t.opts.OnRequest(ctx, req.Method, req.Params)
+	case req.Method == "ping":
+		result = struct{}{}
+	default:
+		err = &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if err != nil {
+		rpcErr, ok := err.(*RPCError)
+		if !ok {
+			rpcErr = &RPCError{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+	} else if data, mErr := json.Marshal(result); mErr == nil {
+		resp.Result = data
+	}
+	if r, err := t.post(context.Background(), resp); err == nil {
+		_, _ = io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}
+}
+
+func (t *HTTPTransport) setErr(err error) {
+	t.mu.Lock()
+	t.err = err
+	t.mu.Unlock()
+}
@@ -0,0 +1,253 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/config"
+	"echo-cli/internal/logger"
+	"echo-cli/internal/tools"
+)
+
+var log = logger.Named("mcp")
+
+const (
+	defaultStartupTimeout = 10 * time.Second
+	defaultToolTimeout    = 60 * time.Second
+)
+
+// ServerStatus 是 /mcp 与 `echo-cli mcp list` 展示的服务器状态快照。
+type ServerStatus struct {
+	Name      string         `json:"name"`
+	Transport string         `json:"transport"`
+	Target    string         `json:"target,omitempty"`
+	Connected bool           `json:"connected"`
+	Error     string         `json:"error,omitempty"`
+	Server    Implementation `json:"server"`
+	Tools     []ToolStatus   `json:"tools,omitempty"`
+}
+
+// ToolStatus 描述一个已注册的 MCP 工具。
+type ToolStatus struct {
+	Name          string `json:"name"`
+	QualifiedName string `json:"qualified_name"`
+	Description   string `json:"description,omitempty"`
+}
+
+type serverState struct {
+	name      string
+	transport string
+	target    string
+	client    *Client
+	handlers  []ToolHandler
+	err       error
+}
+
+// Manager 管理配置中全部 MCP 服务器的连接，并向工具注册表与提示词提供工具。
+type Manager struct {
+	mu      sync.Mutex
+	servers []*serverState
+}
+
+// ConnectAll 并发连接所有启用的服务器；单个服务器失败只记录在状态中，不影响其它服务器。
+func ConnectAll(ctx context.Context, servers map[string]config.MCPServerConfig) *Manager {
+	m := &Manager{}
+	names := make([]string, 0, len(servers))
+	for name, cfg := range servers {
+		if cfg.IsEnabled() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	m.servers = make([]*serverState, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			m.servers[i] = connectServer(ctx, name, servers[name])
+		}(i, name)
+	}
+	wg.Wait()
+	return m
+}
+
+func connectServer(ctx context.Context, name string, cfg config.MCPServerConfig) *serverState {
+	state := &serverState{name: name}
+	timeout := defaultStartupTimeout
+	if cfg.StartupTimeoutSec > 0 {
+		timeout = time.Duration(cfg.StartupTimeoutSec) * time.Second
+	}
+	toolTimeout := defaultToolTimeout
+	if cfg.ToolTimeoutSec > 0 {
+		toolTimeout = time.Duration(cfg.ToolTimeoutSec) * time.Second
+	}
+
+	var (
+		transport Transport
+		err       error
+	)
+	switch {
+	case strings.TrimSpace(cfg.URL) != "":
+		state.transport, state.target = "http", cfg.URL
+		token := ""
+		if env := strings.TrimSpace(cfg.BearerTokenEnvVar); env != "" {
+			token = os.Getenv(env)
+		}
+		transport, err = NewHTTPTransport(HTTPConfig{URL: cfg.URL, BearerToken: token, Headers: cfg.HTTPHeaders}, ConnOptions{})
+	case strings.TrimSpace(cfg.Command) != "":
+		state.transport = "stdio"
+		state.target = strings.TrimSpace(strings.Join(append([]string{cfg.Command}, cfg.Args...), " "))
+		transport, err = StartStdio(StdioConfig{Command: cfg.Command, Args: cfg.Args, Env: cfg.Env, Dir: cfg.Cwd}, ConnOptions{})
+	default:
+		err = fmt.Errorf("either command or url must be set")
+	}
+	if err != nil {
+		state.err = err
+		log.Warnf("mcp server=%s connect failed: %v", name, err)
+		return state
+	}
+
+	startCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := Connect(startCtx, transport)
+	if err == nil {
+		var list []Tool
+		list, err = client.ListTools(startCtx)
+		if err == nil {
+			state.client = client
+			for _, tool := range list {
+				state.handlers = append(state.handlers, ToolHandler{
+					server:  name,
+					tool:    tool,
+					name:    QualifiedToolName(name, tool.Name),
+					client:  client,
+					timeout: toolTimeout,
+				})
+			}
+		}
+	}
+	if err != nil {
+		_ = transport.Close()
+		state.err = err
+		log.Warnf("mcp server=%s initialize failed: %v", name, err)
+		return state
+	}
+	log.Infof("mcp server=%s transport=%s tools=%d", name, state.transport, len(state.handlers))
+	return state
+}
+
+// Handlers 返回所有已连接服务器的工具处理器。
+func (m *Manager) Handlers() []tools.Handler {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []tools.Handler
+	for _, s := range m.servers {
+		for _, h := range s.handlers {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// ToolSpecs 返回所有已连接服务器的工具定义，供模型调用。
+func (m *Manager) ToolSpecs() []agent.ToolSpec {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []agent.ToolSpec
+	for _, s := range m.servers {
+		for _, h := range s.handlers {
+			out = append(out, h.Spec())
+		}
+	}
+	return out
+}
+
+// Status 返回各服务器的当前状态；已连接的服务器会检查底层传输是否仍然可用。
+func (m *Manager) Status() []ServerStatus {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ServerStatus, 0, len(m.servers))
+	for _, s := range m.servers {
+		st := ServerStatus{Name: s.name, Transport: s.transport, Target: s.target}
+		err := s.err
+		if s.client != nil {
+			st.Server = s.client.ServerInfo().ServerInfo
+			if err == nil {
+				err = s.client.Err()
+			}
+		}
+		st.Connected = s.client != nil && err == nil
+		if err != nil {
+			st.Error = err.Error()
+		}
+		for _, h := range s.handlers {
+			st.Tools = append(st.Tools, ToolStatus{Name: h.tool.Name, QualifiedName: h.name, Description: h.tool.Description})
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Close 关闭全部服务器连接。
+func (m *Manager) Close() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.servers {
+		if s.client != nil {
+			_ = s.client.Close()
+		}
+	}
+}
+
+// FormatStatus 渲染服务器状态，供 /mcp 与 `echo-cli mcp list` 共用。
+func FormatStatus(statuses []ServerStatus) string {
+	if len(statuses) == 0 {
+		return "No MCP servers configured. Add [mcp_servers.<name>] to ~/.echo/config.toml."
+	}
+	var sb strings.Builder
+	for i, st := range statuses {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		health := "connected"
+		if !st.Connected {
+			health = "failed"
+		}
+		sb.WriteString(fmt.Sprintf("%s (%s) %s", st.Name, st.Transport, health))
+		if st.Target != "" {
+			sb.WriteString("\n  └ target: " + st.Target)
+		}
+		if st.Server.Name != "" {
+			sb.WriteString(fmt.Sprintf("\n  └ server: %s %s", st.Server.Name, st.Server.Version))
+		}
+		if st.Error != "" {
+			sb.WriteString("\n  └ error: " + st.Error)
+		}
+		if st.Connected {
+			sb.WriteString(fmt.Sprintf("\n  └ tools (%d):", len(st.Tools)))
+			for _, tool := range st.Tools {
+				sb.WriteString("\n      - " + tool.QualifiedName)
+			}
+		}
+	}
+	return sb.String()
+}
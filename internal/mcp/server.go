@@ -0,0 +1,378 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"echo-cli/internal/events"
+	"echo-cli/internal/tools"
+
+	"github.com/google/uuid"
+)
+
+// RunTaskToolName 是 mcp-server 暴露的唯一工具。
+const RunTaskToolName = "run_task"
+
+// TaskGateway 是 MCP 服务器提交任务所需的 SQ/EQ 能力（repl.Gateway 实现了该接口）。
+type TaskGateway interface {
+	SubmitUserInput(ctx context.Context, items []events.InputMessage, inputCtx events.InputContext) (string, error)
+	SubmitInterrupt(ctx context.Context, sessionID string) (string, error)
+	SubmitApprovalDecision(ctx context.Context, sessionID string, decision events.ApprovalDecisionOperation) (string, error)
+	Events() <-chan events.Event
+}
+
+// ServerOptions 配置 MCP 服务器提交任务时使用的默认上下文。
+type ServerOptions struct {
+	Gateway         TaskGateway
+	Model           string
+	System          string
+	Language        string
+	ReasoningEffort string
+	Version         string
+}
+
+// Server 将 execution.Engine 以 MCP 工具的形式暴露给其它 MCP 客户端（stdio 传输）。
+type Server struct {
+	opts  ServerOptions
+	conn  *Conn
+	ready chan struct{}
+
+	mu           sync.Mutex
+	clientCaps   map[string]any
+	routes       map[string]*taskRoute
+	progressStep map[string]float64
+}
+
+// NewServer 创建 MCP 服务器。
+func NewServer(opts ServerOptions) *Server {
+	if opts.Version == "" {
+		opts.Version = ClientInfo.Version
+	}
+	return &Server{
+		opts:         opts,
+		ready:        make(chan struct{}),
+		routes:       map[string]*taskRoute{},
+		progressStep: map[string]float64{},
+	}
+}
+
+// Serve 在 r/w 上处理 MCP 请求，直到输入结束或 ctx 取消。
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	if s.opts.Gateway == nil {
+		return fmt.Errorf("mcp server requires a gateway")
+	}
+	eventsCh := s.opts.Gateway.Events()
+	go s.routeEvents(ctx, eventsCh)
+
+	s.conn = NewConn(r, w, ConnOptions{OnRequest: s.handleRequest})
+	close(s.ready)
+	select {
+	case <-ctx.Done():
+		_ = s.conn.Close()
+		return ctx.Err()
+	case <-s.conn.Done():
+		return nil
+	}
+}
+
+// taskRoute 把某个会话的 EQ 事件交给正在等待的 tools/call；done 关闭后不再投递。
+type taskRoute struct {
+	events chan events.Event
+	done   chan struct{}
+}
+
+// routeEvents 按会话把 EQ 事件分发给正在执行的 tools/call。
+func (s *Server) routeEvents(ctx context.Context, ch <-chan events.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			s.mu.Lock()
+			route := s.routes[ev.SessionID]
+			s.mu.Unlock()
+			if route == nil {
+				continue
+			}
+			select {
+			case route.events <- ev:
+			case <-route.done:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) handleRequest(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	<-s.ready
+	switch method {
+	case "initialize":
+		var p InitializeParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		s.mu.Lock()
+		s.clientCaps = p.Capabilities
+		s.mu.Unlock()
+		version := p.ProtocolVersion
+		if version == "" {
+			version = ProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]any{"tools": map[string]any{"listChanged": false}},
+			ServerInfo:      Implementation{Name: "echo-cli-mcp-server", Version: s.opts.Version},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return ListToolsResult{Tools: []Tool{runTaskTool()}}, nil
+	case "tools/call":
+		var p CallToolParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		if p.Name != RunTaskToolName {
+			return nil, &RPCError{Code: CodeInvalidParams, Message: "unknown tool: " + p.Name}
+		}
+		return s.runTask(ctx, p), nil
+	}
+	return nil, &RPCError{Code: CodeMethodNotFound, Message: "method not found: " + method}
+}
+
+func runTaskTool() Tool {
+	return Tool{
+		Name:        RunTaskToolName,
+		Title:       "Run echo-cli task",
+		Description: "Run a coding task with the echo-cli agent in its working directory. Pass session_id from a previous result to continue that conversation.",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"prompt":           map[string]any{"type": "string", "description": "Task or follow-up message for the agent."},
+				"session_id":       map[string]any{"type": "string", "description": "Optional session id returned by an earlier run_task call."},
+				"model":            map[string]any{"type": "string", "description": "Optional model override."},
+				"reasoning_effort": map[string]any{"type": "string", "description": "Optional reasoning effort hint."},
+			},
+			"required": []string{"prompt"},
+		},
+	}
+}
+
+type runTaskArgs struct {
+	Prompt          string `json:"prompt"`
+	SessionID       string `json:"session_id"`
+	Model           string `json:"model"`
+	ReasoningEffort string `json:"reasoning_effort"`
+}
+
+func errorResult(msg string) CallToolResult {
+	return CallToolResult{Content: []Content{TextContent(msg)}, IsError: true}
+}
+
+// runTask 提交一次用户输入并等待任务结束，期间把 EQ 事件转为 MCP 通知，审批转为 elicitation。
+func (s *Server) runTask(ctx context.Context, call CallToolParams) CallToolResult {
+	var args runTaskArgs
+	if len(call.Arguments) > 0 {
+		if err := json.Unmarshal(call.Arguments, &args); err != nil {
+			return errorResult("invalid arguments: " + err.Error())
+		}
+	}
+	if strings.TrimSpace(args.Prompt) == "" {
+		return errorResult("prompt is required")
+	}
+	sessionID := strings.TrimSpace(args.SessionID)
+	if sessionID == "" {
+		sessionID = uuid.NewString()
+	}
+	var progressToken any
+	if call.Meta != nil {
+		progressToken = call.Meta["progressToken"]
+	}
+
+	route := &taskRoute{events: make(chan events.Event, 64), done: make(chan struct{})}
+	s.mu.Lock()
+	if _, busy := s.routes[sessionID]; busy {
+		s.mu.Unlock()
+		return errorResult("session " + sessionID + " already has a running task")
+	}
+	s.routes[sessionID] = route
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.routes, sessionID)
+		delete(s.progressStep, sessionID)
+		s.mu.Unlock()
+		close(route.done)
+	}()
+
+	model := s.opts.Model
+	if strings.TrimSpace(args.Model) != "" {
+		model = strings.TrimSpace(args.Model)
+	}
+	effort := s.opts.ReasoningEffort
+	if strings.TrimSpace(args.ReasoningEffort) != "" {
+		effort = strings.TrimSpace(args.ReasoningEffort)
+	}
+	subID, err := s.opts.Gateway.SubmitUserInput(ctx, []events.InputMessage{{Role: "user", Content: args.Prompt}}, events.InputContext{
+		SessionID:       sessionID,
+		Model:           model,
+		System:          s.opts.System,
+		Language:        s.opts.Language,
+		ReasoningEffort: effort,
+	})
+	if err != nil {
+		return errorResult("submit task failed: " + err.Error())
+	}
+
+	var answer strings.Builder
+	final := ""
+	for {
+		select {
+		case <-ctx.Done():
+			_, _ = s.opts.Gateway.SubmitInterrupt(context.Background(), sessionID)
+			return errorResult("task cancelled")
+		case ev := <-route.events:
+			if ev.SubmissionID != subID {
+				continue
+			}
+			switch ev.Type {
+			case events.EventAgentOutput:
+				out, ok := ev.Payload.(events.AgentOutput)
+				if !ok {
+					continue
+				}
+				if out.Final {
+					final = out.Content
+					continue
+				}
+				answer.WriteString(out.Content)
+				s.notifyProgress(ctx, sessionID, progressToken, "agent_output", out.Content)
+			case events.EventToolEvent:
+				toolEvt, ok := ev.Payload.(tools.ToolEvent)
+				if !ok {
+					continue
+				}
+				if toolEvt.Result.Status == "requires_approval" && toolEvt.Result.ApprovalID != "" {
+					go s.elicitApproval(ctx, sessionID, toolEvt.Result)
+					continue
+				}
+				if text := describeToolEvent(toolEvt); text != "" {
+					s.notifyProgress(ctx, sessionID, progressToken, "tool_event", text)
+				}
+			case events.EventError:
+				return errorResult(fmt.Sprint(ev.Payload))
+			case events.EventTaskCompleted:
+				if final == "" {
+					final = answer.String()
+				}
+				return CallToolResult{
+					Content: []Content{TextContent(final)},
+					StructuredContent: map[string]any{
+						"session_id": sessionID,
+						"message":    final,
+					},
+				}
+			}
+		}
+	}
+}
+
+// notifyProgress 有 progressToken 时发送 notifications/progress，否则发送日志通知。
+func (s *Server) notifyProgress(ctx context.Context, sessionID string, token any, kind string, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	if token != nil {
+		s.mu.Lock()
+		s.progressStep[sessionID]++
+		step := s.progressStep[sessionID]
+		s.mu.Unlock()
+		_ = s.conn.Notify(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      step,
+			"message":       text,
+		})
+		return
+	}
+	_ = s.conn.Notify(ctx, "notifications/message", map[string]any{
+		"level":  "info",
+		"logger": "echo-cli",
+		"data":   map[string]any{"session_id": sessionID, "type": kind, "text": text},
+	})
+}
+
+func describeToolEvent(ev tools.ToolEvent) string {
+	res := ev.Result
+	target := strings.TrimSpace(res.Command)
+	if target == "" {
+		target = strings.TrimSpace(res.Path)
+	}
+	switch ev.Type {
+	case "item.started":
+		return strings.TrimSpace(fmt.Sprintf("%s started: %s", res.Kind, target))
+	case "item.completed":
+		status := res.Status
+		if status == "" {
+			status = "completed"
+		}
+		line := strings.TrimSpace(fmt.Sprintf("%s %s: %s", res.Kind, status, target))
+		if res.Error != "" {
+			line += " (" + res.Error + ")"
+		}
+		return line
+	default:
+		return ""
+	}
+}
+
+// elicitApproval 通过 elicitation/create 请求客户端审批；客户端不支持或拒绝时按拒绝处理（fail closed）。
+func (s *Server) elicitApproval(ctx context.Context, sessionID string, res tools.ToolResult) {
+	approved := false
+	s.mu.Lock()
+	_, supported := s.clientCaps["elicitation"]
+	s.mu.Unlock()
+	if supported {
+		message := "echo-cli requests approval to run: " + strings.TrimSpace(res.Command)
+		if reason := strings.TrimSpace(res.ApprovalReason); reason != "" {
+			message += "\nReason: " + reason
+		}
+		callCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+		raw, err := s.conn.Call(callCtx, "elicitation/create", map[string]any{
+			"message": message,
+			"requestedSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"approve": map[string]any{"type": "boolean", "title": "Approve", "description": "Allow this command to run."},
+				},
+				"required": []string{"approve"},
+			},
+		})
+		cancel()
+		if err == nil {
+			approved = parseElicitationApproval(raw)
+		}
+	}
+	_, _ = s.opts.Gateway.SubmitApprovalDecision(context.Background(), sessionID, events.ApprovalDecisionOperation{ApprovalID: res.ApprovalID, Approved: approved})
+}
+
+func parseElicitationApproval(raw json.RawMessage) bool {
+	var resp struct {
+		Action  string         `json:"action"`
+		Content map[string]any `json:"content"`
+	}
+	if json.Unmarshal(raw, &resp) != nil || resp.Action != "accept" {
+		return false
+	}
+	// 只有显式 approve:true 才算批准；缺省或类型不符一律拒绝。
+	v, ok := resp.Content["approve"].(bool)
+	return ok && v
+}
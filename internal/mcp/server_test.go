@@ -0,0 +1,178 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"echo-cli/internal/events"
+	"echo-cli/internal/tools"
+)
+
+// scriptedGateway 模拟引擎：收到输入后依次发出审批请求、等待审批决策、输出并结束。
+type scriptedGateway struct {
+	ch        chan events.Event
+	mu        sync.Mutex
+	decisions chan bool
+}
+
+func newScriptedGateway() *scriptedGateway {
+	return &scriptedGateway{ch: make(chan events.Event, 16), decisions: make(chan bool, 1)}
+}
+
+func (g *scriptedGateway) SubmitUserInput(ctx context.Context, items []events.InputMessage, inputCtx events.InputContext) (string, error) {
+	sub, sess := "sub-1", inputCtx.SessionID
+	go func() {
+		g.ch <- events.Event{Type: events.EventToolEvent, SubmissionID: sub, SessionID: sess, Payload: tools.ToolEvent{
+			Type:   "item.updated",
+			Result: tools.ToolResult{ID: "call-1", Kind: tools.ToolCommand, Status: "requires_approval", Command: "rm -rf build", ApprovalID: "call-1"},
+		}}
+		approved := <-g.decisions
+		text := "denied"
+		if approved {
+			text = "approved"
+		}
+		g.ch <- events.Event{Type: events.EventAgentOutput, SubmissionID: sub, SessionID: sess, Payload: events.AgentOutput{Content: "working"}}
+		g.ch <- events.Event{Type: events.EventAgentOutput, SubmissionID: sub, SessionID: sess, Payload: events.AgentOutput{Content: text, Final: true}}
+		g.ch <- events.Event{Type: events.EventTaskCompleted, SubmissionID: sub, SessionID: sess}
+	}()
+	return sub, nil
+}
+
+func (g *scriptedGateway) SubmitInterrupt(ctx context.Context, sessionID string) (string, error) {
+	return "interrupt", nil
+}
+
+func (g *scriptedGateway) SubmitApprovalDecision(ctx context.Context, sessionID string, decision events.ApprovalDecisionOperation) (string, error) {
+	g.decisions <- decision.Approved
+	return "decision", nil
+}
+
+func (g *scriptedGateway) Events() <-chan events.Event { return g.ch }
+
+func TestServerRunTaskWithElicitation(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	gateway := newScriptedGateway()
+	server := NewServer(ServerOptions{Gateway: gateway})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx, serverR, serverW) }()
+
+	var (
+		mu       sync.Mutex
+		progress []string
+		elicited string
+	)
+	conn := NewConn(clientR, clientW, ConnOptions{
+		OnRequest: func(ctx context.Context, method string, params json.RawMessage) (any, error) {
+			if method != "elicitation/create" {
+				return nil, &RPCError{Code: CodeMethodNotFound, Message: method}
+			}
+			var p struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(params, &p)
+			mu.Lock()
+			elicited = p.Message
+			mu.Unlock()
+			return map[string]any{"action": "accept", "content": map[string]any{"approve": true}}, nil
+		},
+		OnNotification: func(method string, params json.RawMessage) {
+			if method == "notifications/progress" {
+				mu.Lock()
+				progress = append(progress, string(params))
+				mu.Unlock()
+			}
+		},
+	})
+
+	if _, err := conn.Call(ctx, "initialize", InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{"elicitation": map[string]any{}},
+		ClientInfo:      Implementation{Name: "test", Version: "0"},
+	}); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	raw, err := conn.Call(ctx, "tools/list", nil)
+	if err != nil {
+		t.Fatalf("tools/list: %v", err)
+	}
+	var list ListToolsResult
+	_ = json.Unmarshal(raw, &list)
+	if len(list.Tools) != 1 || list.Tools[0].Name != RunTaskToolName {
+		t.Fatalf("unexpected tools %+v", list.Tools)
+	}
+
+	raw, err = conn.Call(ctx, "tools/call", CallToolParams{
+		Name:      RunTaskToolName,
+		Arguments: json.RawMessage(`{"prompt":"clean build"}`),
+		Meta:      map[string]any{"progressToken": "tok"},
+	})
+	if err != nil {
+		t.Fatalf("tools/call: %v", err)
+	}
+	var result CallToolResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if result.IsError || FormatContent(result) != "approved" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	structured, _ := result.StructuredContent.(map[string]any)
+	if structured["session_id"] == "" {
+		t.Fatalf("expected session id in structured content, got %+v", result.StructuredContent)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if elicited == "" {
+		t.Fatalf("expected approval elicitation")
+	}
+	if len(progress) == 0 {
+		t.Fatalf("expected progress notifications")
+	}
+}
+
+func TestServerDeniesApprovalWithoutElicitationSupport(t *testing.T) {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	gateway := newScriptedGateway()
+	server := NewServer(ServerOptions{Gateway: gateway})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() { _ = server.Serve(ctx, serverR, serverW) }()
+	conn := NewConn(clientR, clientW, ConnOptions{})
+
+	if _, err := conn.Call(ctx, "initialize", InitializeParams{ProtocolVersion: ProtocolVersion, Capabilities: map[string]any{}}); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	raw, err := conn.Call(ctx, "tools/call", CallToolParams{Name: RunTaskToolName, Arguments: json.RawMessage(`{"prompt":"x"}`)})
+	if err != nil {
+		t.Fatalf("tools/call: %v", err)
+	}
+	var result CallToolResult
+	_ = json.Unmarshal(raw, &result)
+	if FormatContent(result) != "denied" {
+		t.Fatalf("expected approval to be denied, got %+v", result)
+	}
+}
+
+func TestParseElicitationApprovalRequiresExplicitApprove(t *testing.T) {
+	cases := map[string]bool{
+		`{"action":"accept","content":{"approve":true}}`:   true,
+		`{"action":"accept","content":{"approve":false}}`:  false,
+		`{"action":"accept"}`:                              false,
+		`{"action":"accept","content":{}}`:                 false,
+		`{"action":"accept","content":{"approve":"true"}}`: false,
+		`{"action":"decline","content":{"approve":true}}`:  false,
+		`not json`: false,
+	}
+	for raw, want := range cases {
+		if got := parseElicitationApproval(json.RawMessage(raw)); got != want {
+			t.Errorf("parseElicitationApproval(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transport 抽象 MCP 客户端与服务器之间的消息通道。
+type Transport interface {
+	Call(ctx context.Context, method string, params any) (json.RawMessage, error)
+	Notify(ctx context.Context, method string, params any) error
+	// Err 返回通道失效原因；仍可用时为 nil。
+	Err() error
+	Close() error
+}
+
+// StdioConfig 描述以子进程方式启动的 MCP 服务器。
+type StdioConfig struct {
+	Command string
+	Args    []string
+	Env     map[string]string
+	Dir     string
+}
+
+// StdioTransport 通过子进程的 stdin/stdout 交换换行分隔的 JSON-RPC 消息。
+type StdioTransport struct {
+	cmd  *exec.Cmd
+	conn *Conn
+	in   io.Closer
+
+	stderrMu sync.Mutex
+	stderr   []string
+
+	waitOnce sync.Once
+	waitErr  error
+	exited   chan struct{}
+}
+
+const stderrTailLines = 20
+
+// StartStdio 启动子进程并建立连接。
+func StartStdio(cfg StdioConfig, opts ConnOptions) (*StdioTransport, error) {
+	if strings.TrimSpace(cfg.Command) == "" {
+		return nil, fmt.Errorf("mcp stdio server requires a command")
+	}
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = os.Environ()
+	keys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd.Env = append(cmd.Env, k+"="+cfg.Env[k])
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start mcp server %q: %w", cfg.Command, err)
+	}
+	t := &StdioTransport{cmd: cmd, in: stdin, exited: make(chan struct{})}
+	go t.drainStderr(stderr)
+	t.conn = NewConn(stdout, stdin, opts)
+	go func() {
+		<-t.conn.Done()
+		t.wait()
+	}()
+	return t, nil
+}
+
+func (t *StdioTransport) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	res, err := t.conn.Call(ctx, method, params)
+	if errors.Is(err, ErrConnClosed) {
+		return nil, t.exitError()
+	}
+	return res, err
+}
+
+func (t *StdioTransport) Notify(ctx context.Context, method string, params any) error {
+	return t.conn.Notify(ctx, method, params)
+}
+
+func (t *StdioTransport) Err() error {
+	if t.conn.Err() == nil {
+		return nil
+	}
+	return t.exitError()
+}
+
+// Close 关闭 stdin 让服务器自行退出，超时后强制结束进程。
+func (t *StdioTransport) Close() error {
+	_ = t.conn.Close()
+	_ = t.in.Close()
+	go t.wait()
+	select {
+	case <-t.exited:
+	case <-time.After(2 * time.Second):
+		if t.cmd.Process != nil {
+			_ = t.cmd.Process.Kill()
+		}
+		<-t.exited
+	}
+	return nil
+}
+
+func (t *StdioTransport) wait() {
+	t.waitOnce.Do(func() {
+		t.waitErr = t.cmd.Wait()
+		close(t.exited)
+	})
+}
+
+// exitError 描述服务器退出的原因；waitErr 只在 exited 关闭后读取（由 close 发布），超时未退出时不带退出状态。
+func (t *StdioTransport) exitError() error {
+	msg := "server exited"
+	select {
+	case <-t.exited:
+		if t.waitErr != nil {
+			msg = fmt.Sprintf("server exited: %v", t.waitErr)
+		}
+	case <-time.After(200 * time.Millisecond):
+	}
+	if tail := t.stderrTail(); tail != "" {
+		msg += ": " + tail
+	}
+	return fmt.Errorf("%w: %s", ErrConnClosed, msg)
+}
+
+func (t *StdioTransport) drainStderr(r io.Reader) {
+	buf := make([]byte, 4096)
+	var partial string
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			partial += string(buf[:n])
+			lines := strings.Split(partial, "\n")
+			partial = lines[len(lines)-1]
+			t.appendStderr(lines[:len(lines)-1])
+		}
+		if err != nil {
+			t.appendStderr([]string{partial})
+			return
+		}
+	}
+}
+
+func (t *StdioTransport) appendStderr(lines []string) {
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			t.stderr = append(t.stderr, line)
+		}
+	}
+	if len(t.stderr) > stderrTailLines {
+		t.stderr = t.stderr[len(t.stderr)-stderrTailLines:]
+	}
+}
+
+func (t *StdioTransport) stderrTail() string {
+	t.stderrMu.Lock()
+	defer t.stderrMu.Unlock()
+	if len(t.stderr) == 0 {
+		return ""
+	}
+	return t.stderr[len(t.stderr)-1]
+}
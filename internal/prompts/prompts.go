@@ -22,6 +22,19 @@ func BuildReasoningEffort(effort string) string {
 	return reasoningEffortPrefix + trimmed
 }
 
+// BuildOutputSchemaRepair 构造修复轮次的用户消息，列出上一条回复违反输出模式的位置。
+func BuildOutputSchemaRepair(problems []string) string {
+	var sb strings.Builder
+	sb.WriteString("上一条回复不符合输出模式：\n")
+	for _, problem := range problems {
+		sb.WriteString("- ")
+		sb.WriteString(problem)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("请只输出一个符合输出模式的 JSON 值，不要附加任何解释或代码块标记。")
+	return sb.String()
+}
+
 // ExtractReasoningEffort 从指令文本中提取推理强度配置，兼容旧的英文前缀。
 func ExtractReasoningEffort(text string) string {
 	if text == "" {
@@ -0,0 +1,14 @@
+package repl
+
+import tuirender "echo-cli/internal/tui/render"
+
+// reasoningCell 以折叠形式展示一段思考内容（标题 + 最后几行）。
+type reasoningCell struct {
+	text string
+}
+
+func (c reasoningCell) ID() string { return "" }
+
+func (c reasoningCell) Render(width int) []tuirender.Line {
+	return tuirender.RenderReasoningLines(c.text, width, false)
+}
@@ -109,9 +109,18 @@ func toolStartSummary(res tools.ToolResult) (prefix string, detail string) {
 	case tools.ToolFileRead:
 		prefix = "↳ reading"
 		detail = strings.TrimSpace(res.Path)
+	case tools.ToolViewImage:
+		prefix = "🖼 viewing"
+		detail = strings.TrimSpace(res.Path)
 	case tools.ToolSearch:
 		prefix = "🔍 searching"
-		detail = strings.TrimSpace(res.Output)
+		detail = strings.TrimSpace(res.Query)
+	case tools.ToolGrep:
+		prefix = "🔍 grep"
+		detail = strings.TrimSpace(res.Query)
+		if path := strings.TrimSpace(res.Path); path != "" {
+			detail += " in " + path
+		}
 	default:
 		prefix = "• running"
 		detail = strings.TrimSpace(res.Status)
@@ -151,6 +160,9 @@ func toolUpdatedLines(res tools.ToolResult) []string {
 		if strings.TrimSpace(res.Command) != "" {
 			out = append(out, "command: "+strings.TrimSpace(res.Command))
 		}
+		if strings.TrimSpace(res.Path) != "" {
+			out = append(out, "path: "+strings.TrimSpace(res.Path))
+		}
 		if strings.TrimSpace(res.ApprovalID) != "" {
 			out = append(out, "approval_id: "+strings.TrimSpace(res.ApprovalID))
 			out = append(out, "action: /approve "+strings.TrimSpace(res.ApprovalID)+"  (or /deny "+strings.TrimSpace(res.ApprovalID)+")")
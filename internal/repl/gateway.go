@@ -45,8 +45,8 @@ func (g *Gateway) SubmitInterrupt(ctx context.Context, sessionID string) (string
 	})
 }
 
-// SubmitApprovalDecision 投递审批结果到 SQ。
-func (g *Gateway) SubmitApprovalDecision(ctx context.Context, sessionID string, approvalID string, approved bool) (string, error) {
+// SubmitApprovalDecision 投递审批结果（含批准范围或拒绝理由）到 SQ。
+func (g *Gateway) SubmitApprovalDecision(ctx context.Context, sessionID string, decision events.ApprovalDecisionOperation) (string, error) {
 	mgr, err := g.managerOrErr()
 	if err != nil {
 		return "", err
@@ -54,15 +54,36 @@ func (g *Gateway) SubmitApprovalDecision(ctx context.Context, sessionID string,
 	return mgr.Submit(ctx, events.Submission{
 		SessionID: sessionID,
 		Operation: events.Operation{
-			Kind: events.OperationApprovalDecision,
-			ApprovalDecision: &events.ApprovalDecisionOperation{
-				ApprovalID: approvalID,
-				Approved:   approved,
-			},
+			Kind:             events.OperationApprovalDecision,
+			ApprovalDecision: &decision,
 		},
 	})
 }
 
+// SubmitUndo 请求将工作区恢复到最近一次 ghost snapshot。
+func (g *Gateway) SubmitUndo(ctx context.Context, sessionID string) (string, error) {
+	mgr, err := g.managerOrErr()
+	if err != nil {
+		return "", err
+	}
+	return mgr.Submit(ctx, events.Submission{
+		SessionID: sessionID,
+		Operation: events.Operation{Kind: events.OperationUndo},
+	})
+}
+
+// SubmitCompact 请求压缩会话上下文；focus 为可选的关注点提示。
+func (g *Gateway) SubmitCompact(ctx context.Context, sessionID string, op events.CompactOperation) (string, error) {
+	mgr, err := g.managerOrErr()
+	if err != nil {
+		return "", err
+	}
+	return mgr.Submit(ctx, events.Submission{
+		SessionID: sessionID,
+		Operation: events.Operation{Kind: events.OperationCompact, Compact: &op},
+	})
+}
+
 // Events 返回 EQ 事件订阅。
 func (g *Gateway) Events() <-chan events.Event {
 	if g.manager == nil {
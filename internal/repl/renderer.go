@@ -26,6 +26,9 @@ type EQRenderer struct {
 	// 对齐 codex：屏幕由 Scrollback + InlineViewport 组成。
 	scrollback *Scrollback
 	viewport   *InlineViewport
+
+	// reasoning 累积当前思考块，遇到下一个非思考事件时作为一个 cell 输出。
+	reasoning strings.Builder
 }
 
 type EQRendererOptions struct {
@@ -74,6 +77,9 @@ func (r *EQRenderer) Handle(evt events.Event) {
 	if r.sessionID != "" && evt.SessionID != r.sessionID {
 		return
 	}
+	if evt.Type != events.EventAgentReasoning {
+		r.flushReasoning()
+	}
 	if rr := r.renderers[evt.Type]; rr != nil {
 		rr.Handle(r, evt)
 	}
@@ -100,6 +106,14 @@ func (r *EQRenderer) AppendMessages(msgs []agent.Message) {
 	}
 }
 
+func (r *EQRenderer) flushReasoning() {
+	text := strings.TrimSpace(r.reasoning.String())
+	r.reasoning.Reset()
+	if text != "" {
+		r.ScrollbackAppend(reasoningCell{text: text})
+	}
+}
+
 func (r *EQRenderer) ScrollbackAppend(cell HistoryCell) {
 	if r == nil || cell == nil || r.scrollback == nil {
 		return
@@ -114,6 +128,7 @@ func defaultCellRenderers() []EventCellRenderer {
 	return []EventCellRenderer{
 		submissionAcceptedRenderer{},
 		agentOutputRenderer{},
+		agentReasoningRenderer{},
 		taskSummaryRenderer{},
 		planUpdatedRenderer{},
 		toolEventRenderer{},
@@ -196,6 +211,21 @@ func (agentOutputRenderer) Handle(r *EQRenderer, evt events.Event) {
 	r.activeSub = ""
 }
 
+type agentReasoningRenderer struct{}
+
+func (agentReasoningRenderer) Type() events.EventType { return events.EventAgentReasoning }
+
+func (agentReasoningRenderer) Handle(r *EQRenderer, evt events.Event) {
+	msg, ok := evt.Payload.(events.AgentReasoning)
+	if !ok {
+		return
+	}
+	if r.activeSub != "" && evt.SubmissionID != r.activeSub {
+		return
+	}
+	r.reasoning.WriteString(msg.Content)
+}
+
 type planUpdatedRenderer struct{}
 
 func (planUpdatedRenderer) Type() events.EventType { return events.EventPlanUpdated }
@@ -111,3 +111,35 @@ func TestEQRenderer_RendersToolEventsAsCells(t *testing.T) {
 		t.Fatalf("expected assistant final cell, got:\n%s", out)
 	}
 }
+
+func TestEQRenderer_FlushesReasoningAsCollapsedCellBeforeAnswer(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewEQRenderer(EQRendererOptions{SessionID: "sess-1", Width: 60, Writer: &buf})
+
+	for _, chunk := range []string{"first\n", "second\n", "third"} {
+		r.Handle(events.Event{
+			Type:         events.EventAgentReasoning,
+			SubmissionID: "sub-1",
+			SessionID:    "sess-1",
+			Timestamp:    time.Now(),
+			Payload:      events.AgentReasoning{Content: chunk},
+		})
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("reasoning should be buffered until the block ends, got:\n%s", buf.String())
+	}
+	r.Handle(events.Event{
+		Type:         events.EventAgentOutput,
+		SubmissionID: "sub-1",
+		SessionID:    "sess-1",
+		Timestamp:    time.Now(),
+		Payload:      events.AgentOutput{Content: "answer", Final: true},
+	})
+
+	out := stripANSI(buf.String())
+	thinking := strings.Index(out, "Thinking · 1 more lines")
+	answer := strings.Index(out, "• answer")
+	if thinking < 0 || answer < thinking || strings.Contains(out, "first") || !strings.Contains(out, "third") {
+		t.Fatalf("expected collapsed thinking cell before answer, got:\n%s", out)
+	}
+}
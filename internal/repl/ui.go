@@ -5,6 +5,7 @@ import (
 	"echo-cli/internal/events"
 	"echo-cli/internal/execution"
 	"echo-cli/internal/logger"
+	"echo-cli/internal/session"
 	"echo-cli/internal/tools"
 	"echo-cli/internal/tui"
 	"echo-cli/internal/tui/slash"
@@ -20,17 +21,20 @@ type UIOptions struct {
 	InitialPrompt   string
 	Language        string
 	InitialMessages []agent.Message
+	Images          []string
 	Events          *events.Bus
 	Runner          tools.Runner
 	ResumePicker    bool
 	ResumeShowAll   bool
-	ResumeSessions  []string
+	ResumeSessions  []session.Summary
 	ResumeSessionID string
 	CustomPrompts   []slash.CustomPrompt
 	SkillsAvailable bool
 	Debug           bool
 	ConversationLog *logger.LogEntry
 	CopyableOutput  bool
+	MCP             tui.MCPStatusSource
+	ApprovalMemory  *tools.ApprovalMemory
 }
 
 // UIResult 返回 TUI 退出时的历史与状态。
@@ -51,6 +55,7 @@ func RunUI(opts UIOptions) (UIResult, error) {
 		InitialPrompt:   opts.InitialPrompt,
 		Language:        opts.Language,
 		InitialMessages: opts.InitialMessages,
+		Images:          opts.Images,
 		Events:          opts.Events,
 		Runner:          opts.Runner,
 		ResumePicker:    opts.ResumePicker,
@@ -62,6 +67,8 @@ func RunUI(opts UIOptions) (UIResult, error) {
 		Debug:           opts.Debug,
 		ConversationLog: opts.ConversationLog,
 		CopyableOutput:  opts.CopyableOutput,
+		MCP:             opts.MCP,
+		ApprovalMemory:  opts.ApprovalMemory,
 	})
 	if err != nil {
 		return UIResult{}, err
@@ -0,0 +1,207 @@
+// Package sandbox 将工具命令限制在工作区内执行：工作目录与临时目录可写、其余只读，并可关闭网络。
+// Linux 上使用 landlock（文件系统）与 user/net namespace（网络）；其它平台不支持受限模式。
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Mode 描述命令执行的沙箱级别。
+type Mode string
+
+const (
+	// ReadOnly 只允许读取文件系统，任何写入都会被拒绝。
+	ReadOnly Mode = "read-only"
+	// WorkspaceWrite 允许写入工作目录、临时目录与额外配置的可写目录，其余只读。
+	WorkspaceWrite Mode = "workspace-write"
+	// FullAccess 不做任何限制（等同于未启用沙箱）。
+	FullAccess Mode = "full-access"
+)
+
+// Modes 列出全部沙箱模式，供帮助信息与校验使用。
+var Modes = []Mode{ReadOnly, WorkspaceWrite, FullAccess}
+
+// ParseMode 解析沙箱模式，接受下划线写法；空字符串返回 full-access。
+func ParseMode(raw string) (Mode, error) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	name = strings.ReplaceAll(name, "_", "-")
+	if name == "" {
+		return FullAccess, nil
+	}
+	for _, m := range Modes {
+		if string(m) == name {
+			return m, nil
+		}
+	}
+	names := make([]string, 0, len(Modes))
+	for _, m := range Modes {
+		names = append(names, string(m))
+	}
+	return "", fmt.Errorf("unknown sandbox mode %q (supported: %s)", raw, strings.Join(names, ", "))
+}
+
+// Policy 描述一次命令执行的沙箱约束。
+type Policy struct {
+	Mode Mode
+	// Workdir 是会话工作目录；workspace-write 下可写。命令自带的 workdir 不会扩大可写范围。
+	Workdir string
+	// WritableRoots 是 workspace-write 下额外可写的目录。
+	WritableRoots []string
+	// NetworkAccess 为 false 时受限模式下的命令没有网络。
+	NetworkAccess bool
+}
+
+// Active 报告策略是否需要受限执行。
+func (p Policy) Active() bool {
+	return p.Mode == ReadOnly || p.Mode == WorkspaceWrite
+}
+
+// writableRoots 返回受限模式下可写的目录（绝对路径，已去重）。
+func (p Policy) writableRoots() []string {
+	if p.Mode != WorkspaceWrite {
+		return nil
+	}
+	candidates := append([]string{p.Workdir, os.TempDir(), "/tmp"}, p.WritableRoots...)
+	seen := map[string]bool{}
+	var roots []string
+	for _, root := range candidates {
+		if strings.TrimSpace(root) == "" {
+			continue
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		roots = append(roots, abs)
+	}
+	return roots
+}
+
+// CanWrite 报告策略是否允许写入 path（相对路径按 Workdir 解析）。
+func (p Policy) CanWrite(path string) bool {
+	if !p.Active() {
+		return true
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.Workdir, path)
+	}
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(resolved, filepath.Base(path))
+	}
+	for _, root := range p.writableRoots() {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe 返回用于提示与日志的简短描述。
+func (p Policy) Describe() string {
+	mode := p.Mode
+	if mode == "" {
+		mode = FullAccess
+	}
+	if !p.Active() {
+		return string(mode)
+	}
+	network := "off"
+	if p.NetworkAccess {
+		network = "on"
+	}
+	return fmt.Sprintf("%s, network %s", mode, network)
+}
+
+// ErrorPrefix 标记沙箱辅助进程自身的错误输出（例如内核不支持 landlock）。
+const ErrorPrefix = "echo-cli sandbox:"
+
+// HelperArg 是沙箱辅助进程的隐藏子命令：echo-cli 以自身可执行文件重新执行，
+// 在 exec 目标命令之前施加限制。入口程序必须在做任何其它事情之前调用 RunHelper。
+const HelperArg = "__echo_sandbox"
+
+// helperExitCode 是辅助进程无法施加限制时的退出码。
+const helperExitCode = 125
+
+// accessDenied 匹配 EACCES/EROFS 的错误文本；只有涉及可写目录之外的路径时才算沙箱拦截。
+var accessDenied = regexp.MustCompile(`(?i)permission denied|read-only file system`)
+
+// networkMarkers 是没有网络时的常见错误，只在策略关闭网络时才算沙箱拦截。
+var networkMarkers = []string{
+	"network is unreachable",
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"name or service not known",
+}
+
+// quotedPath 匹配错误信息中加引号的路径，如 touch: cannot touch '/etc/x' 或 mkdir 的 ‘/x’。
+var quotedPath = regexp.MustCompile("['‘\"`]([^'’\"`]+)['’\"`]")
+
+// Denied 按输出判断一次失败的受限命令是否被沙箱拦截：辅助进程自身的错误、
+// 涉及可写目录之外路径的 EACCES/EROFS，以及关闭网络时的网络错误。普通的权限或网络失败不算。
+func (p Policy) Denied(output string) bool {
+	if !p.Active() {
+		return false
+	}
+	if strings.Contains(output, ErrorPrefix) {
+		return true
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if !p.NetworkAccess {
+			lower := strings.ToLower(line)
+			for _, marker := range networkMarkers {
+				if strings.Contains(lower, marker) {
+					return true
+				}
+			}
+		}
+		if !accessDenied.MatchString(line) {
+			continue
+		}
+		for _, path := range pathCandidates(line) {
+			if !p.CanWrite(path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathCandidates 从一行错误信息中取出可能的路径：引号内的文本、含 "/" 的词，
+// 以及按 ": " 分段后每段的最后一个词（如 "sh: 1: cannot create file.txt: Permission denied" 中的 file.txt）。
+func pathCandidates(line string) []string {
+	var out []string
+	for _, m := range quotedPath.FindAllStringSubmatch(line, -1) {
+		out = append(out, m[1])
+	}
+	for _, part := range strings.Split(accessDenied.ReplaceAllString(line, ""), ": ") {
+		fields := strings.Fields(part)
+		for i, f := range fields {
+			f = strings.Trim(f, "'\"‘’`,;()[]")
+			if f != "" && (strings.Contains(f, "/") || i == len(fields)-1) {
+				out = append(out, expandHome(f))
+			}
+		}
+	}
+	return out
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
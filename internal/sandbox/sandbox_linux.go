@@ -0,0 +1,205 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// helperSpec 是传给沙箱辅助进程的限制参数。
+type helperSpec struct {
+	Writable []string `json:"writable,omitempty"`
+	// Loopback 表示命令运行在新的 network namespace 中，需要先启用 lo。
+	Loopback bool `json:"loopback,omitempty"`
+}
+
+// devicePaths 在所有受限模式下保持可写，否则 shell 的重定向与 pty 会失败。
+var devicePaths = []string{
+	"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom",
+	"/dev/tty", "/dev/ptmx", "/dev/pts", "/dev/shm",
+}
+
+const (
+	accessRead = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	// accessFile 是可以授予单个文件（而非目录）的权限。
+	accessFile = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+)
+
+// Supported 报告当前内核是否可用 landlock。
+func Supported() error {
+	_, err := landlockABI()
+	return err
+}
+
+// Wrap 把 cmd 改写为经由沙箱辅助进程执行：辅助进程先施加 landlock 限制再 exec 原命令；
+// 关闭网络时辅助进程运行在新的 user/net namespace 中。
+func Wrap(cmd *exec.Cmd, p Policy) error {
+	if !p.Active() {
+		return nil
+	}
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	spec, err := json.Marshal(helperSpec{Writable: p.writableRoots(), Loopback: !p.NetworkAccess})
+	if err != nil {
+		return err
+	}
+	args := []string{exe, HelperArg, string(spec), "--", cmd.Path}
+	cmd.Args = append(args, cmd.Args...)
+	cmd.Path = exe
+	if !p.NetworkAccess {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		uid, gid := os.Getuid(), os.Getgid()
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+	}
+	return nil
+}
+
+// RunHelper 是沙箱辅助进程的入口（echo-cli __echo_sandbox <spec> -- <path> <argv...>）。
+// 成功时以目标命令替换当前进程，不会返回；失败时输出 ErrorPrefix 开头的错误并退出。
+func RunHelper(args []string) {
+	// landlock 只作用于调用线程，随后的 execve 也必须发生在同一线程上。
+	runtime.LockOSThread()
+	if err := runHelper(args); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", ErrorPrefix, err)
+		os.Exit(helperExitCode)
+	}
+}
+
+func runHelper(args []string) error {
+	if len(args) < 4 || args[1] != "--" {
+		return errors.New("usage: " + HelperArg + " <spec> -- <path> <argv...>")
+	}
+	var spec helperSpec
+	if err := json.Unmarshal([]byte(args[0]), &spec); err != nil {
+		return fmt.Errorf("parse spec: %w", err)
+	}
+	if spec.Loopback {
+		// 启用失败只影响本地回环连接，不阻止命令执行。
+		_ = loopbackUp()
+	}
+	if err := restrictFilesystem(spec.Writable); err != nil {
+		return err
+	}
+	path, argv := args[2], args[3:]
+	if err := syscall.Exec(path, argv, os.Environ()); err != nil {
+		return fmt.Errorf("exec %s: %w", path, err)
+	}
+	return nil
+}
+
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, fmt.Errorf("landlock unavailable: %w", errno)
+	}
+	return int(abi), nil
+}
+
+// handledAccess 返回当前 ABI 支持的全部文件系统权限。
+func handledAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR | unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK | unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return access
+}
+
+// restrictFilesystem 对当前线程施加 landlock：全局只读，writable 与设备文件可写。
+func restrictFilesystem(writable []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+	handled := handledAccess(abi)
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	// 只处理文件系统权限，结构体只需传入 access_fs 字段。
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr.Access_fs), 0)
+	if errno != 0 {
+		return fmt.Errorf("create landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	if err := addPathRule(ruleset, "/", accessRead&handled); err != nil {
+		return err
+	}
+	for _, path := range append(append([]string{}, devicePaths...), writable...) {
+		if err := addPathRule(ruleset, path, handled); err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("landlock restrict self: %w", errno)
+	}
+	return nil
+}
+
+func addPathRule(ruleset int, path string, access uint64) error {
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= accessFile
+	}
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock rule for %s: %w", path, errno)
+	}
+	return nil
+}
+
+// loopbackUp 在新的 network namespace 中启用 lo，使本地回环仍然可用。
+func loopbackUp() error {
+	sock, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(sock)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(sock, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return err
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	return unix.IoctlIfreq(sock, unix.SIOCSIFFLAGS, ifr)
+}
@@ -0,0 +1,84 @@
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == HelperArg {
+		RunHelper(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+func runSandboxed(t *testing.T, p Policy, script string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("bash", "-c", script)
+	cmd.Dir = p.Workdir
+	if err := Wrap(cmd, p); err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func requireSandbox(t *testing.T) {
+	t.Helper()
+	if err := Supported(); err != nil {
+		t.Skipf("landlock not available: %v", err)
+	}
+}
+
+func TestWorkspaceWriteConfinesWrites(t *testing.T) {
+	requireSandbox(t)
+	workdir := t.TempDir()
+	p := Policy{Mode: WorkspaceWrite, Workdir: workdir, NetworkAccess: true}
+	if out, err := runSandboxed(t, p, "echo ok > inside.txt && cat inside.txt"); err != nil || strings.TrimSpace(out) != "ok" {
+		t.Fatalf("expected write inside workdir to succeed, out=%q err=%v", out, err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	outside := filepath.Join(home, ".echo-sandbox-test")
+	out, err := runSandboxed(t, p, "touch "+outside)
+	_ = os.Remove(outside)
+	if err == nil || !p.Denied(out) {
+		t.Fatalf("expected write outside workdir to be denied, out=%q err=%v", out, err)
+	}
+}
+
+func TestReadOnlyDeniesWorkdirWrites(t *testing.T) {
+	requireSandbox(t)
+	workdir := t.TempDir()
+	p := Policy{Mode: ReadOnly, Workdir: workdir, NetworkAccess: true}
+	out, err := runSandboxed(t, p, "cat /etc/hostname >/dev/null && echo x > file.txt")
+	if err == nil || !p.Denied(out) {
+		t.Fatalf("expected read-only sandbox to deny writes, out=%q err=%v", out, err)
+	}
+	if _, statErr := os.Stat(filepath.Join(workdir, "file.txt")); statErr == nil {
+		t.Fatalf("file should not have been created")
+	}
+}
+
+func TestNetworkOffUsesSeparateNamespace(t *testing.T) {
+	requireSandbox(t)
+	if err := exec.Command("unshare", "-Urn", "true").Run(); err != nil {
+		t.Skipf("user namespaces not available: %v", err)
+	}
+	host, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Skip("no /proc/self/ns/net")
+	}
+	out, err := runSandboxed(t, Policy{Mode: WorkspaceWrite, Workdir: t.TempDir()}, "readlink /proc/self/ns/net")
+	if err != nil {
+		t.Fatalf("sandboxed command failed: %v (%s)", err, out)
+	}
+	if strings.TrimSpace(out) == host {
+		t.Fatalf("expected a separate network namespace, got %s", out)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+var errUnsupported = errors.New("sandboxed execution is only supported on Linux")
+
+// Supported 报告当前平台是否支持受限执行。
+func Supported() error { return errUnsupported }
+
+// Wrap 在非 Linux 平台上无法施加限制；受限模式直接报错（fail closed）。
+func Wrap(cmd *exec.Cmd, p Policy) error {
+	if !p.Active() {
+		return nil
+	}
+	return errUnsupported
+}
+
+// RunHelper 在非 Linux 平台上不可用。
+func RunHelper(args []string) {
+	fmt.Fprintf(os.Stderr, "%s %v\n", ErrorPrefix, errUnsupported)
+	os.Exit(helperExitCode)
+}
@@ -0,0 +1,63 @@
+package sandbox
+
+import "testing"
+
+func TestPolicyCanWrite(t *testing.T) {
+	workdir := t.TempDir()
+	p := Policy{Mode: WorkspaceWrite, Workdir: workdir, WritableRoots: []string{"/opt/cache"}}
+	if !p.CanWrite("src/main.go") || !p.CanWrite("/opt/cache/x") {
+		t.Fatalf("expected workdir and writable roots to be writable")
+	}
+	if p.CanWrite("/usr/local/escape.txt") || p.CanWrite("/etc/passwd") {
+		t.Fatalf("expected paths outside writable roots to be denied")
+	}
+	if (Policy{Mode: ReadOnly, Workdir: workdir}).CanWrite("a.txt") {
+		t.Fatalf("read-only policy must deny writes")
+	}
+	if !(Policy{Mode: FullAccess}).CanWrite("/etc/passwd") {
+		t.Fatalf("full access must allow writes")
+	}
+}
+
+func TestPolicyDenied(t *testing.T) {
+	workdir := t.TempDir()
+	p := Policy{Mode: WorkspaceWrite, Workdir: workdir}
+	cases := []struct {
+		policy Policy
+		output string
+		want   bool
+	}{
+		{p, ErrorPrefix + " landlock is not supported by this kernel", true},
+		{p, "touch: cannot touch '/usr/local/x': Permission denied", true},
+		{p, "mkdir: cannot create directory ‘/opt/app’: Permission denied", true},
+		{p, "PermissionError: [Errno 13] Permission denied: '/etc/app.conf'", true},
+		{p, "bash: line 1: /var/lib/x: Read-only file system", true},
+		// 可写目录内的权限错误是普通失败（例如文件本身只读）。
+		{p, "bash: line 1: out.txt: Permission denied", false},
+		{p, "open " + workdir + "/secret: permission denied", false},
+		{p, "git@github.com: Permission denied (publickey).", false},
+		{p, "curl: (6) Could not resolve host: example.invalid", true},
+		{Policy{Mode: WorkspaceWrite, Workdir: workdir, NetworkAccess: true}, "curl: (6) Could not resolve host: example.invalid", false},
+		{Policy{Mode: WorkspaceWrite, Workdir: workdir, NetworkAccess: true}, "ssh: Could not resolve hostname x: Name or service not known", false},
+		{Policy{Mode: ReadOnly, Workdir: workdir, NetworkAccess: true}, "sh: 1: cannot create file.txt: Permission denied", true},
+		{Policy{Mode: FullAccess}, ErrorPrefix + " x", false},
+		{p, "exit status 1", false},
+	}
+	for _, c := range cases {
+		if got := c.policy.Denied(c.output); got != c.want {
+			t.Errorf("%s: Denied(%q) = %v, want %v", c.policy.Describe(), c.output, got, c.want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if m, err := ParseMode("workspace_write"); err != nil || m != WorkspaceWrite {
+		t.Fatalf("got %q %v", m, err)
+	}
+	if m, _ := ParseMode(""); m != FullAccess {
+		t.Fatalf("empty mode should be full-access, got %q", m)
+	}
+	if _, err := ParseMode("yolo"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
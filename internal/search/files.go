@@ -1,36 +1,28 @@
 package search
 
 import (
-	"io/fs"
+	"context"
 	"path/filepath"
 )
 
-// FindFiles returns up to limit relative file paths under root, skipping common ignores.
+// FindFiles 返回 root 下最多 limit 个未被忽略的文件（相对路径），按相关度排序：层级浅的在前。
+// 遍历结果由进程级索引缓存，文件系统变化后自动刷新。
 func FindFiles(root string, limit int) ([]string, error) {
 	if limit <= 0 {
 		limit = 200
 	}
-	paths := make([]string, 0, limit)
-	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			name := d.Name()
-			if name == ".git" || name == "node_modules" || name == ".idea" || name == "target" || name == "vendor" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-		rel, err := filepath.Rel(root, path)
-		if err != nil {
-			return err
-		}
-		paths = append(paths, rel)
-		if len(paths) >= limit {
-			return fs.SkipAll
-		}
-		return nil
-	})
-	return paths, err
+	return SearchFiles(context.Background(), root, "", limit)
+}
+
+// SearchFiles 返回与 query 模糊匹配的前 limit 个文件（相对路径），按匹配得分排序；query 为空时同 FindFiles。
+func SearchFiles(ctx context.Context, root, query string, limit int) ([]string, error) {
+	matches, err := DefaultIndex().Find(ctx, root, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(matches))
+	for i, m := range matches {
+		paths[i] = filepath.FromSlash(m.Path)
+	}
+	return paths, nil
 }
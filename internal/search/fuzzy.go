@@ -0,0 +1,150 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// FuzzyMatch 是一个模糊匹配结果；Positions 为命中的 rune 下标，可用于高亮。
+type FuzzyMatch struct {
+	Index     int
+	Path      string
+	Score     int
+	Positions []int
+}
+
+const (
+	scoreMatch       = 16
+	bonusConsecutive = 16
+	bonusSegment     = 12 // 路径段开头（/ 之后）
+	bonusBoundary    = 8  // 单词边界（_ - . 空格之后）或驼峰
+	bonusBasename    = 4  // 命中文件名部分
+	bonusFirstChar   = 6
+	penaltyGap       = 1
+)
+
+// FuzzyFind 按子序列匹配 query（不区分大小写，忽略空白），返回按得分降序排列的结果；
+// 得分相同时较短、字典序靠前的路径优先。query 为空时按原顺序返回全部路径。
+func FuzzyFind(query string, paths []string) []FuzzyMatch {
+	q := []rune(strings.ToLower(strings.Join(strings.Fields(query), "")))
+	out := make([]FuzzyMatch, 0, len(paths))
+	for i, p := range paths {
+		if len(q) == 0 {
+			out = append(out, FuzzyMatch{Index: i, Path: p})
+			continue
+		}
+		score, positions, ok := fuzzyScore(q, []rune(p))
+		if !ok {
+			continue
+		}
+		out = append(out, FuzzyMatch{Index: i, Path: p, Score: score, Positions: positions})
+	}
+	if len(q) == 0 {
+		return out
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if len(out[i].Path) != len(out[j].Path) {
+			return len(out[i].Path) < len(out[j].Path)
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// fuzzyScore 用动态规划求 q 在 text 中得分最高的子序列对齐。
+func fuzzyScore(q, text []rune) (int, []int, bool) {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+	// 先做一次贪心检查，排除不含该子序列的路径。
+	for i, j := 0, 0; i < len(q); j++ {
+		if j == len(lower) {
+			return 0, nil, false
+		}
+		if lower[j] == q[i] {
+			i++
+		}
+	}
+
+	base := strings.LastIndex(string(text), "/")
+	baseStart := len([]rune(string(text)[:base+1]))
+	bonus := make([]int, len(text))
+	for j := range text {
+		switch {
+		case j == 0:
+			bonus[j] = bonusFirstChar
+		case text[j-1] == '/':
+			bonus[j] = bonusSegment
+		case strings.ContainsRune("_-. ", text[j-1]):
+			bonus[j] = bonusBoundary
+		case unicode.IsLower(text[j-1]) && unicode.IsUpper(text[j]):
+			bonus[j] = bonusBoundary
+		}
+		if j >= baseStart {
+			bonus[j] += bonusBasename
+		}
+	}
+
+	const none = -1 << 30
+	n, m := len(q), len(text)
+	score := make([][]int, n)
+	from := make([][]int, n)
+	for i := range n {
+		score[i] = make([]int, m)
+		from[i] = make([]int, m)
+		for j := range m {
+			score[i][j] = none
+		}
+	}
+	for j := range m {
+		if lower[j] == q[0] {
+			score[0][j] = scoreMatch + bonus[j] - min(j, 8)*penaltyGap
+			from[0][j] = -1
+		}
+	}
+	for i := 1; i < n; i++ {
+		// best 记录 k < j-1 范围内 score[i-1][k] + k*penaltyGap 的最大值，使间隔惩罚可在 O(1) 内计算。
+		best, bestK := none, -1
+		for j := i; j < m; j++ {
+			if k := j - 2; k >= 0 && score[i-1][k] != none && score[i-1][k]+k*penaltyGap > best {
+				best, bestK = score[i-1][k]+k*penaltyGap, k
+			}
+			if lower[j] != q[i] {
+				continue
+			}
+			cand, prev := none, -1
+			if bestK >= 0 {
+				cand, prev = best-(j-1)*penaltyGap+scoreMatch+bonus[j], bestK
+			}
+			if k := j - 1; score[i-1][k] != none {
+				if c := score[i-1][k] + scoreMatch + bonus[j] + bonusConsecutive; c >= cand {
+					cand, prev = c, k
+				}
+			}
+			if prev >= 0 {
+				score[i][j], from[i][j] = cand, prev
+			}
+		}
+	}
+
+	bestScore, end := none, -1
+	for j := range m {
+		if score[n-1][j] > bestScore {
+			bestScore, end = score[n-1][j], j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	positions := make([]int, n)
+	for i := n - 1; i >= 0; i-- {
+		positions[i] = end
+		end = from[i][end]
+	}
+	return bestScore, positions, true
+}
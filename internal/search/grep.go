@@ -0,0 +1,377 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultMaxResults 是未指定时返回的匹配行上限。
+	DefaultMaxResults = 100
+	// DefaultMaxBytes 是 FormatGrep 未指定时的输出字节上限。
+	DefaultMaxBytes = 32 * 1024
+	// maxFileBytes 以上的文件不搜索（多为生成文件或数据文件）。
+	maxFileBytes = 8 * 1024 * 1024
+	// maxLineRunes 截断超长行（压缩后的 JS、单行 JSON 等）。
+	maxLineRunes = 400
+	// binarySniffBytes 内出现 NUL 字节即视为二进制文件。
+	binarySniffBytes = 8000
+)
+
+// GrepOptions 描述一次内容搜索。
+type GrepOptions struct {
+	Pattern string
+	// Literal 为 true 时按字面量匹配，否则 Pattern 为 Go 正则（RE2）。
+	Literal    bool
+	IgnoreCase bool
+	// Path 是相对 root 的子目录或文件，空表示整个 root。
+	Path string
+	// Include/Exclude 是 glob：不含 / 时匹配文件名，否则匹配相对路径（支持 **）。
+	Include []string
+	Exclude []string
+	// Context 是每个匹配前后附带的行数。
+	Context int
+	// MaxResults 是匹配行上限，<=0 时使用 DefaultMaxResults。
+	MaxResults int
+}
+
+// GrepLine 是输出中的一行；Match 为 false 表示上下文行。
+type GrepLine struct {
+	Number int
+	Text   string
+	Match  bool
+}
+
+// GrepFile 汇总单个文件的匹配行与上下文行（按行号排序）。
+type GrepFile struct {
+	Path    string
+	Lines   []GrepLine
+	Matches int
+}
+
+// GrepResult 是一次搜索的结果；Truncated 表示达到 MaxResults 后停止。
+type GrepResult struct {
+	Files         []GrepFile
+	Matches       int
+	FilesSearched int
+	Truncated     bool
+}
+
+// Grep 在 root 下搜索内容：遵循 .gitignore，跳过二进制与超大文件，多个文件并行扫描。
+// 结果按路径与行号排序，与并发度无关；可被多个调用方同时使用。
+func Grep(ctx context.Context, root string, opts GrepOptions) (GrepResult, error) {
+	re, err := compilePattern(opts)
+	if err != nil {
+		return GrepResult{}, err
+	}
+	include, err := compileFilters(opts.Include)
+	if err != nil {
+		return GrepResult{}, err
+	}
+	exclude, err := compileFilters(opts.Exclude)
+	if err != nil {
+		return GrepResult{}, err
+	}
+	maxResults := opts.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxResults
+	}
+	contextLines := max(opts.Context, 0)
+
+	files, err := grepTargets(ctx, root, opts.Path, include, exclude)
+	if err != nil {
+		return GrepResult{}, err
+	}
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]*GrepFile, len(files))
+	done := make([]bool, len(files))
+	var (
+		mu        sync.Mutex
+		next      int
+		collected int
+	)
+	// 按文件顺序累计已完成的前缀；匹配数达到上限后取消剩余扫描，结果仍然确定。
+	finish := func(idx int, file *GrepFile) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[idx] = file
+		done[idx] = true
+		for next < len(done) && done[next] {
+			if results[next] != nil {
+				collected += results[next].Matches
+			}
+			next++
+		}
+		if collected >= maxResults {
+			cancel()
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), max(len(files), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				finish(idx, grepFile(root, files[idx], re, contextLines))
+			}
+		}()
+	}
+feed:
+	for idx := range files {
+		select {
+		case jobs <- idx:
+		case <-scanCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return GrepResult{}, err
+	}
+
+	var res GrepResult
+	for idx, file := range results {
+		if !done[idx] {
+			break
+		}
+		res.FilesSearched++
+		if file == nil {
+			continue
+		}
+		if res.Matches+file.Matches > maxResults {
+			*file = truncateFile(*file, maxResults-res.Matches)
+			res.Truncated = true
+		}
+		res.Files = append(res.Files, *file)
+		res.Matches += file.Matches
+		if res.Matches >= maxResults {
+			res.Truncated = res.Truncated || idx < len(results)-1
+			break
+		}
+	}
+	return res, nil
+}
+
+func compilePattern(opts GrepOptions) (*regexp.Regexp, error) {
+	if opts.Pattern == "" {
+		return nil, errors.New("empty search pattern")
+	}
+	expr := opts.Pattern
+	if opts.Literal {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if opts.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", opts.Pattern, err)
+	}
+	return re, nil
+}
+
+type globFilter struct {
+	re       *regexp.Regexp
+	basename bool
+}
+
+func compileFilters(globs []string) ([]globFilter, error) {
+	var out []globFilter
+	for _, g := range globs {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		basename := !strings.Contains(g, "/")
+		re, err := compileGlob(strings.TrimPrefix(g, "/"), true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+		out = append(out, globFilter{re: re, basename: basename})
+	}
+	return out, nil
+}
+
+func matchAny(filters []globFilter, rel string) bool {
+	for _, f := range filters {
+		target := rel
+		if f.basename {
+			target = path.Base(rel)
+		}
+		if f.re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// grepTargets 列出待搜索的文件（相对 root，/ 分隔）；sub 指向单个文件时直接返回该文件。
+func grepTargets(ctx context.Context, root, sub string, include, exclude []globFilter) ([]string, error) {
+	sub = strings.Trim(filepath.ToSlash(filepath.Clean(sub)), "/")
+	if sub == "." {
+		sub = ""
+	}
+	if sub != "" {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(sub)))
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{sub}, nil
+		}
+	}
+	all, err := DefaultIndex().Files(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, rel := range all {
+		if sub != "" && !strings.HasPrefix(rel, sub+"/") {
+			continue
+		}
+		if len(include) > 0 && !matchAny(include, rel) {
+			continue
+		}
+		if matchAny(exclude, rel) {
+			continue
+		}
+		files = append(files, rel)
+	}
+	return files, nil
+}
+
+// grepFile 扫描单个文件；无匹配、二进制、超大或不可读时返回 nil。
+func grepFile(root, rel string, re *regexp.Regexp, contextLines int) *GrepFile {
+	full := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileBytes {
+		return nil
+	}
+	data, err := os.ReadFile(full)
+	if err != nil || bytes.IndexByte(data[:min(len(data), binarySniffBytes)], 0) >= 0 {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	file := &GrepFile{Path: rel}
+	emitted := 0 // 已输出到的行号（1 起）
+	for i, line := range lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		for j := max(i-contextLines, emitted); j < i; j++ {
+			file.Lines = append(file.Lines, GrepLine{Number: j + 1, Text: clipLine(lines[j])})
+		}
+		if i < emitted {
+			// 该行已作为上一个匹配的后置上下文输出，改标为匹配行。
+			for k := range file.Lines {
+				if file.Lines[k].Number == i+1 {
+					file.Lines[k].Match = true
+				}
+			}
+		} else {
+			file.Lines = append(file.Lines, GrepLine{Number: i + 1, Text: clipLine(line), Match: true})
+		}
+		file.Matches++
+		emitted = max(emitted, i+1)
+		for j := emitted; j < min(i+1+contextLines, len(lines)); j++ {
+			file.Lines = append(file.Lines, GrepLine{Number: j + 1, Text: clipLine(lines[j])})
+			emitted = j + 1
+		}
+	}
+	if file.Matches == 0 {
+		return nil
+	}
+	return file
+}
+
+func clipLine(line string) string {
+	line = strings.TrimRight(line, "\r")
+	if !utf8.ValidString(line) {
+		line = strings.ToValidUTF8(line, "�")
+	}
+	if utf8.RuneCountInString(line) <= maxLineRunes {
+		return line
+	}
+	return string([]rune(line)[:maxLineRunes]) + " …"
+}
+
+// truncateFile 只保留前 keep 个匹配行及其之前的上下文。
+func truncateFile(file GrepFile, keep int) GrepFile {
+	matches := 0
+	for i, line := range file.Lines {
+		if !line.Match {
+			continue
+		}
+		if matches == keep {
+			file.Lines = file.Lines[:i]
+			break
+		}
+		matches++
+	}
+	file.Matches = matches
+	return file
+}
+
+// FormatGrep 以 rg --no-heading 风格输出结果：匹配行为 path:line:text，上下文行为 path-line-text，
+// 不相邻的片段之间用 -- 分隔；超过 maxBytes（<=0 时为 DefaultMaxBytes）的部分被截断并注明。
+func FormatGrep(res GrepResult, maxBytes int) string {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if res.Matches == 0 {
+		return fmt.Sprintf("no matches (%d files searched)", res.FilesSearched)
+	}
+	var sb strings.Builder
+	shown := 0
+	cut := false
+write:
+	for _, file := range res.Files {
+		prev := 0
+		for _, line := range file.Lines {
+			var entry string
+			if prev > 0 && line.Number > prev+1 {
+				entry = "--\n"
+			}
+			sep := "-"
+			if line.Match {
+				sep = ":"
+			}
+			entry += file.Path + sep + fmt.Sprint(line.Number) + sep + line.Text + "\n"
+			if sb.Len()+len(entry) > maxBytes {
+				cut = true
+				break write
+			}
+			sb.WriteString(entry)
+			if line.Match {
+				shown++
+			}
+			prev = line.Number
+		}
+		if len(file.Lines) > 0 && sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+	}
+	out := strings.TrimRight(sb.String(), "\n")
+	switch {
+	case cut:
+		out += fmt.Sprintf("\n[output truncated at %d bytes: showing %d of %d matches; narrow the pattern, path or include globs]", maxBytes, shown, res.Matches)
+	case res.Truncated:
+		out += fmt.Sprintf("\n[stopped after %d matches; raise max_results or narrow the search]", res.Matches)
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule 是 .gitignore 中的一行规则；base 为该文件所在目录（相对根目录，/ 分隔）。
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	base    string
+}
+
+// ignoreMatcher 按 git 语义判断路径是否被忽略：后出现的规则优先，子目录的规则排在父目录之后。
+// 值不可变，进入子目录时通过 withFile 派生新的 matcher，可在并发遍历中共享。
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// withFile 读取 dir（相对根目录）下的忽略文件并返回追加了其规则的 matcher；文件不存在时返回自身。
+func (m *ignoreMatcher) withFile(root, dir, name string) *ignoreMatcher {
+	f, err := os.Open(path.Join(root, dir, name))
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+	var added []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), dir); ok {
+			added = append(added, rule)
+		}
+	}
+	if len(added) == 0 {
+		return m
+	}
+	next := &ignoreMatcher{rules: make([]ignoreRule, 0, len(m.rules)+len(added))}
+	next.rules = append(append(next.rules, m.rules...), added...)
+	return next
+}
+
+// ignored 判断相对根目录的路径（/ 分隔）是否被忽略。
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			var ok bool
+			if target, ok = strings.CutPrefix(rel, rule.base+"/"); !ok {
+				continue
+			}
+		}
+		if rule.re.MatchString(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	// 含有中间斜杠的模式相对忽略文件所在目录锚定，否则可匹配任意层级。
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	re, err := compileGlob(line, anchored)
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+// compileGlob 把 gitignore 风格的 glob 转为正则：* 与 ? 不跨越 /，** 匹配任意层级目录。
+// anchored 为 false 时模式可以匹配任意目录下的同名路径。
+func compileGlob(pattern string, anchored bool) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				atStart := i == 0 || pattern[i-1] == '/'
+				i++
+				if atStart && i+1 < len(pattern) && pattern[i+1] == '/' {
+					// "**/" 匹配零个或多个目录。
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
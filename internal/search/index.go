@@ -0,0 +1,309 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxIndexFiles 是单个根目录索引的文件数上限，防止超大目录占满内存。
+	maxIndexFiles = 200000
+	// maxCachedRoots 是同时缓存的根目录数，超出时淘汰最久未使用的。
+	maxCachedRoots = 16
+	// racyWindow 内修改过的目录无法仅凭 mtime 证明之后未再变化（时间精度有限），此类快照下次使用前重新遍历。
+	racyWindow = 2 * time.Second
+)
+
+// defaultIgnores 是优先级最低的内置规则（常见依赖/构建目录），可被配置或 .gitignore 中的 ! 规则取消。
+// .git 目录始终跳过。
+var defaultIgnores = []string{"node_modules/", ".idea/", "target/", "vendor/"}
+
+// Index 缓存各根目录下未被忽略的文件列表。规则依次为内置规则、附加规则、.git/info/exclude、
+// 各级 .gitignore 与 .ignore，后者优先。快照在每次使用前核对目录与忽略文件的 mtime，有变化时重新遍历。
+// 可被多个 goroutine 同时使用。
+type Index struct {
+	base *ignoreMatcher
+
+	mu    sync.Mutex
+	roots map[string]*rootEntry
+}
+
+type rootEntry struct {
+	mu   sync.Mutex // 串行化同一根目录的构建，并发调用方复用同一次遍历
+	snap *snapshot
+	used time.Time
+}
+
+type snapshot struct {
+	files  []string // 相对路径，/ 分隔，按路径排序
+	stamps []stamp
+	racy   bool
+}
+
+// stamp 记录构建时某个目录或忽略文件的 mtime。
+type stamp struct {
+	path string
+	mod  time.Time
+}
+
+// NewIndex 创建索引；ignore 为 gitignore 语法的附加规则，相对根目录求值。
+func NewIndex(ignore []string) *Index {
+	base := &ignoreMatcher{}
+	for _, line := range append(append([]string{}, defaultIgnores...), ignore...) {
+		if rule, ok := parseIgnoreLine(line, ""); ok {
+			base.rules = append(base.rules, rule)
+		}
+	}
+	return &Index{base: base, roots: map[string]*rootEntry{}}
+}
+
+var (
+	indexMu      sync.RWMutex
+	defaultIndex = NewIndex(nil)
+)
+
+// SetIgnorePatterns 设置进程级索引的附加忽略规则（配置 [search] ignore），并清空缓存。
+func SetIgnorePatterns(patterns []string) {
+	ix := NewIndex(patterns)
+	indexMu.Lock()
+	defaultIndex = ix
+	indexMu.Unlock()
+}
+
+// DefaultIndex 返回进程级索引。
+func DefaultIndex() *Index {
+	indexMu.RLock()
+	defer indexMu.RUnlock()
+	return defaultIndex
+}
+
+// Files 返回 root 下未被忽略的文件（/ 分隔的相对路径，按路径排序）；返回的切片被缓存共享，调用方不得修改。
+func (ix *Index) Files(ctx context.Context, root string) ([]string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	entry := ix.entry(abs)
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.snap != nil && entry.snap.fresh() {
+		return entry.snap.files, nil
+	}
+	snap, err := buildSnapshot(ctx, abs, ix.base)
+	if err != nil {
+		entry.snap = nil
+		return nil, err
+	}
+	entry.snap = snap
+	return snap.files, nil
+}
+
+// Find 返回与 query 模糊匹配的前 limit 个文件（limit<=0 表示不限）。query 为空时按相关度排序：
+// 层级浅的在前，其次按路径。
+func (ix *Index) Find(ctx context.Context, root, query string, limit int) ([]FuzzyMatch, error) {
+	files, err := ix.Files(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	var matches []FuzzyMatch
+	if strings.TrimSpace(query) != "" {
+		matches = FuzzyFind(query, files)
+	} else {
+		matches = make([]FuzzyMatch, len(files))
+		for i, p := range files {
+			matches[i] = FuzzyMatch{Index: i, Path: p}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			di, dj := strings.Count(matches[i].Path, "/"), strings.Count(matches[j].Path, "/")
+			if di != dj {
+				return di < dj
+			}
+			return matches[i].Path < matches[j].Path
+		})
+	}
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (ix *Index) entry(root string) *rootEntry {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	entry, ok := ix.roots[root]
+	if !ok {
+		if len(ix.roots) >= maxCachedRoots {
+			oldest := ""
+			for key, e := range ix.roots {
+				if oldest == "" || e.used.Before(ix.roots[oldest].used) {
+					oldest = key
+				}
+			}
+			delete(ix.roots, oldest)
+		}
+		entry = &rootEntry{}
+		ix.roots[root] = entry
+	}
+	entry.used = time.Now()
+	return entry
+}
+
+// fresh 并行核对快照记录的 mtime；任何目录或忽略文件有变化（含被删除）时返回 false。
+func (s *snapshot) fresh() bool {
+	if s.racy {
+		return false
+	}
+	var stale atomic.Bool
+	var wg sync.WaitGroup
+	workers := min(runtime.NumCPU(), max(len(s.stamps)/64, 1))
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(s.stamps) && !stale.Load(); i += workers {
+				info, err := os.Stat(s.stamps[i].path)
+				if err != nil || !info.ModTime().Equal(s.stamps[i].mod) {
+					stale.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return !stale.Load()
+}
+
+// walker 并发遍历目录树：每个目录一个 goroutine，由信号量限制同时进行的 I/O。
+type walker struct {
+	ctx  context.Context
+	root string
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	files   []string
+	stamps  []stamp
+	rootErr error
+	full    atomic.Bool
+}
+
+func buildSnapshot(ctx context.Context, root string, base *ignoreMatcher) (*snapshot, error) {
+	started := time.Now()
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	w := &walker{ctx: ctx, root: root, sem: make(chan struct{}, 2*runtime.NumCPU())}
+	// .git 与 .git/info 不参与遍历，单独记录 mtime 以发现 exclude 文件的新建。
+	for _, meta := range []string{".git", filepath.Join(".git", "info")} {
+		if info, err := os.Stat(filepath.Join(root, meta)); err == nil && info.IsDir() {
+			w.record(stamp{path: filepath.Join(root, meta), mod: info.ModTime()})
+		}
+	}
+	ignore := w.loadIgnore(base, "", ".git/info/exclude")
+	w.wg.Add(1)
+	go w.visit("", ignore)
+	w.wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if w.rootErr != nil {
+		return nil, w.rootErr
+	}
+	sort.Strings(w.files)
+	snap := &snapshot{files: w.files, stamps: w.stamps}
+	for _, st := range w.stamps {
+		if !st.mod.Before(started.Add(-racyWindow)) {
+			snap.racy = true
+			break
+		}
+	}
+	// 达到上限时结果不完整，不缓存为可复用的快照。
+	snap.racy = snap.racy || w.full.Load()
+	return snap, nil
+}
+
+func (w *walker) visit(dir string, ignore *ignoreMatcher) {
+	defer w.wg.Done()
+	if w.ctx.Err() != nil || w.full.Load() {
+		return
+	}
+	w.sem <- struct{}{}
+	abs := filepath.Join(w.root, filepath.FromSlash(dir))
+	// 先取 mtime 再读目录：两者之间的修改会使下次核对失败，而不会被遗漏。
+	info, statErr := os.Stat(abs)
+	entries, err := os.ReadDir(abs)
+	if err != nil || statErr != nil {
+		<-w.sem
+		if dir == "" {
+			w.mu.Lock()
+			w.rootErr = errors.Join(statErr, err)
+			w.mu.Unlock()
+		}
+		// 子目录不可读时跳过，与 rg 的行为一致。
+		return
+	}
+	w.record(stamp{path: abs, mod: info.ModTime()})
+	ignore = w.loadIgnore(ignore, dir, ".gitignore")
+	ignore = w.loadIgnore(ignore, dir, ".ignore")
+	<-w.sem
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		rel := path.Join(dir, name)
+		if entry.IsDir() {
+			if name == ".git" || ignore.ignored(rel, true) {
+				continue
+			}
+			w.wg.Add(1)
+			go w.visit(rel, ignore)
+			continue
+		}
+		if !entry.Type().IsRegular() && entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if !ignore.ignored(rel, false) {
+			files = append(files, rel)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if room := maxIndexFiles - len(w.files); len(files) >= room {
+		files = files[:max(room, 0)]
+		w.full.Store(true)
+	}
+	w.files = append(w.files, files...)
+}
+
+// loadIgnore 追加 dir 下忽略文件的规则，并记录其 mtime 以便内容修改时使缓存失效。
+func (w *walker) loadIgnore(ignore *ignoreMatcher, dir, name string) *ignoreMatcher {
+	full := filepath.Join(w.root, filepath.FromSlash(dir), filepath.FromSlash(name))
+	info, err := os.Stat(full)
+	if err != nil || info.IsDir() {
+		return ignore
+	}
+	w.record(stamp{path: full, mod: info.ModTime()})
+	return ignore.withFile(w.root, dir, name)
+}
+
+func (w *walker) record(st stamp) {
+	w.mu.Lock()
+	w.stamps = append(w.stamps, st)
+	w.mu.Unlock()
+}
@@ -0,0 +1,217 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for rel, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	return root
+}
+
+func TestFindFilesHonoursNestedGitignore(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":          "*.log\nbuild/\n!keep.log\n",
+		"main.go":             "",
+		"debug.log":           "",
+		"keep.log":            "",
+		"build/out.txt":       "",
+		"pkg/.gitignore":      "/gen.go\n",
+		"pkg/gen.go":          "",
+		"pkg/sub/gen.go":      "",
+		"node_modules/x.js":   "",
+		"docs/build/index.md": "",
+	})
+	got, err := FindFiles(root, 0)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	want := []string{".gitignore", "keep.log", "main.go", "pkg/.gitignore", "pkg/sub/gen.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestIndexHonoursIgnoreFilesAndConfiguredPatterns(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".git/info/exclude":   "secret.txt\n",
+		".git/HEAD":           "",
+		".ignore":             "*.tmp\n",
+		"secret.txt":          "",
+		"a.tmp":               "",
+		"z/deep/main.go":      "",
+		"b.go":                "",
+		"vendor/lib.go":       "",
+		"generated/schema.go": "",
+		"local/.ignore":       "!keep.tmp\n",
+		"local/keep.tmp":      "",
+	})
+	ix := NewIndex([]string{"generated/", "!vendor/"})
+	got, err := ix.Files(context.Background(), root)
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+	want := []string{".ignore", "b.go", "local/.ignore", "local/keep.tmp", "vendor/lib.go", "z/deep/main.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	matches, err := ix.Find(context.Background(), root, "", 3)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	var paths []string
+	for _, m := range matches {
+		paths = append(paths, m.Path)
+	}
+	if want := []string{".ignore", "b.go", "local/.ignore"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("empty query should rank shallow paths first, got %v", paths)
+	}
+	matches, _ = ix.Find(context.Background(), root, "main", 0)
+	if len(matches) != 1 || matches[0].Path != "z/deep/main.go" {
+		t.Fatalf("unexpected fuzzy matches %+v", matches)
+	}
+}
+
+func TestIndexCachesUntilTreeChanges(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore": "*.log\n",
+		"a.go":       "",
+		"pkg/b.go":   "",
+		"pkg/c.log":  "",
+	})
+	// 把 mtime 调到很久以前，避免快照因落在时间精度窗口内而不被复用。
+	age := func(rels ...string) {
+		old := time.Now().Add(-time.Hour)
+		for _, rel := range rels {
+			if err := os.Chtimes(filepath.Join(root, rel), old, old); err != nil {
+				t.Fatalf("chtimes: %v", err)
+			}
+		}
+	}
+	age(".", "pkg", ".gitignore")
+	ix := NewIndex(nil)
+	first, err := ix.Files(context.Background(), root)
+	if err != nil {
+		t.Fatalf("files: %v", err)
+	}
+	second, _ := ix.Files(context.Background(), root)
+	if len(first) != 3 || &first[0] != &second[0] {
+		t.Fatalf("expected cached snapshot, got %v then %v", first, second)
+	}
+
+	writeFile := func(rel, content string) {
+		if err := os.WriteFile(filepath.Join(root, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	writeFile("pkg/d.go", "")
+	got, _ := ix.Files(context.Background(), root)
+	if want := []string{".gitignore", "a.go", "pkg/b.go", "pkg/d.go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after adding a file got %v, want %v", got, want)
+	}
+
+	age(".", "pkg")
+	writeFile(".gitignore", "*.go\n")
+	got, _ = ix.Files(context.Background(), root)
+	if want := []string{".gitignore", "pkg/c.log"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after editing .gitignore got %v, want %v", got, want)
+	}
+}
+
+func TestGrepModesFiltersAndContext(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":     "ignored.go\n",
+		"a.go":           "package a\n\nfunc Foo() {}\n// foo.Bar()\nvar x = 1\n",
+		"b.txt":          "Foo in text\n",
+		"ignored.go":     "func Foo() {}\n",
+		"bin.dat":        "Foo\x00binary",
+		"sub/c.go":       "func FooBar() {}\n",
+		"sub/c_test.go":  "func TestFoo() {}\n",
+		"sub/deep/d.go":  "no match here\n",
+		"sub/deep/e.txt": "foo.Bar\n",
+	})
+
+	res, err := Grep(context.Background(), root, GrepOptions{Pattern: `func Foo\w*`, Include: []string{"*.go"}, Exclude: []string{"*_test.go"}})
+	if err != nil {
+		t.Fatalf("grep: %v", err)
+	}
+	out := FormatGrep(res, 0)
+	if out != "a.go:3:func Foo() {}\n\nsub/c.go:1:func FooBar() {}" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	res, _ = Grep(context.Background(), root, GrepOptions{Pattern: "foo.bar", Literal: true, IgnoreCase: true, Path: "sub"})
+	if res.Matches != 1 || res.Files[0].Path != "sub/deep/e.txt" {
+		t.Fatalf("literal search should not treat . as a wildcard: %+v", res)
+	}
+
+	res, _ = Grep(context.Background(), root, GrepOptions{Pattern: "Foo", Path: "a.go", Context: 1})
+	if out := FormatGrep(res, 0); out != "a.go-2-\na.go:3:func Foo() {}\na.go-4-// foo.Bar()" {
+		t.Fatalf("unexpected context output:\n%s", out)
+	}
+}
+
+func TestGrepCapsResultsAndBytes(t *testing.T) {
+	var sb strings.Builder
+	for range 50 {
+		sb.WriteString("match line\n")
+	}
+	root := writeTree(t, map[string]string{"a.txt": sb.String(), "b.txt": sb.String()})
+
+	res, err := Grep(context.Background(), root, GrepOptions{Pattern: "match", MaxResults: 60})
+	if err != nil {
+		t.Fatalf("grep: %v", err)
+	}
+	if res.Matches != 60 || !res.Truncated || len(res.Files) != 2 || res.Files[1].Matches != 10 {
+		t.Fatalf("unexpected capped result: matches=%d truncated=%v files=%d", res.Matches, res.Truncated, len(res.Files))
+	}
+	if out := FormatGrep(res, 0); !strings.Contains(out, "[stopped after 60 matches") {
+		t.Fatalf("missing max-results notice:\n%s", out)
+	}
+	out := FormatGrep(res, 200)
+	if len(out) > 400 || !strings.Contains(out, "[output truncated at 200 bytes") {
+		t.Fatalf("missing byte cap notice:\n%s", out)
+	}
+	if _, err := Grep(context.Background(), root, GrepOptions{Pattern: "("}); err == nil {
+		t.Fatalf("expected invalid regex error")
+	}
+}
+
+func TestFuzzyFindRanksBasenameAndSegmentMatches(t *testing.T) {
+	paths := []string{
+		"internal/tui/render/markdown.go",
+		"internal/tui/model.go",
+		"docs/models/overview.md",
+		"internal/execution/engine.go",
+	}
+	got := FuzzyFind("tuimodel", paths)
+	if len(got) != 1 || got[0].Path != "internal/tui/model.go" {
+		t.Fatalf("unexpected matches %+v", got)
+	}
+	got = FuzzyFind("model", paths)
+	if len(got) != 2 || got[0].Path != "internal/tui/model.go" {
+		t.Fatalf("expected the basename match first, got %+v", got)
+	}
+	if want := []int{13, 14, 15, 16, 17}; !reflect.DeepEqual(got[0].Positions, want) {
+		t.Fatalf("positions = %v, want %v", got[0].Positions, want)
+	}
+	if got := FuzzyFind("", paths); len(got) != len(paths) || got[0].Index != 0 {
+		t.Fatalf("empty query should keep order, got %+v", got)
+	}
+}
@@ -0,0 +1,375 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"echo-cli/internal/agent"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/prompts"
+	"echo-cli/internal/tools"
+)
+
+// FormatVersion 是当前 rollout（JSONL）会话格式的版本；旧的单文件 JSON 记录视为版本 1。
+const FormatVersion = 2
+
+// LineType 标记 rollout 文件中每一行的类型。
+type LineType string
+
+const (
+	// LineSessionMeta 是文件首行：会话 ID、格式版本、工作目录与创建时间。
+	LineSessionMeta LineType = "session_meta"
+	// LineResponseItem 追加一个 ResponseItem（消息、推理、工具调用/输出、ghost snapshot、压缩摘要）。
+	LineResponseItem LineType = "response_item"
+	// LineCompacted 用完整的新历史替换此前的全部 ResponseItem（压缩或 undo 之后）。
+	LineCompacted LineType = "compacted"
+	// LineTurnContext 记录一次用户回合的模型、工作目录、用量与起止时间。
+	LineTurnContext LineType = "turn_context"
+	// LineMessage 追加一条 UI 转录消息（恢复时用于重新渲染，不喂给模型）。
+	LineMessage LineType = "message"
+	// LineTranscript 用完整的转录替换此前的全部 UI 消息。
+	LineTranscript LineType = "transcript"
+	// LineApprovals 记录会话内记住的批准，最后一行生效。
+	LineApprovals LineType = "approvals"
+)
+
+// RolloutLine 是 rollout 文件的一行。
+type RolloutLine struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Type      LineType        `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type sessionMeta struct {
+	ID      string    `json:"id"`
+	Version int       `json:"version"`
+	Workdir string    `json:"workdir,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+type turnContextPayload struct {
+	echocontext.TurnRecord
+	Workdir string `json:"workdir,omitempty"`
+}
+
+type compactedPayload struct {
+	Items []echocontext.ResponseItem `json:"items"`
+}
+
+type transcriptPayload struct {
+	Messages []agent.Message `json:"messages"`
+}
+
+// readRollout 逐行重放 rollout 文件；未知的行类型会被忽略，以便旧版本读取新文件。
+func readRollout(path string) (Record, error) {
+	return replayRollout(path, nil)
+}
+
+// readRolloutTranscript 只重放元数据、转录与回合行，跳过体积最大的 ResponseItem 历史；供会话列表摘要使用。
+func readRolloutTranscript(path string) (Record, error) {
+	return replayRollout(path, func(t LineType) bool {
+		return t == LineMessage || t == LineTranscript || t == LineTurnContext
+	})
+}
+
+// readRolloutMeta 只读取 rollout 文件的首行（session_meta）；Updated 取文件的修改时间。
+func readRolloutMeta(path string) (Record, error) {
+	var rec Record
+	f, err := os.Open(path)
+	if err != nil {
+		return rec, err
+	}
+	defer f.Close()
+	raw, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && len(bytes.TrimSpace(raw)) == 0 {
+		return rec, fmt.Errorf("%s: missing session_meta", path)
+	}
+	var line RolloutLine
+	if err := json.Unmarshal(raw, &line); err != nil || line.Type != LineSessionMeta {
+		return rec, fmt.Errorf("%s: missing session_meta", path)
+	}
+	if err := applyLine(&rec, line); err != nil {
+		return rec, fmt.Errorf("%s:1: %w", path, err)
+	}
+	rec.Updated = line.Timestamp
+	if info, err := f.Stat(); err == nil && info.ModTime().After(rec.Updated) {
+		rec.Updated = info.ModTime()
+	}
+	return rec, nil
+}
+
+// replayRollout 重放 rollout 文件；keep 非 nil 时只应用 session_meta 与 keep 返回 true 的行。
+func replayRollout(path string, keep func(LineType) bool) (Record, error) {
+	var rec Record
+	f, err := os.Open(path)
+	if err != nil {
+		return rec, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	lineNo := 0
+	for {
+		raw, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(raw)) > 0 {
+			lineNo++
+			var line RolloutLine
+			if err := json.Unmarshal(raw, &line); err != nil {
+				if readErr != nil {
+					// 最后一行写到一半（例如进程被杀），丢弃即可。
+					break
+				}
+				return rec, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			if keep == nil || line.Type == LineSessionMeta || keep(line.Type) {
+				if err := applyLine(&rec, line); err != nil {
+					return rec, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+				}
+			}
+			if line.Timestamp.After(rec.Updated) {
+				rec.Updated = line.Timestamp
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if rec.Version == 0 {
+		return rec, fmt.Errorf("%s: missing session_meta", path)
+	}
+	rec.GhostSnapshots = echocontext.GhostCommits(rec.Items)
+	return rec, nil
+}
+
+func applyLine(rec *Record, line RolloutLine) error {
+	switch line.Type {
+	case LineSessionMeta:
+		var meta sessionMeta
+		if err := json.Unmarshal(line.Payload, &meta); err != nil {
+			return err
+		}
+		if meta.Version > FormatVersion {
+			return fmt.Errorf("session format version %d is newer than supported version %d", meta.Version, FormatVersion)
+		}
+		rec.ID = meta.ID
+		rec.Version = meta.Version
+		rec.Workdir = meta.Workdir
+		rec.Created = meta.Created
+	case LineResponseItem:
+		var item echocontext.ResponseItem
+		if err := json.Unmarshal(line.Payload, &item); err != nil {
+			return err
+		}
+		rec.Items = append(rec.Items, item)
+	case LineCompacted:
+		var payload compactedPayload
+		if err := json.Unmarshal(line.Payload, &payload); err != nil {
+			return err
+		}
+		rec.Items = payload.Items
+	case LineTurnContext:
+		var payload turnContextPayload
+		if err := json.Unmarshal(line.Payload, &payload); err != nil {
+			return err
+		}
+		rec.Turns = append(rec.Turns, payload.TurnRecord)
+	case LineMessage:
+		var msg agent.Message
+		if err := json.Unmarshal(line.Payload, &msg); err != nil {
+			return err
+		}
+		rec.Messages = append(rec.Messages, msg)
+	case LineTranscript:
+		var payload transcriptPayload
+		if err := json.Unmarshal(line.Payload, &payload); err != nil {
+			return err
+		}
+		rec.Messages = payload.Messages
+	case LineApprovals:
+		var grants []tools.ApprovalGrant
+		if err := json.Unmarshal(line.Payload, &grants); err != nil {
+			return err
+		}
+		rec.Approvals = grants
+	}
+	return nil
+}
+
+// rolloutLines 计算把 prev（磁盘上的状态，nil 表示新文件）更新到 rec 需要追加的行：
+// 历史只增长时逐项追加，否则写入一条替换行。
+func rolloutLines(prev *Record, rec Record, now time.Time) ([]RolloutLine, error) {
+	var lines []RolloutLine
+	add := func(typ LineType, payload any) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, RolloutLine{Timestamp: now, Type: typ, Payload: data})
+		return nil
+	}
+
+	var prevItems, prevMessages []string
+	var prevTurns int
+	var prevApprovals []tools.ApprovalGrant
+	if prev == nil {
+		created := rec.Created
+		if created.IsZero() {
+			created = now
+		}
+		if err := add(LineSessionMeta, sessionMeta{ID: rec.ID, Version: FormatVersion, Workdir: rec.Workdir, Created: created}); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if prevItems, err = encodeAll(prev.Items); err != nil {
+			return nil, err
+		}
+		if prevMessages, err = encodeAll(prev.Messages); err != nil {
+			return nil, err
+		}
+		prevTurns = len(prev.Turns)
+		prevApprovals = prev.Approvals
+	}
+
+	items, err := encodeAll(rec.Items)
+	if err != nil {
+		return nil, err
+	}
+	if hasPrefix(items, prevItems) {
+		for _, item := range items[len(prevItems):] {
+			lines = append(lines, RolloutLine{Timestamp: now, Type: LineResponseItem, Payload: json.RawMessage(item)})
+		}
+	} else if err := add(LineCompacted, compactedPayload{Items: nonNilItems(rec.Items)}); err != nil {
+		return nil, err
+	}
+
+	if len(rec.Turns) > prevTurns {
+		for _, turn := range rec.Turns[prevTurns:] {
+			if err := add(LineTurnContext, turnContextPayload{TurnRecord: turn, Workdir: rec.Workdir}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	messages, err := encodeAll(rec.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if hasPrefix(messages, prevMessages) {
+		for _, msg := range messages[len(prevMessages):] {
+			lines = append(lines, RolloutLine{Timestamp: now, Type: LineMessage, Payload: json.RawMessage(msg)})
+		}
+	} else if err := add(LineTranscript, transcriptPayload{Messages: rec.Messages}); err != nil {
+		return nil, err
+	}
+
+	if !sameApprovals(prevApprovals, rec.Approvals) {
+		if err := add(LineApprovals, rec.Approvals); err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+func encodeAll[T any](values []T) ([]string, error) {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, string(data))
+	}
+	return out, nil
+}
+
+func hasPrefix(values, prefix []string) bool {
+	if len(prefix) > len(values) {
+		return false
+	}
+	for i := range prefix {
+		if values[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func nonNilItems(items []echocontext.ResponseItem) []echocontext.ResponseItem {
+	if items == nil {
+		return []echocontext.ResponseItem{}
+	}
+	return items
+}
+
+func sameApprovals(a, b []tools.ApprovalGrant) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	left, errA := json.Marshal(a)
+	right, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(left, right)
+}
+
+func appendLines(path string, lines []RolloutLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ConversationMessages 从 UI 历史中提取应喂给模型的对话消息：
+// 丢弃 tool 等非模型角色，以及系统注入的输出格式与审查模式提示词。
+func ConversationMessages(messages []agent.Message) []agent.Message {
+	var filtered []agent.Message
+	for _, msg := range messages {
+		// Anthropic/OpenAI 消息角色通常仅支持 system/user/assistant。
+		if msg.Role != agent.RoleSystem && msg.Role != agent.RoleUser && msg.Role != agent.RoleAssistant {
+			continue
+		}
+		if msg.Role == agent.RoleSystem {
+			if strings.HasPrefix(msg.Content, prompts.OutputSchemaPrefix) {
+				continue
+			}
+			if msg.Content == prompts.ReviewModeSystemPrompt {
+				continue
+			}
+		}
+		filtered = append(filtered, msg)
+	}
+	return filtered
+}
+
+// migrateLegacy 把版本 1 的 JSON 记录转换为 ResponseItem 历史：
+// 与旧的恢复流程一致，先载入过滤后的对话消息，再追加 ghost snapshot。
+func migrateLegacy(rec Record) Record {
+	items := echocontext.MessagesToResponseItems(ConversationMessages(rec.Messages))
+	for _, commit := range rec.GhostSnapshots {
+		items = append(items, echocontext.NewGhostSnapshotItem(commit))
+	}
+	rec.Items = items
+	rec.Created = rec.Updated
+	return rec
+}
@@ -8,20 +8,48 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"echo-cli/internal/agent"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/tools"
 
 	"github.com/google/uuid"
 )
 
+// Record 是一个会话的完整状态，保存在 ~/.echo/sessions/<id>.jsonl（rollout 格式，见 FormatVersion）。
 type Record struct {
-	ID       string          `json:"id"`
-	Workdir  string          `json:"workdir,omitempty"`
+	ID      string `json:"id"`
+	Workdir string `json:"workdir,omitempty"`
+	// Messages 是 UI 转录，用于恢复时重新渲染；模型上下文以 Items 为准。保存时为 nil 表示沿用已有的转录。
 	Messages []agent.Message `json:"messages"`
 	Updated  time.Time       `json:"updated"`
+	// GhostSnapshots 记录尚未撤销的工作区快照，恢复会话后仍可 /undo。
+	// rollout 格式中它们包含在 Items 里，读取时由 Items 推导。
+	GhostSnapshots []echocontext.GhostCommit `json:"ghost_snapshots,omitempty"`
+	// Approvals 记录会话内记住的批准（approve for session / always approve prefix）。
+	Approvals []tools.ApprovalGrant `json:"approvals,omitempty"`
+
+	// Version 是读取到的格式版本；旧的 JSON 记录为 1。
+	Version int `json:"-"`
+	// Created 是会话首次保存的时间。
+	Created time.Time `json:"-"`
+	// Items 是 ContextManager 中的完整 ResponseItem 历史（推理项、工具调用/输出、ghost snapshot、压缩摘要）。
+	// 保存时为 nil 表示沿用文件中已有的历史。
+	Items []echocontext.ResponseItem `json:"-"`
+	// Turns 是每个用户回合的模型、用量与起止时间；保存时只追加新的回合。
+	Turns []echocontext.TurnRecord `json:"-"`
 }
 
+const (
+	rolloutExt = ".jsonl"
+	legacyExt  = ".json"
+	// migratedExt 是迁移后保留的旧 JSON 记录的后缀。
+	migratedExt = ".json.bak"
+)
+
 func dir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -42,32 +70,125 @@ func ensureDir() (string, error) {
 }
 
 func Save(id string, workdir string, messages []agent.Message) (string, error) {
-	if id == "" {
-		id = uuid.NewString()
+	return SaveRecord(Record{ID: id, Workdir: workdir, Messages: messages})
+}
+
+// SaveRecord 把会话状态追加写入 rollout 文件，返回会话 ID。
+// 新的 ResponseItem、回合与转录消息逐行追加；历史被压缩或 undo 改写时写入一条替换行。
+// Items、Turns 或 Messages 为 nil 时沿用文件中已有的内容。
+func SaveRecord(rec Record) (string, error) {
+	written, err := save(rec, nil)
+	return written.ID, err
+}
+
+// Recorder 在会话进行中逐步写入 rollout 文件：记住每个会话上次写入的状态，之后只追加变化的部分，
+// 不必每次重读文件。进程中途被杀或崩溃时，已记录的条目与回合仍保留在文件中。可并发使用。
+type Recorder struct {
+	mu      sync.Mutex
+	written map[string]Record
+}
+
+// NewRecorder 创建一个 Recorder。
+func NewRecorder() *Recorder {
+	return &Recorder{written: map[string]Record{}}
+}
+
+// Save 与 SaveRecord 相同；同一会话只在第一次保存时读取已有文件。
+func (r *Recorder) Save(rec Record) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var prev *Record
+	if last, ok := r.written[rec.ID]; ok && rec.ID != "" {
+		prev = &last
 	}
-	d, err := ensureDir()
+	written, err := save(rec, prev)
 	if err != nil {
 		return "", err
 	}
-	rec := Record{ID: id, Workdir: workdir, Messages: messages, Updated: time.Now()}
-	data, err := json.MarshalIndent(rec, "", "  ")
+	r.written[written.ID] = written
+	return written.ID, nil
+}
+
+// save 追加写入 rec 相对 prev 的变化并返回写入后的状态；prev 为 nil 时从文件读取。
+func save(rec Record, prev *Record) (Record, error) {
+	if rec.ID == "" {
+		rec.ID = uuid.NewString()
+	}
+	d, err := ensureDir()
 	if err != nil {
-		return "", err
+		return rec, err
 	}
-	path := filepath.Join(d, id+".json")
-	if err := os.WriteFile(path, data, 0o644); err != nil {
-		return "", err
+	path := filepath.Join(d, rec.ID+rolloutExt)
+	if prev == nil {
+		if existing, err := load(d, rec.ID); err == nil {
+			prev = &existing
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return rec, err
+		}
+	}
+	if prev != nil {
+		if rec.Items == nil {
+			rec.Items = prev.Items
+		}
+		if rec.Turns == nil {
+			rec.Turns = prev.Turns
+		}
+		if rec.Messages == nil {
+			rec.Messages = prev.Messages
+		}
+	} else if rec.Items == nil {
+		rec.Items = migrateLegacy(rec).Items
+	}
+	lines, err := rolloutLines(prev, rec, time.Now())
+	if err != nil {
+		return rec, err
 	}
-	return id, nil
+	if err := appendLines(path, lines); err != nil {
+		return rec, err
+	}
+	return rec, nil
 }
 
+// Load 读取会话；旧的 JSON 记录会被迁移为 rollout 文件（原文件保留为 .json.bak）。
 func Load(id string) (Record, error) {
-	var rec Record
 	d, err := dir()
 	if err != nil {
+		return Record{}, err
+	}
+	return load(d, id)
+}
+
+func load(d string, id string) (Record, error) {
+	path := filepath.Join(d, id+rolloutExt)
+	rec, err := readRollout(path)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
 		return rec, err
 	}
-	path := filepath.Join(d, id+".json")
+	legacyPath := filepath.Join(d, id+legacyExt)
+	legacy, err := readLegacy(legacyPath)
+	if err != nil {
+		return Record{}, err
+	}
+	if legacy.ID == "" {
+		legacy.ID = id
+	}
+	migrated := migrateLegacy(legacy)
+	lines, err := rolloutLines(nil, migrated, migrated.Updated)
+	if err != nil {
+		return Record{}, err
+	}
+	if err := appendLines(path, lines); err != nil {
+		return Record{}, fmt.Errorf("migrate session %s: %w", id, err)
+	}
+	if err := os.Rename(legacyPath, filepath.Join(d, id+migratedExt)); err != nil {
+		return Record{}, fmt.Errorf("migrate session %s: %w", id, err)
+	}
+	return readRollout(path)
+}
+
+// readLegacy 读取版本 1 的单文件 JSON 记录。
+func readLegacy(path string) (Record, error) {
+	var rec Record
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return rec, err
@@ -75,6 +196,7 @@ func Load(id string) (Record, error) {
 	if err := json.Unmarshal(data, &rec); err != nil {
 		return rec, err
 	}
+	rec.Version = 1
 	return rec, nil
 }
 
@@ -87,22 +209,39 @@ func Last() (Record, error) {
 	if err != nil {
 		return Record{}, err
 	}
-	if len(entries) == 0 {
+	var latestID string
+	var latest time.Time
+	for _, e := range entries {
+		id, ok := sessionID(e)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if latestID == "" || info.ModTime().After(latest) || (info.ModTime().Equal(latest) && id > latestID) {
+			latestID, latest = id, info.ModTime()
+		}
+	}
+	if latestID == "" {
 		return Record{}, fmt.Errorf("no sessions found")
 	}
-	sort.Slice(entries, func(i, j int) bool {
-		iInfo, _ := entries[i].Info()
-		jInfo, _ := entries[j].Info()
-		if iInfo == nil || jInfo == nil {
-			return entries[i].Name() > entries[j].Name()
-		}
-		return iInfo.ModTime().After(jInfo.ModTime())
-	})
-	return Load(trimExt(entries[0].Name()))
+	return load(d, latestID)
 }
 
-func trimExt(name string) string {
-	return name[:len(name)-len(filepath.Ext(name))]
+// sessionID 返回会话文件对应的 ID；非会话文件（例如迁移备份）返回 false。
+func sessionID(e fs.DirEntry) (string, bool) {
+	if e.IsDir() {
+		return "", false
+	}
+	name := e.Name()
+	for _, ext := range []string{rolloutExt, legacyExt} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+	return "", false
 }
 
 func ListIDs() ([]string, error) {
@@ -117,31 +256,38 @@ func ListIDs() ([]string, error) {
 		}
 		return nil, err
 	}
+	seen := map[string]bool{}
 	ids := make([]string, 0, len(entries))
 	for _, e := range entries {
-		if e.IsDir() {
+		id, ok := sessionID(e)
+		if !ok || seen[id] {
 			continue
 		}
-		name := trimExt(e.Name())
-		ids = append(ids, name)
+		seen[id] = true
+		ids = append(ids, id)
 	}
 	return ids, nil
 }
 
-// List returns session records, optionally filtering by workdir when available.
+// List 返回按更新时间倒序的会话元数据；showAll 为 false 时只包含 workdir 下的会话。
+// 只读取每个会话文件的首行，不重放历史，也不迁移旧的 JSON 记录；完整内容用 Load 读取。
 func List(showAll bool, workdir string) ([]Record, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
 	ids, err := ListIDs()
 	if err != nil {
 		return nil, err
 	}
 	var records []Record
 	for _, id := range ids {
-		rec, err := Load(id)
+		rec, err := peek(d, id, readRolloutMeta)
 		if err != nil {
 			continue
 		}
 		if showAll || rec.Workdir == "" || workdir == "" || samePath(rec.Workdir, workdir) {
-			records = append(records, rec)
+			records = append(records, Record{ID: rec.ID, Workdir: rec.Workdir, Updated: rec.Updated, Version: rec.Version, Created: rec.Created})
 		}
 	}
 	sort.Slice(records, func(i, j int) bool {
@@ -150,6 +296,22 @@ func List(showAll bool, workdir string) ([]Record, error) {
 	return records, nil
 }
 
+// peek 用 read 读取会话的 rollout 文件；只有旧的 JSON 记录时直接读取它，不做迁移。
+func peek(d string, id string, read func(path string) (Record, error)) (Record, error) {
+	rec, err := read(filepath.Join(d, id+rolloutExt))
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return rec, err
+	}
+	legacy, err := readLegacy(filepath.Join(d, id+legacyExt))
+	if err != nil {
+		return Record{}, err
+	}
+	if legacy.ID == "" {
+		legacy.ID = id
+	}
+	return legacy, nil
+}
+
 func samePath(a, b string) bool {
 	if a == b {
 		return true
@@ -0,0 +1,314 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"echo-cli/internal/agent"
+	echocontext "echo-cli/internal/context"
+	"echo-cli/internal/tools"
+)
+
+func sessionsDir(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	return filepath.Join(home, ".echo", "sessions")
+}
+
+func lineTypes(t *testing.T, path string) []LineType {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open rollout: %v", err)
+	}
+	defer f.Close()
+	var types []LineType
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var line RolloutLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, line.Type)
+	}
+	return types
+}
+
+func sampleItems() []echocontext.ResponseItem {
+	return []echocontext.ResponseItem{
+		echocontext.NewUserMessageItem("list files"),
+		{Type: echocontext.ResponseItemTypeReasoning, Reasoning: &echocontext.ReasoningResponseItem{
+			ID:      "rs_1",
+			Summary: []echocontext.ReasoningItemReasoningSummary{{Type: "summary_text", Text: "run ls"}},
+		}},
+		echocontext.NewGhostSnapshotItem(echocontext.GhostCommit{ID: "abc123", Parent: "def456"}),
+		{Type: echocontext.ResponseItemTypeFunctionCall, FunctionCall: &echocontext.FunctionCallResponseItem{
+			Name: "exec_command", Arguments: `{"cmd":"ls"}`, CallID: "call_1",
+		}},
+		{Type: echocontext.ResponseItemTypeFunctionCallOutput, FunctionCallOutput: &echocontext.FunctionCallOutputResponseItem{
+			CallID: "call_1", Output: echocontext.FunctionCallOutputPayload{Content: "a.go\nb.go"},
+		}},
+		echocontext.NewAssistantMessageItem("two files"),
+	}
+}
+
+func TestSaveRecord_RoundTripsResponseItemsAndTurns(t *testing.T) {
+	dir := sessionsDir(t)
+	started := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := Record{
+		ID:        "s1",
+		Workdir:   "/repo",
+		Messages:  []agent.Message{{Role: agent.RoleUser, Content: "list files"}, {Role: agent.RoleAssistant, Content: "two files"}},
+		Items:     sampleItems(),
+		Turns:     []echocontext.TurnRecord{{Model: "m1", InputTokens: 120, OutputTokens: 30, Started: started, Completed: started.Add(time.Second)}},
+		Approvals: []tools.ApprovalGrant{{Scope: tools.ApprovalScopePrefix, Prefix: "go test"}},
+	}
+	if _, err := SaveRecord(rec); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	got, err := Load("s1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.Version != FormatVersion || got.Workdir != "/repo" || got.Created.IsZero() {
+		t.Fatalf("unexpected meta: %+v", got)
+	}
+	if !reflect.DeepEqual(got.Items, rec.Items) {
+		t.Fatalf("items differ:\n got %+v\nwant %+v", got.Items, rec.Items)
+	}
+	if !reflect.DeepEqual(got.Turns, rec.Turns) || !reflect.DeepEqual(got.Messages, rec.Messages) || !reflect.DeepEqual(got.Approvals, rec.Approvals) {
+		t.Fatalf("unexpected record: %+v", got)
+	}
+	if len(got.GhostSnapshots) != 1 || got.GhostSnapshots[0].ID != "abc123" {
+		t.Fatalf("expected ghost snapshots derived from items, got %+v", got.GhostSnapshots)
+	}
+	want := []LineType{LineSessionMeta, LineResponseItem, LineResponseItem, LineResponseItem, LineResponseItem, LineResponseItem, LineResponseItem, LineTurnContext, LineMessage, LineMessage, LineApprovals}
+	if types := lineTypes(t, filepath.Join(dir, "s1.jsonl")); !reflect.DeepEqual(types, want) {
+		t.Fatalf("unexpected lines %v", types)
+	}
+}
+
+func TestSaveRecord_AppendsOnlyNewItems(t *testing.T) {
+	dir := sessionsDir(t)
+	items := sampleItems()
+	if _, err := SaveRecord(Record{ID: "s1", Items: items[:2]}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	turn := echocontext.TurnRecord{Model: "m1"}
+	if _, err := SaveRecord(Record{ID: "s1", Items: items, Turns: []echocontext.TurnRecord{turn}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	path := filepath.Join(dir, "s1.jsonl")
+	types := lineTypes(t, path)
+	if len(types) != 1+len(items)+1 {
+		t.Fatalf("expected items appended one per line, got %v", types)
+	}
+
+	// 压缩后历史不再是前缀，写入一条替换行。
+	compacted := []echocontext.ResponseItem{
+		{Type: echocontext.ResponseItemTypeCompactionSummary, CompactionSummary: &echocontext.CompactionSummaryResponseItem{EncryptedContent: "summary"}},
+	}
+	if _, err := SaveRecord(Record{ID: "s1", Items: compacted}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	types = lineTypes(t, path)
+	if types[len(types)-1] != LineCompacted {
+		t.Fatalf("expected compacted line, got %v", types)
+	}
+	got, err := Load("s1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(got.Items, compacted) || len(got.Turns) != 1 {
+		t.Fatalf("unexpected record after compaction: %+v", got)
+	}
+}
+
+func TestRecorder_WritesEachStepAndKeepsTranscript(t *testing.T) {
+	dir := sessionsDir(t)
+	path := filepath.Join(dir, "s1.jsonl")
+	r := NewRecorder()
+	items := sampleItems()
+	// 回合进行中逐步记录：每一步之后文件都可以被读回，进程此时退出也不丢失。
+	for i := 1; i <= len(items); i++ {
+		if _, err := r.Save(Record{ID: "s1", Workdir: "/repo", Items: items[:i]}); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+		got, err := Load("s1")
+		if err != nil {
+			t.Fatalf("load after %d items: %v", i, err)
+		}
+		if !reflect.DeepEqual(got.Items, items[:i]) {
+			t.Fatalf("after %d items got %+v", i, got.Items)
+		}
+	}
+	turn := echocontext.TurnRecord{Model: "m1", InputTokens: 10}
+	if _, err := r.Save(Record{ID: "s1", Workdir: "/repo", Items: items, Turns: []echocontext.TurnRecord{turn}}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if types := lineTypes(t, path); len(types) != 1+len(items)+1 {
+		t.Fatalf("expected one line per item and turn, got %v", types)
+	}
+
+	// 退出时写入转录；之后的增量记录不带转录，也不会清空它。
+	messages := []agent.Message{{Role: agent.RoleUser, Content: "list files"}}
+	if _, err := SaveRecord(Record{ID: "s1", Workdir: "/repo", Messages: messages}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := NewRecorder().Save(Record{ID: "s1", Workdir: "/repo", Items: items}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	got, err := Load("s1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(got.Messages, messages) || len(got.Turns) != 1 || len(got.Items) != len(items) {
+		t.Fatalf("unexpected record %+v", got)
+	}
+}
+
+func TestLoad_MigratesLegacyJSONRecord(t *testing.T) {
+	dir := sessionsDir(t)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{
+  "id": "old",
+  "workdir": "/repo",
+  "messages": [
+    {"Role": "user", "Content": "hi"},
+    {"Role": "tool", "Content": "tool block"},
+    {"Role": "assistant", "Content": "hello"}
+  ],
+  "updated": "2024-05-01T10:00:00Z",
+  "ghost_snapshots": [{"id": "g1"}]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := ListIDs()
+	if err != nil || len(ids) != 1 || ids[0] != "old" {
+		t.Fatalf("expected legacy session listed, got %v err=%v", ids, err)
+	}
+	rec, err := Load("old")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if rec.Version != FormatVersion || rec.Workdir != "/repo" || len(rec.Messages) != 3 {
+		t.Fatalf("unexpected migrated record: %+v", rec)
+	}
+	if len(rec.Items) != 3 || echocontext.LastAssistantMessage(rec.Items) != "hello" {
+		t.Fatalf("expected conversation items plus ghost snapshot, got %+v", rec.Items)
+	}
+	if len(rec.GhostSnapshots) != 1 || rec.GhostSnapshots[0].ID != "g1" {
+		t.Fatalf("expected ghost snapshot preserved, got %+v", rec.GhostSnapshots)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.json.bak")); err != nil {
+		t.Fatalf("expected legacy backup: %v", err)
+	}
+	ids, _ = ListIDs()
+	if len(ids) != 1 {
+		t.Fatalf("backup must not be listed as a session, got %v", ids)
+	}
+	last, err := Last()
+	if err != nil || last.ID != "old" {
+		t.Fatalf("expected last session to be migrated record, got %+v err=%v", last, err)
+	}
+}
+
+func TestSummaries_ReadOnlyMetadataWithoutMigrating(t *testing.T) {
+	dir := sessionsDir(t)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `{"id": "old", "workdir": "/repo", "messages": [{"Role": "user", "Content": "hi"}], "updated": "2024-05-01T10:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveRecord(Record{ID: "new", Workdir: "/repo", Items: sampleItems(), Messages: []agent.Message{{Role: agent.RoleUser, Content: "list files"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := List(false, "/repo")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expected both sessions listed, got %+v err=%v", records, err)
+	}
+	for _, rec := range records {
+		if rec.Items != nil || rec.Messages != nil || rec.Updated.IsZero() {
+			t.Fatalf("List should return metadata only, got %+v", rec)
+		}
+	}
+	summaries, err := Summaries(false, "/repo")
+	if err != nil || len(summaries) != 2 {
+		t.Fatalf("expected two summaries, got %+v err=%v", summaries, err)
+	}
+	for _, s := range summaries {
+		if s.FirstUserMessage == "" || s.MessageCount != 1 {
+			t.Fatalf("expected summary from the transcript, got %+v", s)
+		}
+	}
+	if rec, err := Preview("old"); err != nil || len(rec.Messages) != 1 {
+		t.Fatalf("expected legacy preview, got %+v err=%v", rec, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); err != nil {
+		t.Fatalf("listing must not migrate legacy records: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("listing must not write a rollout for legacy records, err=%v", err)
+	}
+}
+
+func TestLoad_RejectsNewerFormat(t *testing.T) {
+	dir := sessionsDir(t)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"timestamp":"2025-01-01T00:00:00Z","type":"session_meta","payload":{"id":"s1","version":99}}` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "s1.jsonl"), []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("s1"); err == nil {
+		t.Fatalf("expected error for newer format version")
+	}
+}
+
+func TestSummaries_ScopeAndMetadata(t *testing.T) {
+	sessionsDir(t)
+	rec := Record{
+		ID:      "s1",
+		Workdir: "/repo",
+		Messages: []agent.Message{
+			{Role: agent.RoleSystem, Content: "sys"},
+			{Role: agent.RoleUser, Content: "  fix\nthe build "},
+			{Role: agent.RoleAssistant, Content: "done"},
+		},
+		Turns: []echocontext.TurnRecord{{Model: "m1"}, {Model: "m2"}},
+	}
+	if _, err := SaveRecord(rec); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveRecord(Record{ID: "s2", Workdir: "/elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	scoped, err := Summaries(false, "/repo")
+	if err != nil || len(scoped) != 1 {
+		t.Fatalf("expected one scoped summary, got %+v err=%v", scoped, err)
+	}
+	s := scoped[0]
+	if s.FirstUserMessage != "fix the build" || s.MessageCount != 2 || s.Model != "m2" || s.Updated.IsZero() {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+	if all, _ := Summaries(true, "/repo"); len(all) != 2 {
+		t.Fatalf("expected all sessions, got %+v", all)
+	}
+}
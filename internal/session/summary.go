@@ -0,0 +1,73 @@
+package session
+
+import (
+	"strings"
+	"time"
+
+	"echo-cli/internal/agent"
+)
+
+// Summary 是会话选择器展示的元数据。
+type Summary struct {
+	ID      string
+	Workdir string
+	Updated time.Time
+	// FirstUserMessage 是会话中第一条用户消息（已压缩空白）。
+	FirstUserMessage string
+	// MessageCount 统计转录中的用户与助手消息。
+	MessageCount int
+	// Model 是最近一个回合使用的模型；旧记录可能为空。
+	Model string
+}
+
+// Summarize 从会话记录提取选择器元数据。
+func Summarize(rec Record) Summary {
+	s := Summary{ID: rec.ID, Workdir: rec.Workdir, Updated: rec.Updated}
+	for _, msg := range rec.Messages {
+		if msg.Role != agent.RoleUser && msg.Role != agent.RoleAssistant {
+			continue
+		}
+		s.MessageCount++
+		if s.FirstUserMessage == "" && msg.Role == agent.RoleUser {
+			s.FirstUserMessage = strings.Join(strings.Fields(msg.Content), " ")
+		}
+	}
+	for i := len(rec.Turns) - 1; i >= 0; i-- {
+		if model := strings.TrimSpace(rec.Turns[i].Model); model != "" {
+			s.Model = model
+			break
+		}
+	}
+	return s
+}
+
+// Summaries 返回按更新时间倒序的会话元数据；showAll 为 false 时只包含 workdir 下的会话。
+// 只读取入选会话的转录与回合行，不加载 ResponseItem 历史，也不迁移旧的 JSON 记录。
+func Summaries(showAll bool, workdir string) ([]Summary, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
+	records, err := List(showAll, workdir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Summary, 0, len(records))
+	for _, rec := range records {
+		if full, err := peek(d, rec.ID, readRolloutTranscript); err == nil {
+			full.Updated = rec.Updated
+			rec = full
+		}
+		out = append(out, Summarize(rec))
+	}
+	return out, nil
+}
+
+// Preview 读取会话的元数据、转录与回合（不含 ResponseItem 历史），不迁移旧的 JSON 记录；供选择器预览。
+func Preview(id string) (Record, error) {
+	d, err := dir()
+	if err != nil {
+		return Record{}, err
+	}
+	return peek(d, id, readRolloutTranscript)
+}
@@ -9,43 +9,51 @@ import (
 type ApprovalDecision struct {
 	ApprovalID string
 	Approved   bool
+	// Scope 为批准的生效范围，空值等同 ApprovalScopeOnce。
+	Scope ApprovalScope
+	// Prefix 为 scope=prefix 时放行的命令前缀；为空时由命令推导（见 CommandPrefix）。
+	Prefix string
+	// Persist 表示 scope=prefix 的规则同时写入项目级审批文件。
+	Persist bool
+	// Reason 为拒绝理由，会写入工具结果回传给模型。
+	Reason string
 }
 
 type ApprovalStore struct {
 	mu       sync.Mutex
-	waiters  map[string]chan bool
-	decided  map[string]bool
+	waiters  map[string]chan ApprovalDecision
+	decided  map[string]ApprovalDecision
 	decidedN int
 }
 
 func NewApprovalStore() *ApprovalStore {
 	return &ApprovalStore{
-		waiters: map[string]chan bool{},
-		decided: map[string]bool{},
+		waiters: map[string]chan ApprovalDecision{},
+		decided: map[string]ApprovalDecision{},
 	}
 }
 
-func (s *ApprovalStore) Wait(ctx context.Context, approvalID string) (bool, error) {
+func (s *ApprovalStore) Wait(ctx context.Context, approvalID string) (ApprovalDecision, error) {
 	if s == nil {
-		return false, fmt.Errorf("approval store not configured")
+		return ApprovalDecision{}, fmt.Errorf("approval store not configured")
 	}
 	if approvalID == "" {
-		return false, fmt.Errorf("missing approval id")
+		return ApprovalDecision{}, fmt.Errorf("missing approval id")
 	}
 	s.mu.Lock()
 	if decided, ok := s.decided[approvalID]; ok {
 		s.mu.Unlock()
 		return decided, nil
 	}
-	ch := make(chan bool, 1)
+	ch := make(chan ApprovalDecision, 1)
 	s.waiters[approvalID] = ch
 	s.mu.Unlock()
 
 	select {
 	case <-ctx.Done():
-		return false, ctx.Err()
-	case approved := <-ch:
-		return approved, nil
+		return ApprovalDecision{}, ctx.Err()
+	case decision := <-ch:
+		return decision, nil
 	}
 }
 
@@ -57,11 +65,11 @@ func (s *ApprovalStore) Resolve(decision ApprovalDecision) bool {
 	defer s.mu.Unlock()
 	if ch, ok := s.waiters[decision.ApprovalID]; ok {
 		delete(s.waiters, decision.ApprovalID)
-		ch <- decision.Approved
+		ch <- decision
 		close(ch)
 		return true
 	}
-	s.decided[decision.ApprovalID] = decision.Approved
+	s.decided[decision.ApprovalID] = decision
 	s.decidedN++
 	// Best-effort bound: keep the last ~256 decisions to avoid unbounded growth.
 	if s.decidedN > 256 {
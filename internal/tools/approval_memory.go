@@ -0,0 +1,313 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ApprovalScope 描述一次人工批准的生效范围。
+type ApprovalScope string
+
+const (
+	// ApprovalScopeOnce 只批准当前这一次调用。
+	ApprovalScopeOnce ApprovalScope = "once"
+	// ApprovalScopeSession 在本会话内自动批准完全相同的命令（或同一批文件的变更）。
+	ApprovalScopeSession ApprovalScope = "session"
+	// ApprovalScopePrefix 在本会话内自动批准以同一前缀开头的命令。
+	ApprovalScopePrefix ApprovalScope = "prefix"
+)
+
+// ParseApprovalScope 解析批准范围；空字符串返回 once。
+func ParseApprovalScope(raw string) (ApprovalScope, error) {
+	switch ApprovalScope(strings.ToLower(strings.TrimSpace(raw))) {
+	case "", ApprovalScopeOnce:
+		return ApprovalScopeOnce, nil
+	case ApprovalScopeSession:
+		return ApprovalScopeSession, nil
+	case ApprovalScopePrefix:
+		return ApprovalScopePrefix, nil
+	}
+	return "", fmt.Errorf("unknown approval scope %q (supported: once, session, prefix)", raw)
+}
+
+// ApprovalGrant 是一条记住的批准，随会话记录一起保存。
+type ApprovalGrant struct {
+	Scope   ApprovalScope `json:"scope"`
+	Command string        `json:"command,omitempty"`
+	Prefix  string        `json:"prefix,omitempty"`
+	Paths   []string      `json:"paths,omitempty"`
+}
+
+// commandSubword 判断第二个词是否像子命令（git push、npm install），而非参数或路径。
+var commandSubword = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// CommandPrefix 推导 "always approve this prefix" 默认使用的前缀：
+// 程序名，加上紧随其后的子命令（如果有）。复合命令只看第一段。
+func CommandPrefix(command string) string {
+	segments := commandSeparators.Split(strings.TrimSpace(command), 2)
+	if len(segments) == 0 {
+		return ""
+	}
+	fields := strings.Fields(segments[0])
+	if len(fields) == 0 {
+		return ""
+	}
+	if len(fields) > 1 && commandSubword.MatchString(fields[1]) {
+		return fields[0] + " " + fields[1]
+	}
+	return fields[0]
+}
+
+// ApprovalMemory 记录按会话划分的批准，以及按项目持久化的命令前缀；
+// Orchestrator 在 LLM 审查与人工审批之前查询它。
+type ApprovalMemory struct {
+	mu              sync.Mutex
+	sessions        map[string][]ApprovalGrant
+	project         *ProjectApprovals
+	projectPrefixes []string
+}
+
+// NewApprovalMemory 创建审批记忆；project 为 nil 时不做项目级持久化。
+func NewApprovalMemory(project *ProjectApprovals) *ApprovalMemory {
+	m := &ApprovalMemory{sessions: map[string][]ApprovalGrant{}, project: project}
+	if project != nil {
+		prefixes, err := project.Load()
+		if err != nil {
+			ensureToolsLogger()
+			toolsLog.Warnf("load project approvals %s: %v", project.Path, err)
+		}
+		m.projectPrefixes = prefixes
+	}
+	return m
+}
+
+// Grants 返回会话内记住的批准（副本），用于写入会话记录。
+func (m *ApprovalMemory) Grants(sessionID string) []ApprovalGrant {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	grants := m.sessions[sessionID]
+	if len(grants) == 0 {
+		return nil
+	}
+	return append([]ApprovalGrant(nil), grants...)
+}
+
+// Seed 在恢复会话时载入之前记住的批准。
+func (m *ApprovalMemory) Seed(sessionID string, grants []ApprovalGrant) {
+	for _, grant := range grants {
+		m.remember(sessionID, grant)
+	}
+}
+
+// Remember 按批准决策记住一条规则；scope=once 时不做任何事。
+func (m *ApprovalMemory) Remember(sessionID string, decision ApprovalDecision, command string, paths []patchPath) {
+	if m == nil || !decision.Approved {
+		return
+	}
+	grant := ApprovalGrant{Scope: decision.Scope}
+	if decision.Scope == ApprovalScopePrefix && len(paths) > 0 {
+		// 文件变更没有"前缀"，按会话内批准同一批文件处理。
+		grant.Scope = ApprovalScopeSession
+	}
+	switch grant.Scope {
+	case ApprovalScopeSession:
+		if len(paths) > 0 {
+			for _, p := range paths {
+				grant.Paths = append(grant.Paths, p.display())
+			}
+		} else {
+			grant.Command = normalizeCommand(command)
+		}
+	case ApprovalScopePrefix:
+		grant.Prefix = strings.Join(strings.Fields(decision.Prefix), " ")
+		if grant.Prefix == "" {
+			grant.Prefix = CommandPrefix(command)
+		}
+		if grant.Prefix != "" && decision.Persist {
+			m.persist(grant.Prefix)
+		}
+	default:
+		return
+	}
+	m.remember(sessionID, grant)
+}
+
+func (m *ApprovalMemory) remember(sessionID string, grant ApprovalGrant) {
+	if m == nil || (grant.Command == "" && grant.Prefix == "" && len(grant.Paths) == 0) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.sessions[sessionID] {
+		if existing.Scope == grant.Scope && existing.Command == grant.Command && existing.Prefix == grant.Prefix &&
+			strings.Join(existing.Paths, "\n") == strings.Join(grant.Paths, "\n") {
+			return
+		}
+	}
+	m.sessions[sessionID] = append(m.sessions[sessionID], grant)
+}
+
+func (m *ApprovalMemory) persist(prefix string) {
+	m.mu.Lock()
+	for _, existing := range m.projectPrefixes {
+		if existing == prefix {
+			m.mu.Unlock()
+			return
+		}
+	}
+	m.projectPrefixes = append(m.projectPrefixes, prefix)
+	m.mu.Unlock()
+	if m.project == nil {
+		return
+	}
+	if err := m.project.Add(prefix); err != nil {
+		ensureToolsLogger()
+		toolsLog.Warnf("persist project approval %q: %v", prefix, err)
+	}
+}
+
+// match 报告命令或文件变更是否已被会话内批准或项目级前缀覆盖。
+func (m *ApprovalMemory) match(sessionID string, command string, paths []patchPath) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if command != "" {
+		normalized := normalizeCommand(command)
+		var prefixes []string
+		for _, grant := range m.sessions[sessionID] {
+			if grant.Command != "" && grant.Command == normalized {
+				return "approved earlier in this session", true
+			}
+			if grant.Prefix != "" {
+				prefixes = append(prefixes, grant.Prefix)
+			}
+		}
+		if commandAllowed(command, prefixes) {
+			return "command prefix approved for this session", true
+		}
+		if commandAllowed(command, m.projectPrefixes) {
+			return "command prefix approved for this project", true
+		}
+		return "", false
+	}
+	if len(paths) == 0 {
+		return "", false
+	}
+	approved := map[string]bool{}
+	for _, grant := range m.sessions[sessionID] {
+		for _, p := range grant.Paths {
+			approved[p] = true
+		}
+	}
+	for _, p := range paths {
+		if !approved[p.display()] {
+			return "", false
+		}
+	}
+	return "files approved earlier in this session", true
+}
+
+func normalizeCommand(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
+
+// ProjectApprovals 把 "always approve this prefix" 规则按项目（工作目录）持久化到 ~/.echo/approvals.json。
+type ProjectApprovals struct {
+	Path    string
+	Workdir string
+}
+
+type projectApprovalsFile struct {
+	Projects map[string]projectApprovalEntry `json:"projects"`
+}
+
+type projectApprovalEntry struct {
+	AllowCommands []string `json:"allow_commands"`
+}
+
+// DefaultProjectApprovalsPath 返回项目级审批文件的默认路径。
+func DefaultProjectApprovalsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".echo", "approvals.json"), nil
+}
+
+// NewProjectApprovals 返回 workdir 对应的项目级审批存储；无法确定路径时返回 nil。
+func NewProjectApprovals(workdir string) *ProjectApprovals {
+	path, err := DefaultProjectApprovalsPath()
+	if err != nil {
+		return nil
+	}
+	if abs, err := filepath.Abs(workdir); err == nil {
+		workdir = abs
+	}
+	return &ProjectApprovals{Path: path, Workdir: workdir}
+}
+
+// Load 返回当前项目已持久化的命令前缀。
+func (p *ProjectApprovals) Load() ([]string, error) {
+	file, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+	return file.Projects[p.Workdir].AllowCommands, nil
+}
+
+// Add 为当前项目追加一条命令前缀（已存在时忽略）。
+func (p *ProjectApprovals) Add(prefix string) error {
+	file, err := p.read()
+	if err != nil {
+		return err
+	}
+	entry := file.Projects[p.Workdir]
+	for _, existing := range entry.AllowCommands {
+		if existing == prefix {
+			return nil
+		}
+	}
+	entry.AllowCommands = append(entry.AllowCommands, prefix)
+	file.Projects[p.Workdir] = entry
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p.Path, data, 0o644)
+}
+
+func (p *ProjectApprovals) read() (projectApprovalsFile, error) {
+	file := projectApprovalsFile{Projects: map[string]projectApprovalEntry{}}
+	if p == nil || strings.TrimSpace(p.Path) == "" {
+		return file, errors.New("project approvals path is empty")
+	}
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return file, nil
+		}
+		return file, err
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("parse %s: %w", p.Path, err)
+	}
+	if file.Projects == nil {
+		file.Projects = map[string]projectApprovalEntry{}
+	}
+	return file, nil
+}
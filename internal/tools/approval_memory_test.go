@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var sessionCallSeq int
+
+func runInSession(o *Orchestrator, approvals *ApprovalStore, sessionID string, h Handler, decision *ApprovalDecision) (ToolResult, bool) {
+	sessionCallSeq++
+	inv := Invocation{Call: ToolCall{ID: fmt.Sprintf("call-%s-%d", sessionID, sessionCallSeq), Name: h.Name(), Payload: json.RawMessage(`{}`)}, SessionID: sessionID}
+	asked := false
+	res := o.Run(context.Background(), inv, h, func(ev ToolEvent) {
+		if ev.Result.Status == "requires_approval" {
+			asked = true
+			if decision != nil {
+				d := *decision
+				d.ApprovalID = ev.Result.ApprovalID
+				go approvals.Resolve(d)
+			}
+		}
+	})
+	return res, asked
+}
+
+func TestCommandPrefix(t *testing.T) {
+	cases := map[string]string{
+		"git push origin main":    "git push",
+		"rm -rf build":            "rm",
+		"python script.py":        "python",
+		"npm install && npm test": "npm install",
+		"":                        "",
+	}
+	for command, want := range cases {
+		if got := CommandPrefix(command); got != want {
+			t.Errorf("CommandPrefix(%q) = %q, want %q", command, got, want)
+		}
+	}
+}
+
+func TestApprovalMemory_SessionScopeSkipsReviewAndApproval(t *testing.T) {
+	reviewer := &countingReviewer{risk: "high"}
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Reviewer: reviewer, Approvals: approvals, Memory: NewApprovalMemory(nil)})
+
+	h := &commandHandler{command: "npm install  lodash"}
+	if res, asked := runInSession(o, approvals, "s1", h, &ApprovalDecision{Approved: true, Scope: ApprovalScopeSession}); !asked || res.Status != "completed" {
+		t.Fatalf("expected first call to be approved interactively, asked=%v res=%+v", asked, res)
+	}
+	h = &commandHandler{command: "npm install lodash"}
+	if res, asked := runInSession(o, approvals, "s1", h, nil); asked || reviewer.calls != 1 || res.Status != "completed" {
+		t.Fatalf("expected remembered command to skip review, asked=%v calls=%d res=%+v", asked, reviewer.calls, res)
+	}
+	if _, asked := runInSession(o, approvals, "s2", h, &ApprovalDecision{Approved: true}); !asked {
+		t.Fatalf("session approval must not leak into another session")
+	}
+	h = &commandHandler{command: "npm install left-pad"}
+	if _, asked := runInSession(o, approvals, "s1", h, &ApprovalDecision{Approved: true}); !asked {
+		t.Fatalf("session scope should only cover the identical command")
+	}
+}
+
+func TestApprovalMemory_PrefixScopeAndPersistence(t *testing.T) {
+	dir := t.TempDir()
+	project := &ProjectApprovals{Path: filepath.Join(dir, "approvals.json"), Workdir: "/repo"}
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalAlways, Approvals: approvals, Memory: NewApprovalMemory(project)})
+
+	decision := &ApprovalDecision{Approved: true, Scope: ApprovalScopePrefix, Persist: true}
+	if _, asked := runInSession(o, approvals, "s1", &commandHandler{command: "git push origin main"}, decision); !asked {
+		t.Fatalf("expected first push to ask")
+	}
+	if _, asked := runInSession(o, approvals, "s1", &commandHandler{command: "git push --tags"}, nil); asked {
+		t.Fatalf("expected prefix approval to cover later pushes")
+	}
+	if _, asked := runInSession(o, approvals, "s1", &commandHandler{command: "git push && rm -rf /"}, &ApprovalDecision{}); !asked {
+		t.Fatalf("every segment must match the remembered prefix")
+	}
+
+	prefixes, err := project.Load()
+	if err != nil || len(prefixes) != 1 || prefixes[0] != "git push" {
+		t.Fatalf("expected persisted prefix, got %v err=%v", prefixes, err)
+	}
+	fresh := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalAlways, Approvals: approvals, Memory: NewApprovalMemory(project)})
+	if _, asked := runInSession(fresh, approvals, "other", &commandHandler{command: "git push"}, nil); asked {
+		t.Fatalf("expected project approval to apply to new sessions")
+	}
+}
+
+func TestApprovalMemory_DenyReasonReachesToolResult(t *testing.T) {
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalAlways, Approvals: approvals, Memory: NewApprovalMemory(nil)})
+	h := &commandHandler{command: "rm -rf build"}
+	res, _ := runInSession(o, approvals, "s1", h, &ApprovalDecision{Reason: "use make clean instead"})
+	if h.called || !strings.Contains(res.Error, "use make clean instead") {
+		t.Fatalf("expected denial reason in tool result, got %+v", res)
+	}
+}
+
+func TestApprovalMemory_SeedAndGrants(t *testing.T) {
+	m := NewApprovalMemory(nil)
+	m.Seed("s1", []ApprovalGrant{{Scope: ApprovalScopePrefix, Prefix: "make"}, {Scope: ApprovalScopePrefix, Prefix: "make"}})
+	if grants := m.Grants("s1"); len(grants) != 1 {
+		t.Fatalf("expected deduplicated grants, got %+v", grants)
+	}
+	if _, ok := m.match("s1", "make test", nil); !ok {
+		t.Fatalf("expected seeded prefix to match")
+	}
+}
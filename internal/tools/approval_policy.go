@@ -0,0 +1,441 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ApprovalPolicy 决定工具调用何时需要人工审批。
+type ApprovalPolicy string
+
+const (
+	// ApprovalNever 从不请求审批（deny 规则仍然生效），适合无人值守的 exec。
+	ApprovalNever ApprovalPolicy = "never"
+	// ApprovalOnRequest 为默认策略：安全命令直接执行，其余命令交给 LLM 审查，高风险或工作区外的文件变更需审批。
+	ApprovalOnRequest ApprovalPolicy = "on-request"
+	// ApprovalOnFailure 不预先审批，仅在执行失败且可通过提权重试时请求审批。
+	ApprovalOnFailure ApprovalPolicy = "on-failure"
+	// ApprovalUntrusted 只自动执行安全命令与 allow 规则命中的调用，其余变更都需审批。
+	ApprovalUntrusted ApprovalPolicy = "untrusted"
+	// ApprovalAlways 所有变更类工具调用都需审批（allow 规则命中的除外）。
+	ApprovalAlways ApprovalPolicy = "always"
+)
+
+// ApprovalPolicies 列出全部策略，供帮助信息与校验使用。
+var ApprovalPolicies = []ApprovalPolicy{ApprovalNever, ApprovalOnRequest, ApprovalOnFailure, ApprovalUntrusted, ApprovalAlways}
+
+// ParseApprovalPolicy 解析策略名称，接受下划线写法；空字符串返回 on-request。
+func ParseApprovalPolicy(raw string) (ApprovalPolicy, error) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	name = strings.ReplaceAll(name, "_", "-")
+	if name == "" {
+		return ApprovalOnRequest, nil
+	}
+	for _, p := range ApprovalPolicies {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	names := make([]string, 0, len(ApprovalPolicies))
+	for _, p := range ApprovalPolicies {
+		names = append(names, string(p))
+	}
+	return "", fmt.Errorf("unknown approval policy %q (supported: %s)", raw, strings.Join(names, ", "))
+}
+
+// ApprovalRules 是在 LLM 审查之前求值的规则：deny 优先于 allow。
+// 命令规则按词前缀匹配（"go test" 匹配 "go test ./..."，不匹配 "go testdata"）；
+// 路径规则为 glob（支持 **），不含 / 的模式按文件名匹配。
+type ApprovalRules struct {
+	AllowCommands []string
+	DenyCommands  []string
+	AllowPaths    []string
+	DenyPaths     []string
+}
+
+// DefaultSafeCommands 是 on-request/untrusted 下无需审查即可执行的只读或常规构建测试命令前缀。
+var DefaultSafeCommands = []string{
+	"ls", "pwd", "cat", "head", "tail", "wc", "echo", "which", "stat", "tree",
+	"grep", "rg", "diff",
+	"git status", "git diff", "git log", "git show", "git rev-parse", "git ls-files",
+	"go test", "go build", "go vet", "go list", "go env", "go version", "gofmt -l", "gofmt -d",
+}
+
+type approvalAction int
+
+const (
+	approvalRun approvalAction = iota
+	approvalAsk
+	approvalReview
+	approvalDeny
+)
+
+type approvalVerdict struct {
+	action approvalAction
+	reason string
+}
+
+// evaluate 依次应用 deny 规则、allow 规则、已记住的批准与策略，得出是否执行、审批、审查或拒绝。
+func (o *Orchestrator) evaluate(inv Invocation, handler Handler, base ToolResult) approvalVerdict {
+	if o != nil && handler.Kind() == ToolFileRead {
+		return o.evaluateRead(inv)
+	}
+	if o == nil || !handler.IsMutating(inv) || handler.Name() == "write_stdin" {
+		// write_stdin 只是向已批准的会话写入输入，不重复审批。
+		return approvalVerdict{action: approvalRun}
+	}
+	rules := o.rules
+	isCommand := handler.Kind() == ToolCommand && handler.Name() == "exec_command"
+	isFileChange := handler.Kind() == ToolApplyPatch
+	var paths []patchPath
+	if isFileChange {
+		paths = patchTargets(inv)
+	}
+
+	switch {
+	case isCommand:
+		if rule, ok := matchAnySegment(base.Command, rules.DenyCommands); ok {
+			return approvalVerdict{action: approvalDeny, reason: fmt.Sprintf("command matches deny rule %q", rule)}
+		}
+		if commandAllowed(base.Command, rules.AllowCommands) {
+			return approvalVerdict{action: approvalRun}
+		}
+	case isFileChange:
+		for _, p := range paths {
+			if rule, ok := matchPathRules(p, rules.DenyPaths); ok {
+				return approvalVerdict{action: approvalDeny, reason: fmt.Sprintf("path %s matches deny rule %q", p.display(), rule)}
+			}
+		}
+		if len(paths) > 0 && len(rules.AllowPaths) > 0 {
+			allowed := true
+			for _, p := range paths {
+				if _, ok := matchPathRules(p, rules.AllowPaths); !ok {
+					allowed = false
+					break
+				}
+			}
+			if allowed {
+				return approvalVerdict{action: approvalRun}
+			}
+		}
+	}
+
+	if isCommand || isFileChange {
+		command := ""
+		if isCommand {
+			command = base.Command
+		}
+		if reason, ok := o.memory.match(inv.SessionID, command, paths); ok {
+			return approvalVerdict{action: approvalRun, reason: reason}
+		}
+	}
+
+	switch o.policy {
+	case ApprovalNever, ApprovalOnFailure:
+		return approvalVerdict{action: approvalRun}
+	case ApprovalAlways:
+		return approvalVerdict{action: approvalAsk, reason: "approval_policy=always"}
+	case ApprovalUntrusted:
+		if isCommand && commandAllowed(base.Command, DefaultSafeCommands) {
+			return approvalVerdict{action: approvalRun}
+		}
+		return approvalVerdict{action: approvalAsk, reason: "approval_policy=untrusted: not a known safe command or allow-listed change"}
+	default:
+		if isCommand {
+			if commandAllowed(base.Command, DefaultSafeCommands) || o.reviewer == nil {
+				return approvalVerdict{action: approvalRun}
+			}
+			return approvalVerdict{action: approvalReview}
+		}
+		if isFileChange {
+			for _, p := range paths {
+				if p.outside {
+					return approvalVerdict{action: approvalAsk, reason: "file change outside workdir: " + p.display()}
+				}
+			}
+		}
+		return approvalVerdict{action: approvalRun}
+	}
+}
+
+// evaluateRead 处理 file_read：工作区内直接读取；工作区外的路径依次应用路径规则、已记住的批准与策略，
+// never/on-failure 直接读取，其余策略请求审批。
+func (o *Orchestrator) evaluateRead(inv Invocation) approvalVerdict {
+	paths := readTargets(inv)
+	if len(paths) == 0 || !paths[0].outside {
+		return approvalVerdict{action: approvalRun}
+	}
+	p := paths[0]
+	if rule, ok := matchPathRules(p, o.rules.DenyPaths); ok {
+		return approvalVerdict{action: approvalDeny, reason: fmt.Sprintf("path %s matches deny rule %q", p.display(), rule)}
+	}
+	if _, ok := matchPathRules(p, o.rules.AllowPaths); ok {
+		return approvalVerdict{action: approvalRun}
+	}
+	if reason, ok := o.memory.match(inv.SessionID, "", paths); ok {
+		return approvalVerdict{action: approvalRun, reason: reason}
+	}
+	switch o.policy {
+	case ApprovalNever, ApprovalOnFailure:
+		return approvalVerdict{action: approvalRun}
+	}
+	return approvalVerdict{action: approvalAsk, reason: "file read outside workdir: " + p.display()}
+}
+
+// commandSeparators 拆分复合命令；每一段都必须命中 allow 规则才算放行。单独的 & 也是分隔符（后台执行）。
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+
+// unsafeFlags 是会让前缀命中的命令写文件或执行其他程序的参数，按程序名索引；空键适用于所有命令。
+var unsafeFlags = map[string][]string{
+	"":      {"--output"},                                         // git diff/log/show --output
+	"rg":    {"--pre"},                                            // 用任意程序预处理文件
+	"go":    {"-o", "-w", "-u", "-exec", "-toolexec", "-vettool"}, // build/test -o、env -w/-u、以及执行外部程序
+	"gofmt": {"-w"},
+	"tree":  {"-o"},
+}
+
+// hasUnsafeFlag 报告一段命令是否带有 unsafeFlags 中的参数（含 --flag、flag=value 写法）。
+func hasUnsafeFlag(fields []string) bool {
+	flags := append(append([]string{}, unsafeFlags[""]...), unsafeFlags[filepath.Base(fields[0])]...)
+	for _, f := range fields[1:] {
+		name, _, _ := strings.Cut(f, "=")
+		for _, flag := range flags {
+			if name == flag || name == "-"+flag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commandAllowed 报告命令的每一段是否都命中前缀列表；含重定向、命令替换或 unsafeFlags 中参数的命令不会被自动放行。
+func commandAllowed(command string, prefixes []string) bool {
+	command = strings.TrimSpace(command)
+	if command == "" || len(prefixes) == 0 {
+		return false
+	}
+	if strings.ContainsAny(command, "`><") || strings.Contains(command, "$(") {
+		return false
+	}
+	for _, segment := range commandSeparators.Split(command, -1) {
+		fields := strings.Fields(segment)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, ok := matchPrefix(fields, prefixes); !ok {
+			return false
+		}
+		if hasUnsafeFlag(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAnySegment 报告复合命令中是否有任意一段命中前缀列表。
+func matchAnySegment(command string, prefixes []string) (string, bool) {
+	if len(prefixes) == 0 {
+		return "", false
+	}
+	for _, segment := range commandSeparators.Split(command, -1) {
+		fields := strings.Fields(strings.Trim(segment, "()$`"))
+		if len(fields) == 0 {
+			continue
+		}
+		if rule, ok := matchPrefix(fields, prefixes); ok {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+func matchPrefix(fields []string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		want := strings.Fields(prefix)
+		if len(want) == 0 || len(want) > len(fields) {
+			continue
+		}
+		matched := true
+		for i, w := range want {
+			if fields[i] != w {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// patchPath 是补丁涉及的一个路径；outside 表示位于工作目录之外。
+type patchPath struct {
+	rel     string
+	abs     string
+	outside bool
+}
+
+func (p patchPath) display() string {
+	if p.rel != "" {
+		return p.rel
+	}
+	return p.abs
+}
+
+// patchTargets 解析 apply_patch 参数中涉及的全部路径。
+func patchTargets(inv Invocation) []patchPath {
+	args := struct {
+		Patch string `json:"patch"`
+		Path  string `json:"path"`
+	}{}
+	_ = json.Unmarshal(inv.Call.Payload, &args)
+	workdir := inv.Workdir
+	if workdir == "" {
+		workdir = "."
+	}
+	raw, _ := patchPathsIn(workdir, args.Patch)
+	if strings.TrimSpace(args.Path) != "" {
+		raw = append(raw, args.Path)
+	}
+	var out []patchPath
+	seen := map[string]bool{}
+	for _, p := range raw {
+		p = strings.TrimSpace(p)
+		if p == "" || p == "/dev/null" {
+			continue
+		}
+		target := classifyPath(workdir, p)
+		if !seen[target.abs] {
+			seen[target.abs] = true
+			out = append(out, target)
+		}
+	}
+	return out
+}
+
+// readTargets 解析 file_read 的目标路径。
+func readTargets(inv Invocation) []patchPath {
+	args := struct {
+		Path string `json:"path"`
+	}{}
+	_ = json.Unmarshal(inv.Call.Payload, &args)
+	p := strings.TrimSpace(args.Path)
+	if p == "" {
+		return nil
+	}
+	workdir := inv.Workdir
+	if workdir == "" {
+		workdir = "."
+	}
+	return []patchPath{classifyPath(workdir, p)}
+}
+
+// classifyPath 解析路径中的符号链接后再判断是否位于工作目录内，
+// 避免仓库内指向外部文件（如 ~/.ssh/id_rsa）的链接绕过审批。
+func classifyPath(workdir, p string) patchPath {
+	wdAbs, _ := filepath.Abs(workdir)
+	abs := p
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(wdAbs, p)
+	}
+	wdReal := realPath(filepath.Clean(wdAbs))
+	abs = realPath(filepath.Clean(abs))
+	if abs == wdReal {
+		// 工作目录本身（如列出目录）。
+		return patchPath{rel: ".", abs: abs}
+	}
+	if rel, _, ok := resolvePathInWorkdir(wdReal, abs); ok {
+		return patchPath{rel: filepath.ToSlash(rel), abs: abs}
+	}
+	return patchPath{abs: abs, outside: true}
+}
+
+// realPath 解析 abs 中的符号链接；尚不存在的末尾部分（如新建文件）原样拼回已解析的父目录。
+func realPath(abs string) string {
+	rest := ""
+	for dir := abs; ; {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+func matchPathRules(p patchPath, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchPathGlob(pattern, p) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchPathGlob 匹配单条路径规则：绝对模式对绝对路径匹配，含 / 的模式对相对路径匹配，其余按文件名匹配。
+func matchPathGlob(pattern string, p patchPath) bool {
+	pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if pattern == "" {
+		return false
+	}
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(pattern, "/"):
+		return re.MatchString(filepath.ToSlash(p.abs))
+	case strings.Contains(pattern, "/"):
+		return p.rel != "" && re.MatchString(p.rel)
+	default:
+		target := p.rel
+		if target == "" {
+			target = filepath.ToSlash(p.abs)
+		}
+		// 不含 / 的模式匹配任意一级路径名，因此 "vendor" 覆盖 vendor 下的全部文件。
+		for _, part := range strings.Split(target, "/") {
+			if re.MatchString(part) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,299 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type countingReviewer struct {
+	calls int
+	risk  string
+}
+
+func (r *countingReviewer) Review(context.Context, string, string) (CommandReview, error) {
+	r.calls++
+	return CommandReview{RiskLevel: r.risk, Description: "reviewed"}, nil
+}
+
+type commandHandler struct {
+	stubExecCommandHandler
+	command string
+}
+
+func (h *commandHandler) Describe(Invocation) ToolResult { return ToolResult{Command: h.command} }
+
+func runWithPolicy(t *testing.T, o *Orchestrator, approvals *ApprovalStore, h Handler, payload string, approve *bool) (ToolResult, bool) {
+	t.Helper()
+	inv := Invocation{Call: ToolCall{ID: "call", Name: h.Name(), Payload: json.RawMessage(payload)}, Workdir: t.TempDir()}
+	asked := false
+	res := o.Run(context.Background(), inv, h, func(ev ToolEvent) {
+		if ev.Result.Status == "requires_approval" {
+			asked = true
+			if approve != nil {
+				go approvals.Resolve(ApprovalDecision{ApprovalID: ev.Result.ApprovalID, Approved: *approve})
+			}
+		}
+	})
+	return res, asked
+}
+
+func TestApprovalPolicy_SafeCommandsSkipReviewer(t *testing.T) {
+	reviewer := &countingReviewer{risk: "high"}
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Reviewer: reviewer, Approvals: approvals})
+
+	h := &commandHandler{command: "go test ./... && git status"}
+	res, asked := runWithPolicy(t, o, approvals, h, `{}`, nil)
+	if asked || reviewer.calls != 0 || res.Status != "completed" {
+		t.Fatalf("safe command should run without review: asked=%v calls=%d res=%+v", asked, reviewer.calls, res)
+	}
+
+	denied := false
+	h = &commandHandler{command: "go test ./... > out.txt"}
+	res, asked = runWithPolicy(t, o, approvals, h, `{}`, &denied)
+	if !asked || reviewer.calls != 1 || h.called || res.Status != "error" {
+		t.Fatalf("redirected command should go through review and approval: asked=%v calls=%d res=%+v", asked, reviewer.calls, res)
+	}
+}
+
+func TestApprovalPolicy_DenyRulesWinOverPolicy(t *testing.T) {
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{
+		Policy:    ApprovalNever,
+		Approvals: approvals,
+		Rules:     ApprovalRules{DenyCommands: []string{"git push"}, AllowCommands: []string{"git"}},
+	})
+	h := &commandHandler{command: "git add -A; git push --force"}
+	res, asked := runWithPolicy(t, o, approvals, h, `{}`, nil)
+	if asked || h.called || !strings.Contains(res.Error, `deny rule "git push"`) {
+		t.Fatalf("expected deny rule to block command, got asked=%v res=%+v", asked, res)
+	}
+}
+
+func TestCommandSegmentsSplitOnBackgroundOperator(t *testing.T) {
+	if commandAllowed("ls & rm -rf ~", DefaultSafeCommands) {
+		t.Fatalf("a backgrounded second command must not be auto-approved")
+	}
+	if !commandAllowed("ls & git status", DefaultSafeCommands) {
+		t.Fatalf("every segment is safe, expected allow")
+	}
+	if rule, ok := matchAnySegment("ls & rm -rf ~", []string{"rm"}); !ok || rule != "rm" {
+		t.Fatalf("deny rule should match the backgrounded segment, got %q %v", rule, ok)
+	}
+	if commandAllowed("git push & rm -rf ~", []string{"git push"}) {
+		t.Fatalf("an approved prefix must not cover a backgrounded command")
+	}
+}
+
+func TestCommandAllowedRejectsOutputFlag(t *testing.T) {
+	for _, cmd := range []string{"git diff --output=/tmp/x", "git diff --output /tmp/x HEAD", "git log -p --output=log.txt"} {
+		if commandAllowed(cmd, DefaultSafeCommands) {
+			t.Fatalf("%q writes a file and must not be auto-approved", cmd)
+		}
+	}
+	if !commandAllowed("git diff --stat", DefaultSafeCommands) {
+		t.Fatalf("plain git diff should stay safe")
+	}
+}
+
+func TestSafeCommandsWithWriteOrExecFlagsNeedApproval(t *testing.T) {
+	approvals := NewApprovalStore()
+	approve := false
+	o := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalUntrusted, Approvals: approvals})
+	for _, cmd := range []string{
+		"rg --pre ./run.sh TODO",
+		"rg --pre=sh TODO",
+		"go env -w GOFLAGS=-mod=mod",
+		"go env -u GOPROXY",
+		"tree -o listing.txt",
+		"gofmt -l -w .",
+		"go build -o /usr/local/bin/tool ./cmd/tool",
+		"go test -exec ./wrapper ./...",
+		"go vet -vettool=./analyzer ./...",
+	} {
+		h := &commandHandler{command: cmd}
+		if _, asked := runWithPolicy(t, o, approvals, h, `{}`, &approve); !asked || h.called {
+			t.Fatalf("%q must ask for approval, asked=%v called=%v", cmd, asked, h.called)
+		}
+	}
+	for _, cmd := range []string{"rg TODO", "go env GOPATH", "tree -L 2", "gofmt -l .", "go build ./...", "go test -run TestX ./..."} {
+		if !commandAllowed(cmd, DefaultSafeCommands) {
+			t.Fatalf("%q should stay safe", cmd)
+		}
+	}
+}
+
+func TestApprovalPolicy_UntrustedAndAlways(t *testing.T) {
+	approvals := NewApprovalStore()
+	approve := true
+
+	untrusted := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalUntrusted, Approvals: approvals})
+	if _, asked := runWithPolicy(t, untrusted, approvals, &commandHandler{command: "ls -la"}, `{}`, nil); asked {
+		t.Fatalf("untrusted should run known safe commands")
+	}
+	h := &commandHandler{command: "npm install"}
+	if res, asked := runWithPolicy(t, untrusted, approvals, h, `{}`, &approve); !asked || !h.called || res.Status != "completed" {
+		t.Fatalf("untrusted should ask before unknown commands, asked=%v res=%+v", asked, res)
+	}
+
+	always := NewOrchestratorWith(OrchestratorOptions{
+		Policy:    ApprovalAlways,
+		Approvals: approvals,
+		Rules:     ApprovalRules{AllowCommands: []string{"make lint"}},
+	})
+	if _, asked := runWithPolicy(t, always, approvals, &commandHandler{command: "ls"}, `{}`, &approve); !asked {
+		t.Fatalf("always should ask even for safe commands")
+	}
+	if _, asked := runWithPolicy(t, always, approvals, &commandHandler{command: "make lint"}, `{}`, nil); asked {
+		t.Fatalf("allow rules should bypass approval")
+	}
+}
+
+func TestApprovalPolicy_FileChanges(t *testing.T) {
+	approvals := NewApprovalStore()
+	patch := func(path string) string {
+		raw, _ := json.Marshal(map[string]string{"patch": "*** Begin Patch\n*** Add File: " + path + "\n+x\n*** End Patch"})
+		return string(raw)
+	}
+
+	o := NewOrchestratorWith(OrchestratorOptions{Approvals: approvals, Rules: ApprovalRules{DenyPaths: []string{".git/**", "*.pem"}}})
+	h := &stubApplyPatchHandler{}
+	if res, _ := runWithPolicy(t, o, approvals, h, patch("certs/server.pem"), nil); h.called || !strings.Contains(res.Error, "certs/server.pem") {
+		t.Fatalf("deny path rule should block patch, got %+v", res)
+	}
+	if _, asked := runWithPolicy(t, o, approvals, &stubApplyPatchHandler{}, patch("src/main.go"), nil); asked {
+		t.Fatalf("on-request should not ask for in-workdir changes")
+	}
+	denied := false
+	if _, asked := runWithPolicy(t, o, approvals, &stubApplyPatchHandler{}, patch("../outside.txt"), &denied); !asked {
+		t.Fatalf("on-request should ask for changes outside workdir")
+	}
+
+	untrusted := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalUntrusted, Approvals: approvals, Rules: ApprovalRules{AllowPaths: []string{"docs/**"}}})
+	if _, asked := runWithPolicy(t, untrusted, approvals, &stubApplyPatchHandler{}, patch("docs/guide/intro.md"), nil); asked {
+		t.Fatalf("allow path rule should bypass approval")
+	}
+	approve := true
+	if _, asked := runWithPolicy(t, untrusted, approvals, &stubApplyPatchHandler{}, patch("main.go"), &approve); !asked {
+		t.Fatalf("untrusted should ask for file changes")
+	}
+}
+
+type stubFileReadHandler struct {
+	called bool
+}
+
+func (h *stubFileReadHandler) Name() string               { return "file_read" }
+func (h *stubFileReadHandler) Kind() ToolKind             { return ToolFileRead }
+func (h *stubFileReadHandler) SupportsParallel() bool     { return true }
+func (h *stubFileReadHandler) IsMutating(Invocation) bool { return false }
+func (h *stubFileReadHandler) Describe(Invocation) ToolResult {
+	return ToolResult{Kind: ToolFileRead}
+}
+func (h *stubFileReadHandler) Handle(context.Context, Invocation) (ToolResult, error) {
+	h.called = true
+	return ToolResult{Status: "completed"}, nil
+}
+
+func TestApprovalPolicy_ReadsOutsideWorkdir(t *testing.T) {
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Approvals: approvals, Rules: ApprovalRules{DenyPaths: []string{"/etc/shadow"}}})
+	if _, asked := runWithPolicy(t, o, approvals, &stubFileReadHandler{}, `{"path":"src/main.go"}`, nil); asked {
+		t.Fatalf("reads inside the workdir should not ask")
+	}
+	if _, asked := runWithPolicy(t, o, approvals, &stubFileReadHandler{}, `{"path":"."}`, nil); asked {
+		t.Fatalf("listing the workdir itself should not ask")
+	}
+	denied := false
+	h := &stubFileReadHandler{}
+	if res, asked := runWithPolicy(t, o, approvals, h, `{"path":"../secrets.txt"}`, &denied); !asked || h.called || res.Status != "error" {
+		t.Fatalf("on-request should ask before reading outside the workdir, asked=%v res=%+v", asked, res)
+	}
+	h = &stubFileReadHandler{}
+	if res, _ := runWithPolicy(t, o, approvals, h, `{"path":"/etc/shadow"}`, nil); h.called || !strings.Contains(res.Error, "deny rule") {
+		t.Fatalf("deny path rule should block the read, got %+v", res)
+	}
+
+	never := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalNever, Approvals: approvals})
+	h = &stubFileReadHandler{}
+	if _, asked := runWithPolicy(t, never, approvals, h, `{"path":"/etc/hosts"}`, nil); asked || !h.called {
+		t.Fatalf("never should read outside the workdir without asking")
+	}
+}
+
+func TestApprovalPolicy_SymlinksOutsideWorkdirNeedApproval(t *testing.T) {
+	workdir, outside := t.TempDir(), t.TempDir()
+	secret := filepath.Join(outside, "id_rsa")
+	if err := os.WriteFile(secret, []byte("key"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink(secret, filepath.Join(workdir, "key")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(workdir, "ext")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	inv := func(payload string) Invocation {
+		return Invocation{Call: ToolCall{ID: "call", Payload: json.RawMessage(payload)}, Workdir: workdir}
+	}
+
+	if got := readTargets(inv(`{"path":"key"}`)); len(got) != 1 || !got[0].outside {
+		t.Fatalf("read through an in-repo symlink should be outside the workdir, got %+v", got)
+	}
+	if got := patchTargets(inv(`{"patch":"*** Begin Patch\n*** Add File: ext/new.txt\n+x\n*** End Patch"}`)); len(got) != 1 || !got[0].outside {
+		t.Fatalf("patch through a symlinked directory should be outside the workdir, got %+v", got)
+	}
+	if got := readTargets(inv(`{"path":"src/main.go"}`)); len(got) != 1 || got[0].outside || got[0].rel != "src/main.go" {
+		t.Fatalf("missing in-repo files should stay inside the workdir, got %+v", got)
+	}
+
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Approvals: approvals})
+	h := &stubFileReadHandler{}
+	asked := false
+	res := o.Run(context.Background(), inv(`{"path":"key"}`), h, func(ev ToolEvent) {
+		if ev.Result.Status == "requires_approval" {
+			asked = true
+			go approvals.Resolve(ApprovalDecision{ApprovalID: ev.Result.ApprovalID, Approved: false})
+		}
+	})
+	if !asked || h.called || res.Status != "error" {
+		t.Fatalf("reading a symlink to an outside file should ask, asked=%v res=%+v", asked, res)
+	}
+}
+
+func TestParseApprovalPolicy(t *testing.T) {
+	if p, err := ParseApprovalPolicy("ON_REQUEST"); err != nil || p != ApprovalOnRequest {
+		t.Fatalf("unexpected %q %v", p, err)
+	}
+	if p, _ := ParseApprovalPolicy(""); p != ApprovalOnRequest {
+		t.Fatalf("empty policy should default to on-request, got %q", p)
+	}
+	if _, err := ParseApprovalPolicy("sometimes"); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
+
+func TestMatchPathGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.md", "docs/readme.md", true},
+		{"vendor", "vendor/pkg/a.go", true},
+		{"internal/**/*.go", "internal/a/b/c.go", true},
+		{"internal/**/*.go", "internal/c.go", true},
+		{"internal/*.go", "internal/a/c.go", false},
+		{"cmd/", "cmd/echo-cli/main.go", true},
+	}
+	for _, tc := range cases {
+		if got := matchPathGlob(tc.pattern, patchPath{rel: tc.rel}); got != tc.want {
+			t.Errorf("matchPathGlob(%q, %q) = %v, want %v", tc.pattern, tc.rel, got, tc.want)
+		}
+	}
+}
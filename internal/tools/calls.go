@@ -20,6 +20,8 @@ func (r ToolRequest) ToCall() ToolCall {
 		payload = map[string]any{"path": r.Path}
 	case ToolSearch:
 		payload = map[string]any{"query": r.Query}
+	case ToolGrep:
+		payload = map[string]any{"pattern": r.Query, "path": r.Path}
 	default:
 		payload = map[string]any{}
 	}
@@ -44,6 +46,8 @@ func (r ToolRequest) effectiveName() string {
 		return "file_read"
 	case ToolSearch:
 		return "file_search"
+	case ToolGrep:
+		return "grep"
 	default:
 		return ""
 	}
@@ -7,4 +7,6 @@ import "context"
 type DispatchRequest struct {
 	Ctx  context.Context
 	Call ToolCall
+	// SessionID 为发起调用的会话，审批记忆按会话生效。
+	SessionID string
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"echo-cli/internal/events"
+	"echo-cli/internal/sandbox"
 	"echo-cli/internal/tools"
 	"echo-cli/internal/tools/handlers"
 )
@@ -15,6 +16,15 @@ type Dispatcher struct {
 
 type Options struct {
 	Reviewer tools.CommandReviewer
+	// Policy/Rules 决定工具调用何时需要审批，见 tools.ApprovalPolicy。
+	Policy tools.ApprovalPolicy
+	Rules  tools.ApprovalRules
+	// Handlers 追加在内置工具之后注册（例如 MCP 工具）。
+	Handlers []tools.Handler
+	// Memory 保存会话内/项目级记住的批准，见 tools.ApprovalMemory。
+	Memory *tools.ApprovalMemory
+	// Sandbox 约束命令执行与文件变更，见 sandbox.Policy。
+	Sandbox sandbox.Policy
 }
 
 func New(runner tools.Runner, bus *events.Bus, workdir string, opts Options) *Dispatcher {
@@ -22,13 +32,22 @@ func New(runner tools.Runner, bus *events.Bus, workdir string, opts Options) *Di
 		runtime: tools.NewRuntime(tools.RuntimeOptions{
 			Runner:   runner,
 			Workdir:  workdir,
-			Handlers: handlers.Default(),
+			Handlers: append(handlers.Default(), opts.Handlers...),
 			Reviewer: opts.Reviewer,
+			Policy:   opts.Policy,
+			Rules:    opts.Rules,
+			Memory:   opts.Memory,
+			Sandbox:  opts.Sandbox,
 		}),
 		bus: bus,
 	}
 }
 
+// ApprovalMemory 返回审批记忆，供调用方在保存/恢复会话时读写记住的批准。
+func (d *Dispatcher) ApprovalMemory() *tools.ApprovalMemory {
+	return d.runtime.ApprovalMemory()
+}
+
 func (d *Dispatcher) Start(ctx context.Context) {
 	if d.bus == nil {
 		return
@@ -52,7 +71,7 @@ func (d *Dispatcher) Start(ctx context.Context) {
 					if v.Call.Name == "" || v.Call.ID == "" {
 						continue
 					}
-					go d.runtime.Dispatch(callCtx, v.Call, func(ev tools.ToolEvent) {
+					go d.runtime.DispatchSession(callCtx, v.SessionID, v.Call, func(ev tools.ToolEvent) {
 						d.bus.Publish(ev)
 					})
 				case tools.ApprovalDecision:
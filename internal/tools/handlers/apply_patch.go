@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -94,6 +95,29 @@ func (ApplyPatchHandler) Handle(ctx context.Context, inv tools.Invocation) (tool
 		paths = []string{strings.TrimSpace(args.Path)}
 	}
 
+	if inv.Sandbox.Active() {
+		for _, rel := range paths {
+			target := rel
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(workdir, rel)
+			}
+			if abs, absErr := filepath.Abs(target); absErr == nil {
+				target = abs
+			}
+			if !inv.Sandbox.CanWrite(target) {
+				msg := fmt.Sprintf("blocked by sandbox (%s): cannot write %s", inv.Sandbox.Describe(), rel)
+				return tools.ToolResult{
+					ID:     inv.Call.ID,
+					Kind:   tools.ToolApplyPatch,
+					Status: tools.StatusSandboxDenied,
+					Error:  msg,
+					Path:   path,
+					Diff:   truncatePatchForEvent(args.Patch),
+				}, errors.New(msg)
+			}
+		}
+	}
+
 	before := make(map[string][]byte, len(paths))
 	for _, rel := range paths {
 		data, err := os.ReadFile(filepath.Join(workdir, rel))
@@ -10,6 +10,8 @@ func Default() []tools.Handler {
 		ApplyPatchHandler{},
 		FileReadHandler{},
 		FileSearchHandler{},
+		GrepHandler{},
 		PlanHandler{},
+		ViewImageHandler{},
 	}
 }
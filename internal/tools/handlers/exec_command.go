@@ -68,6 +68,7 @@ func (ExecCommandHandler) Handle(ctx context.Context, inv tools.Invocation) (too
 		BaseEnv:        os.Environ(),
 		YieldTime:      yield,
 		MaxOutputBytes: args.MaxOutputBytes,
+		Sandbox:        inv.Sandbox,
 	}
 	res, err := inv.UnifiedExec.ExecCommand(ctx, spec)
 	toolRes := tools.ToolResult{
@@ -92,6 +93,10 @@ func (ExecCommandHandler) Handle(ctx context.Context, inv tools.Invocation) (too
 		toolRes.Status = "error"
 		toolRes.Error = enrichCommandError(err, toolRes.ExitCode, toolRes.Output)
 	}
+	if toolRes.Status == "error" && inv.Sandbox.Denied(toolRes.Output+"\n"+toolRes.Error) {
+		toolRes.Status = tools.StatusSandboxDenied
+		toolRes.Error = fmt.Sprintf("blocked by sandbox (%s): %s", inv.Sandbox.Describe(), toolRes.Error)
+	}
 	return toolRes, err
 }
 
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"echo-cli/internal/sandbox"
+	"echo-cli/internal/tools"
+)
+
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == sandbox.HelperArg {
+		sandbox.RunHelper(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+func TestExecCommandHandler_SandboxDenialHasDistinctStatus(t *testing.T) {
+	if err := sandbox.Supported(); err != nil {
+		t.Skipf("landlock not available: %v", err)
+	}
+	workdir := t.TempDir()
+	// 避免登录 shell 读取真实 HOME 下的 rc 文件。
+	t.Setenv("HOME", t.TempDir())
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	outside := filepath.Join(cwd, "sandbox-escape.txt")
+	defer os.Remove(outside)
+
+	run := func(command string) tools.ToolResult {
+		payload, _ := json.Marshal(map[string]any{"command": command, "yield_time_ms": 5000})
+		inv := tools.Invocation{
+			Call:        tools.ToolCall{ID: "1", Name: "exec_command", Payload: payload},
+			Workdir:     workdir,
+			UnifiedExec: tools.NewUnifiedExecManager(),
+			Sandbox:     sandbox.Policy{Mode: sandbox.WorkspaceWrite, Workdir: workdir, NetworkAccess: true},
+		}
+		res, _ := ExecCommandHandler{}.Handle(context.Background(), inv)
+		return res
+	}
+
+	if res := run("echo ok > inside.txt"); res.Status != "completed" {
+		t.Fatalf("expected write inside workdir to succeed, got %+v", res)
+	}
+	res := run("touch " + outside)
+	if res.Status != tools.StatusSandboxDenied {
+		t.Fatalf("expected sandbox_denied status, got %+v", res)
+	}
+	if _, err := os.Stat(outside); err == nil {
+		t.Fatalf("file outside workdir should not exist")
+	}
+}
+
+func TestApplyPatchHandler_ReadOnlySandboxDeniesWrites(t *testing.T) {
+	tmp := t.TempDir()
+	payload, _ := json.Marshal(map[string]any{"patch": "*** Begin Patch\n*** Add File: new.txt\n+hello\n*** End Patch"})
+	inv := tools.Invocation{
+		Call:    tools.ToolCall{ID: "1", Name: "apply_patch", Payload: payload},
+		Workdir: tmp,
+		Runner:  localRunner{},
+		Sandbox: sandbox.Policy{Mode: sandbox.ReadOnly, Workdir: tmp},
+	}
+	res, err := ApplyPatchHandler{}.Handle(context.Background(), inv)
+	if err == nil || res.Status != tools.StatusSandboxDenied {
+		t.Fatalf("expected sandbox_denied, got %+v err=%v", res, err)
+	}
+	if _, statErr := os.Stat(filepath.Join(tmp, "new.txt")); statErr == nil {
+		t.Fatalf("patch should not have been applied")
+	}
+}
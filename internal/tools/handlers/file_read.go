@@ -1,17 +1,46 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"echo-cli/internal/tools"
 )
 
+const (
+	// defaultReadLimit 是未指定 limit 时读取的行数。
+	defaultReadLimit = 2000
+	// maxReadBytes 是单次输出的字节上限（约 16k token），超出时截断并提示续读的 offset。
+	maxReadBytes = 64 * 1024
+	// maxReadLineRunes 截断超长行（压缩文件、单行 JSON 等）。
+	maxReadLineRunes = 2000
+	// maxDirEntries 是目录列表的条目上限。
+	maxDirEntries = 500
+	// maxDecodeBytes 是需要整体解码（UTF-16）的文件大小上限。
+	maxDecodeBytes = 8 * 1024 * 1024
+	sniffBytes     = 8000
+)
+
 type FileReadHandler struct{}
 
+type fileReadArgs struct {
+	Path   string `json:"path"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+
 func (FileReadHandler) Name() string           { return "file_read" }
 func (FileReadHandler) Kind() tools.ToolKind   { return tools.ToolFileRead }
 func (FileReadHandler) SupportsParallel() bool { return true }
@@ -20,9 +49,7 @@ func (FileReadHandler) IsMutating(tools.Invocation) bool {
 }
 
 func (FileReadHandler) Describe(inv tools.Invocation) tools.ToolResult {
-	args := struct {
-		Path string `json:"path"`
-	}{}
+	var args fileReadArgs
 	_ = json.Unmarshal(inv.Call.Payload, &args)
 	return tools.ToolResult{
 		ID:   inv.Call.ID,
@@ -31,11 +58,14 @@ func (FileReadHandler) Describe(inv tools.Invocation) tools.ToolResult {
 	}
 }
 
+// Handle 读取文件的指定行范围（带行号），或列出目录内容。
+// 工作区外的路径由审批策略把关（见 tools.Orchestrator），这里不再限制。
 func (FileReadHandler) Handle(_ context.Context, inv tools.Invocation) (tools.ToolResult, error) {
-	args := struct {
-		Path string `json:"path"`
-	}{}
+	var args fileReadArgs
 	if err := json.Unmarshal(inv.Call.Payload, &args); err != nil || args.Path == "" {
+		if err == nil {
+			err = errors.New("missing path")
+		}
 		return tools.ToolResult{
 			ID:     inv.Call.ID,
 			Kind:   tools.ToolFileRead,
@@ -47,7 +77,7 @@ func (FileReadHandler) Handle(_ context.Context, inv tools.Invocation) (tools.To
 	if !filepath.IsAbs(target) && inv.Workdir != "" {
 		target = filepath.Join(inv.Workdir, target)
 	}
-	data, err := os.ReadFile(target)
+	output, err := readPath(target, args.Offset, args.Limit)
 	status := "completed"
 	errMsg := ""
 	if err != nil {
@@ -59,7 +89,192 @@ func (FileReadHandler) Handle(_ context.Context, inv tools.Invocation) (tools.To
 		Kind:   tools.ToolFileRead,
 		Status: status,
 		Error:  errMsg,
-		Output: string(data),
+		Output: output,
 		Path:   args.Path,
 	}, err
 }
+
+func readPath(path string, offset, limit int) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return listDirectory(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if info.Size() == 0 {
+		return "[empty file]", nil
+	}
+
+	br := bufio.NewReaderSize(f, 64*1024)
+	head, _ := br.Peek(sniffBytes)
+	var src *bufio.Reader
+	note := ""
+	switch encoding := detectEncoding(head); encoding {
+	case "binary":
+		return fmt.Sprintf("[binary file: %s, %d bytes; not shown]", http.DetectContentType(head), info.Size()), nil
+	case "utf-16le", "utf-16be":
+		if info.Size() > maxDecodeBytes {
+			return "", fmt.Errorf("%s file is too large to decode (%d bytes)", encoding, info.Size())
+		}
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return "", err
+		}
+		src = bufio.NewReader(strings.NewReader(decodeUTF16(data, encoding == "utf-16be")))
+		note = "[decoded from " + encoding + "]\n"
+	default:
+		if bytes.HasPrefix(head, []byte("\xef\xbb\xbf")) {
+			_, _ = br.Discard(3)
+		}
+		src = br
+	}
+	out, err := numberedLines(src, offset, limit)
+	if err != nil {
+		return "", err
+	}
+	return note + out, nil
+}
+
+// numberedLines 输出 [offset, offset+limit) 行（行号从 1 开始，cat -n 格式），
+// 超出字节上限或行数上限时附上续读提示。
+func numberedLines(r *bufio.Reader, offset, limit int) (string, error) {
+	if offset <= 0 {
+		offset = 1
+	}
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	var sb strings.Builder
+	lineNo := 0
+	last := 0
+	more := false
+	invalid := false
+	for {
+		line, err := r.ReadString('\n')
+		if line == "" && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", err
+		}
+		lineNo++
+		if lineNo < offset {
+			continue
+		}
+		if lineNo >= offset+limit {
+			more = true
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if !utf8.ValidString(line) {
+			invalid = true
+			line = strings.ToValidUTF8(line, "�")
+		}
+		if utf8.RuneCountInString(line) > maxReadLineRunes {
+			line = string([]rune(line)[:maxReadLineRunes]) + " … [line truncated]"
+		}
+		entry := fmt.Sprintf("%6d\t%s\n", lineNo, line)
+		if sb.Len()+len(entry) > maxReadBytes && last > 0 {
+			more = true
+			break
+		}
+		sb.WriteString(entry)
+		last = lineNo
+		if err != nil {
+			break
+		}
+	}
+	if last == 0 {
+		return "", fmt.Errorf("offset %d is past the end of the file (%d lines)", offset, lineNo)
+	}
+	out := strings.TrimRight(sb.String(), "\n")
+	if invalid {
+		out += "\n[invalid UTF-8 sequences were replaced with U+FFFD]"
+	}
+	if more {
+		out += fmt.Sprintf("\n[truncated: showing lines %d-%d; call file_read with offset=%d to continue]", offset, last, last+1)
+	}
+	return out, nil
+}
+
+// detectEncoding 根据 BOM 与内容判断编码：utf-8、utf-16le/be 或 binary（含 NUL 或大量非法 UTF-8）。
+func detectEncoding(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	case bytes.IndexByte(head, 0) >= 0:
+		return "binary"
+	}
+	if utf8.Valid(head) {
+		return "utf-8"
+	}
+	invalid := 0
+	for rest := head; len(rest) > 0; {
+		r, size := utf8.DecodeRune(rest)
+		// 截断在多字节字符中间的结尾不算非法。
+		if r == utf8.RuneError && size == 1 && len(rest) >= utf8.UTFMax {
+			invalid++
+		}
+		rest = rest[size:]
+	}
+	if invalid*10 > len(head) {
+		return "binary"
+	}
+	return "utf-8"
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	data = data[2:] // BOM
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// listDirectory 列出目录条目：子目录在前并以 / 结尾，文件附带大小。
+func listDirectory(path string) (string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[directory: %d entries]\n", len(entries))
+	for i, entry := range entries {
+		if i == maxDirEntries {
+			fmt.Fprintf(&sb, "[truncated: %d more entries]\n", len(entries)-maxDirEntries)
+			break
+		}
+		switch {
+		case entry.IsDir():
+			sb.WriteString(entry.Name() + "/\n")
+		case entry.Type()&os.ModeSymlink != 0:
+			sb.WriteString(entry.Name() + "@\n")
+		default:
+			size := ""
+			if info, err := entry.Info(); err == nil {
+				size = fmt.Sprintf(" (%d bytes)", info.Size())
+			}
+			sb.WriteString(entry.Name() + size + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n"), nil
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"echo-cli/internal/tools"
+)
+
+func readWith(t *testing.T, workdir string, args map[string]any) (tools.ToolResult, error) {
+	t.Helper()
+	payload, _ := json.Marshal(args)
+	return FileReadHandler{}.Handle(context.Background(), tools.Invocation{
+		Call:    tools.ToolCall{ID: "1", Name: "file_read", Payload: payload},
+		Workdir: workdir,
+	})
+}
+
+func TestFileReadHandler_LineRangesAndTruncation(t *testing.T) {
+	tmp := t.TempDir()
+	var sb strings.Builder
+	for i := 1; i <= 3000; i++ {
+		fmt.Fprintf(&sb, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "big.txt"), []byte(sb.String()), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	res, err := readWith(t, tmp, map[string]any{"path": "big.txt", "offset": 10, "limit": 2})
+	if err != nil || res.Output != "    10\tline 10\n    11\tline 11\n[truncated: showing lines 10-11; call file_read with offset=12 to continue]" {
+		t.Fatalf("unexpected range output %q err=%v", res.Output, err)
+	}
+
+	res, _ = readWith(t, tmp, map[string]any{"path": "big.txt"})
+	if !strings.HasSuffix(res.Output, "[truncated: showing lines 1-2000; call file_read with offset=2001 to continue]") {
+		t.Fatalf("default limit should stop at 2000 lines, got tail %q", res.Output[len(res.Output)-120:])
+	}
+
+	long := strings.Repeat("x", 1000) + "\n"
+	if err := os.WriteFile(filepath.Join(tmp, "wide.txt"), []byte(strings.Repeat(long, 200)), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	res, _ = readWith(t, tmp, map[string]any{"path": "wide.txt"})
+	if len(res.Output) > maxReadBytes+200 || !strings.Contains(res.Output, "call file_read with offset=") {
+		t.Fatalf("byte cap not applied: %d bytes", len(res.Output))
+	}
+
+	if res, err := readWith(t, tmp, map[string]any{"path": "big.txt", "offset": 5000}); err == nil || !strings.Contains(res.Error, "past the end") {
+		t.Fatalf("expected offset error, got %+v", res)
+	}
+}
+
+func TestFileReadHandler_BinaryEncodingsAndDirectories(t *testing.T) {
+	tmp := t.TempDir()
+	files := map[string][]byte{
+		"image.png": []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+		"bom.txt":   []byte("\xef\xbb\xbfhello\n"),
+		"wide.txt":  {0xff, 0xfe, 'h', 0, 'i', 0, '\n', 0},
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tmp, name), data, 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(tmp, "sub"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	res, _ := readWith(t, tmp, map[string]any{"path": "image.png"})
+	if res.Output != "[binary file: image/png, 16 bytes; not shown]" {
+		t.Fatalf("unexpected binary output %q", res.Output)
+	}
+	res, _ = readWith(t, tmp, map[string]any{"path": "bom.txt"})
+	if res.Output != "     1\thello" {
+		t.Fatalf("BOM should be stripped, got %q", res.Output)
+	}
+	res, _ = readWith(t, tmp, map[string]any{"path": "wide.txt"})
+	if res.Output != "[decoded from utf-16le]\n     1\thi" {
+		t.Fatalf("unexpected utf-16 output %q", res.Output)
+	}
+	res, _ = readWith(t, tmp, map[string]any{"path": "."})
+	if res.Output != "[directory: 4 entries]\nsub/\nbom.txt (9 bytes)\nimage.png (16 bytes)\nwide.txt (8 bytes)" {
+		t.Fatalf("unexpected listing %q", res.Output)
+	}
+}
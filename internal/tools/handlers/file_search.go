@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 
 	"echo-cli/internal/search"
 	"echo-cli/internal/tools"
 )
 
+// fileSearchResultLimit 是返回的路径数上限。
+const fileSearchResultLimit = 200
+
 type FileSearchHandler struct{}
 
 func (FileSearchHandler) Name() string           { return "file_search" }
@@ -19,17 +23,20 @@ func (FileSearchHandler) IsMutating(tools.Invocation) bool {
 
 func (FileSearchHandler) Describe(inv tools.Invocation) tools.ToolResult {
 	return tools.ToolResult{
-		ID:   inv.Call.ID,
-		Kind: tools.ToolSearch,
+		ID:    inv.Call.ID,
+		Kind:  tools.ToolSearch,
+		Query: fileSearchQuery(inv),
 	}
 }
 
-func (FileSearchHandler) Handle(_ context.Context, inv tools.Invocation) (tools.ToolResult, error) {
+func (FileSearchHandler) Handle(ctx context.Context, inv tools.Invocation) (tools.ToolResult, error) {
 	root := inv.Workdir
 	if root == "" {
 		root = "."
 	}
-	paths, err := search.FindFiles(root, 200)
+	query := fileSearchQuery(inv)
+	// 查询为空时按层级由浅到深返回；否则按模糊匹配得分排序。
+	out, err := search.SearchFiles(ctx, root, query, fileSearchResultLimit)
 	status := "completed"
 	errMsg := ""
 	if err != nil {
@@ -41,6 +48,15 @@ func (FileSearchHandler) Handle(_ context.Context, inv tools.Invocation) (tools.
 		Kind:   tools.ToolSearch,
 		Status: status,
 		Error:  errMsg,
-		Output: strings.Join(paths, "\n"),
+		Output: strings.Join(out, "\n"),
+		Query:  query,
 	}, err
 }
+
+func fileSearchQuery(inv tools.Invocation) string {
+	var args struct {
+		Query string `json:"query"`
+	}
+	_ = json.Unmarshal(inv.Call.Payload, &args)
+	return strings.TrimSpace(args.Query)
+}
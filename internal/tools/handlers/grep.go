@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"echo-cli/internal/search"
+	"echo-cli/internal/tools"
+)
+
+// GrepHandler 在工作区内搜索文件内容（正则或字面量），遵循 .gitignore。
+type GrepHandler struct{}
+
+type grepArgs struct {
+	Pattern    string   `json:"pattern"`
+	Literal    bool     `json:"literal"`
+	IgnoreCase bool     `json:"ignore_case"`
+	Path       string   `json:"path"`
+	Include    []string `json:"include"`
+	Exclude    []string `json:"exclude"`
+	Context    int      `json:"context"`
+	MaxResults int      `json:"max_results"`
+}
+
+// maxGrepContext 限制上下文行数，避免单次输出过大。
+const maxGrepContext = 10
+
+func (GrepHandler) Name() string           { return "grep" }
+func (GrepHandler) Kind() tools.ToolKind   { return tools.ToolGrep }
+func (GrepHandler) SupportsParallel() bool { return true }
+func (GrepHandler) IsMutating(tools.Invocation) bool {
+	return false
+}
+
+func (GrepHandler) Describe(inv tools.Invocation) tools.ToolResult {
+	var args grepArgs
+	_ = json.Unmarshal(inv.Call.Payload, &args)
+	return tools.ToolResult{
+		ID:    inv.Call.ID,
+		Kind:  tools.ToolGrep,
+		Path:  args.Path,
+		Query: args.Pattern,
+	}
+}
+
+func (GrepHandler) Handle(ctx context.Context, inv tools.Invocation) (tools.ToolResult, error) {
+	var args grepArgs
+	if err := json.Unmarshal(inv.Call.Payload, &args); err != nil || args.Pattern == "" {
+		if err == nil {
+			err = errors.New("missing pattern")
+		}
+		return tools.ToolResult{
+			ID:     inv.Call.ID,
+			Kind:   tools.ToolGrep,
+			Status: "error",
+			Error:  "invalid grep payload",
+		}, fmt.Errorf("invalid grep payload: %w", err)
+	}
+	fail := func(err error) (tools.ToolResult, error) {
+		return tools.ToolResult{
+			ID:     inv.Call.ID,
+			Kind:   tools.ToolGrep,
+			Status: "error",
+			Error:  err.Error(),
+			Path:   args.Path,
+			Query:  args.Pattern,
+		}, err
+	}
+	root, err := workspacePath(inv.Workdir, ".")
+	if err != nil {
+		return fail(err)
+	}
+	sub := ""
+	if strings.TrimSpace(args.Path) != "" {
+		target, err := workspacePath(inv.Workdir, args.Path)
+		if err != nil {
+			return fail(err)
+		}
+		if sub, err = filepath.Rel(root, target); err != nil {
+			return fail(err)
+		}
+	}
+	res, err := search.Grep(ctx, root, search.GrepOptions{
+		Pattern:    args.Pattern,
+		Literal:    args.Literal,
+		IgnoreCase: args.IgnoreCase,
+		Path:       sub,
+		Include:    args.Include,
+		Exclude:    args.Exclude,
+		Context:    min(max(args.Context, 0), maxGrepContext),
+		MaxResults: args.MaxResults,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return tools.ToolResult{
+		ID:     inv.Call.ID,
+		Kind:   tools.ToolGrep,
+		Status: "completed",
+		Output: search.FormatGrep(res, search.DefaultMaxBytes),
+		Path:   args.Path,
+		Query:  args.Pattern,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"echo-cli/internal/tools"
+)
+
+func TestGrepHandler_SearchesWorkspace(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "pkg"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "pkg", "a.go"), []byte("package pkg\n\nfunc Run() {}\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	call := func(args map[string]any) (tools.ToolResult, error) {
+		payload, _ := json.Marshal(args)
+		return GrepHandler{}.Handle(context.Background(), tools.Invocation{
+			Call:    tools.ToolCall{ID: "1", Name: "grep", Payload: payload},
+			Workdir: tmp,
+		})
+	}
+
+	res, err := call(map[string]any{"pattern": "func Run", "path": "pkg", "context": 1})
+	if err != nil || res.Status != "completed" {
+		t.Fatalf("status=%s err=%v", res.Status, err)
+	}
+	if res.Output != "pkg/a.go-2-\npkg/a.go:3:func Run() {}" {
+		t.Fatalf("unexpected output:\n%s", res.Output)
+	}
+
+	res, _ = call(map[string]any{"pattern": "Nope"})
+	if res.Status != "completed" || !strings.HasPrefix(res.Output, "no matches") {
+		t.Fatalf("expected empty result, got %+v", res)
+	}
+
+	if res, err := call(map[string]any{"pattern": "x", "path": "../"}); err == nil || !strings.Contains(res.Error, "outside the workspace") {
+		t.Fatalf("expected workspace error, got %+v", res)
+	}
+}
+
+func TestFileSearchHandler_RanksByQuery(t *testing.T) {
+	tmp := t.TempDir()
+	for _, rel := range []string{"internal/tui/model.go", "internal/tui/view.go", "docs/model.md"} {
+		full := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, nil, 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	payload, _ := json.Marshal(map[string]any{"query": "tui model"})
+	res, err := FileSearchHandler{}.Handle(context.Background(), tools.Invocation{
+		Call:    tools.ToolCall{ID: "1", Name: "file_search", Payload: payload},
+		Workdir: tmp,
+	})
+	if err != nil || res.Output != filepath.FromSlash("internal/tui/model.go") {
+		t.Fatalf("unexpected result %q err=%v", res.Output, err)
+	}
+}
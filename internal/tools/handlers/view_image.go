@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/tools"
+)
+
+// ViewImageHandler 读取工作区内的图片，交给模型作为图片输入查看。
+type ViewImageHandler struct{}
+
+func (ViewImageHandler) Name() string           { return "view_image" }
+func (ViewImageHandler) Kind() tools.ToolKind   { return tools.ToolViewImage }
+func (ViewImageHandler) SupportsParallel() bool { return true }
+func (ViewImageHandler) IsMutating(tools.Invocation) bool {
+	return false
+}
+
+func (ViewImageHandler) Describe(inv tools.Invocation) tools.ToolResult {
+	args := struct {
+		Path string `json:"path"`
+	}{}
+	_ = json.Unmarshal(inv.Call.Payload, &args)
+	return tools.ToolResult{
+		ID:   inv.Call.ID,
+		Kind: tools.ToolViewImage,
+		Path: args.Path,
+	}
+}
+
+func (ViewImageHandler) Handle(_ context.Context, inv tools.Invocation) (tools.ToolResult, error) {
+	args := struct {
+		Path string `json:"path"`
+	}{}
+	if err := json.Unmarshal(inv.Call.Payload, &args); err != nil || strings.TrimSpace(args.Path) == "" {
+		if err == nil {
+			err = errors.New("missing path")
+		}
+		return tools.ToolResult{
+			ID:     inv.Call.ID,
+			Kind:   tools.ToolViewImage,
+			Status: "error",
+			Error:  "invalid view_image payload",
+		}, fmt.Errorf("invalid view_image payload: %w", err)
+	}
+	fail := func(err error) (tools.ToolResult, error) {
+		return tools.ToolResult{
+			ID:     inv.Call.ID,
+			Kind:   tools.ToolViewImage,
+			Status: "error",
+			Error:  err.Error(),
+			Path:   args.Path,
+		}, err
+	}
+	target, err := workspacePath(inv.Workdir, args.Path)
+	if err != nil {
+		return fail(err)
+	}
+	image, err := agent.LoadImage(target)
+	if err != nil {
+		return fail(err)
+	}
+	return tools.ToolResult{
+		ID:     inv.Call.ID,
+		Kind:   tools.ToolViewImage,
+		Status: "completed",
+		Output: fmt.Sprintf("attached image %s (%s)", args.Path, image.MediaType),
+		Path:   args.Path,
+		Images: []string{image.DataURL()},
+	}, nil
+}
+
+// workspacePath 解析相对工作目录的路径（含符号链接），并拒绝工作目录之外的文件。
+func workspacePath(workdir, path string) (string, error) {
+	root := workdir
+	if root == "" {
+		root = "."
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+	// 仓库内的符号链接可能指向工作目录之外，按解析后的真实路径判断。
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the workspace", path)
+	}
+	return target, nil
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"echo-cli/internal/tools"
+)
+
+func TestViewImageHandler_AttachesWorkspaceImages(t *testing.T) {
+	tmp := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if err := os.WriteFile(filepath.Join(tmp, "ui.png"), png, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	call := func(path string) (tools.ToolResult, error) {
+		payload, _ := json.Marshal(map[string]any{"path": path})
+		return ViewImageHandler{}.Handle(context.Background(), tools.Invocation{
+			Call:    tools.ToolCall{ID: "1", Name: "view_image", Payload: payload},
+			Workdir: tmp,
+		})
+	}
+
+	res, err := call("ui.png")
+	if err != nil || res.Status != "completed" {
+		t.Fatalf("status=%s err=%v", res.Status, err)
+	}
+	if len(res.Images) != 1 || !strings.HasPrefix(res.Images[0], "data:image/png;base64,") {
+		t.Fatalf("unexpected images %v", res.Images)
+	}
+	if strings.Contains(res.Output, "base64") {
+		t.Fatalf("output should not carry image data: %q", res.Output)
+	}
+
+	res, err = call("../outside.png")
+	if err == nil || res.Status != "error" || !strings.Contains(res.Error, "outside the workspace") {
+		t.Fatalf("expected workspace error, got status=%s err=%v", res.Status, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "outside.png")
+	if err := os.WriteFile(outside, png, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(tmp, "link.png")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	res, err = call("link.png")
+	if err == nil || res.Status != "error" || !strings.Contains(res.Error, "outside the workspace") {
+		t.Fatalf("expected symlink escape to be rejected, got status=%s err=%v", res.Status, err)
+	}
+}
@@ -2,6 +2,8 @@ package tools
 
 import (
 	"context"
+
+	"echo-cli/internal/sandbox"
 )
 
 // Runner 提供最小化的执行接口。
@@ -15,6 +17,10 @@ type Invocation struct {
 	Call    ToolCall
 	Workdir string
 	Runner  Runner
+	// SessionID 标识发起调用的会话，用于查询会话内记住的批准。
+	SessionID string
+	// Sandbox 是命令执行与文件变更的沙箱约束；审批提权后为 full-access。
+	Sandbox sandbox.Policy
 
 	UnifiedExec *UnifiedExecManager
 }
@@ -4,22 +4,36 @@ import (
 	"context"
 	"fmt"
 	"strings"
+
+	"echo-cli/internal/sandbox"
 )
 
 type Orchestrator struct {
 	reviewer  CommandReviewer
 	approvals *ApprovalStore
+	policy    ApprovalPolicy
+	rules     ApprovalRules
+	memory    *ApprovalMemory
 }
 
 type OrchestratorOptions struct {
 	Reviewer  CommandReviewer
 	Approvals *ApprovalStore
+	// Policy 为空时按 on-request 处理。
+	Policy ApprovalPolicy
+	Rules  ApprovalRules
+	// Memory 记住 "本会话批准"/"前缀批准" 等决策；为 nil 时每次都重新询问。
+	Memory *ApprovalMemory
 }
 
-func NewOrchestrator() *Orchestrator { return &Orchestrator{} }
+func NewOrchestrator() *Orchestrator { return &Orchestrator{policy: ApprovalOnRequest} }
 
 func NewOrchestratorWith(opts OrchestratorOptions) *Orchestrator {
-	return &Orchestrator{reviewer: opts.Reviewer, approvals: opts.Approvals}
+	policy := opts.Policy
+	if policy == "" {
+		policy = ApprovalOnRequest
+	}
+	return &Orchestrator{reviewer: opts.Reviewer, approvals: opts.Approvals, policy: policy, rules: opts.Rules, memory: opts.Memory}
 }
 
 func (o *Orchestrator) Run(ctx context.Context, inv Invocation, handler Handler, emit func(ToolEvent)) ToolResult {
@@ -32,23 +46,25 @@ func (o *Orchestrator) Run(ctx context.Context, inv Invocation, handler Handler,
 		Result: base,
 	})
 
-	if o != nil && o.shouldRequireApproval(handler) {
-		if err := o.waitForApproval(ctx, inv, base, emit); err != nil {
-			result := ToolResult{
-				ID:       inv.Call.ID,
-				Kind:     handler.Kind(),
-				Status:   "error",
-				Error:    err.Error(),
-				Command:  base.Command,
-				ExitCode: -1,
-			}
-			emit(ToolEvent{Type: "item.completed", Result: result})
-			return result
+	if err := o.gate(ctx, inv, handler, base, emit); err != nil {
+		result := ToolResult{
+			ID:       inv.Call.ID,
+			Kind:     handler.Kind(),
+			Status:   "error",
+			Error:    err.Error(),
+			Command:  base.Command,
+			Path:     base.Path,
+			ExitCode: -1,
 		}
+		emit(ToolEvent{Type: "item.completed", Result: result})
+		return result
 	}
 
 	result, err := handler.Handle(ctx, inv)
 	result = normalizeResult(result, err, inv, handler)
+	if result.Status == StatusSandboxDenied {
+		result = o.escalate(ctx, inv, handler, base, result, emit)
+	}
 
 	emit(ToolEvent{
 		Type:   "item.completed",
@@ -74,20 +90,25 @@ func normalizeResult(result ToolResult, err error, inv Invocation, handler Handl
 	return result
 }
 
-func (o *Orchestrator) shouldRequireApproval(handler Handler) bool {
-	if o == nil || o.reviewer == nil {
-		return false
+// gate 按审批策略决定调用能否执行；返回错误表示被规则拒绝或审批未通过。
+func (o *Orchestrator) gate(ctx context.Context, inv Invocation, handler Handler, base ToolResult, emit func(ToolEvent)) error {
+	verdict := o.evaluate(inv, handler, base)
+	switch verdict.action {
+	case approvalDeny:
+		return fmt.Errorf("denied by approval rule: %s", verdict.reason)
+	case approvalAsk:
+		return o.waitForApproval(ctx, inv, inv.Call.ID, base, verdict.reason, emit)
+	case approvalReview:
+		return o.reviewCommand(ctx, inv, base, emit)
 	}
-	// 对齐 codex：只对“启动一个新命令”的入口做安全审查；write_stdin 不重复审查。
-	return handler.Kind() == ToolCommand && handler.Name() == "exec_command"
+	return nil
 }
 
-func (o *Orchestrator) waitForApproval(ctx context.Context, inv Invocation, base ToolResult, emit func(ToolEvent)) error {
+// reviewCommand 由 LLM 审查命令风险，仅 risk_level=high 时请求人工审批。
+func (o *Orchestrator) reviewCommand(ctx context.Context, inv Invocation, base ToolResult, emit func(ToolEvent)) error {
 	if o == nil || o.reviewer == nil {
 		return nil
 	}
-	approvalID := inv.Call.ID
-
 	review, err := o.reviewer.Review(ctx, inv.Workdir, base.Command)
 	if err != nil {
 		// Fail closed: 审查失败时要求人工审批。
@@ -99,14 +120,45 @@ func (o *Orchestrator) waitForApproval(ctx context.Context, inv Invocation, base
 	if strings.ToLower(strings.TrimSpace(review.RiskLevel)) != "high" {
 		return nil
 	}
-	if o.approvals == nil {
-		return fmt.Errorf("approval required but approval store not configured")
-	}
-
 	msg := strings.TrimSpace(review.Description)
 	if msg == "" {
 		msg = "命令被判定为高风险，需要人工审批"
 	}
+	return o.waitForApproval(ctx, inv, inv.Call.ID, base, "risk_level=high: "+msg, emit)
+}
+
+// escalate 在沙箱拒绝后请求人工审批，批准后在沙箱外重试一次；
+// never 策略下或审批未通过时保留沙箱拒绝的结果交给模型。
+func (o *Orchestrator) escalate(ctx context.Context, inv Invocation, handler Handler, base ToolResult, denied ToolResult, emit func(ToolEvent)) ToolResult {
+	if o == nil || o.policy == ApprovalNever || !inv.Sandbox.Active() {
+		return denied
+	}
+	command := ""
+	var paths []patchPath
+	switch handler.Kind() {
+	case ToolCommand:
+		command = base.Command
+	case ToolApplyPatch:
+		paths = patchTargets(inv)
+	}
+	if _, remembered := o.memory.match(inv.SessionID, command, paths); !remembered {
+		reason := denied.Error + "; approve to retry without sandbox"
+		// 使用独立的审批 ID，避免与执行前的审批决策混淆。
+		if err := o.waitForApproval(ctx, inv, inv.Call.ID+":escalate", base, reason, emit); err != nil {
+			denied.Error += "; " + err.Error()
+			return denied
+		}
+	}
+	inv.Sandbox = sandbox.Policy{Mode: sandbox.FullAccess}
+	result, err := handler.Handle(ctx, inv)
+	return normalizeResult(result, err, inv, handler)
+}
+
+// waitForApproval 发出 requires_approval 事件并阻塞等待审批结果。
+func (o *Orchestrator) waitForApproval(ctx context.Context, inv Invocation, approvalID string, base ToolResult, reason string, emit func(ToolEvent)) error {
+	if o.approvals == nil {
+		return fmt.Errorf("approval required but approval store not configured")
+	}
 	emit(ToolEvent{
 		Type: "item.updated",
 		Result: ToolResult{
@@ -114,19 +166,32 @@ func (o *Orchestrator) waitForApproval(ctx context.Context, inv Invocation, base
 			Kind:           handlerKindFallback(base.Kind, ToolCommand),
 			Status:         "requires_approval",
 			Command:        base.Command,
+			Path:           base.Path,
+			Diff:           base.Diff,
 			ApprovalID:     approvalID,
-			ApprovalReason: "risk_level=high: " + msg,
-			Output:         "approval_required: risk_level=high: " + msg,
+			ApprovalReason: reason,
+			Output:         "approval_required: " + reason,
 		},
 	})
 
-	approved, err := o.approvals.Wait(ctx, approvalID)
+	decision, err := o.approvals.Wait(ctx, approvalID)
 	if err != nil {
 		return err
 	}
-	if !approved {
+	if !decision.Approved {
+		if reason := strings.TrimSpace(decision.Reason); reason != "" {
+			return fmt.Errorf("approval denied by user: %s", reason)
+		}
 		return fmt.Errorf("approval denied")
 	}
+	switch base.Kind {
+	case ToolApplyPatch:
+		o.memory.Remember(inv.SessionID, decision, "", patchTargets(inv))
+	case ToolFileRead:
+		o.memory.Remember(inv.SessionID, decision, "", readTargets(inv))
+	case ToolCommand:
+		o.memory.Remember(inv.SessionID, decision, base.Command, nil)
+	}
 	emit(ToolEvent{
 		Type: "item.updated",
 		Result: ToolResult{
@@ -134,6 +199,7 @@ func (o *Orchestrator) waitForApproval(ctx context.Context, inv Invocation, base
 			Kind:       handlerKindFallback(base.Kind, ToolCommand),
 			Status:     "approved",
 			Command:    base.Command,
+			Path:       base.Path,
 			ApprovalID: approvalID,
 		},
 	})
@@ -2,8 +2,11 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
+
+	"echo-cli/internal/sandbox"
 )
 
 type stubApplyPatchHandler struct {
@@ -133,3 +136,54 @@ func TestOrchestrator_DeniedApprovalStopsExecCommand(t *testing.T) {
 		t.Fatalf("expected error result when denied, got %+v", res)
 	}
 }
+
+// sandboxedHandler 在沙箱内返回 sandbox_denied，沙箱外正常完成。
+type sandboxedHandler struct {
+	stubExecCommandHandler
+	runs int
+}
+
+func (h *sandboxedHandler) Describe(Invocation) ToolResult {
+	return ToolResult{Command: "touch /etc/x"}
+}
+func (h *sandboxedHandler) Handle(_ context.Context, inv Invocation) (ToolResult, error) {
+	h.runs++
+	if inv.Sandbox.Active() {
+		return ToolResult{Status: StatusSandboxDenied, Error: "blocked by sandbox"}, nil
+	}
+	return ToolResult{Status: "completed"}, nil
+}
+
+func TestOrchestrator_SandboxDenialEscalates(t *testing.T) {
+	approvals := NewApprovalStore()
+	o := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalOnFailure, Approvals: approvals})
+	h := &sandboxedHandler{}
+	inv := Invocation{
+		Call:    ToolCall{ID: "sbx", Name: h.Name(), Payload: json.RawMessage(`{}`)},
+		Sandbox: sandbox.Policy{Mode: sandbox.ReadOnly},
+	}
+	var asked []string
+	res := o.Run(context.Background(), inv, h, func(ev ToolEvent) {
+		if ev.Result.Status == "requires_approval" {
+			asked = append(asked, ev.Result.ApprovalID)
+			go approvals.Resolve(ApprovalDecision{ApprovalID: ev.Result.ApprovalID, Approved: true})
+		}
+	})
+	if len(asked) != 1 || asked[0] != "sbx:escalate" {
+		t.Fatalf("expected a single escalation prompt, got %v", asked)
+	}
+	if res.Status != "completed" || h.runs != 2 {
+		t.Fatalf("expected rerun outside sandbox, runs=%d res=%+v", h.runs, res)
+	}
+
+	never := NewOrchestratorWith(OrchestratorOptions{Policy: ApprovalNever, Approvals: approvals})
+	h = &sandboxedHandler{}
+	res = never.Run(context.Background(), inv, h, func(ev ToolEvent) {
+		if ev.Result.Status == "requires_approval" {
+			t.Errorf("never policy must not ask for escalation")
+		}
+	})
+	if res.Status != StatusSandboxDenied || h.runs != 1 {
+		t.Fatalf("expected sandbox denial to reach the model, runs=%d res=%+v", h.runs, res)
+	}
+}
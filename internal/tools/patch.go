@@ -1,15 +1,9 @@
 package tools
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io/fs"
-	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
-	"time"
 )
 
 type patchOpKind int
@@ -27,11 +21,16 @@ type patchOp struct {
 	lines   []string
 }
 
-// ApplyPatch runs the system `patch` command with unified diff content.
+// ApplyPatch applies an Echo Patch ("*** Begin Patch") or a unified diff in-process.
+// All hunks are validated before any file is written; on failure nothing is changed
+// and the error (a *PatchError for mismatched hunks) lists every hunk that failed.
 func ApplyPatch(ctx context.Context, workdir string, diff string) error {
 	if strings.TrimSpace(diff) == "" {
 		return fmt.Errorf("empty patch content")
 	}
+	if workdir == "" {
+		workdir = "."
+	}
 	if strings.HasPrefix(strings.TrimSpace(diff), "*** Begin Patch") {
 		ops, err := parseBeginPatch(diff)
 		if err != nil {
@@ -39,25 +38,7 @@ func ApplyPatch(ctx context.Context, workdir string, diff string) error {
 		}
 		return applyParsedPatch(ctx, workdir, ops)
 	}
-	return applySystemPatch(ctx, workdir, diff)
-}
-
-func applySystemPatch(ctx context.Context, workdir string, diff string) error {
-	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "patch", "-p0", "--force")
-	if workdir != "" {
-		cmd.Dir = workdir
-	}
-	cmd.Stdin = bytes.NewBufferString(diff)
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("apply patch failed: %w: %s", err, stderr.String())
-	}
-	return nil
+	return applyUnifiedPatch(ctx, workdir, diff)
 }
 
 func parseBeginPatch(text string) ([]patchOp, error) {
@@ -126,6 +107,9 @@ func parseBeginPatch(text string) ([]patchOp, error) {
 }
 
 func applyParsedPatch(ctx context.Context, workdir string, ops []patchOp) error {
+	tx := newPatchTx(workdir)
+	var failed []HunkError
+	total := 0
 	for _, op := range ops {
 		select {
 		case <-ctx.Done():
@@ -134,99 +118,79 @@ func applyParsedPatch(ctx context.Context, workdir string, ops []patchOp) error
 		}
 		switch op.kind {
 		case patchOpAdd:
-			if err := applyAdd(workdir, op); err != nil {
+			if err := applyAdd(tx, op); err != nil {
 				return err
 			}
 		case patchOpDelete:
-			if err := applyDelete(workdir, op); err != nil {
+			if err := applyDelete(tx, op); err != nil {
 				return err
 			}
 		case patchOpUpdate:
-			if err := applyUpdate(workdir, op); err != nil {
+			n, fails, err := applyUpdate(tx, op)
+			if err != nil {
 				return err
 			}
+			total += n
+			failed = append(failed, fails...)
 		default:
 			return fmt.Errorf("unsupported patch operation for %s", op.path)
 		}
 	}
-	return nil
+	if len(failed) > 0 {
+		return &PatchError{Failed: failed, Total: total}
+	}
+	return tx.commit()
 }
 
-func applyAdd(workdir string, op patchOp) error {
-	target := filepath.Join(workdir, op.path)
-	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
-		return err
-	}
-	content := stripPrefixes(op.lines, '+')
-	return os.WriteFile(target, []byte(content), 0o644)
+func applyAdd(tx *patchTx, op patchOp) error {
+	return tx.write(op.path, []byte(stripPrefixes(op.lines, '+')), 0o644)
 }
 
-func applyDelete(workdir string, op patchOp) error {
-	target := filepath.Join(workdir, op.path)
-	if err := os.Remove(target); err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("delete target does not exist: %s", op.path)
-		}
+func applyDelete(tx *patchTx, op patchOp) error {
+	if err := checkPatchPath(op.path); err != nil {
 		return err
 	}
-	return nil
+	if !tx.exists(op.path) {
+		return fmt.Errorf("delete target does not exist: %s", op.path)
+	}
+	return tx.remove(op.path)
 }
 
-func applyUpdate(workdir string, op patchOp) error {
-	oldPath := filepath.Join(workdir, op.path)
-	data, err := os.ReadFile(oldPath)
+// applyUpdate 暂存一次 Update File 操作，返回 hunk 总数与失败的 hunk。
+func applyUpdate(tx *patchTx, op patchOp) (int, []HunkError, error) {
+	data, fileMode, err := tx.read(op.path)
 	if err != nil {
-		return err
-	}
-	fileMode := fs.FileMode(0o644)
-	if info, statErr := os.Stat(oldPath); statErr == nil {
-		fileMode = info.Mode()
+		return 0, nil, err
 	}
 
 	origLines, hadTrailing := splitLines(string(data))
+	var content string
+	total := 0
 	if shouldReplaceEntireFile(op.lines) {
-		content := joinReplacementLines(op.lines, hadTrailing)
-		if err := os.MkdirAll(filepath.Dir(oldPath), 0o755); err != nil {
-			return err
+		content = joinReplacementLines(op.lines, hadTrailing)
+	} else {
+		hunks, err := collectHunks(op.lines)
+		if err != nil {
+			return 0, nil, err
 		}
-		if err := os.WriteFile(oldPath, []byte(content), fileMode); err != nil {
-			return err
+		total = len(hunks)
+		updated, failed := applyHunkList(op.path, origLines, hunks, defaultPatchFuzz)
+		if len(failed) > 0 {
+			return total, failed, nil
 		}
-		if op.newPath != "" && op.newPath != op.path {
-			newPath := filepath.Join(workdir, op.newPath)
-			if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
-				return err
-			}
-			if err := os.Rename(oldPath, newPath); err != nil {
-				return err
-			}
+		content = strings.Join(updated, "\n")
+		if hadTrailing {
+			content += "\n"
 		}
-		return nil
-	}
-	updated, err := applyHunks(origLines, op.lines)
-	if err != nil {
-		return err
-	}
-	content := strings.Join(updated, "\n")
-	if hadTrailing {
-		content += "\n"
-	}
-	if err := os.MkdirAll(filepath.Dir(oldPath), 0o755); err != nil {
-		return err
-	}
-	if err := os.WriteFile(oldPath, []byte(content), fileMode); err != nil {
-		return err
 	}
+	target := op.path
 	if op.newPath != "" && op.newPath != op.path {
-		newPath := filepath.Join(workdir, op.newPath)
-		if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
-			return err
-		}
-		if err := os.Rename(oldPath, newPath); err != nil {
-			return err
+		if err := tx.remove(op.path); err != nil {
+			return total, nil, err
 		}
+		target = op.newPath
 	}
-	return nil
+	return total, nil, tx.write(target, []byte(content), fileMode)
 }
 
 func joinReplacementLines(lines []string, hadTrailing bool) string {
@@ -272,97 +236,60 @@ func splitLines(text string) ([]string, bool) {
 	return strings.Split(text, "\n"), hasTrailing
 }
 
-func applyHunks(orig []string, lines []string) ([]string, error) {
-	hunks, err := collectHunks(lines)
-	if err != nil {
-		return nil, err
-	}
-
-	result := append([]string{}, orig...)
-	cursor := 0
-	for _, hunk := range hunks {
-		oldLines, newLines, err := decodeHunk(hunk)
-		if err != nil {
-			return nil, err
+// collectHunks 按 @@ 行切分 Echo Patch 的 hunk；其中不含行号，位置由上下文决定。
+func collectHunks(lines []string) ([]patchHunk, error) {
+	var hunks []patchHunk
+	var current []string
+	header := ""
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
 		}
-		idx := findSubslice(result, oldLines, cursor)
-		if idx == -1 {
-			return nil, fmt.Errorf("patch context not found for %v", oldLines)
+		decoded, err := decodeHunk(current)
+		if err != nil {
+			return err
 		}
-		next := make([]string, 0, len(result)-len(oldLines)+len(newLines))
-		next = append(next, result[:idx]...)
-		next = append(next, newLines...)
-		next = append(next, result[idx+len(oldLines):]...)
-		result = next
-		cursor = idx + len(newLines)
+		hunks = append(hunks, patchHunk{header: header, start: -1, lines: decoded})
+		return nil
 	}
-	return result, nil
-}
-
-func collectHunks(lines []string) ([][]string, error) {
-	var hunks [][]string
-	var current []string
 	for _, line := range lines {
 		trim := strings.TrimSpace(line)
 		if strings.HasPrefix(trim, "*** ") && trim != "*** End of File" {
 			return nil, fmt.Errorf("unexpected directive inside hunk: %s", trim)
 		}
+		if trim == "*** End of File" {
+			continue
+		}
 		if strings.HasPrefix(trim, "@@") {
-			if len(current) > 0 {
-				hunks = append(hunks, current)
+			if err := flush(); err != nil {
+				return nil, err
 			}
 			current = []string{}
+			header = trim
 			continue
 		}
 		current = append(current, line)
 	}
-	if len(current) > 0 {
-		hunks = append(hunks, current)
+	if err := flush(); err != nil {
+		return nil, err
 	}
 	return hunks, nil
 }
 
-func decodeHunk(lines []string) ([]string, []string, error) {
-	oldLines := make([]string, 0, len(lines))
-	newLines := make([]string, 0, len(lines))
-
+func decodeHunk(lines []string) ([]hunkLine, error) {
+	out := make([]hunkLine, 0, len(lines))
 	for _, line := range lines {
 		if len(line) == 0 {
-			return nil, nil, fmt.Errorf("invalid empty hunk line")
+			return nil, fmt.Errorf("invalid empty hunk line")
 		}
 		switch line[0] {
-		case ' ':
-			content := line[1:]
-			oldLines = append(oldLines, content)
-			newLines = append(newLines, content)
-		case '-':
-			oldLines = append(oldLines, line[1:])
-		case '+':
-			newLines = append(newLines, line[1:])
+		case ' ', '-', '+':
+			out = append(out, hunkLine{op: line[0], text: line[1:]})
 		default:
-			return nil, nil, fmt.Errorf("invalid hunk line: %s", line)
-		}
-	}
-	return oldLines, newLines, nil
-}
-
-func findSubslice(lines []string, target []string, start int) int {
-	if len(target) == 0 {
-		return start
-	}
-	for i := start; i+len(target) <= len(lines); i++ {
-		match := true
-		for j := range target {
-			if lines[i+j] != target[j] {
-				match = false
-				break
-			}
-		}
-		if match {
-			return i
+			return nil, fmt.Errorf("invalid hunk line: %s", line)
 		}
 	}
-	return -1
+	return out, nil
 }
 
 func stripPrefixes(lines []string, prefix byte) string {
@@ -0,0 +1,372 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPatchFuzz 是上下文不匹配时最多忽略的首尾上下文行数（同 GNU patch 的 fuzz factor）。
+const defaultPatchFuzz = 2
+
+type hunkLine struct {
+	op   byte // ' '、'-' 或 '+'
+	text string
+}
+
+// patchHunk 是一段待应用的改动；start 为原文件中期望的起始行（0 起），未知时为 -1。
+type patchHunk struct {
+	header string
+	start  int
+	lines  []hunkLine
+	// noNewlineOld/noNewlineNew 对应 "\ No newline at end of file" 标记。
+	noNewlineOld bool
+	noNewlineNew bool
+}
+
+// HunkError 描述一个未能应用的 hunk。
+type HunkError struct {
+	Path   string
+	Index  int // 在该文件中的序号，从 1 开始
+	Header string
+	Reason string
+	// Expected 是原文件中应当出现的行（最多 maxExpectedLines 行）。
+	Expected []string
+}
+
+const maxExpectedLines = 6
+
+func (e HunkError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: hunk #%d", e.Path, e.Index)
+	if e.Header != "" {
+		fmt.Fprintf(&sb, " (%s)", e.Header)
+	}
+	sb.WriteString(": " + e.Reason)
+	if len(e.Expected) > 0 {
+		sb.WriteString("; expected:")
+		for _, line := range e.Expected {
+			sb.WriteString("\n    | " + line)
+		}
+	}
+	return sb.String()
+}
+
+// PatchError 汇总补丁中全部失败的 hunk；返回该错误时没有任何文件被修改。
+type PatchError struct {
+	Failed []HunkError
+	Total  int
+}
+
+func (e *PatchError) Error() string {
+	lines := []string{fmt.Sprintf("patch not applied: %d of %d hunks failed; no files were changed", len(e.Failed), e.Total)}
+	for _, h := range e.Failed {
+		lines = append(lines, "  "+strings.ReplaceAll(h.Error(), "\n", "\n  "))
+	}
+	lines = append(lines, "re-read the affected files and regenerate the failed hunks")
+	return strings.Join(lines, "\n")
+}
+
+// applyHunkList 依次把 hunks 应用到 orig，返回结果与失败的 hunk（失败的 hunk 被跳过，其余继续，以便一次报告全部问题）。
+// 每个 hunk 依次尝试精确匹配、忽略行尾空白、忽略全部空白差异；仍失败时逐步去掉首尾至多 fuzz 行上下文重试。
+// 同一级别内选择离期望位置最近的匹配；匹配到的上下文行保留文件中的原文。
+func applyHunkList(path string, orig []string, hunks []patchHunk, fuzz int) ([]string, []HunkError) {
+	result := append([]string{}, orig...)
+	var failed []HunkError
+	cursor, offset := 0, 0
+	for i, h := range hunks {
+		hint := cursor
+		if h.start >= 0 {
+			hint = max(h.start+offset, cursor)
+		}
+		pos, top, bottom, ok := locateHunk(result, h.lines, hint, cursor, fuzz)
+		if !ok {
+			failed = append(failed, hunkFailure(path, i, h, result))
+			continue
+		}
+		lines := h.lines[top : len(h.lines)-bottom]
+		replaced := 0
+		var repl []string
+		for _, line := range lines {
+			switch line.op {
+			case ' ':
+				repl = append(repl, result[pos+replaced])
+				replaced++
+			case '-':
+				replaced++
+			case '+':
+				repl = append(repl, line.text)
+			}
+		}
+		next := make([]string, 0, len(result)-replaced+len(repl))
+		next = append(next, result[:pos]...)
+		next = append(next, repl...)
+		next = append(next, result[pos+replaced:]...)
+		result = next
+		cursor = pos + len(repl)
+		offset += len(repl) - replaced
+	}
+	return result, failed
+}
+
+func hunkFailure(path string, idx int, h patchHunk, current []string) HunkError {
+	old := oldSide(h.lines)
+	reason := "context not found"
+	if h.start >= 0 {
+		reason = fmt.Sprintf("context not found near line %d", h.start+1)
+	}
+	if len(current) == 0 && len(old) > 0 {
+		reason = "file is empty"
+	}
+	return HunkError{
+		Path:     path,
+		Index:    idx + 1,
+		Header:   h.header,
+		Reason:   reason,
+		Expected: old[:min(len(old), maxExpectedLines)],
+	}
+}
+
+func oldSide(lines []hunkLine) []string {
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line.op != '+' {
+			out = append(out, line.text)
+		}
+	}
+	return out
+}
+
+// locateHunk 返回 hunk 在 lines 中的起始位置以及为匹配而去掉的首尾上下文行数。
+func locateHunk(lines []string, hunk []hunkLine, hint, cursor, fuzz int) (pos, top, bottom int, ok bool) {
+	lead, trail := 0, 0
+	for lead < len(hunk) && hunk[lead].op == ' ' {
+		lead++
+	}
+	for trail < len(hunk)-lead && hunk[len(hunk)-1-trail].op == ' ' {
+		trail++
+	}
+	for fz := 0; fz <= fuzz; fz++ {
+		top, bottom = min(fz, lead), min(fz, trail)
+		if fz > 0 && top == min(fz-1, lead) && bottom == min(fz-1, trail) {
+			continue // 没有更多上下文可去掉
+		}
+		old := oldSide(hunk[top : len(hunk)-bottom])
+		if len(old) == 0 {
+			if fz > 0 && len(oldSide(hunk)) > 0 {
+				break // 去掉全部上下文后无从定位
+			}
+			return min(max(hint, cursor), len(lines)), top, bottom, true
+		}
+		for _, norm := range lineNormalizers {
+			if p := nearestMatch(lines, old, max(hint+top, cursor), cursor, norm); p >= 0 {
+				return p, top, bottom, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+var lineNormalizers = []func(string) string{
+	func(s string) string { return s },
+	func(s string) string { return strings.TrimRight(s, " \t\r") },
+	func(s string) string { return strings.Join(strings.Fields(s), " ") },
+}
+
+// nearestMatch 在 [cursor, len) 中查找 target，返回离 hint 最近的位置。
+func nearestMatch(lines, target []string, hint, cursor int, norm func(string) string) int {
+	want := make([]string, len(target))
+	for i, t := range target {
+		want[i] = norm(t)
+	}
+	matchAt := func(i int) bool {
+		for j := range want {
+			if norm(lines[i+j]) != want[j] {
+				return false
+			}
+		}
+		return true
+	}
+	last := len(lines) - len(target)
+	for d := 0; hint-d >= cursor || hint+d <= last; d++ {
+		if i := hint + d; i >= cursor && i <= last && matchAt(i) {
+			return i
+		}
+		if i := hint - d; d > 0 && i >= cursor && i <= last && matchAt(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// patchTx 在内存中暂存补丁对文件的修改。全部 hunk 校验通过后 commit 逐个原子写入，
+// 中途失败时恢复已写入的文件，使补丁要么完整生效，要么不留下任何修改。
+type patchTx struct {
+	workdir string
+	files   map[string]*stagedFile
+	order   []string
+}
+
+type stagedFile struct {
+	data   []byte
+	mode   fs.FileMode
+	exists bool
+}
+
+func newPatchTx(workdir string) *patchTx {
+	return &patchTx{workdir: workdir, files: map[string]*stagedFile{}}
+}
+
+// abs 把补丁中的相对路径解析到工作目录下。
+func (tx *patchTx) abs(path string) (string, error) {
+	if err := checkPatchPath(path); err != nil {
+		return "", err
+	}
+	return filepath.Join(tx.workdir, path), nil
+}
+
+// checkPatchPath 拒绝绝对路径以及含 ".." 跳出工作目录的路径：补丁只能修改工作目录内的文件。
+func checkPatchPath(path string) error {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") {
+		return fmt.Errorf("%s: absolute paths are not allowed in patches; use a path relative to the working directory", path)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return fmt.Errorf("%s: paths containing \"..\" are not allowed in patches", path)
+		}
+	}
+	return nil
+}
+
+// read 返回文件在暂存状态下的内容；不存在时返回 fs.ErrNotExist。
+func (tx *patchTx) read(path string) ([]byte, fs.FileMode, error) {
+	abs, err := tx.abs(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if st, ok := tx.files[abs]; ok {
+		if !st.exists {
+			return nil, 0, &fs.PathError{Op: "open", Path: abs, Err: fs.ErrNotExist}
+		}
+		return st.data, st.mode, nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, 0, err
+	}
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(abs); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return data, mode, nil
+}
+
+func (tx *patchTx) exists(path string) bool {
+	_, _, err := tx.read(path)
+	return err == nil
+}
+
+func (tx *patchTx) stage(path string, st *stagedFile) error {
+	abs, err := tx.abs(path)
+	if err != nil {
+		return err
+	}
+	if _, ok := tx.files[abs]; !ok {
+		tx.order = append(tx.order, abs)
+	}
+	tx.files[abs] = st
+	return nil
+}
+
+func (tx *patchTx) write(path string, data []byte, mode fs.FileMode) error {
+	return tx.stage(path, &stagedFile{data: data, mode: mode, exists: true})
+}
+
+func (tx *patchTx) remove(path string) error {
+	return tx.stage(path, &stagedFile{})
+}
+
+type fileBackup struct {
+	path    string
+	data    []byte
+	mode    fs.FileMode
+	existed bool
+}
+
+func (tx *patchTx) commit() error {
+	done := make([]fileBackup, 0, len(tx.order))
+	for _, abs := range tx.order {
+		st := tx.files[abs]
+		prev := fileBackup{path: abs}
+		if info, err := os.Stat(abs); err == nil {
+			data, err := os.ReadFile(abs)
+			if err != nil {
+				return tx.rollback(done, err)
+			}
+			prev.existed, prev.data, prev.mode = true, data, info.Mode().Perm()
+		}
+		var err error
+		switch {
+		case st.exists:
+			err = writeFileAtomic(abs, st.data, st.mode)
+		case prev.existed:
+			err = os.Remove(abs)
+		}
+		if err != nil {
+			return tx.rollback(done, err)
+		}
+		done = append(done, prev)
+	}
+	return nil
+}
+
+func (tx *patchTx) rollback(done []fileBackup, cause error) error {
+	var errs []error
+	for i := len(done) - 1; i >= 0; i-- {
+		b := done[i]
+		var err error
+		if b.existed {
+			err = writeFileAtomic(b.path, b.data, b.mode)
+		} else if _, statErr := os.Lstat(b.path); statErr == nil {
+			err = os.Remove(b.path)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%w (rollback incomplete: %v)", cause, errors.Join(errs...))
+	}
+	return fmt.Errorf("%w (changes rolled back)", cause)
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名；目标为符号链接时写入其指向的文件。
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".echo-patch-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
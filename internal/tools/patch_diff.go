@@ -22,6 +22,12 @@ type PatchSummary struct {
 // It supports both the custom "*** Begin Patch" format and unified diffs.
 // Returned paths are trimmed but otherwise reflect the patch content (e.g. can be absolute).
 func ExtractPatchPaths(patch string) ([]string, error) {
+	return patchPathsIn("", patch)
+}
+
+// patchPathsIn is ExtractPatchPaths with -pN detection against workdir, so that
+// the returned paths match the files ApplyPatch will touch.
+func patchPathsIn(workdir string, patch string) ([]string, error) {
 	patch = strings.TrimSpace(patch)
 	if patch == "" {
 		return nil, fmt.Errorf("empty patch")
@@ -43,39 +49,7 @@ func ExtractPatchPaths(patch string) ([]string, error) {
 		}
 		return paths, nil
 	}
-
-	// Unified diff: scan --- / +++ headers.
-	var paths []string
-	var pendingOld string
-	for _, line := range strings.Split(patch, "\n") {
-		if strings.HasPrefix(line, "--- ") {
-			pendingOld = parseUnifiedHeaderPath(strings.TrimPrefix(line, "--- "))
-			continue
-		}
-		if strings.HasPrefix(line, "+++ ") {
-			newPath := parseUnifiedHeaderPath(strings.TrimPrefix(line, "+++ "))
-			oldPath := pendingOld
-			pendingOld = ""
-
-			pick := newPath
-			if pick == "" || pick == "/dev/null" {
-				pick = oldPath
-			}
-			if pick == "" || pick == "/dev/null" {
-				continue
-			}
-			// Common "a/.." + "b/.." pairing: prefer the stripped workspace path.
-			if strings.HasPrefix(oldPath, "a/") && strings.HasPrefix(newPath, "b/") {
-				os := strings.TrimPrefix(oldPath, "a/")
-				ns := strings.TrimPrefix(newPath, "b/")
-				if os == ns && os != "" {
-					pick = os
-				}
-			}
-			paths = append(paths, pick)
-		}
-	}
-	return paths, nil
+	return unifiedPatchPaths(workdir, patch)
 }
 
 func parseUnifiedHeaderPath(rest string) string {
@@ -149,7 +123,10 @@ func dedupePreserveOrder(in []string) []string {
 // SummarizePatch returns a workspace-scoped summary. It resolves patch paths to
 // workspace-relative paths when possible.
 func SummarizePatch(workdir string, patch string) (PatchSummary, error) {
-	raw, err := ExtractPatchPaths(patch)
+	if workdir == "" {
+		workdir = "."
+	}
+	raw, err := patchPathsIn(workdir, patch)
 	if err != nil {
 		return PatchSummary{}, err
 	}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -178,3 +179,287 @@ func TestApplyPatchBeginPatch_MissingEndPatch(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func writeFixtures(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		full := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("write fixture: %v", err)
+		}
+	}
+}
+
+func readFixture(t *testing.T, dir, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		t.Fatalf("read %s: %v", rel, err)
+	}
+	return string(data)
+}
+
+func TestApplyPatchUnifiedGitDiffWithOffsetsAndWhitespace(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{
+		"src/main.go": "package main\n\n// added later\n// more\n\nfunc main() {\n\tprintln(\"hi\")  \n}\n\nfunc helper() {}\n",
+	})
+	// 行号偏移 2 行，第二个 hunk 的上下文缺少行尾空白。
+	patch := `diff --git a/src/main.go b/src/main.go
+index 111..222 100644
+--- a/src/main.go
++++ b/src/main.go
+@@ -3,4 +3,4 @@
+ func main() {
+-	println("hi")
++	println("hello")
+ }
+ 
+@@ -8,1 +8,2 @@
+ func helper() {}
++func other()  {}
+`
+	if err := ApplyPatch(context.Background(), dir, patch); err != nil {
+		t.Fatalf("apply patch: %v", err)
+	}
+	want := "package main\n\n// added later\n// more\n\nfunc main() {\n\tprintln(\"hello\")\n}\n\nfunc helper() {}\nfunc other()  {}\n"
+	if got := readFixture(t, dir, "src/main.go"); got != want {
+		t.Fatalf("unexpected content:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestApplyPatchUnifiedCreateDeleteRenameAndEOF(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{
+		"old.txt":  "one\ntwo\n",
+		"gone.txt": "bye\n",
+		"tail.txt": "a\nb",
+	})
+	patch := `diff --git a/old.txt b/new.txt
+similarity index 80%
+rename from old.txt
+rename to new.txt
+--- a/old.txt
++++ b/new.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++2
+--- /dev/null
++++ b/added/file.txt
+@@ -0,0 +1,2 @@
++first
++second
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+--- a/tail.txt
++++ b/tail.txt
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+`
+	if err := ApplyPatch(context.Background(), dir, patch); err != nil {
+		t.Fatalf("apply patch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected old.txt renamed away, err=%v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected gone.txt deleted, err=%v", err)
+	}
+	for rel, want := range map[string]string{
+		"new.txt":        "one\n2\n",
+		"added/file.txt": "first\nsecond\n",
+		"tail.txt":       "a\nc\n",
+	} {
+		if got := readFixture(t, dir, rel); got != want {
+			t.Fatalf("%s = %q, want %q", rel, got, want)
+		}
+	}
+	paths, err := ExtractPatchPaths(patch)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if want := "old.txt new.txt added/file.txt gone.txt tail.txt"; strings.Join(paths, " ") != want {
+		t.Fatalf("paths = %v, want %s", paths, want)
+	}
+}
+
+func TestApplyPatchUnifiedFuzzAndStripDetection(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{"pkg/a.txt": "1\n2\n3\n4\n5\n"})
+	// 首尾上下文与文件不符（fuzz 2 可忽略），路径带两层无关前缀（-p2）。
+	patch := `--- orig/tree/pkg/a.txt
++++ new/tree/pkg/a.txt
+@@ -1,5 +1,5 @@
+ x
+ 2
+-3
++three
+ 4
+ y
+`
+	if err := ApplyPatch(context.Background(), dir, patch); err != nil {
+		t.Fatalf("apply patch: %v", err)
+	}
+	if got := readFixture(t, dir, "pkg/a.txt"); got != "1\n2\nthree\n4\n5\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+}
+
+func TestApplyPatchUnifiedHunkLinesLookingLikeFileHeaders(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{"schema.sql": "select 1;\n-- old\nselect 2;\n"})
+	// 删除 "-- old"、添加 "++ new" 在 diff 中成为相邻的 "--- old"/"+++ new"，行数未满足前不能当作文件头。
+	patch := `--- a/schema.sql
++++ b/schema.sql
+@@ -1,3 +1,3 @@
+ select 1;
+--- old
++++ new
+ select 2;
+`
+	if err := ApplyPatch(context.Background(), dir, patch); err != nil {
+		t.Fatalf("apply patch: %v", err)
+	}
+	if got := readFixture(t, dir, "schema.sql"); got != "select 1;\n++ new\nselect 2;\n" {
+		t.Fatalf("unexpected content %q", got)
+	}
+}
+
+func TestApplyPatchUnifiedMiscountedHunks(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{"one.txt": "a\nb\nc\n", "two.txt": "x\n"})
+	// 第一个 hunk 的行数偏小（多出 +EXTRA 与 c），第二个文件的 hunk 行数偏大（会吞掉第三个文件的头部）。
+	patch := `--- a/one.txt
++++ b/one.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B
++EXTRA
+ c
+--- a/two.txt
++++ b/two.txt
+@@ -1,3 +1,3 @@
+-x
++y
+--- /dev/null
++++ b/three.txt
+@@ -0,0 +1 @@
++new
+`
+	if err := ApplyPatch(context.Background(), dir, patch); err != nil {
+		t.Fatalf("apply patch: %v", err)
+	}
+	for path, want := range map[string]string{"one.txt": "a\nB\nEXTRA\nc\n", "two.txt": "y\n", "three.txt": "new\n"} {
+		if got := readFixture(t, dir, path); got != want {
+			t.Fatalf("%s: got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestApplyPatchRejectsPathsOutsideWorkdir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "work")
+	writeFixtures(t, dir, map[string]string{"a.txt": "alpha\n"})
+	outside := filepath.Join(root, "escape.txt")
+
+	cases := map[string]string{
+		"unified dotdot":   "--- a/a.txt\n+++ b/../escape.txt\n@@ -1 +1 @@\n-alpha\n+ALPHA\n",
+		"unified create":   "--- /dev/null\n+++ b/../escape.txt\n@@ -0,0 +1 @@\n+x\n",
+		"unified absolute": "--- /dev/null\n+++ " + outside + "\n@@ -0,0 +1 @@\n+x\n",
+		"echo dotdot":      "*** Begin Patch\n*** Add File: ../escape.txt\n+x\n*** End Patch",
+		"echo absolute":    "*** Begin Patch\n*** Add File: " + outside + "\n+x\n*** End Patch",
+		"echo move":        "*** Begin Patch\n*** Update File: a.txt\n*** Move to: sub/../../escape.txt\n@@\n-alpha\n+ALPHA\n*** End Patch",
+	}
+	for name, patch := range cases {
+		err := ApplyPatch(context.Background(), dir, patch)
+		if err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Fatalf("%s: expected path to be rejected, got %v", name, err)
+		}
+		if _, statErr := os.Stat(outside); !os.IsNotExist(statErr) {
+			t.Fatalf("%s: file outside workdir was written", name)
+		}
+		if got := readFixture(t, dir, "a.txt"); got != "alpha\n" {
+			t.Fatalf("%s: a.txt changed to %q", name, got)
+		}
+	}
+}
+
+func TestApplyPatchIsAllOrNothing(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{"a.txt": "alpha\n", "b.txt": "beta\n", "blocker": "file\n"})
+
+	unified := `--- a.txt
++++ a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- b.txt
++++ b.txt
+@@ -1 +1 @@
+-gamma
++GAMMA
+`
+	err := ApplyPatch(context.Background(), dir, unified)
+	var perr *PatchError
+	if !errors.As(err, &perr) || len(perr.Failed) != 1 || perr.Total != 2 {
+		t.Fatalf("expected one failed hunk of two, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "b.txt: hunk #1") || !strings.Contains(msg, "| gamma") || !strings.Contains(msg, "no files were changed") {
+		t.Fatalf("unexpected error message:\n%s", msg)
+	}
+
+	echo := `*** Begin Patch
+*** Update File: a.txt
+@@
+-alpha
++ALPHA
+*** Update File: b.txt
+@@
+-missing
++x
+*** End Patch`
+	if err := ApplyPatch(context.Background(), dir, echo); !errors.As(err, &perr) {
+		t.Fatalf("expected PatchError, got %v", err)
+	}
+
+	// 写入阶段失败（blocker 是文件，无法作为目录）时回滚已写入的 a.txt。
+	commitFail := `--- a.txt
++++ a.txt
+@@ -1 +1 @@
+-alpha
++ALPHA
+--- /dev/null
++++ blocker/new.txt
+@@ -0,0 +1 @@
++x
+`
+	if err := ApplyPatch(context.Background(), dir, commitFail); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rolled back error, got %v", err)
+	}
+	if got := readFixture(t, dir, "a.txt"); got != "alpha\n" {
+		t.Fatalf("a.txt modified by failed patch: %q", got)
+	}
+}
+
+func TestApplyPatchRejectsBinary(t *testing.T) {
+	dir := t.TempDir()
+	writeFixtures(t, dir, map[string]string{"img.bin": "\x00\x01\x02\n"})
+	gitBinary := "diff --git a/img.bin b/img.bin\nindex 1..2 100644\nBinary files a/img.bin and b/img.bin differ\n"
+	if err := ApplyPatch(context.Background(), dir, gitBinary); err == nil || !strings.Contains(err.Error(), "binary patches are not supported") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	text := "--- img.bin\n+++ img.bin\n@@ -1 +1 @@\n-x\n+y\n"
+	if err := ApplyPatch(context.Background(), dir, text); err == nil || !strings.Contains(err.Error(), "binary file") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
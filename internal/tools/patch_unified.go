@@ -0,0 +1,406 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxStripLevel 是自动探测 -pN 时尝试的最大前缀层数。
+const maxStripLevel = 3
+
+// unifiedFile 是 unified diff 中一个文件的改动；路径为头部原文，尚未剥离前缀。
+type unifiedFile struct {
+	oldPath string
+	newPath string
+	hunks   []patchHunk
+	// headers 为 true 表示已读到 ---/+++ 头部。
+	headers bool
+	// git 扩展头部：rename from/to 的路径不带 a/ b/ 前缀。
+	renameFrom string
+	renameTo   string
+	created    bool
+	deleted    bool
+	binary     bool
+}
+
+func (f *unifiedFile) display() string {
+	for _, p := range []string{f.renameTo, f.newPath, f.oldPath} {
+		if p != "" && p != "/dev/null" {
+			return p
+		}
+	}
+	return "(unknown file)"
+}
+
+// targets 返回剥离 strip 层前缀后的源路径与目标路径；新增文件的 from 与删除文件的 to 为空。
+func (f *unifiedFile) targets(strip int) (from, to string) {
+	if f.renameFrom != "" && f.renameTo != "" {
+		return f.renameFrom, f.renameTo
+	}
+	from, to = stripPathPrefix(f.oldPath, strip), stripPathPrefix(f.newPath, strip)
+	if f.created {
+		from = ""
+	}
+	if f.deleted {
+		to = ""
+	}
+	return from, to
+}
+
+// stripPathPrefix 去掉前 n 个路径分量（同 patch -pN）；绝对路径保持不变，由 checkPatchPath 拒绝。
+func stripPathPrefix(p string, n int) string {
+	if p == "" || p == "/dev/null" {
+		return ""
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	parts := strings.Split(p, "/")
+	if n >= len(parts) {
+		return ""
+	}
+	return path.Clean(strings.Join(parts[n:], "/"))
+}
+
+// parseUnifiedDiff 解析 unified diff（含 git 扩展头部）。hunk 优先按头部行数读取，
+// 行数与内容不符（常见于手写或模型生成的 diff）时按行首字符判断边界。
+func parseUnifiedDiff(text string) ([]*unifiedFile, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var files []*unifiedFile
+	var cur *unifiedFile
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			cur = &unifiedFile{}
+			cur.oldPath, cur.newPath = parseGitDiffLine(strings.TrimPrefix(line, "diff --git "))
+			files = append(files, cur)
+		case isFileHeader(lines, i):
+			if cur == nil || cur.headers || len(cur.hunks) > 0 {
+				cur = &unifiedFile{}
+				files = append(files, cur)
+			}
+			cur.oldPath = parseUnifiedHeaderPath(strings.TrimPrefix(line, "--- "))
+			cur.newPath = parseUnifiedHeaderPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			cur.headers = true
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("invalid unified diff: hunk %q has no file header", line)
+			}
+			hunk, next := parseUnifiedHunk(lines, i)
+			cur.hunks = append(cur.hunks, hunk)
+			i = next - 1
+		case cur == nil:
+			// 第一个文件头之前的说明文字。
+		case strings.HasPrefix(line, "rename from "):
+			cur.renameFrom = strings.TrimSpace(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			cur.renameTo = strings.TrimSpace(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "new file mode"):
+			cur.created = true
+		case strings.HasPrefix(line, "deleted file mode"):
+			cur.deleted = true
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			cur.binary = true
+		}
+	}
+	return files, nil
+}
+
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// parseGitDiffLine 拆分 "diff --git a/x b/y" 中的两个路径。
+func parseGitDiffLine(rest string) (string, string) {
+	if i := strings.LastIndex(rest, " b/"); i >= 0 {
+		return strings.TrimSpace(rest[:i]), strings.TrimSpace(rest[i+1:])
+	}
+	if fields := strings.Fields(rest); len(fields) == 2 {
+		return fields[0], fields[1]
+	}
+	return "", ""
+}
+
+// parseHunkHeader 解析 "@@ -l,s +l,s @@"；省略的行数为 1。
+func parseHunkHeader(header string) (oldStart, oldCount, newCount int, ok bool) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, 0, false
+	}
+	parse := func(spec string) (int, int, bool) {
+		start, count, found := strings.Cut(spec[1:], ",")
+		s, err := strconv.Atoi(start)
+		if err != nil {
+			return 0, 0, false
+		}
+		if !found {
+			return s, 1, true
+		}
+		c, err := strconv.Atoi(count)
+		return s, c, err == nil
+	}
+	oldStart, oldCount, ok1 := parse(fields[1])
+	_, newCount, ok2 := parse(fields[2])
+	return oldStart, oldCount, newCount, ok1 && ok2
+}
+
+// parseUnifiedHunk 读取从 lines[i]（"@@" 行）开始的 hunk，返回 hunk 与下一行的下标。
+// 头部行数与内容一致时按行数读取；读不满（行数偏大）或读满后紧跟着多余的 diff 行（行数偏小）时，
+// 改为按行首字符判断边界，避免吞掉下一个文件的头部或丢掉多出的行。
+func parseUnifiedHunk(lines []string, i int) (patchHunk, int) {
+	h := patchHunk{header: strings.TrimSpace(lines[i]), start: -1}
+	oldStart, oldCount, newCount, counted := parseHunkHeader(lines[i])
+	if counted {
+		h.start = oldStart
+		if oldCount > 0 {
+			h.start = oldStart - 1
+		}
+		byCount := h
+		next, oldSeen, newSeen := scanHunk(&byCount, lines, i+1, true, oldCount, newCount)
+		if oldSeen == oldCount && newSeen == newCount && !strayHunkLine(lines, next) {
+			return byCount, next
+		}
+	}
+	next, _, _ := scanHunk(&h, lines, i+1, false, 0, 0)
+	// 按行首字符读取时，末尾的空行视为补丁文本的结尾而非上下文。
+	for n := len(h.lines); n > 0 && h.lines[n-1] == (hunkLine{op: ' '}); n-- {
+		h.lines = h.lines[:n-1]
+	}
+	return h, next
+}
+
+// scanHunk 从 lines[j] 起把 hunk 内容追加到 h，返回停止处的下标与读到的新旧行数。
+// byCount 时读满 oldCount/newCount 即停止：删除 "-- old" 再添加 "++ new" 这类行看起来像文件头，但仍属于本 hunk；
+// 只有后面紧跟 "@@" 的文件头才结束 hunk。否则遇到 "@@"、文件头或非 diff 行即停止。
+func scanHunk(h *patchHunk, lines []string, j int, byCount bool, oldCount, newCount int) (int, int, int) {
+	oldSeen, newSeen := 0, 0
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		if strings.HasPrefix(line, "\\") {
+			// "\ No newline at end of file" 作用于前一行。
+			if n := len(h.lines); n > 0 {
+				h.noNewlineOld = h.noNewlineOld || h.lines[n-1].op != '+'
+				h.noNewlineNew = h.noNewlineNew || h.lines[n-1].op != '-'
+			}
+			continue
+		}
+		if byCount {
+			if oldSeen >= oldCount && newSeen >= newCount {
+				break
+			}
+			if isFileHeader(lines, j) && j+2 < len(lines) && strings.HasPrefix(lines[j+2], "@@") {
+				break
+			}
+		} else if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff --git ") || isFileHeader(lines, j) {
+			break
+		}
+		op, text := byte(' '), ""
+		switch {
+		case line == "":
+			// 空上下文行常被编辑器去掉了行首空格。
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			op, text = line[0], line[1:]
+		default:
+			return j, oldSeen, newSeen
+		}
+		h.lines = append(h.lines, hunkLine{op: op, text: text})
+		if op != '+' {
+			oldSeen++
+		}
+		if op != '-' {
+			newSeen++
+		}
+	}
+	return j, oldSeen, newSeen
+}
+
+// strayHunkLine 报告按行数读完的 hunk 之后（跳过空行）是否还有不属于文件头的 diff 行，即头部行数偏小。
+func strayHunkLine(lines []string, j int) bool {
+	for j < len(lines) && lines[j] == "" {
+		j++
+	}
+	if j >= len(lines) || isFileHeader(lines, j) {
+		return false
+	}
+	c := lines[j][0]
+	return c == ' ' || c == '-' || c == '+'
+}
+
+// detectStrip 选择 -pN：优先取使所有被修改文件都存在于 workdir 的最小 N；
+// workdir 为空或无法判断（例如全是新文件）时，git 风格的 a/ b/ 前缀剥离一层。
+func detectStrip(workdir string, files []*unifiedFile) int {
+	if workdir != "" {
+		best, bestHits := 0, 0
+		for strip := 0; strip <= maxStripLevel; strip++ {
+			hits, total := 0, 0
+			for _, f := range files {
+				if f.created || f.oldPath == "" || f.oldPath == "/dev/null" || f.renameFrom != "" {
+					continue
+				}
+				total++
+				p := stripPathPrefix(f.oldPath, strip)
+				if p == "" {
+					continue
+				}
+				if !filepath.IsAbs(p) {
+					p = filepath.Join(workdir, p)
+				}
+				if info, err := os.Stat(p); err == nil && !info.IsDir() {
+					hits++
+				}
+			}
+			if total > 0 && hits == total {
+				return strip
+			}
+			if hits > bestHits {
+				best, bestHits = strip, hits
+			}
+		}
+		if bestHits > 0 {
+			return best
+		}
+	}
+	for _, f := range files {
+		for _, p := range []string{f.oldPath, f.newPath} {
+			if p != "" && p != "/dev/null" && !strings.HasPrefix(p, "a/") && !strings.HasPrefix(p, "b/") {
+				return 0
+			}
+		}
+	}
+	return 1
+}
+
+// unifiedPatchPaths 返回 unified diff 涉及的路径（已按 detectStrip 剥离前缀），重命名时包含新旧两个路径。
+func unifiedPatchPaths(workdir, patch string) ([]string, error) {
+	files, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return nil, err
+	}
+	strip := detectStrip(workdir, files)
+	var paths []string
+	for _, f := range files {
+		from, to := f.targets(strip)
+		if from != "" && from != to {
+			paths = append(paths, from)
+		}
+		if to != "" {
+			paths = append(paths, to)
+		}
+	}
+	return paths, nil
+}
+
+func applyUnifiedPatch(ctx context.Context, workdir, diff string) error {
+	files, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("invalid patch: no file headers found (expected a unified diff or *** Begin Patch)")
+	}
+	strip := detectStrip(workdir, files)
+	tx := newPatchTx(workdir)
+	var failed []HunkError
+	total := 0
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.binary {
+			return fmt.Errorf("%s: binary patches are not supported; no files were changed", f.display())
+		}
+		from, to := f.targets(strip)
+		if from == "" && to == "" {
+			return fmt.Errorf("%s: cannot resolve path with -p%d", f.display(), strip)
+		}
+		for _, p := range []string{from, to} {
+			if p == "" {
+				continue
+			}
+			if err := checkPatchPath(p); err != nil {
+				return fmt.Errorf("%w; no files were changed", err)
+			}
+		}
+		total += len(f.hunks)
+		fails, err := stageUnifiedFile(tx, f, from, to)
+		if err != nil {
+			return err
+		}
+		failed = append(failed, fails...)
+	}
+	if len(failed) > 0 {
+		return &PatchError{Failed: failed, Total: total}
+	}
+	return tx.commit()
+}
+
+// stageUnifiedFile 把一个文件的改动应用到暂存区；from 为空表示新建，to 为空表示删除。
+func stageUnifiedFile(tx *patchTx, f *unifiedFile, from, to string) ([]HunkError, error) {
+	var data []byte
+	mode := os.FileMode(0o644)
+	if from != "" {
+		var err error
+		if data, mode, err = tx.read(from); err != nil {
+			return nil, fmt.Errorf("%s: %w", from, err)
+		}
+		if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+			return nil, fmt.Errorf("%s: refusing to patch a binary file; no files were changed", from)
+		}
+	} else if existing, _, err := tx.read(to); err == nil && len(existing) > 0 {
+		return nil, fmt.Errorf("%s: cannot create file, it already exists", to)
+	}
+
+	text := string(data)
+	crlf := strings.Contains(text, "\r\n")
+	if crlf {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	orig, trailing := splitLines(text)
+	if from == "" {
+		trailing = true
+	}
+	name := to
+	if name == "" {
+		name = from
+	}
+	result, failed := applyHunkList(name, orig, f.hunks, defaultPatchFuzz)
+	if len(failed) > 0 {
+		return failed, nil
+	}
+	for _, h := range f.hunks {
+		switch {
+		case h.noNewlineNew:
+			trailing = false
+		case h.noNewlineOld:
+			trailing = true
+		}
+	}
+
+	if to == "" {
+		if len(result) > 0 {
+			return []HunkError{{Path: from, Index: len(f.hunks), Reason: fmt.Sprintf("file deletion leaves %d lines that are not in the patch", len(result))}}, nil
+		}
+		return nil, tx.remove(from)
+	}
+	content := strings.Join(result, "\n")
+	if trailing && len(result) > 0 {
+		content += "\n"
+	}
+	if crlf {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	if from != "" && from != to {
+		if err := tx.remove(from); err != nil {
+			return nil, err
+		}
+	}
+	return nil, tx.write(to, []byte(content), mode)
+}
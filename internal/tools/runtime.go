@@ -6,6 +6,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"echo-cli/internal/sandbox"
 )
 
 // Runtime 协调路由与并行控制。
@@ -16,6 +18,8 @@ type Runtime struct {
 	runner       Runner
 	unifiedExec  *UnifiedExecManager
 	approvals    *ApprovalStore
+	memory       *ApprovalMemory
+	sandbox      sandbox.Policy
 	lock         sync.RWMutex
 }
 
@@ -27,6 +31,12 @@ type RuntimeOptions struct {
 	UnifiedExec  *UnifiedExecManager
 	Reviewer     CommandReviewer
 	Approvals    *ApprovalStore
+	Policy       ApprovalPolicy
+	Rules        ApprovalRules
+	// Memory 保存会话内与项目级记住的批准；为 nil 时使用仅内存的默认实现。
+	Memory *ApprovalMemory
+	// Sandbox 约束 exec_command 与 apply_patch；Workdir 为空时使用运行时工作目录。
+	Sandbox sandbox.Policy
 }
 
 func NewRuntime(opts RuntimeOptions) *Runtime {
@@ -35,13 +45,24 @@ func NewRuntime(opts RuntimeOptions) *Runtime {
 	if approvals == nil {
 		approvals = NewApprovalStore()
 	}
+	memory := opts.Memory
+	if memory == nil {
+		memory = NewApprovalMemory(nil)
+	}
 	orchestrator := opts.Orchestrator
 	if orchestrator == nil {
 		orchestrator = NewOrchestratorWith(OrchestratorOptions{
 			Reviewer:  opts.Reviewer,
 			Approvals: approvals,
+			Policy:    opts.Policy,
+			Rules:     opts.Rules,
+			Memory:    memory,
 		})
 	}
+	sandboxPolicy := opts.Sandbox
+	if sandboxPolicy.Workdir == "" {
+		sandboxPolicy.Workdir = opts.Workdir
+	}
 	unifiedExec := opts.UnifiedExec
 	if unifiedExec == nil {
 		unifiedExec = NewUnifiedExecManager()
@@ -54,10 +75,17 @@ func NewRuntime(opts RuntimeOptions) *Runtime {
 		runner:       opts.Runner,
 		unifiedExec:  unifiedExec,
 		approvals:    approvals,
+		memory:       memory,
+		sandbox:      sandboxPolicy,
 	}
 }
 
 func (r *Runtime) Dispatch(ctx context.Context, call ToolCall, emit func(ToolEvent)) (ToolResult, error) {
+	return r.DispatchSession(ctx, "", call, emit)
+}
+
+// DispatchSession 与 Dispatch 相同，但带上会话 ID，使会话内记住的批准生效。
+func (r *Runtime) DispatchSession(ctx context.Context, sessionID string, call ToolCall, emit func(ToolEvent)) (ToolResult, error) {
 	handler, ok := r.registry.Handler(call.Name)
 	kind := ToolKind("unknown")
 	if ok {
@@ -75,6 +103,8 @@ func (r *Runtime) Dispatch(ctx context.Context, call ToolCall, emit func(ToolEve
 		Call:        call,
 		Workdir:     r.workdir,
 		Runner:      r.runner,
+		SessionID:   sessionID,
+		Sandbox:     r.sandbox,
 		UnifiedExec: r.unifiedExec,
 	}
 
@@ -99,6 +129,14 @@ func (r *Runtime) ResolveApproval(decision ApprovalDecision) bool {
 	return r.approvals.Resolve(decision)
 }
 
+// ApprovalMemory 返回运行时使用的审批记忆，用于保存/恢复会话内的批准。
+func (r *Runtime) ApprovalMemory() *ApprovalMemory {
+	if r == nil {
+		return nil
+	}
+	return r.memory
+}
+
 func logToolRequest(call ToolCall, kind ToolKind, recognized bool, workdir string) {
 	ensureToolsLogger()
 
@@ -12,6 +12,9 @@ const (
 	ToolFileRead   ToolKind = "file_read"
 	ToolSearch     ToolKind = "file_search"
 	ToolPlanUpdate ToolKind = "plan_update"
+	ToolMCP        ToolKind = "mcp_tool_call"
+	ToolViewImage  ToolKind = "view_image"
+	ToolGrep       ToolKind = "grep"
 )
 
 // ToolCall 表示一次工具调用的标准化结构。
@@ -38,7 +41,7 @@ type ToolRequest struct {
 type ToolResult struct {
 	ID     string
 	Kind   ToolKind
-	Status string // started|updated|completed|error
+	Status string // started|updated|completed|error|sandbox_denied
 	Output string
 	// Diff 用于 file_change(apply_patch) 的变更内容展示（例如 unified diff 或 begin_patch 格式）。
 	Diff     string
@@ -48,9 +51,14 @@ type ToolResult struct {
 	SessionID string
 	Path      string
 	Command   string
-	Plan      []PlanItem
+	// Query 是 file_search/grep 的查询内容。
+	Query string
+	Plan  []PlanItem
 	// Explanation 是 update_plan 的可选说明。
 	Explanation string
+	// Images 是 view_image 读取的图片（data URL），由引擎作为用户消息附在工具输出之后；
+	// 不参与 JSON 序列化，避免日志与事件流写入图片数据。
+	Images []string `json:"-"`
 
 	// ApprovalID 非空表示本次工具调用需要人工审批才能继续执行。
 	ApprovalID string
@@ -58,6 +66,9 @@ type ToolResult struct {
 	ApprovalReason string
 }
 
+// StatusSandboxDenied 表示调用因沙箱限制失败；审批策略允许时可请求在沙箱外重试。
+const StatusSandboxDenied = "sandbox_denied"
+
 type ToolEvent struct {
 	Type   string // item.started|item.updated|item.completed
 	Result ToolResult
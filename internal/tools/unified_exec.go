@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"echo-cli/internal/sandbox"
+
 	"github.com/creack/pty"
 	"github.com/google/uuid"
 )
@@ -93,6 +95,8 @@ type ExecCommandSpec struct {
 	BaseEnv        []string
 	YieldTime      time.Duration
 	MaxOutputBytes int
+	// Sandbox 非 full-access 时命令经由沙箱辅助进程执行。
+	Sandbox sandbox.Policy
 }
 
 type ExecCommandResult struct {
@@ -130,6 +134,10 @@ func (m *UnifiedExecManager) ExecCommand(ctx context.Context, spec ExecCommandSp
 		cmd.Dir = spec.Workdir
 	}
 	cmd.Env = withUnifiedExecEnv(spec.BaseEnv)
+	if err := sandbox.Wrap(cmd, spec.Sandbox); err != nil {
+		procCancel()
+		return ExecCommandResult{}, fmt.Errorf("%s %w", sandbox.ErrorPrefix, err)
+	}
 
 	sess := &unifiedExecSession{
 		id:       uuid.NewString(),
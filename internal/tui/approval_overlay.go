@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"echo-cli/internal/events"
 	"echo-cli/internal/tools"
 	tuirender "echo-cli/internal/tui/render"
 
@@ -15,8 +16,14 @@ import (
 type approvalRequest struct {
 	ID        string
 	Command   string
+	Path      string
 	Reason    string
 	SessionID string
+	// Prefix 是 "always approve" 默认放行的命令前缀。
+	Prefix string
+	// denying 为 true 时正在输入拒绝理由。
+	denying    bool
+	denyReason string
 }
 
 func (m *Model) enqueueApprovalRequest(result tools.ToolResult, sessionID string) {
@@ -30,9 +37,13 @@ func (m *Model) enqueueApprovalRequest(result tools.ToolResult, sessionID string
 	req := approvalRequest{
 		ID:        approvalID,
 		Command:   strings.TrimSpace(result.Command),
+		Path:      strings.TrimSpace(result.Path),
 		Reason:    strings.TrimSpace(result.ApprovalReason),
 		SessionID: strings.TrimSpace(sessionID),
 	}
+	if req.Command != "" {
+		req.Prefix = tools.CommandPrefix(req.Command)
+	}
 	if m.approvalActive == nil {
 		m.approvalActive = &req
 		return
@@ -83,12 +94,27 @@ func (m *Model) approvalView(width int) string {
 		lines = append(lines, "", "Command:")
 		lines = append(lines, indentApprovalLines(tuirender.WrapText(command, contentWidth-2))...)
 	}
+	if path := strings.TrimSpace(m.approvalActive.Path); path != "" {
+		lines = append(lines, "", "File:")
+		lines = append(lines, indentApprovalLines(tuirender.WrapText(path, contentWidth-2))...)
+	}
 	reason := strings.TrimSpace(m.approvalActive.Reason)
 	if reason != "" {
 		lines = append(lines, "", "Reason:")
 		lines = append(lines, indentApprovalLines(tuirender.WrapText(reason, contentWidth-2))...)
 	}
-	lines = append(lines, "", hintStyle.Render("[y] approve • [n] deny"))
+	if m.approvalActive.denying {
+		lines = append(lines, "", "Deny reason (sent to the model):")
+		lines = append(lines, indentApprovalLines(tuirender.WrapText(m.approvalActive.denyReason+"▏", contentWidth-2))...)
+		lines = append(lines, "", hintStyle.Render("[enter] deny • [esc] back"))
+		return lipgloss.NewStyle().Width(contentWidth).Render(strings.Join(lines, "\n"))
+	}
+	hints := []string{"[y] approve once", "[a] approve for session"}
+	if prefix := m.approvalActive.Prefix; prefix != "" {
+		hints = append(hints, fmt.Sprintf("[p] always approve %q ([P] also for this project)", prefix))
+	}
+	lines = append(lines, "", hintStyle.Render(strings.Join(hints, " • ")))
+	lines = append(lines, hintStyle.Render("[n] deny • [d] deny with reason"))
 	return lipgloss.NewStyle().Width(contentWidth).Render(strings.Join(lines, "\n"))
 }
 
@@ -107,27 +133,73 @@ func (m *Model) handleApprovalKey(msg tea.KeyMsg) tea.Cmd {
 	if m.approvalActive == nil {
 		return nil
 	}
-	key := strings.ToLower(msg.String())
-	switch key {
-	case "y":
-		cmd, ok := m.submitApprovalDecision(*m.approvalActive, true)
-		if ok {
-			m.advanceApprovalQueue()
+	req := m.approvalActive
+	if req.denying {
+		return m.handleDenyReasonKey(msg)
+	}
+	decision := events.ApprovalDecisionOperation{}
+	switch msg.String() {
+	case "P":
+		if req.Prefix == "" {
+			return nil
+		}
+		decision = events.ApprovalDecisionOperation{Approved: true, Scope: string(tools.ApprovalScopePrefix), Prefix: req.Prefix, Persist: true}
+	default:
+		switch strings.ToLower(msg.String()) {
+		case "y":
+			decision = events.ApprovalDecisionOperation{Approved: true}
+		case "a":
+			decision = events.ApprovalDecisionOperation{Approved: true, Scope: string(tools.ApprovalScopeSession)}
+		case "p":
+			if req.Prefix == "" {
+				return nil
+			}
+			decision = events.ApprovalDecisionOperation{Approved: true, Scope: string(tools.ApprovalScopePrefix), Prefix: req.Prefix}
+		case "n":
+		case "d":
+			req.denying = true
+			return nil
+		case "ctrl+c", "q":
+			return tea.Quit
+		default:
+			return nil
 		}
-		return cmd
-	case "n":
-		cmd, ok := m.submitApprovalDecision(*m.approvalActive, false)
-		if ok {
-			m.advanceApprovalQueue()
+	}
+	return m.resolveActiveApproval(decision)
+}
+
+// handleDenyReasonKey 处理拒绝理由输入：enter 提交，esc 返回选项。
+func (m *Model) handleDenyReasonKey(msg tea.KeyMsg) tea.Cmd {
+	req := m.approvalActive
+	switch msg.Type {
+	case tea.KeyEnter:
+		return m.resolveActiveApproval(events.ApprovalDecisionOperation{Reason: strings.TrimSpace(req.denyReason)})
+	case tea.KeyEsc:
+		req.denying = false
+		req.denyReason = ""
+	case tea.KeyBackspace:
+		if r := []rune(req.denyReason); len(r) > 0 {
+			req.denyReason = string(r[:len(r)-1])
 		}
-		return cmd
-	case "ctrl+c", "q":
+	case tea.KeySpace:
+		req.denyReason += " "
+	case tea.KeyRunes:
+		req.denyReason += string(msg.Runes)
+	case tea.KeyCtrlC:
 		return tea.Quit
 	}
 	return nil
 }
 
-func (m *Model) submitApprovalDecision(req approvalRequest, approved bool) (tea.Cmd, bool) {
+func (m *Model) resolveActiveApproval(decision events.ApprovalDecisionOperation) tea.Cmd {
+	cmd, ok := m.submitApprovalDecision(*m.approvalActive, decision)
+	if ok {
+		m.advanceApprovalQueue()
+	}
+	return cmd
+}
+
+func (m *Model) submitApprovalDecision(req approvalRequest, decision events.ApprovalDecisionOperation) (tea.Cmd, bool) {
 	if m.gateway == nil {
 		m.appendAssistantMessage("gateway not configured; cannot submit approval decision.")
 		return nil, false
@@ -148,8 +220,9 @@ func (m *Model) submitApprovalDecision(req approvalRequest, approved bool) (tea.
 		m.appendAssistantMessage("session id not set; cannot submit approval decision.")
 		return nil, false
 	}
+	decision.ApprovalID = approvalID
 	return func() tea.Msg {
-		if _, err := m.gateway.SubmitApprovalDecision(context.Background(), sessionID, approvalID, approved); err != nil {
+		if _, err := m.gateway.SubmitApprovalDecision(context.Background(), sessionID, decision); err != nil {
 			return systemMsg{Text: fmt.Sprintf("submit approval decision failed: %v", err)}
 		}
 		return nil
@@ -12,9 +12,8 @@ import (
 )
 
 type approvalDecision struct {
-	sessionID  string
-	approvalID string
-	approved   bool
+	sessionID string
+	events.ApprovalDecisionOperation
 }
 
 type approvalGateway struct {
@@ -25,8 +24,16 @@ func (g *approvalGateway) SubmitUserInput(ctx context.Context, items []events.In
 	return "sub-id", nil
 }
 
-func (g *approvalGateway) SubmitApprovalDecision(ctx context.Context, sessionID string, approvalID string, approved bool) (string, error) {
-	g.decisions = append(g.decisions, approvalDecision{sessionID: sessionID, approvalID: approvalID, approved: approved})
+func (g *approvalGateway) SubmitApprovalDecision(ctx context.Context, sessionID string, decision events.ApprovalDecisionOperation) (string, error) {
+	g.decisions = append(g.decisions, approvalDecision{sessionID: sessionID, ApprovalDecisionOperation: decision})
+	return "sub-id", nil
+}
+
+func (g *approvalGateway) SubmitUndo(ctx context.Context, sessionID string) (string, error) {
+	return "sub-id", nil
+}
+
+func (g *approvalGateway) SubmitCompact(ctx context.Context, sessionID string, op events.CompactOperation) (string, error) {
 	return "sub-id", nil
 }
 
@@ -72,11 +79,11 @@ func TestApprovalOverlay_SubmitsDecision(t *testing.T) {
 		t.Fatalf("expected one decision, got %d", len(gateway.decisions))
 	}
 	decision := gateway.decisions[0]
-	if !decision.approved {
-		t.Fatalf("expected approved decision, got denied")
+	if !decision.Approved || decision.Scope != "" {
+		t.Fatalf("expected one-off approval, got %+v", decision)
 	}
-	if decision.approvalID != "tool-1" {
-		t.Fatalf("expected approval id tool-1, got %q", decision.approvalID)
+	if decision.ApprovalID != "tool-1" {
+		t.Fatalf("expected approval id tool-1, got %q", decision.ApprovalID)
 	}
 	if decision.sessionID != "sess-1" {
 		t.Fatalf("expected session id sess-1, got %q", decision.sessionID)
@@ -85,3 +92,61 @@ func TestApprovalOverlay_SubmitsDecision(t *testing.T) {
 		t.Fatalf("expected approval overlay to be cleared")
 	}
 }
+
+func approvalModel(gateway *approvalGateway, command string) *Model {
+	m := New(Options{})
+	m.gateway = gateway
+	m.handleEngineEvent(events.Event{
+		Type:      events.EventToolEvent,
+		SessionID: "sess-1",
+		Payload: tools.ToolEvent{
+			Type:   "item.updated",
+			Result: tools.ToolResult{Status: "requires_approval", ApprovalID: "tool-1", Command: command},
+		},
+	})
+	return m
+}
+
+func TestApprovalOverlay_AlwaysApprovePrefix(t *testing.T) {
+	gateway := &approvalGateway{}
+	m := approvalModel(gateway, "git push origin main")
+	if view := m.View(); !strings.Contains(view, `"git push"`) {
+		t.Fatalf("expected derived prefix in overlay, got: %s", view)
+	}
+	cmd := m.handleApprovalKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'P'}})
+	if cmd == nil {
+		t.Fatalf("expected approval command")
+	}
+	cmd()
+	got := gateway.decisions[0]
+	if !got.Approved || got.Scope != "prefix" || got.Prefix != "git push" || !got.Persist {
+		t.Fatalf("unexpected decision %+v", got)
+	}
+}
+
+func TestApprovalOverlay_DenyWithReason(t *testing.T) {
+	gateway := &approvalGateway{}
+	m := approvalModel(gateway, "rm -rf build")
+	if cmd := m.handleApprovalKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}}); cmd != nil {
+		t.Fatalf("expected reason input before submitting")
+	}
+	m.handleApprovalKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("use")})
+	m.handleApprovalKey(tea.KeyMsg{Type: tea.KeySpace})
+	m.handleApprovalKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("make cleanx")})
+	m.handleApprovalKey(tea.KeyMsg{Type: tea.KeyBackspace})
+	if view := m.View(); !strings.Contains(view, "Deny reason") {
+		t.Fatalf("expected reason prompt, got: %s", view)
+	}
+	cmd := m.handleApprovalKey(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatalf("expected deny command")
+	}
+	cmd()
+	got := gateway.decisions[0]
+	if got.Approved || got.Reason != "use make clean" {
+		t.Fatalf("unexpected decision %+v", got)
+	}
+	if m.approvalActive != nil {
+		t.Fatalf("expected approval overlay to be cleared")
+	}
+}
@@ -0,0 +1,41 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"echo-cli/internal/events"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// submitCompact 触发 /compact [关注点]：请求 core 用摘要替换会话历史，
+// 结果（压缩前后 token 数与摘要）通过 EQ 的 compact.completed 事件渲染。
+func (m *Model) submitCompact(focus string) tea.Cmd {
+	if m.pending {
+		m.appendAssistantMessage("cannot compact while a task is running.")
+		return nil
+	}
+	if m.gateway == nil {
+		m.appendAssistantMessage("compact is not available: gateway not configured.")
+		return nil
+	}
+	sessionID := strings.TrimSpace(m.eqCtx.SessionID)
+	if sessionID == "" {
+		sessionID = strings.TrimSpace(m.resumeSessionID)
+	}
+	if sessionID == "" {
+		m.appendAssistantMessage("session id not set; cannot compact.")
+		return nil
+	}
+	m.appendAssistantMessage("compacting conversation…")
+	gateway := m.gateway
+	op := events.CompactOperation{Focus: strings.TrimSpace(focus), Model: m.defaultInputContext().Model}
+	return func() tea.Msg {
+		if _, err := gateway.SubmitCompact(context.Background(), sessionID, op); err != nil {
+			return systemMsg{Text: fmt.Sprintf("submit compact failed: %v", err)}
+		}
+		return nil
+	}
+}
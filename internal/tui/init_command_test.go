@@ -24,11 +24,19 @@ func (g *stubGateway) SubmitUserInput(ctx context.Context, items []events.InputM
 	return "sub-id", nil
 }
 
-func (g *stubGateway) SubmitApprovalDecision(ctx context.Context, sessionID string, approvalID string, approved bool) (string, error) {
+func (g *stubGateway) SubmitApprovalDecision(ctx context.Context, sessionID string, decision events.ApprovalDecisionOperation) (string, error) {
 	g.submissions++
 	return "sub-id", nil
 }
 
+func (g *stubGateway) SubmitUndo(ctx context.Context, sessionID string) (string, error) {
+	return "sub-id", nil
+}
+
+func (g *stubGateway) SubmitCompact(ctx context.Context, sessionID string, op events.CompactOperation) (string, error) {
+	return "sub-id", nil
+}
+
 func (g *stubGateway) Events() <-chan events.Event {
 	return nil
 }
@@ -14,6 +14,7 @@ import (
 	"echo-cli/internal/history"
 	"echo-cli/internal/i18n"
 	"echo-cli/internal/logger"
+	"echo-cli/internal/mcp"
 	"echo-cli/internal/search"
 	"echo-cli/internal/session"
 	"echo-cli/internal/tools"
@@ -40,23 +41,36 @@ type Options struct {
 	InitialPrompt   string
 	Language        string
 	InitialMessages []agent.Message
+	// Images 是 --image 加载的图片 data URL，随第一次提交的用户输入发送。
+	Images          []string
 	Events          *events.Bus
 	Runner          tools.Runner
 	ResumePicker    bool
 	ResumeShowAll   bool
-	ResumeSessions  []string
+	ResumeSessions  []session.Summary
 	ResumeSessionID string
 	CustomPrompts   []slash.CustomPrompt
 	SkillsAvailable bool
 	Debug           bool
 	ConversationLog *logger.LogEntry
 	CopyableOutput  bool
+	// MCP 提供 /mcp 展示的服务器状态；nil 表示未配置 MCP。
+	MCP MCPStatusSource
+	// ApprovalMemory 是会话内记住的批准；恢复会话时用保存的批准填充。
+	ApprovalMemory *tools.ApprovalMemory
+}
+
+// MCPStatusSource 提供 MCP 服务器连接状态。
+type MCPStatusSource interface {
+	Status() []mcp.ServerStatus
 }
 
 // SubmissionGateway 抽象 REPL 层提交/订阅能力，避免 TUI 与实现耦合。
 type SubmissionGateway interface {
 	SubmitUserInput(ctx context.Context, items []events.InputMessage, inputCtx events.InputContext) (string, error)
-	SubmitApprovalDecision(ctx context.Context, sessionID string, approvalID string, approved bool) (string, error)
+	SubmitApprovalDecision(ctx context.Context, sessionID string, decision events.ApprovalDecisionOperation) (string, error)
+	SubmitUndo(ctx context.Context, sessionID string) (string, error)
+	SubmitCompact(ctx context.Context, sessionID string, op events.CompactOperation) (string, error)
 	Events() <-chan events.Event
 }
 
@@ -98,9 +112,11 @@ type Model struct {
 	viewport                 tuirender.HighPerformanceViewport
 	eventsPane               viewport.Model
 	search                   list.Model
-	sessions                 list.Model
+	sessions                 sessionPicker
 	messages                 []agent.Message
 	planUpdate               *tools.UpdatePlanArgs
+	usage                    agent.TokenUsage            // 本会话各任务的用量合计，状态栏据此显示缓存命中率
+	submissionUsage          map[string]agent.TokenUsage // 各提交最近一次任务总结中的累计用量
 	eqCtx                    tuirender.Context
 	eqRenderers              map[events.EventType]tuirender.EventRenderer
 	streamIdx                int
@@ -113,6 +129,10 @@ type Model struct {
 	toolRuntime              *tools.Runtime
 	eventsSub                <-chan any
 	gateway                  SubmissionGateway
+	engine                   *execution.Engine
+	mcp                      MCPStatusSource
+	approvalMemory           *tools.ApprovalMemory
+	pendingImages            []string
 	eqSub                    <-chan events.Event
 	activeSub                string
 	pending                  bool
@@ -174,14 +194,8 @@ func New(opts Options) *Model {
 	search.Title = "Select file (@ search)"
 	search.SetShowStatusBar(false)
 	search.DisableQuitKeybindings()
-	sessionItems := []list.Item{}
-	for _, id := range opts.ResumeSessions {
-		sessionItems = append(sessionItems, listItem(id))
-	}
-	sessions := list.New(sessionItems, list.NewDefaultDelegate(), 40, 10)
-	sessions.Title = "Resume session"
-	sessions.SetShowStatusBar(false)
-	sessions.DisableQuitKeybindings()
+	search.Filter = fuzzyPathFilter
+	sessions := newSessionPicker(opts.ResumeSessions, opts.ResumeShowAll)
 
 	runner := opts.Runner
 	if runner == nil {
@@ -231,6 +245,9 @@ func New(opts Options) *Model {
 		transcriptDirty: true,
 		slash:           sl,
 		conversationLog: opts.ConversationLog,
+		mcp:             opts.MCP,
+		approvalMemory:  opts.ApprovalMemory,
+		pendingImages:   opts.Images,
 	}
 	// TUI doesn't render submission.accepted into transcript because user input is
 	// already echoed locally. Still keep ActiveSub in sync.
@@ -252,6 +269,7 @@ func New(opts Options) *Model {
 	if opts.Events != nil {
 		m.eventsSub = opts.Events.Subscribe()
 	}
+	m.engine = opts.Engine
 	if opts.Gateway != nil {
 		m.gateway = opts.Gateway
 		m.eqSub = opts.Gateway.Events()
@@ -377,31 +395,33 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m.finish(cmds...)
 		}
+		if msg.String() == "ctrl+r" {
+			m.toggleReasoning()
+			return m.finish(cmds...)
+		}
 		if m.pickingSession {
-			var cmd tea.Cmd
-			m.sessions, cmd = m.sessions.Update(msg)
-			if cmd != nil {
-				cmds = append(cmds, cmd)
+			if m.sessions.Filtering() {
+				if cmd := m.sessions.Update(msg); cmd != nil {
+					cmds = append(cmds, cmd)
+				}
+				return m.finish(cmds...)
 			}
 			switch msg.String() {
 			case "enter":
-				if sel, ok := m.sessions.SelectedItem().(listItem); ok {
-					rec, err := session.Load(string(sel))
-					if err != nil {
-						m.pickingSession = false
-						cmds = append(cmds, func() tea.Msg { return systemMsg{Text: fmt.Sprintf("session load error: %v", err)} })
-						return m.finish(cmds...)
+				m.pickingSession = false
+				if sel, ok := m.sessions.Selected(); ok {
+					if cmd := m.resumeSession(sel.ID); cmd != nil {
+						cmds = append(cmds, cmd)
 					}
-					m.resumeSessionID = rec.ID
-					m.eqCtx.SessionID = rec.ID
-					m.pickingSession = false
-					m.loadTranscriptMessages(rec.Messages)
-					return m.finish(cmds...)
 				}
+				return m.finish(cmds...)
 			case "esc", "ctrl+c":
 				m.pickingSession = false
 				return m.finish(cmds...)
 			}
+			if cmd := m.sessions.Update(msg); cmd != nil {
+				cmds = append(cmds, cmd)
+			}
 			return m.finish(cmds...)
 		}
 		if m.searching {
@@ -604,7 +624,11 @@ func (m *Model) View() string {
 		return lipgloss.JoinVertical(lipgloss.Left, content, overlay)
 	}
 	if m.pickingSession {
-		overlay := modalStyle.Render(m.sessions.View())
+		width := m.width - 4
+		if width < 20 {
+			width = m.width
+		}
+		overlay := modalStyle.Render(m.sessions.View(width))
 		return lipgloss.JoinVertical(lipgloss.Left, content, overlay)
 	}
 	if m.slash != nil && m.slash.Open() {
@@ -700,7 +724,7 @@ func (m *Model) startSubmission(input string, inputCtx events.InputContext) tea.
 	}
 	m.eqCtx.SessionID = inputCtx.SessionID
 	id, err := m.gateway.SubmitUserInput(context.Background(), []events.InputMessage{
-		{Role: "user", Content: input},
+		{Role: "user", Content: input, Images: m.pendingImages},
 	}, inputCtx)
 	if err != nil {
 		m.err = err
@@ -708,6 +732,7 @@ func (m *Model) startSubmission(input string, inputCtx events.InputContext) tea.
 		m.pendingSince = time.Time{}
 		return nil
 	}
+	m.pendingImages = nil
 	m.activeSub = id
 	m.eqCtx.ActiveSub = id
 	return tea.Batch(m.listenQueues()...)
@@ -836,6 +861,24 @@ func (m *Model) handleEngineEvent(evt events.Event) tea.Cmd {
 		}
 		m.planUpdate = &next
 		m.refreshTranscript() // plan section affects available viewport height
+	case events.EventTaskSummary:
+		if summary, ok := evt.Payload.(events.TaskSummary); ok {
+			// 每次模型调用后都会发出任务总结，其中的用量是该提交的累计值，只累加与上一次总结的差值。
+			prev := m.submissionUsage[evt.SubmissionID]
+			m.usage.InputTokens += summary.InputTokens - prev.InputTokens
+			m.usage.OutputTokens += summary.OutputTokens - prev.OutputTokens
+			m.usage.CacheCreationInputTokens += summary.CacheCreationInputTokens - prev.CacheCreationInputTokens
+			m.usage.CacheReadInputTokens += summary.CacheReadInputTokens - prev.CacheReadInputTokens
+			if m.submissionUsage == nil {
+				m.submissionUsage = map[string]agent.TokenUsage{}
+			}
+			m.submissionUsage[evt.SubmissionID] = agent.TokenUsage{
+				InputTokens:              summary.InputTokens,
+				OutputTokens:             summary.OutputTokens,
+				CacheCreationInputTokens: summary.CacheCreationInputTokens,
+				CacheReadInputTokens:     summary.CacheReadInputTokens,
+			}
+		}
 	case events.EventAgentOutput:
 		msg, ok := evt.Payload.(events.AgentOutput)
 		if !ok || evt.SubmissionID != m.activeSub {
@@ -1291,6 +1334,9 @@ func (m *Model) statusLine(width int) string {
 		}
 	}
 	parts = append(parts, scrollLabel)
+	if m.usage.CacheReadInputTokens+m.usage.CacheCreationInputTokens > 0 {
+		parts = append(parts, fmt.Sprintf("Cache:%d%%", int(m.usage.CacheHitRate()*100+0.5)))
+	}
 	if m.err != nil {
 		parts = append(parts, fmt.Sprintf("Error: %v", m.err))
 	}
@@ -1517,11 +1563,24 @@ func (m *Model) loadSearch() tea.Cmd {
 		root = "."
 	}
 	return func() tea.Msg {
-		paths, err := search.FindFiles(root, 200)
+		paths, err := search.FindFiles(root, mentionFileLimit)
 		return searchResultsMsg{Paths: paths, Err: err}
 	}
 }
 
+// mentionFileLimit 是 @ 选择器加载的文件数上限。
+const mentionFileLimit = 20000
+
+// fuzzyPathFilter 让 @ 选择器使用与 file_search 工具相同的模糊匹配与排序。
+func fuzzyPathFilter(term string, targets []string) []list.Rank {
+	matches := search.FuzzyFind(term, targets)
+	ranks := make([]list.Rank, len(matches))
+	for i, m := range matches {
+		ranks[i] = list.Rank{Index: m.Index, MatchedIndexes: m.Positions}
+	}
+	return ranks
+}
+
 type listItem string
 
 func (i listItem) FilterValue() string { return string(i) }
@@ -1558,20 +1617,14 @@ func (m *Model) executeSlashCommand(cmd slash.Command, args string) tea.Cmd {
 		return nil
 	case slash.CommandStatus:
 		info := fmt.Sprintf("model=%s dir=%s", m.modelName, m.workdir)
+		if total := m.usage.TotalInputTokens(); total > 0 {
+			info += fmt.Sprintf(" input=%d cache_read=%d cache_write=%d cache_hit=%.0f%% output=%d",
+				total, m.usage.CacheReadInputTokens, m.usage.CacheCreationInputTokens, m.usage.CacheHitRate()*100, m.usage.OutputTokens)
+		}
 		m.appendAssistantMessage(info)
 		return nil
 	case slash.CommandSessions:
-		ids, err := session.ListIDs()
-		if err != nil {
-			return func() tea.Msg { return systemMsg{Text: fmt.Sprintf("sessions error: %v", err)} }
-		}
-		items := make([]list.Item, 0, len(ids))
-		for _, id := range ids {
-			items = append(items, listItem(id))
-		}
-		m.sessions.SetItems(items)
-		m.pickingSession = true
-		return nil
+		return m.openSessionPicker(args)
 	case slash.CommandModel:
 		if arg := firstArg(args); arg != "" {
 			m.modelName = arg
@@ -1581,14 +1634,10 @@ func (m *Model) executeSlashCommand(cmd slash.Command, args string) tea.Cmd {
 	case slash.CommandInit:
 		return m.handleInitCommand()
 	case slash.CommandResume:
-		rec, err := session.Last()
-		if err != nil {
-			return func() tea.Msg { return systemMsg{Text: fmt.Sprintf("resume error: %v", err)} }
+		if id := firstArg(args); id != "" && id != "--all" && id != "all" {
+			return m.resumeSession(id)
 		}
-		m.resumeSessionID = rec.ID
-		m.eqCtx.SessionID = rec.ID
-		m.loadTranscriptMessages(rec.Messages)
-		return nil
+		return m.openSessionPicker(args)
 	case slash.CommandDiff:
 		return m.runTool(tools.ToolRequest{
 			ID:      "local-diff",
@@ -1659,18 +1708,15 @@ func (m *Model) executeSlashCommand(cmd slash.Command, args string) tea.Cmd {
 		m.appendAssistantMessage("Review mode enabled for subsequent turns.")
 		return nil
 	case slash.CommandCompact:
-		cmd := m.toggleChrome()
-		if m.chromeCollapsed {
-			m.appendAssistantMessage("已折叠顶部信息，留出更多会话空间。")
-		} else {
-			m.appendAssistantMessage("已恢复完整顶部信息。")
-		}
-		return cmd
+		return m.submitCompact(args)
 	case slash.CommandUndo:
-		m.appendAssistantMessage("undo is not available in this build.")
-		return nil
+		return m.submitUndo()
 	case slash.CommandMCP:
-		m.appendAssistantMessage("MCP UI not implemented in Go TUI yet.")
+		var statuses []mcp.ServerStatus
+		if m.mcp != nil {
+			statuses = m.mcp.Status()
+		}
+		m.appendAssistantMessage(mcp.FormatStatus(statuses))
 		return nil
 	case slash.CommandLogout:
 		m.appendAssistantMessage("Use `echo-cli logout` to clear credentials.")
@@ -1700,6 +1746,19 @@ func (m *Model) toggleChrome() tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
+// toggleReasoning 展开或折叠转录中的思考块。
+func (m *Model) toggleReasoning() {
+	if m.eqCtx.Transcript == nil {
+		return
+	}
+	state := "折叠思考内容"
+	if m.eqCtx.Transcript.SetReasoningExpanded(!m.eqCtx.Transcript.ReasoningExpanded()) {
+		state = "展开思考内容"
+	}
+	m.logEvent("layout", state)
+	m.refreshTranscript()
+}
+
 func (m *Model) resetSession() {
 	m.resetTranscriptMessages()
 	m.streamIdx = -1
@@ -1712,6 +1771,8 @@ func (m *Model) resetSession() {
 	m.eqCtx.SessionID = ""
 	m.reviewMode = false
 	m.planUpdate = nil
+	m.usage = agent.TokenUsage{}
+	m.submissionUsage = nil
 }
 
 func firstArg(args string) string {
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+
+	"echo-cli/internal/events"
 )
 
 func TestFlushTranscriptUsesViewportHeight(t *testing.T) {
@@ -40,3 +42,28 @@ func TestConversationHeightExpandsWhenChromeCollapsed(t *testing.T) {
 		t.Fatalf("collapsed height should be greater than expanded height (got %d vs %d)", collapsed, expanded)
 	}
 }
+
+func TestStatusLineShowsSessionCacheHitRate(t *testing.T) {
+	m := New(Options{})
+	m.resize(120, 30)
+	if strings.Contains(m.statusLine(120), "Cache:") {
+		t.Fatalf("cache rate should be hidden before any cached usage is reported")
+	}
+	// 同一提交的多次总结是累计值（sub-1 的第二次包含第一次），不能重复累加。
+	for _, ev := range []struct {
+		sub     string
+		summary events.TaskSummary
+	}{
+		{"sub-1", events.TaskSummary{InputTokens: 100, CacheCreationInputTokens: 400, OutputTokens: 20}},
+		{"sub-1", events.TaskSummary{InputTokens: 200, CacheCreationInputTokens: 800, OutputTokens: 50}},
+		{"sub-2", events.TaskSummary{InputTokens: 100, CacheReadInputTokens: 900, OutputTokens: 40}},
+	} {
+		m.handleEngineEvent(events.Event{Type: events.EventTaskSummary, SubmissionID: ev.sub, Payload: ev.summary})
+	}
+	if line := m.statusLine(120); !strings.Contains(line, "Cache:45%") {
+		t.Fatalf("expected session cache hit rate in status line, got %q", line)
+	}
+	if m.usage.InputTokens != 300 || m.usage.OutputTokens != 90 {
+		t.Fatalf("running totals were double counted: %+v", m.usage)
+	}
+}
@@ -0,0 +1,22 @@
+package render
+
+import "echo-cli/internal/events"
+
+// agentReasoningRenderer 把思考增量追加到转录中的折叠思考块。
+type agentReasoningRenderer struct{}
+
+func (agentReasoningRenderer) Type() events.EventType { return events.EventAgentReasoning }
+
+func (agentReasoningRenderer) Handle(ctx *Context, evt events.Event) {
+	if ctx.Transcript == nil {
+		return
+	}
+	if ctx.ActiveSub != "" && evt.SubmissionID != ctx.ActiveSub {
+		return
+	}
+	msg, ok := evt.Payload.(events.AgentReasoning)
+	if !ok || msg.Content == "" {
+		return
+	}
+	ctx.Emit(ctx.Transcript.AppendReasoningChunk(msg.Content))
+}
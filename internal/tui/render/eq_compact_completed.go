@@ -0,0 +1,39 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"echo-cli/internal/events"
+)
+
+// compactCompletedRenderer reports the outcome of a /compact request.
+type compactCompletedRenderer struct{}
+
+func (compactCompletedRenderer) Type() events.EventType { return events.EventCompactCompleted }
+
+func (compactCompletedRenderer) Handle(ctx *Context, evt events.Event) {
+	if ctx == nil || ctx.Transcript == nil {
+		return
+	}
+	result, ok := evt.Payload.(events.CompactResult)
+	if !ok {
+		return
+	}
+	msg := strings.TrimSpace(result.Message)
+	if msg == "" {
+		msg = "compaction finished"
+	}
+	if !result.Success {
+		ctx.Emit(ctx.Transcript.AppendToolBlock("compact: " + msg))
+		return
+	}
+	block := "⇣ " + msg
+	if result.TrimmedItems > 0 {
+		block += fmt.Sprintf(" (dropped %d oldest items to fit the window)", result.TrimmedItems)
+	}
+	if summary := strings.TrimSpace(result.Summary); summary != "" {
+		block += "\n\n" + summary
+	}
+	ctx.Emit(ctx.Transcript.AppendToolBlock(block))
+}
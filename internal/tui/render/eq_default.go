@@ -8,11 +8,14 @@ func DefaultRenderers() map[events.EventType]EventRenderer {
 		submissionAcceptedRenderer{},
 		taskStartedRenderer{},
 		agentOutputRenderer{},
+		agentReasoningRenderer{},
 		toolEventRenderer{},
 		taskSummaryRenderer{},
 		taskTerminalRenderer{typ: events.EventTaskCompleted},
 		taskTerminalRenderer{typ: events.EventError},
 		planUpdatedRenderer{},
+		undoCompletedRenderer{},
+		compactCompletedRenderer{},
 	}
 	out := make(map[events.EventType]EventRenderer, len(renderers))
 	for _, r := range renderers {
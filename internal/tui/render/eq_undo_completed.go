@@ -0,0 +1,31 @@
+package render
+
+import (
+	"strings"
+
+	"echo-cli/internal/events"
+)
+
+// undoCompletedRenderer reports the outcome of an /undo request.
+type undoCompletedRenderer struct{}
+
+func (undoCompletedRenderer) Type() events.EventType { return events.EventUndoCompleted }
+
+func (undoCompletedRenderer) Handle(ctx *Context, evt events.Event) {
+	if ctx == nil || ctx.Transcript == nil {
+		return
+	}
+	result, ok := evt.Payload.(events.UndoResult)
+	if !ok {
+		return
+	}
+	msg := strings.TrimSpace(result.Message)
+	if msg == "" {
+		msg = "undo finished"
+	}
+	if result.Success {
+		ctx.Emit(ctx.Transcript.AppendToolBlock("↶ " + msg))
+		return
+	}
+	ctx.Emit(ctx.Transcript.AppendToolBlock("undo: " + msg))
+}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"echo-cli/internal/agent"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RoleReasoning 标记转录视图中的思考块；它只用于展示，不属于对话历史。
+const RoleReasoning agent.Role = "reasoning"
+
+// reasoningPreviewLines 是折叠时展示的最后几行。
+const reasoningPreviewLines = 2
+
+var (
+	reasoningHeaderStyle = lipgloss.NewStyle().Faint(true).Bold(true)
+	reasoningStyle       = lipgloss.NewStyle().Faint(true).Italic(true)
+)
+
+// RenderReasoningLines 渲染思考块：折叠时只显示标题与最后几行，展开时显示全文。
+func RenderReasoningLines(content string, width int, expanded bool) []Line {
+	wrapWidth := width - 4
+	if wrapWidth < 1 {
+		wrapWidth = width
+	}
+	body := wrapLines(strings.TrimSpace(content), wrapWidth, reasoningStyle)
+	hidden := 0
+	if !expanded && len(body) > reasoningPreviewLines {
+		hidden = len(body) - reasoningPreviewLines
+		body = body[hidden:]
+	}
+	header := "✻ Thinking"
+	if hidden > 0 {
+		header += fmt.Sprintf(" · %d more lines", hidden)
+	}
+	lines := []Line{{Spans: []Span{{Text: header, Style: reasoningHeaderStyle}}}}
+	return append(lines, PrefixLines(body, Span{Text: "  │ ", Style: reasoningStyle}, Span{Text: "  │ ", Style: reasoningStyle})...)
+}
+
+// AppendReasoningChunk 追加思考增量到视图中的思考块（不进入对话历史）。
+// 流式回答的空占位之前插入，使思考块显示在回答上方。
+func (t *Transcript) AppendReasoningChunk(chunk string) []string {
+	if t == nil || chunk == "" {
+		return nil
+	}
+	n := len(t.view)
+	switch {
+	case n > 0 && t.view[n-1].Role == RoleReasoning:
+		t.view[n-1].Content += chunk
+	case n > 0 && t.view[n-1].Role == agent.RoleAssistant && t.view[n-1].Content == "":
+		if n > 1 && t.view[n-2].Role == RoleReasoning {
+			t.view[n-2].Content += chunk
+		} else {
+			t.view = append(t.view[:n-1], agent.Message{Role: RoleReasoning, Content: chunk}, t.view[n-1])
+		}
+	default:
+		t.view = append(t.view, agent.Message{Role: RoleReasoning, Content: chunk})
+	}
+	return t.renderDelta()
+}
+
+// SetReasoningExpanded 切换思考块的展开状态，返回新状态。
+func (t *Transcript) SetReasoningExpanded(expanded bool) bool {
+	if t == nil {
+		return false
+	}
+	if t.expandReasoning != expanded {
+		t.expandReasoning = expanded
+		t.lastRender = nil
+	}
+	return t.expandReasoning
+}
+
+// ReasoningExpanded 报告思考块是否展开显示。
+func (t *Transcript) ReasoningExpanded() bool {
+	return t != nil && t.expandReasoning
+}
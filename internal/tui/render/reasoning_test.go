@@ -0,0 +1,40 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"echo-cli/internal/agent"
+)
+
+func TestTranscript_ReasoningRendersAboveAnswerAndCollapses(t *testing.T) {
+	tr := NewTranscript(60)
+	tr.AppendUser("why?")
+	tr.AppendAssistantChunk("")
+	for _, chunk := range []string{"step one\n", "step two\n", "step three\n", "step four"} {
+		tr.AppendReasoningChunk(chunk)
+	}
+	tr.AppendAssistantChunk("because")
+
+	view := tr.ViewMessages()
+	if len(view) != 3 || view[1].Role != RoleReasoning || view[2].Role != agent.RoleAssistant || view[2].Content != "because" {
+		t.Fatalf("unexpected view %+v", view)
+	}
+	for _, msg := range tr.Messages() {
+		if msg.Role == RoleReasoning {
+			t.Fatalf("reasoning must not enter conversation history")
+		}
+	}
+
+	collapsed := strings.Join(LinesToPlainStrings(tr.RenderViewLines(60)), "\n")
+	if !strings.Contains(collapsed, "Thinking · 2 more lines") || strings.Contains(collapsed, "step one") || !strings.Contains(collapsed, "step four") {
+		t.Fatalf("unexpected collapsed render:\n%s", collapsed)
+	}
+	if !tr.SetReasoningExpanded(true) {
+		t.Fatalf("expected expanded state")
+	}
+	expanded := strings.Join(LinesToPlainStrings(tr.RenderViewLines(60)), "\n")
+	if !strings.Contains(expanded, "step one") || strings.Contains(expanded, "more lines") {
+		t.Fatalf("unexpected expanded render:\n%s", expanded)
+	}
+}
@@ -41,6 +41,9 @@ func toolUpdatedBlock(res tools.ToolResult) string {
 		if strings.TrimSpace(res.Command) != "" {
 			sb.WriteString("\n  └ command: " + strings.TrimSpace(res.Command))
 		}
+		if strings.TrimSpace(res.Path) != "" {
+			sb.WriteString("\n  └ path: " + strings.TrimSpace(res.Path))
+		}
 		if strings.TrimSpace(res.ApprovalID) != "" {
 			sb.WriteString("\n  └ approval_id: " + strings.TrimSpace(res.ApprovalID))
 		}
@@ -69,8 +72,14 @@ func toolStartLine(res tools.ToolResult) (prefix string, detail string) {
 		return "Δ applying", strings.TrimSpace(res.Path)
 	case tools.ToolFileRead:
 		return "↳ reading", strings.TrimSpace(res.Path)
+	case tools.ToolViewImage:
+		return "🖼 viewing", strings.TrimSpace(res.Path)
 	case tools.ToolSearch:
-		return "🔍 searching", strings.TrimSpace(res.Output)
+		return "🔍 searching", strings.TrimSpace(res.Query)
+	case tools.ToolGrep:
+		return "🔍 grep", grepDetail(res)
+	case tools.ToolMCP:
+		return "⚙ calling", strings.TrimSpace(res.Command)
 	default:
 		return "• running", strings.TrimSpace(res.Status)
 	}
@@ -144,3 +153,12 @@ func renderIndentedTruncatedLines(text string, limit int) string {
 	}
 	return sb.String()
 }
+
+// grepDetail 展示 grep 的模式与搜索路径。
+func grepDetail(res tools.ToolResult) string {
+	detail := strings.TrimSpace(res.Query)
+	if path := strings.TrimSpace(res.Path); path != "" {
+		detail += " in " + path
+	}
+	return detail
+}
@@ -20,10 +20,15 @@ var (
 )
 
 // RenderMessages 使用 ColumnRenderable 将消息列表渲染为行。
+// 思考块以折叠形式渲染。
 func RenderMessages(msgs []agent.Message, width int) []Line {
+	return renderMessages(msgs, width, false)
+}
+
+func renderMessages(msgs []agent.Message, width int, expandReasoning bool) []Line {
 	col := NewColumn()
 	for _, msg := range msgs {
-		col.Push(messageRenderable{msg: msg})
+		col.Push(messageRenderable{msg: msg, expandReasoning: expandReasoning})
 	}
 	buf := Buffer{}
 	height := col.DesiredHeight(width)
@@ -33,7 +38,8 @@ func RenderMessages(msgs []agent.Message, width int) []Line {
 
 type messageRenderable struct {
 	baseRenderable
-	msg agent.Message
+	msg             agent.Message
+	expandReasoning bool
 }
 
 func (m messageRenderable) Render(area Rect, buf *Buffer) {
@@ -45,6 +51,8 @@ func (m messageRenderable) Render(area Rect, buf *Buffer) {
 		buf.WriteLines(renderAssistantLines(content, area.Width)...)
 	case "tool":
 		buf.WriteLines(renderToolLines(content, area.Width)...)
+	case RoleReasoning:
+		buf.WriteLines(RenderReasoningLines(content, area.Width, m.expandReasoning)...)
 	default:
 		buf.WriteLines(StaticLines(wrapPlain(content, area.Width))...)
 	}
@@ -58,6 +66,8 @@ func (m messageRenderable) DesiredHeight(width int) int {
 		return len(renderAssistantLines(m.msg.Content, width))
 	case "tool":
 		return len(renderToolLines(m.msg.Content, width))
+	case RoleReasoning:
+		return len(RenderReasoningLines(m.msg.Content, width, m.expandReasoning))
 	default:
 		return len(wrapPlain(m.msg.Content, width))
 	}
@@ -218,6 +228,8 @@ type Transcript struct {
 	// such as tool.event cells.
 	view       []agent.Message
 	lastRender []string
+	// expandReasoning 控制思考块展开全文还是折叠为最后几行。
+	expandReasoning bool
 }
 
 // NewTranscript 创建 Transcript。
@@ -343,7 +355,7 @@ func (t *Transcript) RenderViewLines(width int) []Line {
 	if width <= 0 {
 		width = t.width
 	}
-	return RenderMessages(t.view, width)
+	return renderMessages(t.view, width, t.expandReasoning)
 }
 
 func filterConversationMessages(msgs []agent.Message) []agent.Message {
@@ -361,7 +373,7 @@ func filterConversationMessages(msgs []agent.Message) []agent.Message {
 }
 
 func (t *Transcript) renderDelta() []string {
-	lines := LinesToStrings(RenderMessages(t.view, t.width))
+	lines := LinesToStrings(renderMessages(t.view, t.width, t.expandReasoning))
 	start := 0
 	for start < len(lines) && start < len(t.lastRender) && t.lastRender[start] == lines[start] {
 		start++
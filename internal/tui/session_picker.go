@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/session"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sessionPreviewMessages 是预览窗格展示的最近消息条数。
+const sessionPreviewMessages = 12
+
+// sessionItem 是会话选择器中的一项；FilterValue 覆盖首条消息、目录、模型与 ID，供模糊过滤。
+type sessionItem struct {
+	summary session.Summary
+}
+
+func (i sessionItem) FilterValue() string {
+	return strings.Join([]string{i.summary.FirstUserMessage, i.summary.Workdir, i.summary.Model, i.summary.ID}, " ")
+}
+
+func (i sessionItem) Title() string {
+	if i.summary.FirstUserMessage == "" {
+		return "(no user message) " + shortSessionID(i.summary.ID)
+	}
+	return truncateRunes(i.summary.FirstUserMessage, 60)
+}
+
+func (i sessionItem) Description() string {
+	parts := []string{relativeTime(i.summary.Updated), fmt.Sprintf("%d msgs", i.summary.MessageCount)}
+	if i.summary.Model != "" {
+		parts = append(parts, i.summary.Model)
+	}
+	if i.summary.Workdir != "" {
+		parts = append(parts, i.summary.Workdir)
+	}
+	return strings.Join(parts, " • ")
+}
+
+// sessionPicker 是 /sessions 与 `resume` 的会话选择器：左侧列表（按 / 模糊过滤），右侧转录预览。
+type sessionPicker struct {
+	list    list.Model
+	showAll bool
+	// previews 缓存已渲染的预览，避免每次移动光标都重新读取会话文件。
+	previews map[string]string
+	load     func(id string) (session.Record, error)
+}
+
+func newSessionPicker(summaries []session.Summary, showAll bool) sessionPicker {
+	l := list.New(nil, list.NewDefaultDelegate(), 60, 14)
+	l.SetShowStatusBar(false)
+	l.DisableQuitKeybindings()
+	p := sessionPicker{list: l, load: session.Preview}
+	p.SetSummaries(summaries, showAll)
+	return p
+}
+
+// SetSummaries 替换列表内容并清空预览缓存。
+func (p *sessionPicker) SetSummaries(summaries []session.Summary, showAll bool) {
+	items := make([]list.Item, 0, len(summaries))
+	for _, s := range summaries {
+		items = append(items, sessionItem{summary: s})
+	}
+	p.list.ResetFilter()
+	p.list.SetItems(items)
+	p.list.Select(0)
+	p.showAll = showAll
+	p.previews = map[string]string{}
+	p.list.Title = "Resume session (current directory)"
+	if showAll {
+		p.list.Title = "Resume session (all directories)"
+	}
+}
+
+// Filtering 报告是否正在输入过滤词；此时 enter/esc 交给列表处理。
+func (p *sessionPicker) Filtering() bool {
+	return p.list.FilterState() == list.Filtering
+}
+
+func (p *sessionPicker) Update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	p.list, cmd = p.list.Update(msg)
+	return cmd
+}
+
+// Selected 返回当前选中的会话。
+func (p *sessionPicker) Selected() (session.Summary, bool) {
+	item, ok := p.list.SelectedItem().(sessionItem)
+	if !ok {
+		return session.Summary{}, false
+	}
+	return item.summary, true
+}
+
+// View 渲染列表与预览窗格；宽度不足时只显示列表。
+func (p *sessionPicker) View(width int) string {
+	listWidth := width
+	previewWidth := 0
+	if width >= 90 {
+		listWidth = width * 11 / 20
+		previewWidth = width - listWidth - 3
+	}
+	p.list.SetSize(listWidth, 16)
+	hint := "↑/↓ select • / filter • enter resume • esc close"
+	left := p.list.View()
+	if previewWidth == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, left, hint)
+	}
+	preview := lipgloss.NewStyle().
+		Width(previewWidth).
+		MaxHeight(18).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		PaddingLeft(1).
+		Render(p.preview(previewWidth - 2))
+	return lipgloss.JoinVertical(lipgloss.Left, lipgloss.JoinHorizontal(lipgloss.Top, left, " ", preview), hint)
+}
+
+func (p *sessionPicker) preview(width int) string {
+	summary, ok := p.Selected()
+	if !ok {
+		return "No sessions."
+	}
+	if cached, ok := p.previews[summary.ID]; ok {
+		return cached
+	}
+	rendered := renderSessionPreview(summary, p.load, width)
+	p.previews[summary.ID] = rendered
+	return rendered
+}
+
+func renderSessionPreview(summary session.Summary, load func(string) (session.Record, error), width int) string {
+	lines := []string{
+		"id: " + summary.ID,
+		"updated: " + summary.Updated.Local().Format("2006-01-02 15:04"),
+	}
+	if summary.Workdir != "" {
+		lines = append(lines, "dir: "+summary.Workdir)
+	}
+	if summary.Model != "" {
+		lines = append(lines, "model: "+summary.Model)
+	}
+	lines = append(lines, "")
+	rec, err := load(summary.ID)
+	if err != nil {
+		return strings.Join(append(lines, "preview unavailable: "+err.Error()), "\n")
+	}
+	var msgs []agent.Message
+	for _, msg := range rec.Messages {
+		if msg.Role == agent.RoleUser || msg.Role == agent.RoleAssistant {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) > sessionPreviewMessages {
+		lines = append(lines, fmt.Sprintf("… %d earlier messages", len(msgs)-sessionPreviewMessages))
+		msgs = msgs[len(msgs)-sessionPreviewMessages:]
+	}
+	for _, msg := range msgs {
+		prefix := "› "
+		if msg.Role == agent.RoleAssistant {
+			prefix = "• "
+		}
+		text := strings.Join(strings.Fields(msg.Content), " ")
+		lines = append(lines, prefix+truncateRunes(text, max(width-2, 10)*2))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func shortSessionID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if limit <= 0 || len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// relativeTime 把更新时间格式化为 "5m ago" / "yesterday 14:03" / "2024-05-01"。
+func relativeTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	now := time.Now()
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour && now.Day() == t.Day():
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 48*time.Hour && now.AddDate(0, 0, -1).Day() == t.Day():
+		return "yesterday " + t.Local().Format("15:04")
+	case now.Year() == t.Year():
+		return t.Local().Format("Jan 02 15:04")
+	}
+	return t.Local().Format("2006-01-02")
+}
+
+// openSessionPicker 打开会话选择器；默认只列出当前工作目录的会话，参数 --all 列出全部。
+func (m *Model) openSessionPicker(args string) tea.Cmd {
+	showAll := false
+	for _, arg := range strings.Fields(args) {
+		if arg == "--all" || arg == "all" {
+			showAll = true
+		}
+	}
+	summaries, err := session.Summaries(showAll, m.workdir)
+	if err != nil {
+		return func() tea.Msg { return systemMsg{Text: fmt.Sprintf("sessions error: %v", err)} }
+	}
+	if len(summaries) == 0 {
+		if showAll {
+			m.appendAssistantMessage("no saved sessions.")
+		} else {
+			m.appendAssistantMessage("no saved sessions for this directory; use /sessions --all to list every session.")
+		}
+		return nil
+	}
+	m.sessions.SetSummaries(summaries, showAll)
+	m.pickingSession = true
+	return nil
+}
+
+// resumeSession 载入会话：重建引擎上下文、恢复记住的批准并重新渲染转录。
+func (m *Model) resumeSession(id string) tea.Cmd {
+	rec, err := session.Load(id)
+	if err != nil {
+		return func() tea.Msg { return systemMsg{Text: fmt.Sprintf("session load error: %v", err)} }
+	}
+	m.resumeSessionID = rec.ID
+	m.eqCtx.SessionID = rec.ID
+	if m.engine != nil {
+		m.engine.RestoreSession(rec.ID, rec.Items, rec.Turns)
+	}
+	if m.approvalMemory != nil {
+		m.approvalMemory.Seed(rec.ID, rec.Approvals)
+	}
+	m.loadTranscriptMessages(rec.Messages)
+	return nil
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"echo-cli/internal/agent"
+	"echo-cli/internal/session"
+	"echo-cli/internal/tools"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSessionItem_ShowsMetadataAndFiltersOnContent(t *testing.T) {
+	item := sessionItem{summary: session.Summary{
+		ID:               "0f4c2a1e-1111-2222-3333-444455556666",
+		Workdir:          "/home/me/repo",
+		Updated:          time.Now().Add(-5 * time.Minute),
+		FirstUserMessage: "fix the flaky login test",
+		MessageCount:     6,
+		Model:            "claude-sonnet",
+	}}
+	if item.Title() != "fix the flaky login test" {
+		t.Fatalf("unexpected title %q", item.Title())
+	}
+	desc := item.Description()
+	for _, want := range []string{"5m ago", "6 msgs", "claude-sonnet", "/home/me/repo"} {
+		if !strings.Contains(desc, want) {
+			t.Fatalf("description %q missing %q", desc, want)
+		}
+	}
+	for _, want := range []string{"flaky login", "/home/me/repo", "0f4c2a1e"} {
+		if !strings.Contains(item.FilterValue(), want) {
+			t.Fatalf("filter value %q missing %q", item.FilterValue(), want)
+		}
+	}
+}
+
+func TestSessionPicker_PreviewShowsRecentTranscript(t *testing.T) {
+	loads := 0
+	p := newSessionPicker([]session.Summary{{ID: "s1", Workdir: "/repo", Model: "m1"}}, false)
+	p.load = func(id string) (session.Record, error) {
+		loads++
+		if id != "s1" {
+			return session.Record{}, errors.New("unexpected id")
+		}
+		var msgs []agent.Message
+		for i := 0; i < sessionPreviewMessages+3; i++ {
+			msgs = append(msgs, agent.Message{Role: agent.RoleUser, Content: "question " + string(rune('a'+i))})
+		}
+		msgs = append(msgs, agent.Message{Role: agent.Role("tool"), Content: "tool block"}, agent.Message{Role: agent.RoleAssistant, Content: "final answer"})
+		return session.Record{ID: id, Messages: msgs}, nil
+	}
+
+	view := p.View(120)
+	if !strings.Contains(view, "final answer") || !strings.Contains(view, "earlier messages") || strings.Contains(view, "tool block") {
+		t.Fatalf("unexpected preview:\n%s", view)
+	}
+	_ = p.View(120)
+	if loads != 1 {
+		t.Fatalf("expected preview to be cached, loaded %d times", loads)
+	}
+	if narrow := p.View(60); strings.Contains(narrow, "final answer") {
+		t.Fatalf("narrow view should hide the preview pane")
+	}
+}
+
+func TestModel_SessionsCommandScopesToWorkdirAndResumes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	here := t.TempDir()
+	grant := tools.ApprovalGrant{Scope: tools.ApprovalScopePrefix, Prefix: "make test"}
+	if _, err := session.SaveRecord(session.Record{ID: "mine", Workdir: here, Messages: []agent.Message{{Role: agent.RoleUser, Content: "hello from here"}}, Approvals: []tools.ApprovalGrant{grant}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.SaveRecord(session.Record{ID: "other", Workdir: t.TempDir(), Messages: []agent.Message{{Role: agent.RoleUser, Content: "elsewhere"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	memory := tools.NewApprovalMemory(nil)
+	m := New(Options{Workdir: here, ApprovalMemory: memory})
+	m.openSessionPicker("")
+	if !m.pickingSession || len(m.sessions.list.Items()) != 1 {
+		t.Fatalf("expected picker scoped to workdir, got %d items", len(m.sessions.list.Items()))
+	}
+	m.openSessionPicker("--all")
+	if len(m.sessions.list.Items()) != 2 {
+		t.Fatalf("expected --all to list every session, got %d", len(m.sessions.list.Items()))
+	}
+
+	m.openSessionPicker("")
+	m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.pickingSession || m.resumeSessionID != "mine" {
+		t.Fatalf("expected enter to resume selected session, picking=%v id=%q", m.pickingSession, m.resumeSessionID)
+	}
+	if len(m.messages) == 0 || !strings.Contains(m.messages[len(m.messages)-1].Content, "hello from here") {
+		t.Fatalf("expected transcript to be loaded, got %+v", m.messages)
+	}
+	if grants := memory.Grants("mine"); len(grants) != 1 || grants[0].Prefix != "make test" {
+		t.Fatalf("expected saved approvals to be restored, got %+v", grants)
+	}
+}
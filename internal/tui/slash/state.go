@@ -551,9 +551,9 @@ func builtinItems(opts Options) []Item {
 	commands = append(commands,
 		Item{Kind: ItemBuiltin, Command: CommandReview, Description: "进入代码审查模式"},
 		Item{Kind: ItemBuiltin, Command: CommandNew, Description: "开始新会话"},
-		Item{Kind: ItemBuiltin, Command: CommandResume, Description: "恢复最近会话"},
+		Item{Kind: ItemBuiltin, Command: CommandResume, Description: "选择会话恢复（可附会话 ID）"},
 		Item{Kind: ItemBuiltin, Command: CommandInit, Description: "生成 AGENTS.md 指南"},
-		Item{Kind: ItemBuiltin, Command: CommandCompact, Description: "压缩上下文"},
+		Item{Kind: ItemBuiltin, Command: CommandCompact, Description: "压缩上下文（可附关注点）"},
 		Item{Kind: ItemBuiltin, Command: CommandUndo, Description: "撤销上一步"},
 		Item{Kind: ItemBuiltin, Command: CommandDiff, Description: "查看工作区 diff"},
 		Item{Kind: ItemBuiltin, Command: CommandMention, Description: "搜索文件/路径"},
@@ -575,7 +575,7 @@ func builtinItems(opts Options) []Item {
 		Item{Kind: ItemBuiltin, Command: CommandRun, Description: "执行本地命令"},
 		Item{Kind: ItemBuiltin, Command: CommandApply, Description: "应用补丁文件"},
 		Item{Kind: ItemBuiltin, Command: CommandAttach, Description: "附加文件内容"},
-		Item{Kind: ItemBuiltin, Command: CommandSessions, Description: "列出会话"},
+		Item{Kind: ItemBuiltin, Command: CommandSessions, Description: "会话列表（--all 显示所有目录）"},
 	)
 	return commands
 }
@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// submitUndo 触发 /undo：请求 core 将工作区恢复到最近一次 ghost snapshot，
+// 结果通过 EQ 的 undo.completed 事件渲染。
+func (m *Model) submitUndo() tea.Cmd {
+	if m.pending {
+		m.appendAssistantMessage("cannot undo while a task is running.")
+		return nil
+	}
+	if m.gateway == nil {
+		m.appendAssistantMessage("undo is not available: gateway not configured.")
+		return nil
+	}
+	sessionID := strings.TrimSpace(m.eqCtx.SessionID)
+	if sessionID == "" {
+		sessionID = strings.TrimSpace(m.resumeSessionID)
+	}
+	if sessionID == "" {
+		m.appendAssistantMessage("session id not set; cannot undo.")
+		return nil
+	}
+	gateway := m.gateway
+	return func() tea.Msg {
+		if _, err := gateway.SubmitUndo(context.Background(), sessionID); err != nil {
+			return systemMsg{Text: fmt.Sprintf("submit undo failed: %v", err)}
+		}
+		return nil
+	}
+}